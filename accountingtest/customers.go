@@ -0,0 +1,198 @@
+package accountingtest
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+
+	accounting "github.com/qbitsoftware/accounting-service"
+)
+
+// Customers returns a snapshot of the customer register in creation order.
+func (f *Fake) Customers() []accounting.Customer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]accounting.Customer, len(f.customers))
+	for i, c := range f.customers {
+		out[i] = *c
+	}
+	return out
+}
+
+func (f *Fake) customerByID(id string) *accounting.Customer {
+	for _, c := range f.customers {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// CreateCustomer adds a customer card. A card with the same RegNo (or, when
+// no RegNo is given, the same name) already present fails with Merit's
+// "custexists" error, so IsCustomerExistsError recognises it.
+func (f *Fake) CreateCustomer(_ context.Context, input accounting.CreateCustomerInput) (*accounting.Customer, error) {
+	const op = "CreateCustomer"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(input.Name) == "" {
+		return nil, f.wrap(op, fmt.Errorf("%w: customer name is required", accounting.ErrInvalidInput))
+	}
+	for _, c := range f.customers {
+		sameRegNo := input.RegNo != "" && c.RegNo == input.RegNo
+		sameName := input.RegNo == "" && strings.EqualFold(c.Name, input.Name)
+		sameCode := input.Code != "" && c.ID == input.Code
+		if sameRegNo || sameName || sameCode {
+			return nil, f.wrap(op, fmt.Errorf("custexists: customer %q already exists as %s", input.Name, c.ID))
+		}
+	}
+
+	id := input.Code
+	if id == "" {
+		id = f.newID("cust")
+	}
+	c := &accounting.Customer{
+		ID:          id,
+		Name:        input.Name,
+		RegNo:       input.RegNo,
+		VATRegNo:    input.VATRegNo,
+		Email:       input.Email,
+		Phone:       input.Phone,
+		Address:     input.Address,
+		City:        input.City,
+		County:      input.County,
+		PostalCode:  input.PostalCode,
+		CountryCode: input.CountryCode,
+		Currency:    input.Currency,
+		Contact:     input.Contact,
+		RefNoBase:   input.RefNoBase,
+	}
+	if input.PaymentDays != nil {
+		c.PaymentDays = *input.PaymentDays
+	}
+	f.customers = append(f.customers, c)
+	out := *c
+	return &out, nil
+}
+
+func (f *Fake) UpdateCustomer(_ context.Context, input accounting.UpdateCustomerInput) error {
+	const op = "UpdateCustomer"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	c := f.customerByID(input.ID)
+	if c == nil {
+		return f.wrap(op, accounting.ErrNotFound)
+	}
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&c.Name, input.Name)
+	set(&c.Email, input.Email)
+	set(&c.Phone, input.Phone)
+	set(&c.Address, input.Address)
+	set(&c.City, input.City)
+	set(&c.PostalCode, input.PostalCode)
+	set(&c.CountryCode, input.CountryCode)
+	set(&c.RegNo, input.RegNo)
+	set(&c.VATRegNo, input.VATRegNo)
+	set(&c.RefNoBase, input.RefNoBase)
+	return nil
+}
+
+func (f *Fake) ListCustomers(_ context.Context, _ accounting.ListCustomersInput) ([]accounting.Customer, error) {
+	const op = "ListCustomers"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]accounting.Customer, len(f.customers))
+	for i, c := range f.customers {
+		out[i] = *c
+	}
+	return out, nil
+}
+
+// CustomerPages yields ListCustomers as a single page.
+func (f *Fake) CustomerPages(ctx context.Context, input accounting.ListCustomersInput) iter.Seq2[[]accounting.Customer, error] {
+	return onePage(func() ([]accounting.Customer, error) { return f.ListCustomers(ctx, input) })
+}
+
+func (f *Fake) FindCustomerByEmail(_ context.Context, email string) (*accounting.Customer, error) {
+	const op = "FindCustomerByEmail"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	for _, c := range f.customers {
+		if strings.ToLower(strings.TrimSpace(c.Email)) == email {
+			out := *c
+			return &out, nil
+		}
+	}
+	return nil, f.wrap(op, accounting.ErrNotFound)
+}
+
+func (f *Fake) GetCustomer(_ context.Context, id string) (*accounting.Customer, error) {
+	const op = "GetCustomer"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	c := f.customerByID(id)
+	if c == nil {
+		return nil, f.wrap(op, accounting.ErrNotFound)
+	}
+	out := *c
+	return &out, nil
+}
+
+// CustomerDebts reports every open (not fully settled) invoice, optionally
+// restricted to one customer name and to invoices at least overdueDays past
+// their due date.
+func (f *Fake) CustomerDebts(_ context.Context, customerName string, overdueDays *int) ([]accounting.CustomerDebt, error) {
+	const op = "CustomerDebts"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	now := f.now()
+	var out []accounting.CustomerDebt
+	for _, r := range f.invoices {
+		if r.credit || r.inv.Paid {
+			continue
+		}
+		if customerName != "" && !strings.EqualFold(r.inv.CustomerName, customerName) {
+			continue
+		}
+		if overdueDays != nil && (r.inv.DueDate.IsZero() || now.Sub(r.inv.DueDate) < dayDuration(*overdueDays)) {
+			continue
+		}
+		out = append(out, accounting.CustomerDebt{
+			CustomerName: r.inv.CustomerName,
+			CustomerID:   r.inv.CustomerID,
+			DocType:      "invoice",
+			DocDate:      r.inv.DocDate,
+			DocNo:        r.inv.Number,
+			DueDate:      r.inv.DueDate,
+			TotalAmount:  r.inv.TotalAmount,
+			PaidAmount:   r.inv.PaidAmount,
+			UnpaidAmount: r.inv.TotalAmount.Sub(r.inv.PaidAmount),
+			Currency:     r.inv.Currency,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,314 @@
+// Package accountingtest provides a stateful in-memory implementation of
+// accounting.Provider and accounting.PrepaymentProvider for consumer tests.
+//
+// The fake models the behaviour callers depend on rather than any single
+// backend's wire format: invoices get sequential numbers, paid/partial status
+// is derived from the payments booked against them, credit notes settle their
+// original, prepayments carry a remainder, and duplicate customers/invoices
+// fail the same way Merit does (so IsCustomerExistsError and
+// IsDuplicateInvoiceError behave as in production).
+//
+// Usage:
+//
+//	fake := accountingtest.New()
+//	client, err := fake.NewClient()
+//	// exercise code that takes *accounting.Client, then inspect fake.Invoices()
+package accountingtest
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	accounting "github.com/qbitsoftware/accounting-service"
+	"github.com/shopspring/decimal"
+)
+
+// ProviderName is the provider label the fake puts on the errors it returns.
+const ProviderName = "accountingtest"
+
+// Operation names accepted by WithUnsupported and FailNext. They match the
+// Provider / PrepaymentProvider method names.
+const (
+	OpGetInvoicePDF          = "GetInvoicePDF"
+	OpDeleteInvoice          = "DeleteInvoice"
+	OpFindInvoiceByRef       = "FindInvoiceByRef"
+	OpGetCustomer            = "GetCustomer"
+	OpDeletePayment          = "DeletePayment"
+	OpCreatePurchase         = "CreatePurchase"
+	OpDeletePurchase         = "DeletePurchase"
+	OpListTaxes              = "ListTaxes"
+	OpListAccounts           = "ListAccounts"
+	OpListDimensions         = "ListDimensions"
+	OpCustomerDebts          = "CustomerDebts"
+	OpCreateCreditNote       = "CreateCreditNote"
+	OpCreatePrepayment       = "CreatePrepayment"
+	OpApplyPrepayment        = "ApplyPrepayment"
+	OpUnallocateToPrepayment = "UnallocateToPrepayment"
+	OpListPrepayments        = "ListPrepayments"
+)
+
+// Option configures a Fake.
+type Option func(*Fake)
+
+// WithUnsupported makes the named operations fail with a "not supported"
+// error, mirroring the capability gaps of real providers (e.g. Excellent
+// Books has no PDF or delete endpoints). The matching Capabilities flags are
+// cleared so Client.Capabilities reflects the gap.
+func WithUnsupported(ops ...string) Option {
+	return func(f *Fake) {
+		for _, op := range ops {
+			f.unsupported[op] = true
+		}
+	}
+}
+
+// WithoutPrepayments makes the provider handed to NewClient not implement
+// accounting.PrepaymentProvider, so PrepaymentService.Supported reports false.
+func WithoutPrepayments() Option {
+	return func(f *Fake) { f.noPrepayments = true }
+}
+
+// WithClock overrides the time source used for change timestamps (the
+// ListInvoicesSince / ListPaymentsSince cursor). Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(f *Fake) { f.now = now }
+}
+
+// WithTaxes seeds the tax register. TaxIDs on invoice lines are resolved
+// against it to compute VAT; unknown TaxIDs are booked at 0%.
+func WithTaxes(taxes ...accounting.Tax) Option {
+	return func(f *Fake) { f.taxes = append([]accounting.Tax(nil), taxes...) }
+}
+
+// WithBanks seeds the bank-account register returned by ListBanks.
+func WithBanks(banks ...accounting.Bank) Option {
+	return func(f *Fake) { f.banks = append([]accounting.Bank(nil), banks...) }
+}
+
+// WithAccounts seeds the chart of accounts returned by ListAccounts.
+func WithAccounts(accounts ...accounting.Account) Option {
+	return func(f *Fake) { f.accounts = append([]accounting.Account(nil), accounts...) }
+}
+
+// WithPaymentTerms seeds the payment-term register returned by ListPaymentTerms.
+func WithPaymentTerms(terms ...accounting.PaymentTerm) Option {
+	return func(f *Fake) { f.paymentTerms = append([]accounting.PaymentTerm(nil), terms...) }
+}
+
+// WithInvoiceNumberStart sets the first auto-assigned invoice number. Invoices
+// created without an explicit InvoiceNo are numbered sequentially from it.
+func WithInvoiceNumberStart(n int) Option {
+	return func(f *Fake) { f.nextInvoiceNo = n }
+}
+
+// Fake is an in-memory accounting backend. It is safe for concurrent use.
+type Fake struct {
+	mu sync.Mutex
+
+	name          string
+	registerOnce  sync.Once
+	now           func() time.Time
+	unsupported   map[string]bool
+	noPrepayments bool
+	failNext      map[string][]error
+
+	nextID        int
+	nextInvoiceNo int
+
+	invoices    []*invoiceRecord
+	customers   []*accounting.Customer
+	payments    []*paymentRecord
+	items       []*accounting.Item
+	purchases   []*accounting.PurchaseInvoice
+	prepayments []*accounting.Prepayment
+
+	taxes        []accounting.Tax
+	accounts     []accounting.Account
+	banks        []accounting.Bank
+	paymentTerms []accounting.PaymentTerm
+	dimensions   accounting.DimensionList
+}
+
+var instances atomic.Int64
+
+// New returns an empty Fake. The default tax register holds "VAT22" (22%)
+// and "VAT0" (0%).
+func New(opts ...Option) *Fake {
+	f := &Fake{
+		name:          fmt.Sprintf("%s-%d", ProviderName, instances.Add(1)),
+		now:           time.Now,
+		unsupported:   map[string]bool{},
+		failNext:      map[string][]error{},
+		nextInvoiceNo: 1,
+		taxes: []accounting.Tax{
+			{ID: "VAT22", Code: "22", Name: "VAT 22%", Pct: decimal.NewFromInt(22)},
+			{ID: "VAT0", Code: "0", Name: "VAT 0%", Pct: decimal.Zero},
+		},
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Name is the provider name this Fake is registered under. Each Fake gets a
+// unique name so parallel tests never share state through the registry.
+func (f *Fake) Name() string { return f.name }
+
+// Config returns an accounting.Config that routes NewClient to this Fake.
+func (f *Fake) Config() accounting.Config {
+	f.register()
+	return accounting.Config{Provider: f.name}
+}
+
+// NewClient registers the Fake (once) and builds an accounting.Client on it.
+func (f *Fake) NewClient() (*accounting.Client, error) {
+	return accounting.NewClient(f.Config())
+}
+
+func (f *Fake) register() {
+	f.registerOnce.Do(func() {
+		accounting.RegisterProvider(f.name, func(accounting.Config) (accounting.Provider, error) {
+			if f.noPrepayments {
+				return providerOnly{f}, nil
+			}
+			return f, nil
+		}, f.Capabilities())
+	})
+}
+
+// providerOnly hides the PrepaymentProvider methods of the wrapped Fake.
+type providerOnly struct{ accounting.Provider }
+
+// Capabilities reports the feature set implied by the Fake's options: every
+// operation and flag is set except those cleared by WithUnsupported, and the
+// prepayment operations are dropped by WithoutPrepayments.
+func (f *Fake) Capabilities() accounting.Capabilities {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ops := accounting.NewOpSet(accounting.Ops()...).Without(accounting.OpInvoiceChangesAfter,
+		accounting.OpPaymentChangesAfter, accounting.OpCustomerChangesAfter, accounting.OpItemChangesAfter)
+	if f.noPrepayments {
+		ops = ops.Without(OpCreatePrepayment, OpApplyPrepayment, OpUnallocateToPrepayment, OpListPrepayments)
+	}
+	for op := range f.unsupported {
+		ops = ops.Without(accounting.Op(op))
+	}
+	return accounting.Capabilities{
+		Operations:               ops,
+		SupportsInvoicePDF:       !f.unsupported[OpGetInvoicePDF],
+		SupportsInvoiceDelete:    !f.unsupported[OpDeleteInvoice],
+		SupportsPaymentDelete:    !f.unsupported[OpDeletePayment],
+		SupportsPurchaseCreate:   !f.unsupported[OpCreatePurchase],
+		SupportsPurchaseDelete:   !f.unsupported[OpDeletePurchase],
+		SupportsTaxList:          !f.unsupported[OpListTaxes],
+		SupportsAccountList:      !f.unsupported[OpListAccounts],
+		SupportsDimensions:       !f.unsupported[OpListDimensions],
+		SupportsCustomerDebts:    !f.unsupported[OpCustomerDebts],
+		SupportsVendorPayments:   true,
+		SupportsFindInvoiceByRef: !f.unsupported[OpFindInvoiceByRef],
+		SupportsIncrementalSync:  true,
+	}
+}
+
+// FailNext queues err to be returned by the next call to op, before any state
+// is touched. Queued errors are consumed in order, one per call.
+func (f *Fake) FailNext(op string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext[op] = append(f.failNext[op], err)
+}
+
+// begin locks the Fake and returns the error the operation must fail with,
+// if any. Callers must defer f.mu.Unlock() regardless of the result.
+func (f *Fake) begin(op string) error {
+	f.mu.Lock()
+	if queue := f.failNext[op]; len(queue) > 0 {
+		f.failNext[op] = queue[1:]
+		return f.wrap(op, queue[0])
+	}
+	if f.unsupported[op] {
+		return f.wrap(op, fmt.Errorf("%w by %s", accounting.ErrNotSupported, ProviderName))
+	}
+	return nil
+}
+
+func (f *Fake) wrap(op string, err error) error {
+	return &accounting.ProviderError{Provider: ProviderName, Op: op, Err: err}
+}
+
+func (f *Fake) newID(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s-%d", prefix, f.nextID)
+}
+
+func (f *Fake) TestConnection(context.Context) error {
+	err := f.begin("TestConnection")
+	defer f.mu.Unlock()
+	return err
+}
+
+func inPeriod(t, start, end time.Time) bool {
+	if !start.IsZero() && t.Before(start) {
+		return false
+	}
+	if !end.IsZero() && t.After(end) {
+		return false
+	}
+	return true
+}
+
+// onePage yields the result of list as a single page. The Fake does not
+// page, so its *Pages methods fail and succeed as the List methods do.
+func onePage[T any](list func() ([]T, error)) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		page, err := list()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if len(page) > 0 {
+			yield(page, nil)
+		}
+	}
+}
+
+// noFeed fails a change-feed read: the Fake has none, so the SyncEngine
+// syncs it by time, as it does Merit.
+func (f *Fake) noFeed(op string) error {
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return f.wrap(op, fmt.Errorf("%w by %s", accounting.ErrNotSupported, ProviderName))
+}
+
+func (f *Fake) InvoiceChangesAfter(context.Context, string, time.Time) (accounting.InvoiceChanges, string, error) {
+	return accounting.InvoiceChanges{}, "", f.noFeed("InvoiceChangesAfter")
+}
+
+func (f *Fake) PaymentChangesAfter(context.Context, string, time.Time) (accounting.PaymentChanges, string, error) {
+	return accounting.PaymentChanges{}, "", f.noFeed("PaymentChangesAfter")
+}
+
+func (f *Fake) CustomerChangesAfter(context.Context, string, time.Time) (accounting.CustomerChanges, string, error) {
+	return accounting.CustomerChanges{}, "", f.noFeed("CustomerChangesAfter")
+}
+
+func (f *Fake) ItemChangesAfter(context.Context, string, time.Time) (accounting.ItemChanges, string, error) {
+	return accounting.ItemChanges{}, "", f.noFeed("ItemChangesAfter")
+}
+
+func dayDuration(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
+
+var (
+	_ accounting.Provider           = (*Fake)(nil)
+	_ accounting.PrepaymentProvider = (*Fake)(nil)
+)
@@ -0,0 +1,268 @@
+package accountingtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	accounting "github.com/qbitsoftware/accounting-service"
+	"github.com/qbitsoftware/accounting-service/accountingtest"
+	"github.com/shopspring/decimal"
+)
+
+func d(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func newClient(t *testing.T, opts ...accountingtest.Option) (*accountingtest.Fake, *accounting.Client) {
+	t.Helper()
+	fake := accountingtest.New(opts...)
+	client, err := fake.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return fake, client
+}
+
+func invoiceInput(no string, price string) accounting.CreateInvoiceInput {
+	return accounting.CreateInvoiceInput{
+		CustomerName: "Acme OÜ",
+		InvoiceNo:    no,
+		DocDate:      time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		DueDate:      time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC),
+		Currency:     "EUR",
+		Lines: []accounting.CreateInvoiceLineInput{
+			{Code: "FEE", Description: "Membership", Quantity: d("1"), UnitPrice: d(price), TaxID: "VAT22"},
+		},
+	}
+}
+
+func TestFake_InvoiceNumberingAndStatus(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t, accountingtest.WithInvoiceNumberStart(1000))
+
+	inv, err := client.Invoices.Create(ctx, invoiceInput("", "100"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if inv.Number != "1000" {
+		t.Errorf("Number = %s, want 1000", inv.Number)
+	}
+	if !inv.TotalAmount.Equal(d("122")) || !inv.TaxAmount.Equal(d("22")) {
+		t.Errorf("totals = %s/%s, want 122/22", inv.TotalAmount, inv.TaxAmount)
+	}
+	if inv.Status != accounting.InvoiceStatusUnpaid {
+		t.Errorf("Status = %s, want unpaid", inv.Status)
+	}
+
+	pay := func(amount string) {
+		t.Helper()
+		if err := client.Payments.Create(ctx, accounting.CreatePaymentInput{
+			InvoiceNo: inv.Number, Amount: d(amount), Currency: "EUR", PaymentDate: inv.DocDate,
+		}); err != nil {
+			t.Fatalf("Payments.Create: %v", err)
+		}
+	}
+	pay("22")
+	got, _ := client.Invoices.Get(ctx, inv.ID)
+	if got.Status != accounting.InvoiceStatusPartial || !got.PaidAmount.Equal(d("22")) {
+		t.Errorf("after partial payment: %s paid %s", got.Status, got.PaidAmount)
+	}
+	pay("100")
+	got, _ = client.Invoices.Get(ctx, inv.ID)
+	if got.Status != accounting.InvoiceStatusPaid || !got.Paid || len(got.Payments) != 2 {
+		t.Errorf("after full payment: %s paid=%v payments=%d", got.Status, got.Paid, len(got.Payments))
+	}
+
+	payments, _ := client.Payments.List(ctx, accounting.ListPaymentsInput{})
+	if err := client.Payments.Delete(ctx, payments[1].ID); err != nil {
+		t.Fatalf("Payments.Delete: %v", err)
+	}
+	got, _ = client.Invoices.Get(ctx, inv.ID)
+	if got.Status != accounting.InvoiceStatusPartial {
+		t.Errorf("after deleting a payment: %s, want partial", got.Status)
+	}
+}
+
+func TestFake_DuplicateErrors(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+
+	if _, err := client.Invoices.Create(ctx, invoiceInput("INV-1", "10")); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	_, err := client.Invoices.Create(ctx, invoiceInput("INV-1", "10"))
+	if !accounting.IsDuplicateInvoiceError(err) {
+		t.Errorf("second Create err = %v, want duplicate invoice error", err)
+	}
+
+	in := accounting.CreateCustomerInput{Name: "Acme AS", RegNo: "12345678"}
+	if _, err := client.Customers.Create(ctx, in); err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	_, err = client.Customers.Create(ctx, in)
+	if !accounting.IsCustomerExistsError(err) {
+		t.Errorf("second Customers.Create err = %v, want customer-exists error", err)
+	}
+}
+
+func TestFake_IdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newClient(t)
+
+	in := invoiceInput("", "10")
+	in.IdempotencyKey = "ORDER-1"
+	first, err := client.Invoices.Create(ctx, in)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	again, err := client.Invoices.Create(ctx, in)
+	if err != nil {
+		t.Fatalf("retried Create: %v", err)
+	}
+	if again.ID != first.ID || first.Number == in.IdempotencyKey {
+		t.Errorf("retried Create = %s/%s, want %s numbered by the fake", again.ID, again.Number, first.ID)
+	}
+
+	// The key belongs to another invoice now.
+	other := in
+	other.CustomerName = "Beta AS"
+	if _, err := client.Invoices.Create(ctx, other); !errors.Is(err, accounting.ErrDuplicate) {
+		t.Errorf("Create of another invoice with the key: err = %v, want ErrDuplicate", err)
+	}
+	if n := len(fake.Invoices()); n != 1 {
+		t.Errorf("%d invoices, want 1", n)
+	}
+
+	pay := accounting.CreatePaymentInput{InvoiceNo: first.Number, PaymentDate: in.DocDate, Amount: d("5"), IdempotencyKey: "PAY-1"}
+	for range 2 {
+		if err := client.Payments.Create(ctx, pay); err != nil {
+			t.Fatalf("Payments.Create: %v", err)
+		}
+	}
+	if n := len(fake.Payments()); n != 1 {
+		t.Errorf("%d payments, want 1", n)
+	}
+}
+
+func TestFake_CreditNoteSettlesOriginal(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+
+	inv, _ := client.Invoices.Create(ctx, invoiceInput("INV-7", "100"))
+	cn, err := client.Invoices.CreateCreditNote(ctx, accounting.CreateCreditNoteInput{
+		CustomerID:        inv.CustomerID,
+		OriginalInvoiceNo: inv.Number,
+		Lines: []accounting.CreateInvoiceLineInput{
+			{Description: "Refund", Quantity: d("1"), UnitPrice: d("-100"), TaxID: "VAT22"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateCreditNote: %v", err)
+	}
+	if !cn.TotalAmount.Equal(d("-122")) {
+		t.Errorf("credit total = %s, want -122", cn.TotalAmount)
+	}
+	orig, _ := client.Invoices.Get(ctx, inv.ID)
+	if orig.Status != accounting.InvoiceStatusPaid {
+		t.Errorf("original status = %s, want paid", orig.Status)
+	}
+
+	_, err = client.Invoices.CreateCreditNote(ctx, accounting.CreateCreditNoteInput{
+		CustomerID:        inv.CustomerID,
+		OriginalInvoiceNo: inv.Number,
+		Lines:             []accounting.CreateInvoiceLineInput{{Quantity: d("1"), UnitPrice: d("-1")}},
+	})
+	if !errors.Is(err, accounting.ErrInvalidInput) {
+		t.Errorf("over-credit err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestFake_PrepaymentRemainder(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+
+	cust, _ := client.Customers.Create(ctx, accounting.CreateCustomerInput{Name: "Mari", Code: "C1"})
+	inv, _ := client.Invoices.Create(ctx, accounting.CreateInvoiceInput{
+		CustomerID: cust.ID,
+		InvoiceNo:  "INV-9",
+		Lines:      []accounting.CreateInvoiceLineInput{{Quantity: d("1"), UnitPrice: d("30")}},
+	})
+
+	if _, err := client.Prepayments.Create(ctx, accounting.CreatePrepaymentInput{
+		CustomerCode: cust.ID, PrepaymentNo: "PP-1", Amount: d("50"),
+	}); err != nil {
+		t.Fatalf("Prepayments.Create: %v", err)
+	}
+	if err := client.Prepayments.Apply(ctx, accounting.ApplyPrepaymentInput{
+		CustomerCode: cust.ID, InvoiceNo: inv.Number, PrepaymentNo: "PP-1", Amount: d("30"),
+	}); err != nil {
+		t.Fatalf("Prepayments.Apply: %v", err)
+	}
+	pps, _ := client.Prepayments.List(ctx, accounting.ListPrepaymentsInput{CustomerCode: cust.ID})
+	if len(pps) != 1 || !pps[0].Remaining.Equal(d("20")) {
+		t.Fatalf("prepayments = %+v, want one with 20 remaining", pps)
+	}
+	got, _ := client.Invoices.Get(ctx, inv.ID)
+	if got.Status != accounting.InvoiceStatusPaid {
+		t.Errorf("invoice status = %s, want paid", got.Status)
+	}
+
+	pp, err := client.Prepayments.Unallocate(ctx, accounting.UnallocateToPrepaymentInput{
+		CustomerCode: cust.ID, InvoiceNo: inv.Number, PrepaymentNo: "PP-2", Amount: d("10"),
+	})
+	if err != nil {
+		t.Fatalf("Prepayments.Unallocate: %v", err)
+	}
+	if !pp.Remaining.Equal(d("10")) {
+		t.Errorf("unallocated remaining = %s, want 10", pp.Remaining)
+	}
+	got, _ = client.Invoices.Get(ctx, inv.ID)
+	if got.Status != accounting.InvoiceStatusPartial {
+		t.Errorf("invoice status after unallocate = %s, want partial", got.Status)
+	}
+}
+
+func TestFake_CapabilityGaps(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t,
+		accountingtest.WithUnsupported(accountingtest.OpGetInvoicePDF, accountingtest.OpDeleteInvoice),
+		accountingtest.WithoutPrepayments(),
+	)
+
+	caps := client.Capabilities()
+	if caps.SupportsInvoicePDF || caps.SupportsInvoiceDelete || !caps.SupportsTaxList {
+		t.Errorf("capabilities = %+v", caps)
+	}
+	if caps.Supports(accounting.OpGetInvoicePDF) || caps.Supports(accounting.OpListPrepayments) || !caps.Supports(accounting.OpListTaxes) {
+		t.Errorf("operations = %v", caps.Operations.Ops())
+	}
+	if _, err := client.Invoices.GetPDF(ctx, "x", false); err == nil {
+		t.Error("GetPDF succeeded on an unsupported fake")
+	}
+	if client.Prepayments.Supported() {
+		t.Error("Prepayments.Supported() = true with WithoutPrepayments")
+	}
+}
+
+func TestFake_FailNextAndSince(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	fake, client := newClient(t, accountingtest.WithClock(func() time.Time { return now }))
+
+	fake.FailNext("CreateInvoice", accounting.ErrRateLimit)
+	if _, err := client.Invoices.Create(ctx, invoiceInput("A", "1")); !accounting.IsRateLimit(err) {
+		t.Errorf("err = %v, want rate limit", err)
+	}
+	if _, err := client.Invoices.Create(ctx, invoiceInput("A", "1")); err != nil {
+		t.Fatalf("retry after injected failure: %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := client.Invoices.Create(ctx, invoiceInput("B", "1")); err != nil {
+		t.Fatal(err)
+	}
+	changed, _ := client.Sync.PullInvoiceStatuses(ctx, now.Add(-time.Minute), time.Time{})
+	if len(changed) != 1 || changed[0].Number != "B" {
+		t.Errorf("PullInvoiceStatuses = %+v, want only B", changed)
+	}
+}
@@ -0,0 +1,431 @@
+package accountingtest
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strconv"
+	"time"
+
+	accounting "github.com/qbitsoftware/accounting-service"
+	"github.com/shopspring/decimal"
+)
+
+// invoiceRecord is the stored form of a sales invoice or credit note.
+type invoiceRecord struct {
+	inv      accounting.Invoice
+	changed  time.Time
+	credited decimal.Decimal // settled by credit notes referencing this invoice
+	credit   bool            // this record is itself a credit note
+	original string          // credit notes: number of the credited invoice
+	key      string          // the IdempotencyKey it was created with
+}
+
+// Invoices returns a snapshot of every stored invoice and credit note, in
+// creation order.
+func (f *Fake) Invoices() []accounting.Invoice {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]accounting.Invoice, len(f.invoices))
+	for i, r := range f.invoices {
+		out[i] = cloneInvoice(r.inv)
+	}
+	return out
+}
+
+func cloneInvoice(inv accounting.Invoice) accounting.Invoice {
+	inv.Lines = append([]accounting.InvoiceLine(nil), inv.Lines...)
+	inv.Payments = append([]accounting.InvoicePayment(nil), inv.Payments...)
+	return inv
+}
+
+func (f *Fake) invoiceByID(id string) *invoiceRecord {
+	for _, r := range f.invoices {
+		if r.inv.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func (f *Fake) invoiceByNumber(no string) *invoiceRecord {
+	for _, r := range f.invoices {
+		if r.inv.Number == no {
+			return r
+		}
+	}
+	return nil
+}
+
+func (f *Fake) taxPct(taxID string) (accounting.Tax, decimal.Decimal) {
+	for _, t := range f.taxes {
+		if t.ID == taxID {
+			return t, t.Pct
+		}
+	}
+	return accounting.Tax{}, decimal.Zero
+}
+
+// buildLines prices the input lines against the tax register and returns
+// them with the net and VAT totals.
+func (f *Fake) buildLines(in []accounting.CreateInvoiceLineInput) ([]accounting.InvoiceLine, decimal.Decimal, decimal.Decimal) {
+	hundred := decimal.NewFromInt(100)
+	lines := make([]accounting.InvoiceLine, len(in))
+	net, vat := decimal.Zero, decimal.Zero
+	for i, l := range in {
+		tax, pct := f.taxPct(l.TaxID)
+		excl := l.Quantity.Mul(l.UnitPrice).Round(2)
+		lineVat := excl.Mul(pct).Div(hundred).Round(2)
+		lines[i] = accounting.InvoiceLine{
+			ID:            f.newID("row"),
+			Description:   l.Description,
+			Quantity:      l.Quantity,
+			UnitPrice:     l.UnitPrice,
+			TaxID:         l.TaxID,
+			TaxName:       tax.Name,
+			TaxPct:        pct,
+			AmountExclVat: excl,
+			AmountInclVat: excl.Add(lineVat),
+			VatAmount:     lineVat,
+			AccountCode:   l.AccountCode,
+		}
+		net = net.Add(excl)
+		vat = vat.Add(lineVat)
+	}
+	return lines, net, vat
+}
+
+// resolveInvoiceCustomer returns the customer an invoice is booked to. An
+// explicit CustomerID must exist; otherwise the customer is matched by name
+// and created inline when missing, the way Merit's sendinvoice does.
+func (f *Fake) resolveInvoiceCustomer(id, name, regNo, email, address, country string) (*accounting.Customer, error) {
+	if id != "" {
+		if c := f.customerByID(id); c != nil {
+			return c, nil
+		}
+		return nil, fmt.Errorf("customer %s: %w", id, accounting.ErrNotFound)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: customer id or name is required", accounting.ErrInvalidInput)
+	}
+	for _, c := range f.customers {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	c := &accounting.Customer{
+		ID:          f.newID("cust"),
+		Name:        name,
+		RegNo:       regNo,
+		Email:       email,
+		Address:     address,
+		CountryCode: country,
+	}
+	f.customers = append(f.customers, c)
+	return c, nil
+}
+
+// assignNumber returns the caller's number, or the next free one from the
+// series. A caller-supplied number that is already taken is a duplicate.
+func (f *Fake) assignNumber(no string) (string, error) {
+	if no != "" {
+		if f.invoiceByNumber(no) != nil {
+			return "", fmt.Errorf("Korduv arve: duplicate invoice number %s", no)
+		}
+		return no, nil
+	}
+	for {
+		candidate := strconv.Itoa(f.nextInvoiceNo)
+		f.nextInvoiceNo++
+		if f.invoiceByNumber(candidate) == nil {
+			return candidate, nil
+		}
+	}
+}
+
+func (f *Fake) CreateInvoice(_ context.Context, input accounting.CreateInvoiceInput) (*accounting.Invoice, error) {
+	const op = "CreateInvoice"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input.Lines) == 0 {
+		return nil, f.wrap(op, fmt.Errorf("%w: invoice has no lines", accounting.ErrInvalidInput))
+	}
+	cust, err := f.resolveInvoiceCustomer(input.CustomerID, input.CustomerName, input.CustomerRegNo,
+		input.CustomerEmail, input.CustomerAddress, input.CustomerCountryCode)
+	if err != nil {
+		return nil, f.wrap(op, err)
+	}
+	number, err := f.assignNumber(input.InvoiceNo)
+	if err != nil {
+		return nil, f.wrap(op, err)
+	}
+
+	lines, net, vat := f.buildLines(input.Lines)
+	r := &invoiceRecord{
+		inv: accounting.Invoice{
+			ID:           f.newID("inv"),
+			Number:       number,
+			CustomerName: cust.Name,
+			CustomerID:   cust.ID,
+			DocDate:      input.DocDate,
+			DueDate:      input.DueDate,
+			TotalAmount:  net.Add(vat),
+			TaxAmount:    vat,
+			Currency:     input.Currency,
+			ReferenceNo:  input.RefNo,
+			Lines:        lines,
+		},
+		changed: f.now(),
+		key:     input.IdempotencyKey,
+	}
+	recomputeStatus(r)
+	f.invoices = append(f.invoices, r)
+	inv := cloneInvoice(r.inv)
+	return &inv, nil
+}
+
+// LocateInvoice finds an earlier CreateInvoice by its IdempotencyKey, which
+// the Fake keeps in a field of its own, or else by its InvoiceNo.
+func (f *Fake) LocateInvoice(_ context.Context, input accounting.CreateInvoiceInput) (*accounting.Invoice, error) {
+	err := f.begin("LocateInvoice")
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range f.invoices {
+		if input.IdempotencyKey != "" && r.key == input.IdempotencyKey ||
+			input.IdempotencyKey == "" && input.InvoiceNo != "" && r.inv.Number == input.InvoiceNo {
+			inv := cloneInvoice(r.inv)
+			return &inv, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *Fake) GetInvoice(_ context.Context, id string) (*accounting.Invoice, error) {
+	const op = "GetInvoice"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	r := f.invoiceByID(id)
+	if r == nil {
+		return nil, f.wrap(op, accounting.ErrNotFound)
+	}
+	inv := cloneInvoice(r.inv)
+	return &inv, nil
+}
+
+// GetInvoicePDF returns a minimal placeholder document named after the
+// invoice number.
+func (f *Fake) GetInvoicePDF(_ context.Context, id string, deliveryNote bool) (*accounting.InvoicePDF, error) {
+	const op = "GetInvoicePDF"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	r := f.invoiceByID(id)
+	if r == nil {
+		return nil, f.wrap(op, accounting.ErrNotFound)
+	}
+	kind := "invoice"
+	if deliveryNote {
+		kind = "delivery-note"
+	}
+	return &accounting.InvoicePDF{
+		FileName:    fmt.Sprintf("%s-%s.pdf", kind, r.inv.Number),
+		FileContent: []byte("%PDF-1.4\n% accountingtest " + r.inv.Number + "\n%%EOF\n"),
+	}, nil
+}
+
+// ListInvoices returns invoices whose DocDate falls in the period (bounds
+// inclusive, zero = open), optionally restricted to one customer.
+func (f *Fake) ListInvoices(_ context.Context, input accounting.ListInvoicesInput) ([]accounting.Invoice, error) {
+	const op = "ListInvoices"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var out []accounting.Invoice
+	for _, r := range f.invoices {
+		if !inPeriod(r.inv.DocDate, input.PeriodStart, input.PeriodEnd) {
+			continue
+		}
+		if input.CustomerCode != "" && r.inv.CustomerID != input.CustomerCode {
+			continue
+		}
+		out = append(out, cloneInvoice(r.inv))
+	}
+	return out, nil
+}
+
+// InvoicePages yields ListInvoices as a single page.
+func (f *Fake) InvoicePages(ctx context.Context, input accounting.ListInvoicesInput) iter.Seq2[[]accounting.Invoice, error] {
+	return onePage(func() ([]accounting.Invoice, error) { return f.ListInvoices(ctx, input) })
+}
+
+func (f *Fake) FindInvoiceByRef(_ context.Context, refStr string) (*accounting.Invoice, error) {
+	const op = "FindInvoiceByRef"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range f.invoices {
+		if r.inv.ReferenceNo == refStr {
+			inv := cloneInvoice(r.inv)
+			return &inv, nil
+		}
+	}
+	return nil, f.wrap(op, accounting.ErrNotFound)
+}
+
+// DeleteInvoice removes an invoice. Like the real providers, an invoice with
+// payments booked against it cannot be deleted.
+func (f *Fake) DeleteInvoice(_ context.Context, id string) error {
+	const op = "DeleteInvoice"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for i, r := range f.invoices {
+		if r.inv.ID != id {
+			continue
+		}
+		if len(r.inv.Payments) > 0 {
+			return f.wrap(op, fmt.Errorf("invoice %s has payments and cannot be deleted", r.inv.Number))
+		}
+		f.invoices = append(f.invoices[:i], f.invoices[i+1:]...)
+		return nil
+	}
+	return f.wrap(op, accounting.ErrNotFound)
+}
+
+// CreateCreditNote books a negative invoice. When OriginalInvoiceNo names an
+// existing invoice, the credited amount settles that invoice's open balance.
+func (f *Fake) CreateCreditNote(_ context.Context, input accounting.CreateCreditNoteInput) (*accounting.Invoice, error) {
+	const op = "CreateCreditNote"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input.Lines) == 0 {
+		return nil, f.wrap(op, fmt.Errorf("%w: credit note has no lines", accounting.ErrInvalidInput))
+	}
+	var original *invoiceRecord
+	if input.OriginalInvoiceNo != "" {
+		if original = f.invoiceByNumber(input.OriginalInvoiceNo); original == nil {
+			return nil, f.wrap(op, fmt.Errorf("original invoice %s: %w", input.OriginalInvoiceNo, accounting.ErrNotFound))
+		}
+	}
+	cust, err := f.resolveInvoiceCustomer(input.CustomerID, input.CustomerName, input.CustomerRegNo,
+		input.CustomerEmail, input.CustomerAddress, input.CustomerCountryCode)
+	if err != nil {
+		return nil, f.wrap(op, err)
+	}
+
+	lines, net, vat := f.buildLines(input.Lines)
+	total := net.Add(vat)
+	if total.IsPositive() {
+		return nil, f.wrap(op, fmt.Errorf("%w: credit note total must be negative, got %s", accounting.ErrInvalidInput, total))
+	}
+	if original != nil {
+		open := original.inv.TotalAmount.Sub(original.inv.PaidAmount)
+		if total.Neg().GreaterThan(open) {
+			return nil, f.wrap(op, fmt.Errorf("%w: credit %s exceeds open amount %s of invoice %s",
+				accounting.ErrInvalidInput, total.Neg(), open, original.inv.Number))
+		}
+	}
+	number, err := f.assignNumber(input.InvoiceNo)
+	if err != nil {
+		return nil, f.wrap(op, err)
+	}
+
+	now := f.now()
+	r := &invoiceRecord{
+		inv: accounting.Invoice{
+			ID:           f.newID("inv"),
+			Number:       number,
+			CustomerName: cust.Name,
+			CustomerID:   cust.ID,
+			DocDate:      input.DocDate,
+			DueDate:      input.DueDate,
+			TotalAmount:  total,
+			TaxAmount:    vat,
+			Currency:     input.Currency,
+			ReferenceNo:  input.RefNo,
+			Lines:        lines,
+		},
+		changed: now,
+		credit:  true,
+	}
+	if original != nil {
+		r.original = original.inv.Number
+		// The credit and the original offset each other in full.
+		r.credited = total.Neg()
+		original.credited = original.credited.Add(total.Neg())
+		original.changed = now
+		recomputeStatus(original)
+	}
+	recomputeStatus(r)
+	f.invoices = append(f.invoices, r)
+	inv := cloneInvoice(r.inv)
+	return &inv, nil
+}
+
+// recomputeStatus derives PaidAmount, Paid and Status from the payments and
+// credits booked against the invoice. Credit notes compare magnitudes, since
+// their totals are negative.
+func recomputeStatus(r *invoiceRecord) {
+	settled := r.credited
+	for _, p := range r.inv.Payments {
+		settled = settled.Add(p.Amount)
+	}
+	r.inv.PaidAmount = settled
+	total := r.inv.TotalAmount.Abs()
+	switch {
+	case settled.IsPositive() && settled.GreaterThanOrEqual(total):
+		r.inv.Paid = true
+		r.inv.Status = accounting.InvoiceStatusPaid
+	case settled.IsPositive():
+		r.inv.Paid = false
+		r.inv.Status = accounting.InvoiceStatusPartial
+	default:
+		r.inv.Paid = false
+		r.inv.Status = accounting.InvoiceStatusUnpaid
+	}
+}
+
+// ListInvoicesSince returns invoices created or modified in [since, until]
+// (zero until = open), i.e. a change-tracked cursor like Merit's DateType=1.
+func (f *Fake) ListInvoicesSince(_ context.Context, since time.Time, until time.Time) ([]accounting.Invoice, error) {
+	const op = "ListInvoicesSince"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var out []accounting.Invoice
+	for _, r := range f.invoices {
+		if inPeriod(r.changed, since, until) {
+			out = append(out, cloneInvoice(r.inv))
+		}
+	}
+	return out, nil
+}
+
+// ListInvoiceChanges is ListInvoicesSince: the Fake keeps no tombstones.
+func (f *Fake) ListInvoiceChanges(ctx context.Context, since time.Time, until time.Time) (accounting.InvoiceChanges, error) {
+	invoices, err := f.ListInvoicesSince(ctx, since, until)
+	return accounting.InvoiceChanges{Invoices: invoices}, err
+}
@@ -0,0 +1,196 @@
+package accountingtest
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	accounting "github.com/qbitsoftware/accounting-service"
+)
+
+type paymentRecord struct {
+	p       accounting.Payment
+	changed time.Time
+	key     string // the IdempotencyKey it was created with
+}
+
+// Payments returns a snapshot of every stored payment (including the receipts
+// behind prepayments) in creation order.
+func (f *Fake) Payments() []accounting.Payment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]accounting.Payment, len(f.payments))
+	for i, r := range f.payments {
+		out[i] = clonePayment(r.p)
+	}
+	return out
+}
+
+func clonePayment(p accounting.Payment) accounting.Payment {
+	p.InvoiceLinks = append([]accounting.PaymentInvoiceLink(nil), p.InvoiceLinks...)
+	return p
+}
+
+// CreatePayment books a customer receipt against InvoiceNo and re-derives
+// the invoice's paid status. A PaymentNo already in use is rejected, as
+// Directo does for receipts with a duplicate number.
+func (f *Fake) CreatePayment(_ context.Context, input accounting.CreatePaymentInput) error {
+	const op = "CreatePayment"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !input.Amount.IsPositive() {
+		return f.wrap(op, fmt.Errorf("%w: payment amount must be positive, got %s", accounting.ErrInvalidInput, input.Amount))
+	}
+	inv := f.invoiceByNumber(input.InvoiceNo)
+	if inv == nil {
+		return f.wrap(op, fmt.Errorf("invoice %s: %w", input.InvoiceNo, accounting.ErrNotFound))
+	}
+	if input.Currency != "" && inv.inv.Currency != "" && input.Currency != inv.inv.Currency {
+		return f.wrap(op, fmt.Errorf("%w: payment currency %s does not match invoice %s currency %s",
+			accounting.ErrInvalidInput, input.Currency, inv.inv.Number, inv.inv.Currency))
+	}
+	if input.PaymentNo != "" {
+		for _, r := range f.payments {
+			if r.p.DocumentNo == input.PaymentNo {
+				return f.wrap(op, fmt.Errorf("duplicate payment number %s", input.PaymentNo))
+			}
+		}
+	}
+
+	now := f.now()
+	id := f.newID("pay")
+	docNo := input.PaymentNo
+	if docNo == "" {
+		docNo = id
+	}
+	f.payments = append(f.payments, &paymentRecord{
+		p: accounting.Payment{
+			ID:              id,
+			DocumentNo:      docNo,
+			DocumentDate:    input.PaymentDate,
+			Amount:          input.Amount,
+			Currency:        input.Currency,
+			Direction:       accounting.PaymentDirectionCustomer,
+			CounterPartID:   inv.inv.CustomerID,
+			CounterPartName: inv.inv.CustomerName,
+			InvoiceLinks: []accounting.PaymentInvoiceLink{{
+				InvoiceID: inv.inv.ID,
+				InvoiceNo: inv.inv.Number,
+				Amount:    input.Amount,
+			}},
+			ExternalPayMode: input.BankID,
+		},
+		changed: now,
+		key:     input.IdempotencyKey,
+	})
+	inv.inv.Payments = append(inv.inv.Payments, accounting.InvoicePayment{
+		Date:      input.PaymentDate,
+		Amount:    input.Amount,
+		Method:    input.BankID,
+		PaymentID: id,
+	})
+	inv.changed = now
+	recomputeStatus(inv)
+	return nil
+}
+
+// LocatePayment is the payments counterpart of LocateInvoice: by
+// IdempotencyKey, or else by PaymentNo.
+func (f *Fake) LocatePayment(_ context.Context, input accounting.CreatePaymentInput) (*accounting.Payment, error) {
+	err := f.begin("LocatePayment")
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range f.payments {
+		if input.IdempotencyKey != "" && r.key == input.IdempotencyKey ||
+			input.IdempotencyKey == "" && input.PaymentNo != "" && r.p.DocumentNo == input.PaymentNo {
+			p := clonePayment(r.p)
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *Fake) ListPayments(_ context.Context, input accounting.ListPaymentsInput) ([]accounting.Payment, error) {
+	const op = "ListPayments"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var out []accounting.Payment
+	for _, r := range f.payments {
+		if inPeriod(r.p.DocumentDate, input.PeriodStart, input.PeriodEnd) {
+			out = append(out, clonePayment(r.p))
+		}
+	}
+	return out, nil
+}
+
+// PaymentPages yields ListPayments as a single page.
+func (f *Fake) PaymentPages(ctx context.Context, input accounting.ListPaymentsInput) iter.Seq2[[]accounting.Payment, error] {
+	return onePage(func() ([]accounting.Payment, error) { return f.ListPayments(ctx, input) })
+}
+
+// DeletePayment removes a payment and re-opens the invoices it settled.
+func (f *Fake) DeletePayment(_ context.Context, id string) error {
+	const op = "DeletePayment"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for i, r := range f.payments {
+		if r.p.ID != id {
+			continue
+		}
+		f.payments = append(f.payments[:i], f.payments[i+1:]...)
+		now := f.now()
+		for _, link := range r.p.InvoiceLinks {
+			inv := f.invoiceByID(link.InvoiceID)
+			if inv == nil {
+				continue
+			}
+			kept := inv.inv.Payments[:0]
+			for _, ip := range inv.inv.Payments {
+				if ip.PaymentID != id {
+					kept = append(kept, ip)
+				}
+			}
+			inv.inv.Payments = kept
+			inv.changed = now
+			recomputeStatus(inv)
+		}
+		return nil
+	}
+	return f.wrap(op, accounting.ErrNotFound)
+}
+
+// ListPaymentsSince returns payments created in [since, until] (zero until =
+// open). Deleted payments are gone, as with the real change-tracked feeds.
+func (f *Fake) ListPaymentsSince(_ context.Context, since time.Time, until time.Time) ([]accounting.Payment, error) {
+	const op = "ListPaymentsSince"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var out []accounting.Payment
+	for _, r := range f.payments {
+		if inPeriod(r.changed, since, until) {
+			out = append(out, clonePayment(r.p))
+		}
+	}
+	return out, nil
+}
+
+// ListPaymentChanges is ListPaymentsSince: the Fake keeps no tombstones.
+func (f *Fake) ListPaymentChanges(ctx context.Context, since time.Time, until time.Time) (accounting.PaymentChanges, error) {
+	payments, err := f.ListPaymentsSince(ctx, since, until)
+	return accounting.PaymentChanges{Payments: payments}, err
+}
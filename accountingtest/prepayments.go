@@ -0,0 +1,205 @@
+package accountingtest
+
+import (
+	"context"
+	"fmt"
+
+	accounting "github.com/qbitsoftware/accounting-service"
+	"github.com/shopspring/decimal"
+)
+
+// Prepayments returns a snapshot of every stored prepayment in creation order.
+func (f *Fake) Prepayments() []accounting.Prepayment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]accounting.Prepayment, len(f.prepayments))
+	for i, p := range f.prepayments {
+		out[i] = *p
+	}
+	return out
+}
+
+func (f *Fake) prepaymentByNumber(no string) *accounting.Prepayment {
+	for _, p := range f.prepayments {
+		if p.Number == no {
+			return p
+		}
+	}
+	return nil
+}
+
+// checkNewPrepayment validates the caller-assigned number and customer of a
+// prepayment about to be created.
+func (f *Fake) checkNewPrepayment(no, customerCode string) error {
+	if no == "" {
+		return fmt.Errorf("%w: prepayment number is required", accounting.ErrInvalidInput)
+	}
+	if f.prepaymentByNumber(no) != nil {
+		return fmt.Errorf("duplicate prepayment number %s", no)
+	}
+	if f.customerByID(customerCode) == nil {
+		return fmt.Errorf("customer %s: %w", customerCode, accounting.ErrNotFound)
+	}
+	return nil
+}
+
+// CreatePrepayment records an unallocated receipt: the money shows up both as
+// a Payment with no invoice links and as a Prepayment with its full amount
+// remaining.
+func (f *Fake) CreatePrepayment(_ context.Context, input accounting.CreatePrepaymentInput) (*accounting.Prepayment, error) {
+	const op = OpCreatePrepayment
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !input.Amount.IsPositive() {
+		return nil, f.wrap(op, fmt.Errorf("%w: prepayment amount must be positive, got %s", accounting.ErrInvalidInput, input.Amount))
+	}
+	if err := f.checkNewPrepayment(input.PrepaymentNo, input.CustomerCode); err != nil {
+		return nil, f.wrap(op, err)
+	}
+
+	cust := f.customerByID(input.CustomerCode)
+	pp := &accounting.Prepayment{
+		Number:       input.PrepaymentNo,
+		DocID:        f.newID("pp"),
+		CustomerCode: input.CustomerCode,
+		Amount:       input.Amount,
+		Remaining:    input.Amount,
+		Currency:     input.Currency,
+		Date:         input.PaymentDate,
+		Comment:      input.Comment,
+	}
+	f.prepayments = append(f.prepayments, pp)
+	f.payments = append(f.payments, &paymentRecord{
+		p: accounting.Payment{
+			ID:              f.newID("pay"),
+			DocumentNo:      input.PrepaymentNo,
+			DocumentDate:    input.PaymentDate,
+			Amount:          input.Amount,
+			Currency:        input.Currency,
+			Direction:       accounting.PaymentDirectionCustomer,
+			CounterPartID:   cust.ID,
+			CounterPartName: cust.Name,
+			ExternalPayMode: input.BankID,
+		},
+		changed: f.now(),
+	})
+	out := *pp
+	return &out, nil
+}
+
+// ApplyPrepayment moves Amount from the prepayment's remainder onto the
+// invoice. Neither may be overdrawn.
+func (f *Fake) ApplyPrepayment(_ context.Context, input accounting.ApplyPrepaymentInput) error {
+	const op = OpApplyPrepayment
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !input.Amount.IsPositive() {
+		return f.wrap(op, fmt.Errorf("%w: amount must be positive, got %s", accounting.ErrInvalidInput, input.Amount))
+	}
+	pp := f.prepaymentByNumber(input.PrepaymentNo)
+	if pp == nil || pp.CustomerCode != input.CustomerCode {
+		return f.wrap(op, fmt.Errorf("prepayment %s: %w", input.PrepaymentNo, accounting.ErrNotFound))
+	}
+	inv := f.invoiceByNumber(input.InvoiceNo)
+	if inv == nil {
+		return f.wrap(op, fmt.Errorf("invoice %s: %w", input.InvoiceNo, accounting.ErrNotFound))
+	}
+	if input.Amount.GreaterThan(pp.Remaining) {
+		return f.wrap(op, fmt.Errorf("%w: amount %s exceeds prepayment %s remainder %s",
+			accounting.ErrInvalidInput, input.Amount, pp.Number, pp.Remaining))
+	}
+	if open := inv.inv.TotalAmount.Sub(inv.inv.PaidAmount); input.Amount.GreaterThan(open) {
+		return f.wrap(op, fmt.Errorf("%w: amount %s exceeds open amount %s of invoice %s",
+			accounting.ErrInvalidInput, input.Amount, open, inv.inv.Number))
+	}
+
+	pp.Remaining = pp.Remaining.Sub(input.Amount)
+	inv.inv.Payments = append(inv.inv.Payments, accounting.InvoicePayment{
+		Date:      input.PaymentDate,
+		Amount:    input.Amount,
+		Method:    "prepayment",
+		PaymentID: pp.Number,
+	})
+	inv.changed = f.now()
+	recomputeStatus(inv)
+	return nil
+}
+
+// UnallocateToPrepayment takes Amount of already-paid money off the invoice
+// (re-opening it) and parks it in a new prepayment.
+func (f *Fake) UnallocateToPrepayment(_ context.Context, input accounting.UnallocateToPrepaymentInput) (*accounting.Prepayment, error) {
+	const op = OpUnallocateToPrepayment
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !input.Amount.IsPositive() {
+		return nil, f.wrap(op, fmt.Errorf("%w: amount must be positive, got %s", accounting.ErrInvalidInput, input.Amount))
+	}
+	inv := f.invoiceByNumber(input.InvoiceNo)
+	if inv == nil {
+		return nil, f.wrap(op, fmt.Errorf("invoice %s: %w", input.InvoiceNo, accounting.ErrNotFound))
+	}
+	paid := decimal.Zero
+	for _, p := range inv.inv.Payments {
+		paid = paid.Add(p.Amount)
+	}
+	if input.Amount.GreaterThan(paid) {
+		return nil, f.wrap(op, fmt.Errorf("%w: amount %s exceeds paid amount %s of invoice %s",
+			accounting.ErrInvalidInput, input.Amount, paid, inv.inv.Number))
+	}
+	if err := f.checkNewPrepayment(input.PrepaymentNo, input.CustomerCode); err != nil {
+		return nil, f.wrap(op, err)
+	}
+
+	pp := &accounting.Prepayment{
+		Number:       input.PrepaymentNo,
+		DocID:        f.newID("pp"),
+		CustomerCode: input.CustomerCode,
+		Amount:       input.Amount,
+		Remaining:    input.Amount,
+		Currency:     input.Currency,
+		Date:         input.PaymentDate,
+		Comment:      input.Comment,
+	}
+	f.prepayments = append(f.prepayments, pp)
+	inv.inv.Payments = append(inv.inv.Payments, accounting.InvoicePayment{
+		Date:      input.PaymentDate,
+		Amount:    input.Amount.Neg(),
+		Method:    "prepayment",
+		PaymentID: pp.Number,
+	})
+	inv.changed = f.now()
+	recomputeStatus(inv)
+	out := *pp
+	return &out, nil
+}
+
+// ListPrepayments returns the customer's prepayments dated within the window
+// (zero bounds = open). An empty CustomerCode lists every customer's.
+func (f *Fake) ListPrepayments(_ context.Context, input accounting.ListPrepaymentsInput) ([]accounting.Prepayment, error) {
+	const op = OpListPrepayments
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var out []accounting.Prepayment
+	for _, pp := range f.prepayments {
+		if input.CustomerCode != "" && pp.CustomerCode != input.CustomerCode {
+			continue
+		}
+		if !inPeriod(pp.Date, input.Since, input.Until) {
+			continue
+		}
+		out = append(out, *pp)
+	}
+	return out, nil
+}
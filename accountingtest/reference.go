@@ -0,0 +1,242 @@
+package accountingtest
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+
+	accounting "github.com/qbitsoftware/accounting-service"
+)
+
+// SetDimensions replaces the dimension register returned by ListDimensions.
+func (f *Fake) SetDimensions(dims accounting.DimensionList) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dimensions = dims
+}
+
+// --- Items ---
+
+func (f *Fake) CreateItem(_ context.Context, input accounting.CreateItemInput) (*accounting.Item, error) {
+	const op = "CreateItem"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if input.Code == "" {
+		return nil, f.wrap(op, fmt.Errorf("%w: item code is required", accounting.ErrInvalidInput))
+	}
+	for _, it := range f.items {
+		if it.Code == input.Code {
+			return nil, f.wrap(op, fmt.Errorf("duplicate item code %s", input.Code))
+		}
+	}
+	it := &accounting.Item{
+		ID:            f.newID("item"),
+		Code:          input.Code,
+		Name:          input.Description,
+		Description:   input.Description,
+		Type:          input.Type,
+		UnitOfMeasure: input.UnitOfMeasure,
+		SalesPrice:    input.SalesPrice,
+		TaxID:         input.TaxID,
+	}
+	f.items = append(f.items, it)
+	out := *it
+	return &out, nil
+}
+
+// ListItems filters on Code (exact), Description (case-insensitive
+// substring) and Type; empty fields match everything.
+func (f *Fake) ListItems(_ context.Context, input accounting.ListItemsInput) ([]accounting.Item, error) {
+	const op = "ListItems"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var out []accounting.Item
+	for _, it := range f.items {
+		if input.Code != "" && it.Code != input.Code {
+			continue
+		}
+		if input.Description != "" && !strings.Contains(strings.ToLower(it.Description), strings.ToLower(input.Description)) {
+			continue
+		}
+		if input.Type != "" && it.Type != input.Type {
+			continue
+		}
+		out = append(out, *it)
+	}
+	return out, nil
+}
+
+// ItemPages yields ListItems as a single page.
+func (f *Fake) ItemPages(ctx context.Context, input accounting.ListItemsInput) iter.Seq2[[]accounting.Item, error] {
+	return onePage(func() ([]accounting.Item, error) { return f.ListItems(ctx, input) })
+}
+
+func (f *Fake) UpdateItem(_ context.Context, input accounting.UpdateItemInput) error {
+	const op = "UpdateItem"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, it := range f.items {
+		if it.ID != input.ID {
+			continue
+		}
+		if input.Code != nil {
+			it.Code = *input.Code
+		}
+		if input.Description != nil {
+			it.Description = *input.Description
+			it.Name = *input.Description
+		}
+		if input.SalesPrice != nil {
+			it.SalesPrice = *input.SalesPrice
+		}
+		if input.TaxID != nil {
+			it.TaxID = *input.TaxID
+		}
+		return nil
+	}
+	return f.wrap(op, accounting.ErrNotFound)
+}
+
+// --- Purchases ---
+
+func (f *Fake) CreatePurchase(_ context.Context, input accounting.CreatePurchaseInput) (*accounting.PurchaseInvoice, error) {
+	const op = OpCreatePurchase
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(input.Lines) == 0 {
+		return nil, f.wrap(op, fmt.Errorf("%w: purchase has no lines", accounting.ErrInvalidInput))
+	}
+	_, net, vat := f.buildLines(input.Lines)
+	vendorID := input.VendorID
+	if vendorID == "" {
+		vendorID = f.newID("vendor")
+	}
+	pi := &accounting.PurchaseInvoice{
+		ID:          f.newID("bill"),
+		Number:      input.BillNo,
+		VendorName:  input.VendorName,
+		VendorID:    vendorID,
+		DocDate:     input.DocDate,
+		DueDate:     input.DueDate,
+		TotalAmount: net.Add(vat),
+		TaxAmount:   vat,
+		Currency:    input.Currency,
+		Status:      accounting.InvoiceStatusUnpaid,
+		ReferenceNo: input.RefNo,
+	}
+	f.purchases = append(f.purchases, pi)
+	out := *pi
+	return &out, nil
+}
+
+func (f *Fake) GetPurchase(_ context.Context, id string) (*accounting.PurchaseInvoice, error) {
+	const op = "GetPurchase"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for _, pi := range f.purchases {
+		if pi.ID == id {
+			out := *pi
+			return &out, nil
+		}
+	}
+	return nil, f.wrap(op, accounting.ErrNotFound)
+}
+
+func (f *Fake) ListPurchases(_ context.Context, input accounting.ListPurchasesInput) ([]accounting.PurchaseInvoice, error) {
+	const op = "ListPurchases"
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var out []accounting.PurchaseInvoice
+	for _, pi := range f.purchases {
+		if inPeriod(pi.DocDate, input.PeriodStart, input.PeriodEnd) {
+			out = append(out, *pi)
+		}
+	}
+	return out, nil
+}
+
+func (f *Fake) DeletePurchase(_ context.Context, id string) error {
+	const op = OpDeletePurchase
+	err := f.begin(op)
+	defer f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for i, pi := range f.purchases {
+		if pi.ID == id {
+			f.purchases = append(f.purchases[:i], f.purchases[i+1:]...)
+			return nil
+		}
+	}
+	return f.wrap(op, accounting.ErrNotFound)
+}
+
+// --- Reference data ---
+
+func (f *Fake) ListTaxes(context.Context) ([]accounting.Tax, error) {
+	err := f.begin(OpListTaxes)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return append([]accounting.Tax(nil), f.taxes...), nil
+}
+
+func (f *Fake) ListAccounts(context.Context) ([]accounting.Account, error) {
+	err := f.begin(OpListAccounts)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return append([]accounting.Account(nil), f.accounts...), nil
+}
+
+func (f *Fake) ListDimensions(context.Context) (*accounting.DimensionList, error) {
+	err := f.begin(OpListDimensions)
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &accounting.DimensionList{
+		Projects:    append([]accounting.Dimension(nil), f.dimensions.Projects...),
+		CostCenters: append([]accounting.Dimension(nil), f.dimensions.CostCenters...),
+		Departments: append([]accounting.Dimension(nil), f.dimensions.Departments...),
+	}, nil
+}
+
+func (f *Fake) ListBanks(context.Context) ([]accounting.Bank, error) {
+	err := f.begin("ListBanks")
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return append([]accounting.Bank(nil), f.banks...), nil
+}
+
+func (f *Fake) ListPaymentTerms(context.Context) ([]accounting.PaymentTerm, error) {
+	err := f.begin("ListPaymentTerms")
+	defer f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return append([]accounting.PaymentTerm(nil), f.paymentTerms...), nil
+}
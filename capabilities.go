@@ -1,10 +1,20 @@
 package accounting
 
+import (
+	"encoding/json"
+	"slices"
+)
+
 // Capabilities describes which optional features a provider supports.
 // Consumers (UIs, sync services) read this to hide actions the active
 // provider cannot perform — e.g. PDF download or invoice deletion for
 // Excellent Books, which the API does not expose.
+//
+// Operations lists every supported operation; the Supports* flags are the
+// older, coarser view of the same information.
 type Capabilities struct {
+	Operations OpSet `json:"operations"`
+
 	SupportsInvoicePDF       bool `json:"supports_invoice_pdf"`
 	SupportsInvoiceDelete    bool `json:"supports_invoice_delete"`
 	SupportsPaymentDelete    bool `json:"supports_payment_delete"`
@@ -16,76 +26,222 @@ type Capabilities struct {
 	SupportsCustomerDebts    bool `json:"supports_customer_debts"`
 	SupportsVendorPayments   bool `json:"supports_vendor_payments"`
 	SupportsFindInvoiceByRef bool `json:"supports_find_invoice_by_ref"`
-	SupportsIncrementalSync  bool `json:"supports_incremental_sync"` // True if ListInvoicesSince / ListPaymentsSince track changes (not just doc-date range)
+	SupportsIncrementalSync  bool `json:"supports_incremental_sync"` // True if sync tracks changes (not just doc-date range); for Excellent Books through SyncService.*ChangesAfter and SyncEngine, not ListInvoicesSince / ListPaymentsSince
+	SupportsDeletionSync     bool `json:"supports_deletion_sync"`    // True if SyncService.PullInvoiceChanges / PullPaymentChanges report hard deletes as tombstones
 }
 
-// ProviderCapabilities returns the capability set for a given provider name.
+// ProviderCapabilities returns the capability set for a given provider name,
+// as declared when the provider was registered with RegisterProvider.
 // Unknown providers return zero-value Capabilities (all features disabled).
 //
 // This is exposed as a free function so consumers can query capabilities
 // without instantiating a Client (e.g. when rendering a provider-selection UI).
 func ProviderCapabilities(provider string) Capabilities {
-	switch provider {
-	case "merit":
-		return Capabilities{
-			SupportsInvoicePDF:       true,
-			SupportsInvoiceDelete:    true,
-			SupportsPaymentDelete:    true,
-			SupportsPurchaseCreate:   true,
-			SupportsPurchaseDelete:   true,
-			SupportsTaxList:          true,
-			SupportsAccountList:      true,
-			SupportsDimensions:       true,
-			SupportsCustomerDebts:    true,
-			SupportsVendorPayments:   true,
-			SupportsFindInvoiceByRef: false,
-			SupportsIncrementalSync:  true,
-		}
-	case "excellentbooks":
-		return Capabilities{
-			SupportsInvoicePDF:       false,
-			SupportsInvoiceDelete:    false,
-			SupportsPaymentDelete:    false,
-			SupportsPurchaseCreate:   false,
-			SupportsPurchaseDelete:   false,
-			SupportsTaxList:          true,
-			SupportsAccountList:      true,
-			SupportsDimensions:       true,
-			SupportsCustomerDebts:    false,
-			SupportsVendorPayments:   false,
-			SupportsFindInvoiceByRef: true,
-			SupportsIncrementalSync:  false,
+	reg, ok := lookupProvider(provider)
+	if !ok {
+		return Capabilities{}
+	}
+	return reg.capabilities
+}
+
+// Supports reports whether the provider implements op. Operations the
+// provider does not support return an error or, for the list-style
+// reference-data calls (ListBanks, ListPaymentTerms), an empty result.
+func (c Capabilities) Supports(op Op) bool {
+	return c.Operations.Has(op)
+}
+
+// Op names a provider operation: a Provider or PrepaymentProvider method.
+// The values are the method names, which are also the Operation.Name an
+// Observer sees.
+type Op string
+
+const (
+	OpTestConnection         Op = "TestConnection"
+	OpCreateInvoice          Op = "CreateInvoice"
+	OpLocateInvoice          Op = "LocateInvoice"
+	OpGetInvoice             Op = "GetInvoice"
+	OpGetInvoicePDF          Op = "GetInvoicePDF"
+	OpListInvoices           Op = "ListInvoices"
+	OpInvoicePages           Op = "InvoicePages"
+	OpFindInvoiceByRef       Op = "FindInvoiceByRef"
+	OpDeleteInvoice          Op = "DeleteInvoice"
+	OpCreateCustomer         Op = "CreateCustomer"
+	OpUpdateCustomer         Op = "UpdateCustomer"
+	OpListCustomers          Op = "ListCustomers"
+	OpCustomerPages          Op = "CustomerPages"
+	OpFindCustomerByEmail    Op = "FindCustomerByEmail"
+	OpGetCustomer            Op = "GetCustomer"
+	OpCreatePayment          Op = "CreatePayment"
+	OpLocatePayment          Op = "LocatePayment"
+	OpListPayments           Op = "ListPayments"
+	OpPaymentPages           Op = "PaymentPages"
+	OpDeletePayment          Op = "DeletePayment"
+	OpCreateItem             Op = "CreateItem"
+	OpListItems              Op = "ListItems"
+	OpItemPages              Op = "ItemPages"
+	OpUpdateItem             Op = "UpdateItem"
+	OpCreateCreditNote       Op = "CreateCreditNote"
+	OpCreatePurchase         Op = "CreatePurchase"
+	OpGetPurchase            Op = "GetPurchase"
+	OpListPurchases          Op = "ListPurchases"
+	OpDeletePurchase         Op = "DeletePurchase"
+	OpListTaxes              Op = "ListTaxes"
+	OpListAccounts           Op = "ListAccounts"
+	OpListDimensions         Op = "ListDimensions"
+	OpListBanks              Op = "ListBanks"
+	OpListPaymentTerms       Op = "ListPaymentTerms"
+	OpCustomerDebts          Op = "CustomerDebts"
+	OpListInvoicesSince      Op = "ListInvoicesSince"
+	OpListPaymentsSince      Op = "ListPaymentsSince"
+	OpListInvoiceChanges     Op = "ListInvoiceChanges"
+	OpListPaymentChanges     Op = "ListPaymentChanges"
+	OpInvoiceChangesAfter    Op = "InvoiceChangesAfter"
+	OpPaymentChangesAfter    Op = "PaymentChangesAfter"
+	OpCustomerChangesAfter   Op = "CustomerChangesAfter"
+	OpItemChangesAfter       Op = "ItemChangesAfter"
+	OpCreatePrepayment       Op = "CreatePrepayment"
+	OpApplyPrepayment        Op = "ApplyPrepayment"
+	OpUnallocateToPrepayment Op = "UnallocateToPrepayment"
+	OpListPrepayments        Op = "ListPrepayments"
+)
+
+// allOps lists every Op in interface order; an Op's index is its OpSet bit.
+var allOps = []Op{
+	OpTestConnection,
+	OpCreateInvoice, OpLocateInvoice, OpGetInvoice, OpGetInvoicePDF, OpListInvoices, OpInvoicePages, OpFindInvoiceByRef, OpDeleteInvoice,
+	OpCreateCustomer, OpUpdateCustomer, OpListCustomers, OpCustomerPages, OpFindCustomerByEmail, OpGetCustomer,
+	OpCreatePayment, OpLocatePayment, OpListPayments, OpPaymentPages, OpDeletePayment,
+	OpCreateItem, OpListItems, OpItemPages, OpUpdateItem,
+	OpCreateCreditNote,
+	OpCreatePurchase, OpGetPurchase, OpListPurchases, OpDeletePurchase,
+	OpListTaxes, OpListAccounts, OpListDimensions, OpListBanks, OpListPaymentTerms,
+	OpCustomerDebts,
+	OpListInvoicesSince, OpListPaymentsSince, OpListInvoiceChanges, OpListPaymentChanges,
+	OpInvoiceChangesAfter, OpPaymentChangesAfter, OpCustomerChangesAfter, OpItemChangesAfter,
+	OpCreatePrepayment, OpApplyPrepayment, OpUnallocateToPrepayment, OpListPrepayments,
+}
+
+var (
+	// prepaymentOps are the PrepaymentProvider operations.
+	prepaymentOps = NewOpSet(OpCreatePrepayment, OpApplyPrepayment, OpUnallocateToPrepayment, OpListPrepayments)
+	// providerOps are the Provider operations.
+	providerOps = NewOpSet(allOps...) &^ prepaymentOps
+	// unflaggedOps are the Provider operations no Supports* flag covers
+	// and few providers have: the idempotent-create lookups and the change
+	// feeds.
+	unflaggedOps = NewOpSet(OpLocateInvoice, OpLocatePayment,
+		OpInvoiceChangesAfter, OpPaymentChangesAfter, OpCustomerChangesAfter, OpItemChangesAfter)
+)
+
+// Ops returns every operation, in Provider then PrepaymentProvider order.
+func Ops() []Op {
+	return slices.Clone(allOps)
+}
+
+// OpSet is a set of operations. It is a bit set so that Capabilities stays
+// comparable; it marshals to JSON as a list of operation names.
+type OpSet uint64
+
+// NewOpSet returns the set of the given operations. Unknown names are
+// ignored.
+func NewOpSet(ops ...Op) OpSet {
+	var s OpSet
+	for _, op := range ops {
+		if i := slices.Index(allOps, op); i >= 0 {
+			s |= 1 << i
 		}
-	case "smartaccounts":
-		return Capabilities{
-			SupportsInvoicePDF:       true,
-			SupportsInvoiceDelete:    true,
-			SupportsPaymentDelete:    true,
-			SupportsPurchaseCreate:   true,
-			SupportsPurchaseDelete:   true,
-			SupportsTaxList:          true,
-			SupportsAccountList:      true,
-			SupportsDimensions:       true,
-			SupportsCustomerDebts:    true,
-			SupportsVendorPayments:   true,
-			SupportsFindInvoiceByRef: true,
-			SupportsIncrementalSync:  true,
+	}
+	return s
+}
+
+// Has reports whether op is in s.
+func (s OpSet) Has(op Op) bool {
+	i := slices.Index(allOps, op)
+	return i >= 0 && s&(1<<i) != 0
+}
+
+// Without returns s minus ops.
+func (s OpSet) Without(ops ...Op) OpSet {
+	return s &^ NewOpSet(ops...)
+}
+
+// Ops returns the operations in s, in Ops order.
+func (s OpSet) Ops() []Op {
+	var ops []Op
+	for i, op := range allOps {
+		if s&(1<<i) != 0 {
+			ops = append(ops, op)
 		}
-	case "directo":
-		return Capabilities{
-			SupportsInvoicePDF:       false,
-			SupportsInvoiceDelete:    true,
-			SupportsPaymentDelete:    true,
-			SupportsPurchaseCreate:   true,
-			SupportsPurchaseDelete:   true,
-			SupportsTaxList:          true,
-			SupportsAccountList:      true,
-			SupportsDimensions:       true,
-			SupportsCustomerDebts:    true,
-			SupportsVendorPayments:   true,
-			SupportsFindInvoiceByRef: true,
-			SupportsIncrementalSync:  false,
+	}
+	return ops
+}
+
+func (s OpSet) MarshalJSON() ([]byte, error) {
+	ops := s.Ops()
+	if ops == nil {
+		ops = []Op{}
+	}
+	return json.Marshal(ops)
+}
+
+func (s *OpSet) UnmarshalJSON(data []byte) error {
+	var ops []Op
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return err
+	}
+	*s = NewOpSet(ops...)
+	return nil
+}
+
+// opFlags ties the per-feature flags to the operation each one describes.
+var opFlags = []struct {
+	op   Op
+	flag func(*Capabilities) *bool
+}{
+	{OpGetInvoicePDF, func(c *Capabilities) *bool { return &c.SupportsInvoicePDF }},
+	{OpDeleteInvoice, func(c *Capabilities) *bool { return &c.SupportsInvoiceDelete }},
+	{OpDeletePayment, func(c *Capabilities) *bool { return &c.SupportsPaymentDelete }},
+	{OpCreatePurchase, func(c *Capabilities) *bool { return &c.SupportsPurchaseCreate }},
+	{OpDeletePurchase, func(c *Capabilities) *bool { return &c.SupportsPurchaseDelete }},
+	{OpListTaxes, func(c *Capabilities) *bool { return &c.SupportsTaxList }},
+	{OpListAccounts, func(c *Capabilities) *bool { return &c.SupportsAccountList }},
+	{OpListDimensions, func(c *Capabilities) *bool { return &c.SupportsDimensions }},
+	{OpCustomerDebts, func(c *Capabilities) *bool { return &c.SupportsCustomerDebts }},
+	{OpFindInvoiceByRef, func(c *Capabilities) *bool { return &c.SupportsFindInvoiceByRef }},
+}
+
+// adapterCapabilities derives the Capabilities of a built-in adapter from
+// the adapter itself: every Provider operation except the unsupported ones
+// listed next to it, plus the prepayment operations when p implements
+// PrepaymentProvider. The per-feature flags are set from the same
+// operations so the two views cannot disagree; base supplies the flags
+// that are not operations (vendor payments, incremental sync).
+func adapterCapabilities(p Provider, base Capabilities, unsupported ...Op) Capabilities {
+	ops := providerOps
+	if _, ok := p.(PrepaymentProvider); ok {
+		ops |= prepaymentOps
+	}
+	c := base
+	c.Operations = ops.Without(unsupported...)
+	for _, f := range opFlags {
+		*f.flag(&c) = c.Operations.Has(f.op)
+	}
+	return c
+}
+
+// withOperations fills in Operations for a capability set registered with
+// the per-feature flags only: every Provider operation, less those whose
+// flag is unset and the unflagged ones.
+func (c Capabilities) withOperations() Capabilities {
+	if c.Operations != 0 {
+		return c
+	}
+	c.Operations = providerOps &^ unflaggedOps
+	for _, f := range opFlags {
+		if !*f.flag(&c) {
+			c.Operations = c.Operations.Without(f.op)
 		}
 	}
-	return Capabilities{}
+	return c
 }
@@ -0,0 +1,124 @@
+// Package cassette provides a record/replay http.RoundTripper for the
+// provider clients (merit, directo, excellentbooks, smartaccounts).
+//
+// In record mode every request is forwarded to the real API and the
+// request/response pair is appended to a JSON cassette file, with
+// credentials scrubbed on the way: Merit ApiId/signature, Directo token and
+// X-Directo-Key, Excellent Books basic auth and SmartAccounts
+// apikey/signature. In replay mode requests are answered from the cassette
+// without touching the network, so a recorded session can be rerun
+// offline.
+//
+// Only the merit phase-0 probe tests are wired to it so far, and no
+// recordings are committed: making one needs live credentials. The
+// Directo and Excellent Books probes under cmd talk to the live APIs
+// only.
+//
+// Usage:
+//
+//	rec, err := cassette.New("testdata/cassettes/getinvoices.json", cassette.ModeFromEnv("CASSETTE_MODE", cassette.ModeReplay))
+//	if err != nil { ... }
+//	defer rec.Stop()
+//	client := merit.New(merit.Config{APIID: id, APIKey: key, HTTPClient: rec.Client()})
+//
+// Requests are matched on method, URL path, query and body. Query
+// parameters that change on every call (timestamp, signature) and the
+// scrubbed credentials are ignored, so a replay with dummy credentials
+// matches a recording made with real ones.
+package cassette
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// Cassette is the on-disk recording: an ordered list of interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the scrubbed form of a recorded request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body holds a payload. Text bodies are stored verbatim so cassettes stay
+// reviewable in diffs; anything that is not valid UTF-8 is base64-encoded.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var enc struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return fmt.Errorf("cassette: body is neither a string nor {\"base64\":...}: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc.Base64)
+	if err != nil {
+		return fmt.Errorf("cassette: decode base64 body: %w", err)
+	}
+	*b = raw
+	return nil
+}
+
+// Load reads a cassette file. A missing file yields an empty cassette and
+// an error wrapping os.ErrNotExist.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &Cassette{}, fmt.Errorf("cassette: read %s: %w", path, err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cassette: parse %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, creating parent directories.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cassette: marshal: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("cassette: create dir: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("cassette: write %s: %w", path, err)
+	}
+	return nil
+}
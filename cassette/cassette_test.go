@@ -0,0 +1,174 @@
+package cassette_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qbitsoftware/accounting-service/cassette"
+	"github.com/qbitsoftware/accounting-service/directo"
+	"github.com/qbitsoftware/accounting-service/excellentbooks"
+	"github.com/qbitsoftware/accounting-service/merit"
+	"github.com/qbitsoftware/accounting-service/smartaccounts"
+)
+
+// redirect sends every request to srv regardless of the host the client
+// targets, so clients can keep their production base URLs.
+type redirect struct{ srv *httptest.Server }
+
+func (rt redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, _ := url.Parse(rt.srv.URL)
+	out := req.Clone(req.Context())
+	out.URL.Scheme = target.Scheme
+	out.URL.Host = target.Host
+	out.Host = ""
+	return http.DefaultTransport.RoundTrip(out)
+}
+
+// fakeAPI answers the four providers' endpoints with canned bodies keyed on
+// the last path segment and counts how many requests reached it.
+func fakeAPI(t *testing.T, hits *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		_, _ = io.ReadAll(r.Body)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/gettaxes"):
+			w.Write([]byte(`[{"Id":"b9b25735","Code":"22%","Name":"Käibemaks 22%","TaxPct":22}]`))
+		case strings.HasSuffix(r.URL.Path, "xmlcore.asp"):
+			w.Write([]byte(`<results><result type="0" desc="OK"/></results>`))
+		case strings.HasSuffix(r.URL.Path, "/CUVc"):
+			w.Write([]byte(`{"data":{"@register":"CUVc","CUVc":[{"Code":"K1","Name":"Mari"}]}}`))
+		case strings.HasSuffix(r.URL.Path, "vatpcs:get"):
+			w.Write([]byte(`{"vatPcs":[{"vatPc":"22","percent":22}]}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+type secrets struct{ id, key string }
+
+var (
+	real  = secrets{id: "real-api-id-4711", key: "real-secret-key-0815"}
+	dummy = secrets{id: "dummy-id", key: "dummy-key"}
+)
+
+// exercise performs one call through each provider client.
+func exercise(t *testing.T, hc *http.Client, s secrets) {
+	t.Helper()
+	ctx := context.Background()
+
+	taxes, err := merit.New(merit.Config{APIID: s.id, APIKey: s.key, HTTPClient: hc}).ListTaxes(ctx)
+	if err != nil || len(taxes) != 1 || taxes[0].Name != "Käibemaks 22%" {
+		t.Fatalf("merit ListTaxes = %+v, %v", taxes, err)
+	}
+
+	dc, err := directo.New(directo.Config{Company: "acme", Token: s.key, RestAPIKey: s.id, HTTPClient: hc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dc.CreatePayment(ctx, directo.ReceiptXML{Number: "R-1"}); err != nil {
+		t.Fatalf("directo CreatePayment: %v", err)
+	}
+
+	eb := excellentbooks.New(excellentbooks.Config{BaseURL: "https://eb.example", Username: s.id, Password: s.key, HTTPClient: hc})
+	custs, _, err := eb.ListCustomers(ctx, excellentbooks.ListParams{Limit: 1})
+	if err != nil || len(custs) != 1 {
+		t.Fatalf("excellentbooks ListCustomers = %+v, %v", custs, err)
+	}
+
+	sa := smartaccounts.New(smartaccounts.Config{APIKey: s.id, SecretKey: s.key, HTTPClient: hc, RatePerSecond: -1})
+	if _, err := sa.ListVatPcs(ctx); err != nil {
+		t.Fatalf("smartaccounts ListVatPcs: %v", err)
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.json")
+	hits := 0
+	srv := fakeAPI(t, &hits)
+	defer srv.Close()
+
+	rec, err := cassette.New(path, cassette.ModeRecord, cassette.WithTransport(redirect{srv}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exercise(t, rec.Client(), real)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if hits != 4 {
+		t.Fatalf("recording reached the API %d times, want 4", hits)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{real.id, real.key, "Basic "} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("cassette leaks %q:\n%s", secret, raw)
+		}
+	}
+
+	// Replay with different credentials and no server: timestamps and
+	// signatures differ, and the scrubbed credentials must not matter.
+	srv.Close()
+	replay, err := cassette.New(path, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exercise(t, replay.Client(), dummy)
+	if hits != 4 {
+		t.Errorf("replay reached the API (%d hits)", hits)
+	}
+}
+
+func TestReplayUnmatched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.json")
+	if err := (&cassette.Cassette{}).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := cassette.New(path, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = merit.New(merit.Config{APIID: "x", APIKey: "y", HTTPClient: rec.Client()}).ListTaxes(context.Background())
+	if !errors.Is(err, cassette.ErrNoInteraction) {
+		t.Errorf("err = %v, want ErrNoInteraction", err)
+	}
+}
+
+func TestReplayOrRecordAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auto.json")
+	hits := 0
+	srv := fakeAPI(t, &hits)
+	defer srv.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		rec, err := cassette.New(path, cassette.ModeReplayOrRecord, cassette.WithTransport(redirect{srv}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := merit.New(merit.Config{APIID: real.id, APIKey: real.key, HTTPClient: rec.Client()})
+		if _, err := c.ListTaxes(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("API hit %d times, want 1 (second run should replay)", hits)
+	}
+}
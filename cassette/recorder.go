@@ -0,0 +1,268 @@
+package cassette
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Mode selects how a Recorder treats the network.
+type Mode int
+
+const (
+	// ModeReplay answers every request from the cassette; an unmatched
+	// request fails with ErrNoInteraction. The network is never used.
+	ModeReplay Mode = iota
+	// ModeRecord sends every request to the real API and rewrites the
+	// cassette from scratch on Stop.
+	ModeRecord
+	// ModeReplayOrRecord replays matching interactions and records the
+	// rest, appending them to the cassette on Stop.
+	ModeReplayOrRecord
+)
+
+// ErrNoInteraction is returned in replay mode when the cassette holds no
+// unused interaction matching the request.
+var ErrNoInteraction = errors.New("cassette: no matching interaction")
+
+// ModeFromEnv reads the mode from the environment variable key
+// ("record", "replay" or "auto"), falling back to def when unset or
+// unrecognised. Tests use it so the same test replays in CI and re-records
+// locally with e.g. CASSETTE_MODE=record.
+func ModeFromEnv(key string, def Mode) Mode {
+	switch strings.ToLower(os.Getenv(key)) {
+	case "record":
+		return ModeRecord
+	case "replay":
+		return ModeReplay
+	case "auto":
+		return ModeReplayOrRecord
+	}
+	return def
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithTransport sets the RoundTripper used to reach the real API in the
+// recording modes. Defaults to http.DefaultTransport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(r *Recorder) { r.next = rt }
+}
+
+// WithSecretQueryParams redacts additional query parameters.
+func WithSecretQueryParams(names ...string) Option {
+	return func(r *Recorder) {
+		for _, n := range names {
+			r.scrub.query[n] = true
+		}
+	}
+}
+
+// WithIgnoredQueryParams excludes additional query parameters from
+// request matching.
+func WithIgnoredQueryParams(names ...string) Option {
+	return func(r *Recorder) {
+		for _, n := range names {
+			r.scrub.ignore[n] = true
+		}
+	}
+}
+
+// WithSecretFormFields redacts additional form-body fields.
+func WithSecretFormFields(names ...string) Option {
+	return func(r *Recorder) {
+		for _, n := range names {
+			r.scrub.form[n] = true
+		}
+	}
+}
+
+// WithSecretHeaders redacts additional request/response headers.
+func WithSecretHeaders(names ...string) Option {
+	return func(r *Recorder) { r.scrub.headers = append(r.scrub.headers, names...) }
+}
+
+// WithReplacement substitutes every literal occurrence of secret (in URLs,
+// headers and bodies) with placeholder — for values such as a company code
+// or tenant host that are not credentials but should not be committed.
+func WithReplacement(secret, placeholder string) Option {
+	return func(r *Recorder) { r.scrub.replacements[secret] = placeholder }
+}
+
+// Recorder is an http.RoundTripper that records to and replays from a
+// cassette file. It is safe for concurrent use; concurrent identical
+// requests are answered in recording order.
+type Recorder struct {
+	path  string
+	mode  Mode
+	next  http.RoundTripper
+	scrub *scrubber
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+	recorded int
+}
+
+// New opens the cassette at path. In ModeReplay the file must exist; in
+// ModeRecord any existing content is discarded when the cassette is saved.
+func New(path string, mode Mode, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:  path,
+		mode:  mode,
+		next:  http.DefaultTransport,
+		scrub: newScrubber(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	switch mode {
+	case ModeRecord:
+		r.cassette = &Cassette{}
+	default:
+		c, err := Load(path)
+		if err != nil && (mode == ModeReplay || !errors.Is(err, os.ErrNotExist)) {
+			return nil, err
+		}
+		r.cassette = c
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Client returns an *http.Client that routes through the Recorder, ready to
+// pass as HTTPClient to any provider client.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Stop persists newly recorded interactions. It is a no-op in ModeReplay
+// or when nothing new was recorded.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mode == ModeReplay || (r.recorded == 0 && r.mode != ModeRecord) {
+		return nil
+	}
+	return r.cassette.Save(r.path)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cassette: read request body: %w", err)
+		}
+	}
+	contentType := req.Header.Get("Content-Type")
+	scrubbedBody := r.scrub.body(contentType, body)
+	key := r.matchKey(req.Method, req.URL, contentType, scrubbedBody)
+
+	if r.mode != ModeRecord {
+		if in, ok := r.take(key); ok {
+			return in.Response.toHTTP(req), nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, r.scrub.url(req.URL))
+		}
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cassette: read response body: %w", err)
+	}
+
+	in := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    r.scrub.url(req.URL),
+			Header: r.scrub.header(req.Header),
+			Body:   scrubbedBody,
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     r.scrub.header(resp.Header),
+			Body:       r.scrub.body(resp.Header.Get("Content-Type"), respBody),
+		},
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.used = append(r.used, true)
+	r.recorded++
+	r.mu.Unlock()
+
+	// Hand the caller the real response, not the scrubbed copy.
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// take returns the first unused interaction matching key and marks it used.
+func (r *Recorder) take(key string) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] {
+			continue
+		}
+		u, err := url.Parse(in.Request.URL)
+		if err != nil {
+			continue
+		}
+		ct := in.Request.Header.Get("Content-Type")
+		if r.matchKey(in.Request.Method, u, ct, in.Request.Body) == key {
+			r.used[i] = true
+			return in, true
+		}
+	}
+	return Interaction{}, false
+}
+
+// matchKey identifies a request for replay: method, path, canonical query
+// (secrets redacted, volatile params dropped) and canonical body. The host
+// is deliberately excluded so a cassette recorded against one tenant host
+// or an httptest server replays against another.
+func (r *Recorder) matchKey(method string, u *url.URL, contentType string, scrubbedBody []byte) string {
+	scrubbedURL, err := url.Parse(r.scrub.url(u))
+	if err != nil {
+		scrubbedURL = u
+	}
+	return method + " " + scrubbedURL.EscapedPath() + "?" + r.scrub.matchQuery(scrubbedURL) + "\n" + matchBody(contentType, scrubbedBody)
+}
+
+func (resp Response) toHTTP(req *http.Request) *http.Response {
+	header := resp.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
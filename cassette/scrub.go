@@ -0,0 +1,156 @@
+package cassette
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces every scrubbed credential in a cassette.
+const Redacted = "REDACTED"
+
+// Credentials carried by the four provider clients. Each list is the
+// union across providers; a name that a given provider does not use is
+// simply never present.
+var (
+	// secretQueryParams: Merit ApiId/signature, SmartAccounts
+	// apikey/signature, Directo XML GET token.
+	secretQueryParams = []string{"ApiId", "apikey", "signature", "token"}
+	// volatileQueryParams change on every call (signed timestamps) and are
+	// left out of request matching.
+	volatileQueryParams = []string{"timestamp", "signature"}
+	// secretFormFields: Directo XML PUT posts its token in the form body.
+	secretFormFields = []string{"token"}
+	// secretHeaders: Excellent Books basic auth, Directo REST key.
+	secretHeaders = []string{"Authorization", "X-Directo-Key", "Cookie", "Set-Cookie"}
+)
+
+// scrubber removes credentials from requests and responses before they are
+// written or compared.
+type scrubber struct {
+	query        map[string]bool
+	ignore       map[string]bool
+	form         map[string]bool
+	headers      []string
+	replacements map[string]string
+}
+
+func newScrubber() *scrubber {
+	s := &scrubber{
+		query:        map[string]bool{},
+		ignore:       map[string]bool{},
+		form:         map[string]bool{},
+		headers:      append([]string(nil), secretHeaders...),
+		replacements: map[string]string{},
+	}
+	for _, k := range secretQueryParams {
+		s.query[k] = true
+	}
+	for _, k := range volatileQueryParams {
+		s.ignore[k] = true
+	}
+	for _, k := range secretFormFields {
+		s.form[k] = true
+	}
+	return s
+}
+
+// replace applies the literal secret → placeholder substitutions.
+func (s *scrubber) replace(v string) string {
+	for secret, placeholder := range s.replacements {
+		if secret != "" {
+			v = strings.ReplaceAll(v, secret, placeholder)
+		}
+	}
+	return v
+}
+
+// url returns u with secret query parameters redacted. The query is
+// re-encoded only when something was redacted, so clean URLs are recorded
+// byte-for-byte.
+func (s *scrubber) url(u *url.URL) string {
+	out := *u
+	q := u.Query()
+	changed := false
+	for k := range q {
+		if s.query[k] {
+			q.Set(k, Redacted)
+			changed = true
+		}
+	}
+	if changed {
+		out.RawQuery = q.Encode()
+	}
+	return s.replace(out.String())
+}
+
+func (s *scrubber) header(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	out := h.Clone()
+	for _, k := range s.headers {
+		if out.Get(k) != "" {
+			out.Set(k, Redacted)
+		}
+	}
+	for k, vs := range out {
+		for i, v := range vs {
+			vs[i] = s.replace(v)
+		}
+		out[k] = vs
+	}
+	return out
+}
+
+// body redacts secret fields of form-encoded bodies. Other bodies only get
+// the literal replacements.
+func (s *scrubber) body(contentType string, b []byte) []byte {
+	if isForm(contentType) && len(s.form) > 0 {
+		if vals, err := url.ParseQuery(string(b)); err == nil {
+			changed := false
+			for k := range vals {
+				if s.form[k] {
+					vals.Set(k, Redacted)
+					changed = true
+				}
+			}
+			if changed {
+				b = []byte(vals.Encode())
+			}
+		}
+	}
+	if len(s.replacements) == 0 {
+		return b
+	}
+	return []byte(s.replace(string(b)))
+}
+
+// matchQuery is the canonical query used for matching: volatile
+// parameters dropped, the rest sorted by url.Values.Encode.
+func (s *scrubber) matchQuery(u *url.URL) string {
+	q := u.Query()
+	for k := range q {
+		if s.ignore[k] {
+			q.Del(k)
+		}
+	}
+	return q.Encode()
+}
+
+// matchBody canonicalises form bodies (field order and %20-vs-+ encoding
+// are not significant) and leaves other bodies as-is.
+func matchBody(contentType string, b []byte) string {
+	if isForm(contentType) {
+		if vals, err := url.ParseQuery(string(b)); err == nil {
+			return vals.Encode()
+		}
+	}
+	return string(b)
+}
+
+func isForm(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mt == "application/x-www-form-urlencoded"
+}
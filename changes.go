@@ -0,0 +1,275 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// EventType names a change a ChangeDetector reports.
+type EventType string
+
+const (
+	// EventInvoicePaid: an invoice became fully settled.
+	EventInvoicePaid EventType = "invoice.paid"
+	// EventInvoicePartiallyPaid: more of an invoice was settled, but not
+	// all of it.
+	EventInvoicePartiallyPaid EventType = "invoice.partially_paid"
+	// EventInvoiceCredited: a credit note was booked. Event.Invoice is the
+	// credit note; the invoice it credits reports its own settlement.
+	EventInvoiceCredited EventType = "invoice.credited"
+	// EventPaymentAdded: a payment was booked.
+	EventPaymentAdded EventType = "payment.added"
+	// EventPaymentDeleted: a payment was deleted. Event.Payment is the last
+	// version seen, or nil when the detector never saw it.
+	EventPaymentDeleted EventType = "payment.deleted"
+	// EventPrepaymentConsumed: part of a prepayment was applied to
+	// invoices.
+	EventPrepaymentConsumed EventType = "prepayment.consumed"
+)
+
+// Event is one change found by a ChangeDetector. Exactly one of Invoice,
+// Payment and Prepayment is set, except for a PaymentDeleted of a payment
+// the detector never saw.
+type Event struct {
+	// ID identifies the change: the same change seen again, e.g. after a
+	// restart, gets the same ID, so receivers can drop duplicates.
+	ID     string    `json:"id"`
+	Type   EventType `json:"type"`
+	Tenant string    `json:"tenant"`
+	// At is when the change was detected, not when it was booked.
+	At time.Time `json:"at"`
+	// Amount is how much changed: settled since the previous snapshot for
+	// invoices, the credit note's total for credits, the payment amount,
+	// or the amount of a prepayment consumed.
+	Amount decimal.Decimal `json:"amount"`
+	// RecordID is the provider ID of the record the event is about.
+	RecordID   string      `json:"record_id"`
+	Invoice    *Invoice    `json:"invoice,omitempty"`
+	Payment    *Payment    `json:"payment,omitempty"`
+	Prepayment *Prepayment `json:"prepayment,omitempty"`
+}
+
+// Notifier delivers events. Notify returns an error when the event may not
+// have been delivered, and the ChangeDetector reports it again next time.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, e Event) error
+
+func (f NotifierFunc) Notify(ctx context.Context, e Event) error { return f(ctx, e) }
+
+// Snapshot is what one sync of a tenant returned: the records changed
+// since the previous sync and the ones deleted. Records absent from a
+// snapshot are taken as unchanged.
+type Snapshot struct {
+	Invoices    []Invoice
+	Payments    []Payment
+	Prepayments []Prepayment
+	Deleted     []Tombstone
+}
+
+// ChangeDetector compares each tenant's snapshots with the versions of the
+// same records it saw before and reports the differences that matter to
+// the business — an invoice paid, a payment deleted — to a Notifier. It
+// fits a SyncEngine handler:
+//
+//	detector := accounting.NewChangeDetector(webhook)
+//	err = engine.SyncInvoices(ctx, "club-42", client, func(ctx context.Context, c accounting.InvoiceChanges) error {
+//	    return detector.Observe(ctx, "club-42", accounting.Snapshot{Invoices: c.Invoices, Deleted: c.Deleted})
+//	})
+//
+// A record's new version is kept only once its events are delivered, so a
+// failed Observe, retried with the same snapshot, reports them again:
+// delivery is at least once. Seen versions live in memory; a restarted
+// process should Seed the detector with the records it already knows, or
+// the next snapshot reports every paid invoice and payment in it as new.
+// It is safe for concurrent use.
+type ChangeDetector struct {
+	notifier Notifier
+	now      func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]*seenRecords
+}
+
+// seenRecords is the last version of each record of a tenant, keyed by
+// provider ID.
+type seenRecords struct {
+	mu          sync.Mutex
+	invoices    map[string]Invoice
+	payments    map[string]Payment
+	prepayments map[string]Prepayment
+}
+
+// NewChangeDetector returns a ChangeDetector that reports to n.
+func NewChangeDetector(n Notifier) *ChangeDetector {
+	return &ChangeDetector{notifier: n, now: time.Now, tenants: make(map[string]*seenRecords)}
+}
+
+func (d *ChangeDetector) tenant(tenant string) *seenRecords {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.tenants[tenant]
+	if !ok {
+		s = &seenRecords{
+			invoices:    make(map[string]Invoice),
+			payments:    make(map[string]Payment),
+			prepayments: make(map[string]Prepayment),
+		}
+		d.tenants[tenant] = s
+	}
+	return s
+}
+
+// Seed records the versions in s as seen without reporting anything.
+func (d *ChangeDetector) Seed(tenant string, s Snapshot) {
+	seen := d.tenant(tenant)
+	seen.mu.Lock()
+	defer seen.mu.Unlock()
+	seen.apply(s)
+}
+
+// Observe reports the changes between s and the versions seen before,
+// then records s. A record never seen is compared with an unpaid, unused
+// one: an invoice first seen paid is reported paid. Observe stops at the
+// first event the Notifier fails to deliver and returns its error; the
+// records before it are recorded, that one and the rest are not.
+func (d *ChangeDetector) Observe(ctx context.Context, tenant string, s Snapshot) error {
+	seen := d.tenant(tenant)
+	seen.mu.Lock()
+	defer seen.mu.Unlock()
+	at := d.now()
+
+	for _, inv := range s.Invoices {
+		prev, ok := seen.invoices[inv.ID]
+		if !ok {
+			prev = Invoice{Status: InvoiceStatusUnpaid}
+		}
+		if err := d.notify(ctx, invoiceEvents(tenant, at, prev, inv, ok)); err != nil {
+			return err
+		}
+		seen.invoices[inv.ID] = inv
+	}
+	for _, p := range s.Payments {
+		if _, ok := seen.payments[p.ID]; !ok {
+			e := Event{Type: EventPaymentAdded, Tenant: tenant, At: at, Amount: p.Amount, RecordID: p.ID, Payment: &p}
+			if err := d.notify(ctx, []Event{e}); err != nil {
+				return err
+			}
+		}
+		seen.payments[p.ID] = p
+	}
+	for _, p := range s.Prepayments {
+		key := prepaymentKey(p)
+		prev, ok := seen.prepayments[key]
+		if !ok {
+			prev = Prepayment{Remaining: p.Amount}
+		}
+		if consumed := prev.Remaining.Sub(p.Remaining); consumed.IsPositive() {
+			e := Event{Type: EventPrepaymentConsumed, Tenant: tenant, At: at, Amount: consumed, RecordID: key, Prepayment: &p}
+			if err := d.notify(ctx, []Event{e}); err != nil {
+				return err
+			}
+		}
+		seen.prepayments[key] = p
+	}
+	for _, t := range s.Deleted {
+		switch t.Entity {
+		case SyncInvoices:
+			delete(seen.invoices, t.ID)
+		case SyncPayments:
+			e := Event{Type: EventPaymentDeleted, Tenant: tenant, At: at, RecordID: t.ID}
+			if p, ok := seen.payments[t.ID]; ok {
+				e.Amount, e.Payment = p.Amount, &p
+			}
+			if err := d.notify(ctx, []Event{e}); err != nil {
+				return err
+			}
+			delete(seen.payments, t.ID)
+		}
+	}
+	return nil
+}
+
+// apply records the versions in s. seen.mu is held.
+func (seen *seenRecords) apply(s Snapshot) {
+	for _, inv := range s.Invoices {
+		seen.invoices[inv.ID] = inv
+	}
+	for _, p := range s.Payments {
+		seen.payments[p.ID] = p
+	}
+	for _, p := range s.Prepayments {
+		seen.prepayments[prepaymentKey(p)] = p
+	}
+	for _, t := range s.Deleted {
+		switch t.Entity {
+		case SyncInvoices:
+			delete(seen.invoices, t.ID)
+		case SyncPayments:
+			delete(seen.payments, t.ID)
+		}
+	}
+}
+
+// invoiceEvents returns the events of an invoice going from prev to cur.
+// known is false for an invoice seen for the first time.
+func invoiceEvents(tenant string, at time.Time, prev, cur Invoice, known bool) []Event {
+	e := Event{Tenant: tenant, At: at, RecordID: cur.ID, Invoice: &cur}
+	if cur.TotalAmount.IsNegative() {
+		if known {
+			return nil
+		}
+		e.Type, e.Amount = EventInvoiceCredited, cur.TotalAmount.Neg()
+		return []Event{e}
+	}
+	e.Amount = cur.PaidAmount.Sub(prev.PaidAmount)
+	switch {
+	case cur.Status == InvoiceStatusPaid && prev.Status != InvoiceStatusPaid:
+		e.Type = EventInvoicePaid
+	case cur.Status == InvoiceStatusPartial && e.Amount.IsPositive():
+		e.Type = EventInvoicePartiallyPaid
+	default:
+		return nil
+	}
+	return []Event{e}
+}
+
+// prepaymentKey identifies a prepayment: by DocID when the provider has
+// one, since Merit numbers need not be unique.
+func prepaymentKey(p Prepayment) string {
+	if p.DocID != "" {
+		return p.DocID
+	}
+	return p.Number
+}
+
+// notify delivers events in order, giving each its ID.
+func (d *ChangeDetector) notify(ctx context.Context, events []Event) error {
+	for _, e := range events {
+		e.ID = eventID(e)
+		if err := d.notifier.Notify(ctx, e); err != nil {
+			return fmt.Errorf("accounting: notify %s %s: %w", e.Type, e.RecordID, err)
+		}
+	}
+	return nil
+}
+
+// eventID derives an event's ID from what changed, so that seeing the
+// same change twice gives the same ID.
+func eventID(e Event) string {
+	id := e.Tenant + "/" + string(e.Type) + "/" + e.RecordID
+	switch {
+	case e.Invoice != nil && e.Type != EventInvoiceCredited:
+		id += "/" + e.Invoice.PaidAmount.String()
+	case e.Prepayment != nil:
+		id += "/" + e.Prepayment.Remaining.String()
+	}
+	return id
+}
@@ -0,0 +1,105 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// recorder is a Notifier that keeps what it is given and fails while err
+// is set.
+type recorder struct {
+	events []Event
+	err    error
+}
+
+func (r *recorder) Notify(_ context.Context, e Event) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recorder) types() []string {
+	out := make([]string, len(r.events))
+	for i, e := range r.events {
+		out[i] = string(e.Type) + " " + e.RecordID + " " + e.Amount.String()
+	}
+	r.events = nil
+	return out
+}
+
+func TestChangeDetector(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	d := NewChangeDetector(rec)
+	dec := decimal.RequireFromString
+	invoice := func(paid string, status InvoiceStatus) Invoice {
+		return Invoice{ID: "inv-1", Number: "1", TotalAmount: dec("100"), PaidAmount: dec(paid), Status: status}
+	}
+	pay := Payment{ID: "pay-1", Amount: dec("40")}
+	pre := Prepayment{Number: "PP-1", Amount: dec("50"), Remaining: dec("50")}
+	d.Seed("club-1", Snapshot{Invoices: []Invoice{invoice("0", InvoiceStatusUnpaid)}, Prepayments: []Prepayment{pre}})
+
+	steps := []struct {
+		name string
+		snap Snapshot
+		want []string
+	}{
+		{"seeded records unchanged", Snapshot{Invoices: []Invoice{invoice("0", InvoiceStatusUnpaid)}, Prepayments: []Prepayment{pre}}, []string{}},
+		{"partial payment", Snapshot{Invoices: []Invoice{invoice("40", InvoiceStatusPartial)}, Payments: []Payment{pay}},
+			[]string{"invoice.partially_paid inv-1 40", "payment.added pay-1 40"}},
+		{"overlap repeats the same versions", Snapshot{Invoices: []Invoice{invoice("40", InvoiceStatusPartial)}, Payments: []Payment{pay}}, []string{}},
+		{"credit note settles the rest", Snapshot{Invoices: []Invoice{
+			invoice("100", InvoiceStatusPaid),
+			{ID: "inv-2", Number: "2", TotalAmount: dec("-60"), Status: InvoiceStatusUnpaid},
+		}}, []string{"invoice.paid inv-1 60", "invoice.credited inv-2 60"}},
+		{"prepayment applied", Snapshot{Prepayments: []Prepayment{{Number: "PP-1", Amount: dec("50"), Remaining: dec("20")}}},
+			[]string{"prepayment.consumed PP-1 30"}},
+		{"payment deleted", Snapshot{
+			Invoices: []Invoice{invoice("60", InvoiceStatusPartial)},
+			Deleted:  []Tombstone{{Entity: SyncPayments, ID: "pay-1"}},
+		}, []string{"payment.deleted pay-1 40"}},
+		{"new invoice already paid", Snapshot{Invoices: []Invoice{{ID: "inv-3", TotalAmount: dec("10"), PaidAmount: dec("10"), Status: InvoiceStatusPaid}}},
+			[]string{"invoice.paid inv-3 10"}},
+	}
+	for _, step := range steps {
+		if err := d.Observe(ctx, "club-1", step.snap); err != nil {
+			t.Fatalf("%s: Observe: %v", step.name, err)
+		}
+		if got := rec.types(); !slices.Equal(got, step.want) {
+			t.Errorf("%s: events = %q, want %q", step.name, got, step.want)
+		}
+	}
+
+	// Events of other tenants are their own.
+	if err := d.Observe(ctx, "club-2", Snapshot{Payments: []Payment{pay}}); err != nil {
+		t.Fatalf("Observe: %v", err)
+	}
+	if got, want := rec.types(), []string{"payment.added pay-1 40"}; !slices.Equal(got, want) {
+		t.Errorf("other tenant: events = %q, want %q", got, want)
+	}
+}
+
+func TestChangeDetector_FailedDeliveryRepeats(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{err: errors.New("down")}
+	d := NewChangeDetector(rec)
+	snap := Snapshot{Payments: []Payment{{ID: "pay-1", Amount: decimal.NewFromInt(5)}}}
+	if err := d.Observe(ctx, "club-1", snap); !errors.Is(err, rec.err) {
+		t.Fatalf("Observe with a failing notifier: err = %v, want %v", err, rec.err)
+	}
+	rec.err = nil
+	for range 2 {
+		if err := d.Observe(ctx, "club-1", snap); err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+	}
+	if len(rec.events) != 1 || rec.events[0].ID != "club-1/payment.added/pay-1" {
+		t.Errorf("events = %+v, want one payment.added", rec.events)
+	}
+}
@@ -0,0 +1,133 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncEntity names a kind of record a SyncEngine keeps in step.
+type SyncEntity string
+
+const (
+	SyncInvoices  SyncEntity = "invoices"
+	SyncPayments  SyncEntity = "payments"
+	SyncCustomers SyncEntity = "customers"
+	SyncItems     SyncEntity = "items"
+)
+
+// Checkpoint is where the sync of one tenant's entity left off.
+type Checkpoint struct {
+	// Since is the time the next sync lists changes from, before the
+	// safety overlap is subtracted.
+	Since time.Time `json:"since,omitzero"`
+	// Cursor is the provider's own change cursor, for providers that
+	// number their changes. When set it is used instead of Since.
+	Cursor string `json:"cursor,omitempty"`
+	// Saved is when the checkpoint was last saved.
+	Saved time.Time `json:"saved,omitzero"`
+}
+
+// IsZero reports whether c is the checkpoint of a tenant never synced.
+func (c Checkpoint) IsZero() bool {
+	return c.Since.IsZero() && c.Cursor == ""
+}
+
+// CheckpointStore persists sync checkpoints, keyed by tenant and entity.
+// Implementations must be safe for concurrent use.
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or a zero Checkpoint when there
+	// is none.
+	Load(ctx context.Context, tenant string, entity SyncEntity) (Checkpoint, error)
+	Save(ctx context.Context, tenant string, entity SyncEntity, cp Checkpoint) error
+}
+
+// FileCheckpointStore keeps checkpoints in a JSON file. Every Save
+// rewrites the file through a temporary file and a rename, so a crash
+// leaves either the old or the new checkpoints on disk, never a torn
+// file. One process should own the file.
+type FileCheckpointStore struct {
+	path string
+
+	mu          sync.Mutex
+	checkpoints map[string]map[SyncEntity]Checkpoint
+}
+
+// NewFileCheckpointStore opens the checkpoint file at path, which need not
+// exist yet; its directory must.
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	s := &FileCheckpointStore{path: path, checkpoints: map[string]map[SyncEntity]Checkpoint{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("accounting: checkpoints: %w", err)
+	}
+	if err := json.Unmarshal(b, &s.checkpoints); err != nil {
+		return nil, fmt.Errorf("accounting: checkpoints: %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *FileCheckpointStore) Load(_ context.Context, tenant string, entity SyncEntity) (Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoints[tenant][entity], nil
+}
+
+func (s *FileCheckpointStore) Save(_ context.Context, tenant string, entity SyncEntity, cp Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, had := s.checkpoints[tenant][entity]
+	if s.checkpoints[tenant] == nil {
+		s.checkpoints[tenant] = map[SyncEntity]Checkpoint{}
+	}
+	s.checkpoints[tenant][entity] = cp
+	if err := s.write(); err != nil {
+		if had {
+			s.checkpoints[tenant][entity] = prev
+		} else {
+			delete(s.checkpoints[tenant], entity)
+		}
+		return fmt.Errorf("accounting: checkpoints: %w", err)
+	}
+	return nil
+}
+
+// write replaces the file with the current checkpoints. s.mu is held.
+func (s *FileCheckpointStore) write() error {
+	b, err := json.MarshalIndent(s.checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, b)
+}
+
+// writeFileAtomic replaces the file at path with b through a temporary
+// file in the same directory and a rename.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
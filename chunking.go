@@ -0,0 +1,110 @@
+package accounting
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// windowConcurrency bounds how many windows of a split date range are
+// fetched at once.
+const windowConcurrency = 4
+
+// listWindows lists the days from start to end, both inclusive, in
+// windows of months months each, so that no request exceeds a provider's
+// period limit. Windows are fetched concurrently, at most
+// windowConcurrency at a time, and their records are concatenated in
+// window order; a record whose key an earlier window already returned is
+// dropped. The first error cancels the windows still in flight and is
+// returned. Without a start there is no range to split, and list is
+// called once.
+func listWindows[T any](ctx context.Context, start, end time.Time, months int, list func(ctx context.Context, start, end time.Time) ([]T, error), key func(*T) string) ([]T, error) {
+	if start.IsZero() {
+		return list(ctx, start, end)
+	}
+	type window struct{ start, end time.Time }
+	var windows []window
+	for from, to := range dateWindows(start, end, months) {
+		windows = append(windows, window{from, to})
+	}
+	if len(windows) == 1 {
+		return list(ctx, windows[0].start, windows[0].end)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, windowConcurrency)
+		results  = make([][]T, len(windows))
+	)
+	for i, w := range windows {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			page, err := list(ctx, w.start, w.end)
+			if err != nil {
+				once.Do(func() { firstErr = err; cancel() })
+				return
+			}
+			results[i] = page
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	all := []T{}
+	for _, page := range results {
+		all = append(all, page...)
+	}
+	return dedupe(all, key), nil
+}
+
+// dedupe keeps the first record of each key, reusing the array of list.
+// Records with an empty key are always kept.
+func dedupe[T any](list []T, key func(*T) string) []T {
+	seen := make(map[string]bool, len(list))
+	return filterList(list, func(v *T) bool {
+		k := key(v)
+		if k == "" {
+			return true
+		}
+		if seen[k] {
+			return false
+		}
+		seen[k] = true
+		return true
+	})
+}
+
+func invoiceKey(inv *Invoice) string {
+	if inv.ID != "" {
+		return inv.ID
+	}
+	return inv.Number
+}
+
+func paymentKey(p *Payment) string {
+	if p.ID != "" {
+		return p.ID
+	}
+	return p.DocumentNo
+}
+
+func purchaseKey(p *PurchaseInvoice) string { return p.ID }
@@ -0,0 +1,160 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/directo/directotest"
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+)
+
+func TestListWindows(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	var inFlight, peak atomic.Int32
+	list := func(_ context.Context, from, to time.Time) ([]string, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		// Each window also returns the first record of the next one, as
+		// a record changed on a boundary can.
+		return []string{from.Format("01"), to.AddDate(0, 0, 1).Format("01")}, nil
+	}
+	got, err := listWindows(ctx, start, end, 1, list, func(s *string) string { return *s })
+	if err != nil {
+		t.Fatalf("listWindows: %v", err)
+	}
+	want := []string{"01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "11", "12"}
+	if !slices.Equal(got, want) {
+		t.Errorf("listWindows = %v, want %v", got, want)
+	}
+	if p := peak.Load(); p > windowConcurrency || p < 2 {
+		t.Errorf("peak concurrency = %d, want between 2 and %d", p, windowConcurrency)
+	}
+
+	boom := errors.New("boom")
+	failing := func(_ context.Context, from, _ time.Time) ([]string, error) {
+		if from.Month() == 2 {
+			return nil, boom
+		}
+		return []string{from.Format("01")}, nil
+	}
+	if got, err := listWindows(ctx, start, end, 1, failing, func(s *string) string { return *s }); !errors.Is(err, boom) || got != nil {
+		t.Errorf("failing window: got %v, %v, want nil, %v", got, err, boom)
+	}
+}
+
+func TestListWindows_MeritYear(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "merit",
+		APIID:    mc.APIID,
+		APIKey:   mc.APIKey,
+		Merit:    &MeritOptions{APIURL: mc.APIURL},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	start := time.Date(e2eDocDate.Year()-1, e2eDocDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, -1)
+	var want []string
+	for i := range 12 {
+		in := e2eInvoice(cust.ID, cust.Name, fmt.Sprintf("Y-%02d", i+1), srv.TaxID(22))
+		in.DocDate = start.AddDate(0, i, 9)
+		in.DueDate = in.DocDate.AddDate(0, 0, 14)
+		if _, err := client.Invoices.Create(ctx, in); err != nil {
+			t.Fatalf("Invoices.Create: %v", err)
+		}
+		want = append(want, in.InvoiceNo)
+	}
+
+	before := countRequests(srv.Requests(), "v2/getinvoices")
+	list, err := client.Invoices.List(ctx, ListInvoicesInput{PeriodStart: start, PeriodEnd: end})
+	if err != nil {
+		t.Fatalf("Invoices.List over a year: %v", err)
+	}
+	var got []string
+	for _, inv := range list {
+		got = append(got, inv.Number)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Invoices.List = %v, want %v", got, want)
+	}
+	if n := countRequests(srv.Requests(), "v2/getinvoices") - before; n != 4 {
+		t.Errorf("Invoices.List made %d requests, want one per quarter (4)", n)
+	}
+
+	// All the invoices changed today; a year-long sync window ending
+	// today finds each once.
+	today := time.Now()
+	changed, err := client.Sync.PullInvoiceStatuses(ctx, today.AddDate(-1, 0, 0), today)
+	if err != nil {
+		t.Fatalf("PullInvoiceStatuses over a year: %v", err)
+	}
+	if len(changed) != len(want) {
+		t.Errorf("PullInvoiceStatuses returned %d invoices, want %d", len(changed), len(want))
+	}
+}
+
+func TestListSince_DirectoUntil(t *testing.T) {
+	ctx := context.Background()
+	srv := directotest.NewServer()
+	defer srv.Close()
+	clock := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv.Now = func() time.Time { return clock }
+	dc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "directo",
+		APIID:    dc.Company,
+		APIKey:   dc.Token,
+		Directo: &DirectoOptions{
+			RESTAPIKey:  dc.RestAPIKey,
+			RESTBaseURL: dc.RESTBaseURL,
+			XMLBaseURL:  dc.XMLBaseURL,
+		},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Code: "C1", Name: "Acme OÜ", Email: "acme@example.com"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	for i, no := range []string{"D-1", "D-2", "D-3"} {
+		clock = time.Date(2025, 3, 1+i, 12, 0, 0, 0, time.UTC)
+		if _, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, no, "1")); err != nil {
+			t.Fatalf("Invoices.Create(%s): %v", no, err)
+		}
+	}
+
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 3, 2, 12, 0, 0, 0, time.UTC)
+	list, err := client.Sync.PullInvoiceStatuses(ctx, since, until)
+	if err != nil {
+		t.Fatalf("PullInvoiceStatuses: %v", err)
+	}
+	var got []string
+	for _, inv := range list {
+		got = append(got, inv.Number)
+	}
+	slices.Sort(got)
+	if want := []string{"D-1", "D-2"}; !slices.Equal(got, want) {
+		t.Errorf("PullInvoiceStatuses up to %s = %v, want %v", until, got, want)
+	}
+}
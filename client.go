@@ -3,17 +3,53 @@ package accounting
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+
+	"github.com/qbitsoftware/accounting-service/redact"
+	"github.com/qbitsoftware/accounting-service/resilience"
 )
 
 // Config holds configuration for creating a new accounting Client.
 type Config struct {
-	Provider   string            // Provider name (e.g. "merit", "directo", "excellentbooks", "smartaccounts")
-	APIID      string            // API identifier (Merit: API ID, Directo: company code, SmartAccounts: public API key)
-	APIKey     string            // API secret key (Merit: API key, Directo: XML token, SmartAccounts: private/secret key)
-	Region     string            // Regional endpoint (Merit: "ee"/"pl"; SmartAccounts: optional host override)
-	HTTPClient *http.Client      // Optional HTTP client; defaults to http.DefaultClient
-	Extra      map[string]string // Provider-specific config (e.g. "rest_api_key" for Directo, "language" for SmartAccounts)
+	Provider   string             // Provider name (e.g. "merit", "directo", "excellentbooks", "smartaccounts")
+	APIID      string             // API identifier (Merit: API ID, Directo: company code, SmartAccounts: public API key)
+	APIKey     string             // API secret key (Merit: API key, Directo: XML token, SmartAccounts: private/secret key)
+	Region     string             // Regional endpoint (Merit: "ee"/"pl"; SmartAccounts: optional host override)
+	HTTPClient *http.Client       // Optional HTTP client; defaults to http.DefaultClient
+	Extra      map[string]string  // Provider-specific config by key; superseded by the typed options below, which win when both are set
+	Resilience *resilience.Policy // Optional retry/timeout/circuit-breaker policy; nil uses each provider client's defaults
+	Logger     *slog.Logger       // Optional logger; defaults to slog.Default(). Request/response bodies are logged at Debug only
+	Redaction  *redact.Policy     // Optional masking of PII and credentials in logged bodies/URLs; nil uses redact.Default()
+	Tenant     string             // Optional tenant label attached to Observer events
+	Observer   Observer           // Optional per-operation metrics/tracing hook; see Observer and Metrics
+
+	// Credentials, if set, supplies APIID and APIKey when the client is built
+	// and again after an auth failure; see CredentialSource.
+	Credentials CredentialSource
+
+	// Typed provider-specific settings; set the one matching Provider.
+	// Validate reports missing or malformed ones before any network call.
+	Merit          *MeritOptions
+	Directo        *DirectoOptions
+	ExcellentBooks *ExcellentBooksOptions
+	SmartAccounts  *SmartAccountsOptions
+
+	// Replica, if set, keeps a local copy of what the client reads and
+	// answers reads from it while the provider is rate limited or down;
+	// see Store. Requires Tenant, which keys the tenant's records.
+	Replica Store
+
+	// IdempotentCreates makes Invoices.Create, Payments.Create and
+	// Prepayments.Create return the document an earlier attempt with the
+	// same caller-assigned number created, instead of failing or posting
+	// twice. A document with the number but another customer, date or
+	// amount is reported as ErrDuplicate.
+	IdempotentCreates bool
+
+	// Middleware, if set, wraps the provider the services call, outside
+	// the Observer and Replica decorators; see Middleware and Chain.
+	Middleware Middleware
 }
 
 // Client is the main entry point for the accounting SDK.
@@ -33,37 +69,53 @@ type Client struct {
 }
 
 // NewClient creates a new Client for the configured accounting provider.
+// The provider is looked up by cfg.Provider among those registered with
+// RegisterProvider.
 func NewClient(cfg Config) (*Client, error) {
-	var p Provider
-	var err error
-	switch cfg.Provider {
-	case "merit":
-		p = newMeritProvider(cfg)
-	case "directo":
-		p, err = newDirectoProvider(cfg)
+	if cfg.Replica != nil && cfg.Tenant == "" {
+		return nil, &ConfigError{Field: "Tenant", Message: "required with Replica"}
+	}
+	if h, ok := cfg.Observer.(HTTPObserver); ok {
+		cfg.Resilience = withHTTPObserver(cfg.Resilience, cfg.Tenant, h)
+	}
+	if cfg.Credentials != nil {
+		creds, err := cfg.credentials(context.Background())
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("accounting: resolve %s credentials: %w", cfg.Provider, err)
+		}
+		cfg.APIID, cfg.APIKey = creds.APIID, creds.APIKey
+	}
+	p, err := newProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Credentials != nil {
+		p = refreshCredentials(p, cfg)
+	}
+	if cfg.Observer != nil {
+		p = observeProvider(p, cfg.Observer, cfg.Provider, cfg.Tenant)
+	}
+	if cfg.Replica != nil {
+		p = replicateProvider(p, cfg.Replica, cfg.Tenant, cfg.Logger)
+	}
+	if cfg.Middleware != nil {
+		if p = cfg.Middleware(p); p == nil {
+			return nil, &ConfigError{Field: "Middleware", Message: "returned a nil Provider"}
 		}
-	case "excellentbooks":
-		p = newExcellentProvider(cfg)
-	case "smartaccounts":
-		p = newSmartAccountsProvider(cfg)
-	default:
-		return nil, fmt.Errorf("%w: %s", ErrUnsupportedProvider, cfg.Provider)
 	}
 
 	c := &Client{
 		provider:     p,
 		providerName: cfg.Provider,
-		Invoices:     &InvoiceService{provider: p},
+		Invoices:     &InvoiceService{provider: p, idempotent: cfg.IdempotentCreates},
 		Customers:    &CustomerService{provider: p},
-		Payments:     &PaymentService{provider: p},
+		Payments:     &PaymentService{provider: p, idempotent: cfg.IdempotentCreates},
 		Items:        &ItemService{provider: p},
 		Purchases:    &PurchaseService{provider: p},
 		Taxes:        &TaxService{provider: p},
 		Reports:      &ReportService{provider: p},
 		Sync:         &SyncService{provider: p},
-		Prepayments:  &PrepaymentService{provider: p, providerName: cfg.Provider},
+		Prepayments:  &PrepaymentService{provider: p, providerName: cfg.Provider, idempotent: cfg.IdempotentCreates},
 	}
 	return c, nil
 }
@@ -73,6 +125,16 @@ func (c *Client) TestConnection(ctx context.Context) error {
 	return c.provider.TestConnection(ctx)
 }
 
+// CircuitState reports the circuit breaker state of the provider's HTTP
+// client. StateOpen means recent calls failed and new ones are rejected
+// with resilience.ErrCircuitOpen until the cooldown elapses.
+func (c *Client) CircuitState() resilience.State {
+	if cs, ok := unwrapProvider(c.provider).(interface{ CircuitState() resilience.State }); ok {
+		return cs.CircuitState()
+	}
+	return resilience.StateClosed
+}
+
 // Capabilities returns the feature set supported by the configured provider.
 func (c *Client) Capabilities() Capabilities {
 	return ProviderCapabilities(c.providerName)
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	accounting "github.com/qbitsoftware/accounting-service"
+)
+
+// fileConfig is the JSON configuration file.
+type fileConfig struct {
+	// StateDir holds checkpoints.json. Required.
+	StateDir string `json:"state_dir"`
+	// Interval between the syncs of a tenant, e.g. "5m". Default 5m.
+	Interval duration `json:"interval"`
+	// Entities to sync: "invoices", "payments", "customers". Default all
+	// three.
+	Entities []accounting.SyncEntity `json:"entities"`
+	// Output is where change records go; stdout when Dir is empty.
+	Output outputConfig `json:"output"`
+	// Lookback is how far back a tenant's first sync reaches. Default 30 days.
+	Lookback duration       `json:"lookback"`
+	Tenants  []tenantConfig `json:"tenants"`
+}
+
+type outputConfig struct {
+	// Dir receives changes.ndjson, rotated to changes-<time>.ndjson once it
+	// reaches MaxBytes.
+	Dir      string `json:"dir"`
+	MaxBytes int64  `json:"max_bytes"` // default 100 MiB
+}
+
+type tenantConfig struct {
+	Tenant   string            `json:"tenant"`
+	Provider string            `json:"provider"`
+	APIID    string            `json:"api_id"`
+	APIKey   string            `json:"api_key"`
+	Region   string            `json:"region"`
+	Extra    map[string]string `json:"extra"`
+}
+
+// duration is a time.Duration written as a string such as "90s".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+const (
+	defaultInterval = 5 * time.Minute
+	defaultMaxBytes = 100 << 20
+)
+
+var syncable = []accounting.SyncEntity{accounting.SyncInvoices, accounting.SyncPayments, accounting.SyncCustomers}
+
+// loadConfig reads the configuration at path. A tenant's api_id, api_key
+// or extra value that is wholly $VAR or ${VAR} is replaced by that
+// environment variable, so credentials need not be written into the
+// file; any other value, a literal $ included, is taken as written.
+func loadConfig(path string) (*fileConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg fileConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.StateDir == "" {
+		return nil, fmt.Errorf("%s: state_dir is required", path)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = duration(defaultInterval)
+	}
+	if len(cfg.Entities) == 0 {
+		cfg.Entities = syncable
+	}
+	for _, e := range cfg.Entities {
+		if !slices.Contains(syncable, e) {
+			return nil, fmt.Errorf("%s: entity %q: want one of %v", path, e, syncable)
+		}
+	}
+	if cfg.Output.MaxBytes <= 0 {
+		cfg.Output.MaxBytes = defaultMaxBytes
+	}
+	if len(cfg.Tenants) == 0 {
+		return nil, fmt.Errorf("%s: no tenants", path)
+	}
+	var errs []error
+	seen := map[string]bool{}
+	for i := range cfg.Tenants {
+		t := &cfg.Tenants[i]
+		if t.Tenant == "" || seen[t.Tenant] {
+			errs = append(errs, fmt.Errorf("tenant %q: names must be set and unique", t.Tenant))
+			continue
+		}
+		seen[t.Tenant] = true
+		if err := t.expandEnv(); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", t.Tenant, err))
+			continue
+		}
+		if err := t.client().Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", t.Tenant, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (t tenantConfig) client() accounting.Config {
+	return accounting.Config{
+		Provider: t.Provider,
+		APIID:    t.APIID,
+		APIKey:   t.APIKey,
+		Region:   t.Region,
+		Extra:    t.Extra,
+		Tenant:   t.Tenant,
+	}
+}
+
+// expandEnv replaces the credential fields that name an environment
+// variable with its value.
+func (t *tenantConfig) expandEnv() error {
+	var errs []error
+	expand := func(field string, v *string) {
+		name, ok := envRef(*v)
+		if !ok {
+			return
+		}
+		val, set := os.LookupEnv(name)
+		if !set {
+			errs = append(errs, fmt.Errorf("%s: environment variable %s is not set", field, name))
+			return
+		}
+		*v = val
+	}
+	expand("api_id", &t.APIID)
+	expand("api_key", &t.APIKey)
+	for k, v := range t.Extra {
+		expand("extra."+k, &v)
+		t.Extra[k] = v
+	}
+	return errors.Join(errs...)
+}
+
+// envRef returns the variable name of a value that is wholly $NAME or
+// ${NAME}.
+func envRef(v string) (string, bool) {
+	name, ok := strings.CutPrefix(v, "$")
+	if !ok {
+		return "", false
+	}
+	if inner, ok := strings.CutPrefix(name, "{"); ok {
+		if name, ok = strings.CutSuffix(inner, "}"); !ok {
+			return "", false
+		}
+	}
+	if name == "" {
+		return "", false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return "", false
+		}
+	}
+	return name, true
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "syncd.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	t.Setenv("SYNCD_TEST_KEY", `se"cret\`)
+	path := writeConfig(t, `{
+		"state_dir": "/tmp/state",
+		"tenants": [{"tenant": "club-42", "provider": "merit", "api_id": "id$1", "api_key": "${SYNCD_TEST_KEY}"}]
+	}`)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if time.Duration(cfg.Interval) != defaultInterval || cfg.Output.MaxBytes != defaultMaxBytes {
+		t.Errorf("interval %v, max_bytes %d; want the defaults", time.Duration(cfg.Interval), cfg.Output.MaxBytes)
+	}
+	if !slices.Equal(cfg.Entities, syncable) {
+		t.Errorf("entities = %v, want %v", cfg.Entities, syncable)
+	}
+	// A variable's value is used as is, quotes and backslashes included;
+	// a literal $ elsewhere is not expanded.
+	if tc := cfg.Tenants[0]; tc.APIKey != `se"cret\` || tc.APIID != "id$1" {
+		t.Errorf("credentials = %q, %q; want %q, %q", tc.APIID, tc.APIKey, "id$1", `se"cret\`)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"no state dir", `{"tenants": [{"tenant": "a", "provider": "merit", "api_id": "i", "api_key": "k"}]}`, "state_dir is required"},
+		{"no tenants", `{"state_dir": "s"}`, "no tenants"},
+		{"unknown entity", `{"state_dir": "s", "entities": ["items"], "tenants": [{"tenant": "a", "provider": "merit", "api_id": "i", "api_key": "k"}]}`, `entity "items"`},
+		{"duplicate tenant", `{"state_dir": "s", "tenants": [
+			{"tenant": "a", "provider": "merit", "api_id": "i", "api_key": "k"},
+			{"tenant": "a", "provider": "merit", "api_id": "i", "api_key": "k"}]}`, "unique"},
+		{"unset variable", `{"state_dir": "s", "tenants": [{"tenant": "a", "provider": "merit", "api_id": "i", "api_key": "$SYNCD_TEST_UNSET"}]}`, "SYNCD_TEST_UNSET is not set"},
+		{"missing credentials", `{"state_dir": "s", "tenants": [{"tenant": "a", "provider": "merit"}]}`, "tenant a"},
+		{"bad interval", `{"state_dir": "s", "interval": "often"}`, "invalid duration"},
+	} {
+		_, err := loadConfig(writeConfig(t, tc.body))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want one mentioning %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestEnvRef(t *testing.T) {
+	for v, want := range map[string]string{
+		"$KEY": "KEY", "${KEY_2}": "KEY_2",
+		"KEY": "", "$": "", "${KEY": "", "a$KEY": "", "$KEY-1": "", "$1KEY": "",
+	} {
+		if got, _ := envRef(v); got != want {
+			t.Errorf("envRef(%q) = %q, want %q", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// accounting-syncd polls the accounting providers of many tenants and
+// writes their invoice, payment and customer changes as NDJSON, one
+// change record per line, so that systems not written in Go can follow
+// them. It runs the SDK's SyncEngine: checkpoints are kept in the state
+// directory and advanced only once a batch is written, so a restart
+// resumes where the last run stopped and delivery is at least once.
+//
+// Usage:
+//
+//	accounting-syncd -config /etc/accounting-syncd.json [-once]
+//
+// The configuration file; a tenant's api_id, api_key or extra value
+// written as $VAR or ${VAR} is taken from the environment:
+//
+//	{
+//	  "state_dir": "/var/lib/accounting-syncd",
+//	  "interval": "5m",
+//	  "entities": ["invoices", "payments", "customers"],
+//	  "output": {"dir": "/var/spool/accounting", "max_bytes": 104857600},
+//	  "tenants": [
+//	    {"tenant": "club-42", "provider": "merit", "api_id": "${CLUB42_ID}", "api_key": "${CLUB42_KEY}"},
+//	    {"tenant": "club-43", "provider": "directo", "api_id": "ACME", "api_key": "${CLUB43_TOKEN}",
+//	     "extra": {"rest_api_key": "${CLUB43_REST_KEY}"}}
+//	  ]
+//	}
+//
+// Without output.dir the records go to stdout; logs always go to stderr.
+// A record is
+//
+//	{"tenant":"club-42","entity":"invoices","op":"upsert","id":"…","synced_at":"…","invoice":{…}}
+//	{"tenant":"club-42","entity":"payments","op":"delete","id":"…","synced_at":"…","deleted_at":"…"}
+//
+// with the SDK's Invoice, Payment or Customer under "invoice", "payment"
+// or "customer". Consumers should treat an upsert as the record's latest
+// state: the same version may be written more than once.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	accounting "github.com/qbitsoftware/accounting-service"
+)
+
+// record is one line of output.
+type record struct {
+	Tenant    string                `json:"tenant"`
+	Entity    accounting.SyncEntity `json:"entity"`
+	Op        string                `json:"op"` // "upsert" or "delete"
+	ID        string                `json:"id"`
+	SyncedAt  time.Time             `json:"synced_at"`
+	DeletedAt time.Time             `json:"deleted_at,omitzero"`
+	Invoice   *accounting.Invoice   `json:"invoice,omitempty"`
+	Payment   *accounting.Payment   `json:"payment,omitempty"`
+	Customer  *accounting.Customer  `json:"customer,omitempty"`
+}
+
+func main() {
+	configPath := flag.String("config", "", "path to the JSON configuration file (required)")
+	once := flag.Bool("once", false, "sync every tenant once and exit")
+	flag.Parse()
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	if *configPath == "" {
+		fmt.Fprintln(os.Stderr, "-config is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, cfg, *once, logger); err != nil {
+		logger.Error("accounting-syncd stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg *fileConfig, once bool, logger *slog.Logger) error {
+	if err := os.MkdirAll(cfg.StateDir, 0o755); err != nil {
+		return err
+	}
+	checkpoints, err := accounting.NewFileCheckpointStore(filepath.Join(cfg.StateDir, "checkpoints.json"))
+	if err != nil {
+		return err
+	}
+	engine, err := accounting.NewSyncEngine(accounting.SyncEngineConfig{Store: checkpoints, Lookback: time.Duration(cfg.Lookback)})
+	if err != nil {
+		return err
+	}
+	var out output = &stdout{}
+	if cfg.Output.Dir != "" {
+		if out, err = openRotatingFile(cfg.Output.Dir, cfg.Output.MaxBytes); err != nil {
+			return err
+		}
+	}
+	defer out.Close()
+
+	tenants := make(map[string]tenantConfig, len(cfg.Tenants))
+	for _, t := range cfg.Tenants {
+		tenants[t.Tenant] = t
+	}
+	pool, err := accounting.NewClientPool(accounting.PoolConfig{
+		Load: func(_ context.Context, tenant string) (accounting.Config, error) {
+			c := tenants[tenant].client()
+			c.Logger = logger
+			return c, nil
+		},
+		IdleTimeout: -1,
+	})
+	if err != nil {
+		return err
+	}
+
+	s := &syncer{engine: engine, pool: pool, out: out, entities: cfg.Entities, logger: logger}
+	var wg sync.WaitGroup
+	var failed sync.Map
+	for _, t := range cfg.Tenants {
+		wg.Go(func() {
+			if once {
+				if !s.syncTenant(ctx, t.Tenant) {
+					failed.Store(t.Tenant, true)
+				}
+				return
+			}
+			s.poll(ctx, t.Tenant, time.Duration(cfg.Interval))
+		})
+	}
+	wg.Wait()
+	var errs []error
+	failed.Range(func(k, _ any) bool {
+		errs = append(errs, fmt.Errorf("tenant %s failed to sync", k))
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+type syncer struct {
+	engine   *accounting.SyncEngine
+	pool     *accounting.ClientPool
+	out      output
+	entities []accounting.SyncEntity
+	logger   *slog.Logger
+}
+
+// poll syncs tenant every interval until ctx is done.
+func (s *syncer) poll(ctx context.Context, tenant string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.syncTenant(ctx, tenant)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncTenant syncs each entity of tenant once, logging failures, and
+// reports whether all succeeded.
+func (s *syncer) syncTenant(ctx context.Context, tenant string) bool {
+	client, err := s.pool.Get(ctx, tenant)
+	if err != nil {
+		s.logger.Error("client", "tenant", tenant, "error", err)
+		return false
+	}
+	ok := true
+	for _, entity := range s.entities {
+		if ctx.Err() != nil {
+			return false
+		}
+		start := time.Now()
+		n, err := s.syncEntity(ctx, tenant, entity, client)
+		if err != nil {
+			ok = false
+			s.logger.Error("sync failed", "tenant", tenant, "entity", entity, "error", err)
+			continue
+		}
+		s.logger.Info("synced", "tenant", tenant, "entity", entity, "records", n, "duration", time.Since(start))
+	}
+	return ok
+}
+
+func (s *syncer) syncEntity(ctx context.Context, tenant string, entity accounting.SyncEntity, client *accounting.Client) (int, error) {
+	var n int
+	emit := func(records []record) error {
+		n += len(records)
+		return s.emit(records)
+	}
+	switch entity {
+	case accounting.SyncInvoices:
+		return n, s.engine.SyncInvoices(ctx, tenant, client, func(_ context.Context, c accounting.InvoiceChanges) error {
+			records := make([]record, 0, len(c.Invoices)+len(c.Deleted))
+			for _, inv := range c.Invoices {
+				records = append(records, record{Tenant: tenant, Entity: entity, Op: "upsert", ID: inv.ID, Invoice: &inv})
+			}
+			return emit(append(records, tombstones(tenant, c.Deleted)...))
+		})
+	case accounting.SyncPayments:
+		return n, s.engine.SyncPayments(ctx, tenant, client, func(_ context.Context, c accounting.PaymentChanges) error {
+			records := make([]record, 0, len(c.Payments)+len(c.Deleted))
+			for _, p := range c.Payments {
+				records = append(records, record{Tenant: tenant, Entity: entity, Op: "upsert", ID: p.ID, Payment: &p})
+			}
+			return emit(append(records, tombstones(tenant, c.Deleted)...))
+		})
+	case accounting.SyncCustomers:
+		return n, s.engine.SyncCustomers(ctx, tenant, client, func(_ context.Context, c accounting.CustomerChanges) error {
+			records := make([]record, 0, len(c.Customers))
+			for _, cust := range c.Customers {
+				records = append(records, record{Tenant: tenant, Entity: entity, Op: "upsert", ID: cust.ID, Customer: &cust})
+			}
+			return emit(records)
+		})
+	}
+	return 0, fmt.Errorf("cannot sync %s", entity)
+}
+
+func tombstones(tenant string, deleted []accounting.Tombstone) []record {
+	records := make([]record, len(deleted))
+	for i, t := range deleted {
+		records[i] = record{Tenant: tenant, Entity: t.Entity, Op: "delete", ID: t.ID, DeletedAt: t.DeletedAt}
+	}
+	return records
+}
+
+// emit writes records as one batch.
+func (s *syncer) emit(records []record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	now := time.Now().UTC()
+	for _, r := range records {
+		r.SyncedAt = now
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	if err := s.out.write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
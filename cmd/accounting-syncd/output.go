@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// output receives batches of NDJSON lines. A batch is written whole, and
+// write returns only once it is on disk (or handed to stdout), so a
+// checkpoint advanced after it never runs ahead of the output.
+type output interface {
+	write(batch []byte) error
+	Close() error
+}
+
+// stdout writes to standard output.
+type stdout struct {
+	mu sync.Mutex
+}
+
+func (o *stdout) write(batch []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := os.Stdout.Write(batch); err != nil {
+		return err
+	}
+	// Pipes and terminals cannot be synced.
+	if err := os.Stdout.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+		return err
+	}
+	return nil
+}
+
+func (o *stdout) Close() error { return nil }
+
+// rotatingFile appends to dir/changes.ndjson and, when a batch would take
+// it past maxBytes, renames it to changes-<UTC time>.ndjson and starts a
+// new one.
+type rotatingFile struct {
+	dir      string
+	maxBytes int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(dir string, maxBytes int64) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{dir: dir, maxBytes: maxBytes}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) path() string { return filepath.Join(r.dir, "changes.ndjson") }
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) write(batch []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 && r.size+int64(len(batch)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+	n, err := r.f.Write(batch)
+	r.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return r.f.Sync()
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	stamp := "changes-" + time.Now().UTC().Format("20060102T150405.000")
+	name := filepath.Join(r.dir, stamp+".ndjson")
+	// Two rotations within a millisecond must not overwrite each other.
+	for i := 1; fileExists(name); i++ {
+		name = filepath.Join(r.dir, fmt.Sprintf("%s-%d.ndjson", stamp, i))
+	}
+	if err := os.Rename(r.path(), name); err != nil {
+		// Keep appending to the current file rather than stopping.
+		return errors.Join(err, r.open())
+	}
+	return r.open()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+var (
+	_ output = (*stdout)(nil)
+	_ output = (*rotatingFile)(nil)
+)
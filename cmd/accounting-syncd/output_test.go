@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	r, err := openRotatingFile(dir, 10)
+	if err != nil {
+		t.Fatalf("openRotatingFile: %v", err)
+	}
+	// The first batch goes in whatever its size; each later one that would
+	// pass max_bytes starts a new file, even within one millisecond.
+	for _, batch := range []string{"aaaa\n", "bbbb\n", "cccccccccccc\n", "dd\n"} {
+		if err := r.write([]byte(batch)); err != nil {
+			t.Fatalf("write %q: %v", batch, err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rotated, contents []string
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(b))
+		if e.Name() != "changes.ndjson" {
+			rotated = append(rotated, e.Name())
+		}
+	}
+	if len(rotated) != 2 {
+		t.Errorf("rotated files = %v, want 2", rotated)
+	}
+	for _, name := range rotated {
+		if !strings.HasPrefix(name, "changes-") || !strings.HasSuffix(name, ".ndjson") {
+			t.Errorf("rotated file %q, want changes-<time>.ndjson", name)
+		}
+	}
+	slices.Sort(contents)
+	if want := []string{"aaaa\nbbbb\n", "cccccccccccc\n", "dd\n"}; !slices.Equal(contents, want) {
+		t.Errorf("file contents = %q, want %q", contents, want)
+	}
+
+	// Reopening appends to the current file and counts what it holds.
+	r, err = openRotatingFile(dir, 10)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer r.Close()
+	if r.size != 3 {
+		t.Errorf("reopened size = %d, want 3", r.size)
+	}
+}
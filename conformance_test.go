@@ -0,0 +1,329 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"log/slog"
+	"testing"
+
+	"github.com/qbitsoftware/accounting-service/directo/directotest"
+	"github.com/qbitsoftware/accounting-service/excellentbooks/ebtest"
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/qbitsoftware/accounting-service/smartaccounts/satest"
+)
+
+// opCalls invokes every operation with throwaway input. The conformance
+// test only looks at whether the adapter reports the operation as not
+// supported, so most calls are expected to fail for other reasons.
+var opCalls = map[Op]func(ctx context.Context, p Provider) error{
+	OpTestConnection: func(ctx context.Context, p Provider) error { return p.TestConnection(ctx) },
+	OpCreateInvoice: func(ctx context.Context, p Provider) error {
+		_, err := p.CreateInvoice(ctx, e2eInvoice("C1", "Acme OÜ", "CONF-1", "1"))
+		return err
+	},
+	OpLocateInvoice: func(ctx context.Context, p Provider) error {
+		_, err := p.LocateInvoice(ctx, e2eInvoice("C1", "Acme OÜ", "CONF-1", "1"))
+		return err
+	},
+	OpGetInvoice: func(ctx context.Context, p Provider) error {
+		_, err := p.GetInvoice(ctx, "CONF-1")
+		return err
+	},
+	OpGetInvoicePDF: func(ctx context.Context, p Provider) error {
+		_, err := p.GetInvoicePDF(ctx, "CONF-1", false)
+		return err
+	},
+	OpListInvoices: func(ctx context.Context, p Provider) error {
+		_, err := p.ListInvoices(ctx, ListInvoicesInput{PeriodStart: e2eDocDate, PeriodEnd: e2eDueDate})
+		return err
+	},
+	OpInvoicePages: func(ctx context.Context, p Provider) error {
+		return pagesErr(p.InvoicePages(ctx, ListInvoicesInput{PeriodStart: e2eDocDate, PeriodEnd: e2eDueDate}))
+	},
+	OpFindInvoiceByRef: func(ctx context.Context, p Provider) error {
+		_, err := p.FindInvoiceByRef(ctx, "CONF-1")
+		return err
+	},
+	OpDeleteInvoice: func(ctx context.Context, p Provider) error { return p.DeleteInvoice(ctx, "CONF-1") },
+	OpCreateCustomer: func(ctx context.Context, p Provider) error {
+		_, err := p.CreateCustomer(ctx, CreateCustomerInput{Code: "C1", Name: "Acme OÜ", RegNo: "12345678"})
+		return err
+	},
+	OpUpdateCustomer: func(ctx context.Context, p Provider) error {
+		name := "Acme AS"
+		return p.UpdateCustomer(ctx, UpdateCustomerInput{ID: "C1", Name: &name})
+	},
+	OpListCustomers: func(ctx context.Context, p Provider) error {
+		_, err := p.ListCustomers(ctx, ListCustomersInput{})
+		return err
+	},
+	OpCustomerPages: func(ctx context.Context, p Provider) error {
+		return pagesErr(p.CustomerPages(ctx, ListCustomersInput{}))
+	},
+	OpFindCustomerByEmail: func(ctx context.Context, p Provider) error {
+		_, err := p.FindCustomerByEmail(ctx, "acme@example.com")
+		return err
+	},
+	OpGetCustomer: func(ctx context.Context, p Provider) error {
+		_, err := p.GetCustomer(ctx, "C1")
+		return err
+	},
+	OpCreatePayment: func(ctx context.Context, p Provider) error {
+		return p.CreatePayment(ctx, CreatePaymentInput{CustomerCode: "C1", CustomerName: "Acme OÜ", PaymentNo: "R-1", InvoiceNo: "CONF-1", PaymentDate: e2eDocDate, Amount: d("1"), BankID: "K"})
+	},
+	OpLocatePayment: func(ctx context.Context, p Provider) error {
+		_, err := p.LocatePayment(ctx, CreatePaymentInput{CustomerCode: "C1", CustomerName: "Acme OÜ", PaymentNo: "R-1", InvoiceNo: "CONF-1", PaymentDate: e2eDocDate, Amount: d("1"), BankID: "K"})
+		return err
+	},
+	OpListPayments: func(ctx context.Context, p Provider) error {
+		_, err := p.ListPayments(ctx, ListPaymentsInput{PeriodStart: e2eDocDate, PeriodEnd: e2eDueDate})
+		return err
+	},
+	OpPaymentPages: func(ctx context.Context, p Provider) error {
+		return pagesErr(p.PaymentPages(ctx, ListPaymentsInput{PeriodStart: e2eDocDate, PeriodEnd: e2eDueDate}))
+	},
+	OpDeletePayment: func(ctx context.Context, p Provider) error { return p.DeletePayment(ctx, "R-1") },
+	OpCreateItem: func(ctx context.Context, p Provider) error {
+		_, err := p.CreateItem(ctx, CreateItemInput{Code: "FEE", Description: "Membership fee"})
+		return err
+	},
+	OpListItems: func(ctx context.Context, p Provider) error {
+		_, err := p.ListItems(ctx, ListItemsInput{})
+		return err
+	},
+	OpItemPages: func(ctx context.Context, p Provider) error {
+		return pagesErr(p.ItemPages(ctx, ListItemsInput{}))
+	},
+	OpUpdateItem: func(ctx context.Context, p Provider) error {
+		description := "Membership fee"
+		return p.UpdateItem(ctx, UpdateItemInput{ID: "FEE", Description: &description})
+	},
+	OpCreateCreditNote: func(ctx context.Context, p Provider) error {
+		_, err := p.CreateCreditNote(ctx, CreateCreditNoteInput{CustomerID: "C1", CustomerName: "Acme OÜ", OriginalInvoiceNo: "CONF-1", DocDate: e2eDocDate})
+		return err
+	},
+	OpCreatePurchase: func(ctx context.Context, p Provider) error {
+		_, err := p.CreatePurchase(ctx, CreatePurchaseInput{VendorName: "Supplier OÜ", DocDate: e2eDocDate})
+		return err
+	},
+	OpGetPurchase: func(ctx context.Context, p Provider) error {
+		_, err := p.GetPurchase(ctx, "P-1")
+		return err
+	},
+	OpListPurchases: func(ctx context.Context, p Provider) error {
+		_, err := p.ListPurchases(ctx, ListPurchasesInput{PeriodStart: e2eDocDate, PeriodEnd: e2eDueDate})
+		return err
+	},
+	OpDeletePurchase: func(ctx context.Context, p Provider) error { return p.DeletePurchase(ctx, "P-1") },
+	OpListTaxes: func(ctx context.Context, p Provider) error {
+		_, err := p.ListTaxes(ctx)
+		return err
+	},
+	OpListAccounts: func(ctx context.Context, p Provider) error {
+		_, err := p.ListAccounts(ctx)
+		return err
+	},
+	OpListDimensions: func(ctx context.Context, p Provider) error {
+		_, err := p.ListDimensions(ctx)
+		return err
+	},
+	OpListBanks: func(ctx context.Context, p Provider) error {
+		_, err := p.ListBanks(ctx)
+		return err
+	},
+	OpListPaymentTerms: func(ctx context.Context, p Provider) error {
+		_, err := p.ListPaymentTerms(ctx)
+		return err
+	},
+	OpCustomerDebts: func(ctx context.Context, p Provider) error {
+		_, err := p.CustomerDebts(ctx, "Acme OÜ", nil)
+		return err
+	},
+	OpListInvoicesSince: func(ctx context.Context, p Provider) error {
+		_, err := p.ListInvoicesSince(ctx, e2eDocDate, e2eDueDate)
+		return err
+	},
+	OpListPaymentsSince: func(ctx context.Context, p Provider) error {
+		_, err := p.ListPaymentsSince(ctx, e2eDocDate, e2eDueDate)
+		return err
+	},
+	OpListInvoiceChanges: func(ctx context.Context, p Provider) error {
+		_, err := p.ListInvoiceChanges(ctx, e2eDocDate, e2eDueDate)
+		return err
+	},
+	OpListPaymentChanges: func(ctx context.Context, p Provider) error {
+		_, err := p.ListPaymentChanges(ctx, e2eDocDate, e2eDueDate)
+		return err
+	},
+	OpInvoiceChangesAfter: func(ctx context.Context, p Provider) error {
+		_, _, err := p.InvoiceChangesAfter(ctx, "", e2eDocDate)
+		return err
+	},
+	OpPaymentChangesAfter: func(ctx context.Context, p Provider) error {
+		_, _, err := p.PaymentChangesAfter(ctx, "", e2eDocDate)
+		return err
+	},
+	OpCustomerChangesAfter: func(ctx context.Context, p Provider) error {
+		_, _, err := p.CustomerChangesAfter(ctx, "", e2eDocDate)
+		return err
+	},
+	OpItemChangesAfter: func(ctx context.Context, p Provider) error {
+		_, _, err := p.ItemChangesAfter(ctx, "", e2eDocDate)
+		return err
+	},
+	OpCreatePrepayment: prepaymentCall(func(ctx context.Context, pp PrepaymentProvider) error {
+		_, err := pp.CreatePrepayment(ctx, CreatePrepaymentInput{CustomerCode: "C1", PrepaymentNo: "PP-1", Amount: d("10"), PaymentDate: e2eDocDate, BankID: "PANK"})
+		return err
+	}),
+	OpApplyPrepayment: prepaymentCall(func(ctx context.Context, pp PrepaymentProvider) error {
+		return pp.ApplyPrepayment(ctx, ApplyPrepaymentInput{CustomerCode: "C1", InvoiceNo: "CONF-1", PrepaymentNo: "PP-1", Amount: d("10"), PaymentDate: e2eDocDate, BankID: "PANK"})
+	}),
+	OpUnallocateToPrepayment: prepaymentCall(func(ctx context.Context, pp PrepaymentProvider) error {
+		_, err := pp.UnallocateToPrepayment(ctx, UnallocateToPrepaymentInput{CustomerCode: "C1", InvoiceNo: "CONF-1", PrepaymentNo: "PP-2", Amount: d("10"), PaymentDate: e2eDocDate, BankID: "PANK"})
+		return err
+	}),
+	OpListPrepayments: prepaymentCall(func(ctx context.Context, pp PrepaymentProvider) error {
+		_, err := pp.ListPrepayments(ctx, ListPrepaymentsInput{CustomerCode: "C1"})
+		return err
+	}),
+}
+
+// errNoPrepayments marks a prepayment operation called on a provider that
+// does not implement PrepaymentProvider.
+var errNoPrepayments = errors.New("provider does not implement PrepaymentProvider")
+
+func prepaymentCall(call func(context.Context, PrepaymentProvider) error) func(context.Context, Provider) error {
+	return func(ctx context.Context, p Provider) error {
+		pp, ok := p.(PrepaymentProvider)
+		if !ok {
+			return errNoPrepayments
+		}
+		return call(ctx, pp)
+	}
+}
+
+// pagesErr returns the first error pages yields.
+func pagesErr[T any](pages iter.Seq2[[]T, error]) error {
+	for _, err := range pages {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNotSupported reports whether err is an adapter's "this operation is not
+// available" answer.
+func isNotSupported(err error) bool {
+	return IsNotSupported(err) || errors.Is(err, errNoPrepayments)
+}
+
+// conformanceClients returns a client per built-in provider, each backed by
+// its emulator.
+func conformanceClients(t *testing.T) map[string]*Client {
+	t.Helper()
+	fast := &resilience.Policy{MaxRetries: -1, RatePerSecond: 1000}
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ms := merittest.NewServer()
+	t.Cleanup(ms.Close)
+	mc := ms.Config()
+	ds := directotest.NewServer()
+	t.Cleanup(ds.Close)
+	dc := ds.Config()
+	es := ebtest.NewServer()
+	t.Cleanup(es.Close)
+	ec := es.Config()
+	ss := satest.NewServer()
+	t.Cleanup(ss.Close)
+	sc := ss.Config()
+
+	return map[string]*Client{
+		"merit": newEmulatorClient(t, Config{
+			Provider:   "merit",
+			APIID:      mc.APIID,
+			APIKey:     mc.APIKey,
+			Extra:      map[string]string{"api_url": mc.APIURL},
+			Resilience: fast,
+			Logger:     quiet,
+		}),
+		"directo": newEmulatorClient(t, Config{
+			Provider: "directo",
+			APIID:    dc.Company,
+			APIKey:   dc.Token,
+			Extra: map[string]string{
+				"rest_api_key":  dc.RestAPIKey,
+				"rest_base_url": dc.RESTBaseURL,
+				"xml_base_url":  dc.XMLBaseURL,
+			},
+			Resilience: fast,
+			Logger:     quiet,
+		}),
+		"excellentbooks": newEmulatorClient(t, Config{
+			Provider:   "excellentbooks",
+			APIID:      ec.Username,
+			APIKey:     ec.Password,
+			Extra:      map[string]string{"base_url": ec.BaseURL, "company_code": ec.CompanyCode},
+			Resilience: fast,
+			Logger:     quiet,
+		}),
+		"smartaccounts": newEmulatorClient(t, Config{
+			Provider:   "smartaccounts",
+			APIID:      sc.APIKey,
+			APIKey:     sc.SecretKey,
+			Region:     sc.Host,
+			HTTPClient: sc.HTTPClient,
+			Resilience: fast,
+			Logger:     quiet,
+		}),
+	}
+}
+
+// TestCapabilitiesConformance runs every operation against each built-in
+// adapter and checks the answer against its advertised Capabilities: an
+// advertised operation must not report itself unsupported, and one that is
+// not advertised must not fail in any other way.
+func TestCapabilitiesConformance(t *testing.T) {
+	for _, op := range Ops() {
+		if opCalls[op] == nil {
+			t.Fatalf("no conformance call for %s", op)
+		}
+	}
+	for name, client := range conformanceClients(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			caps := client.Capabilities()
+			for _, op := range Ops() {
+				err := opCalls[op](ctx, client.provider)
+				switch {
+				case caps.Supports(op) && err != nil && isNotSupported(err):
+					t.Errorf("%s is advertised but returns %v", op, err)
+				case !caps.Supports(op) && err != nil && !isNotSupported(err):
+					t.Errorf("%s is not advertised but fails with %v, want a not-supported error", op, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCapabilitiesFlagsMatchOperations(t *testing.T) {
+	for _, name := range []string{"merit", "directo", "excellentbooks", "smartaccounts"} {
+		caps := ProviderCapabilities(name)
+		for _, f := range opFlags {
+			if *f.flag(&caps) != caps.Supports(f.op) {
+				t.Errorf("%s: flag for %s = %v, Operations says %v", name, f.op, *f.flag(&caps), caps.Supports(f.op))
+			}
+		}
+	}
+	// Directo's purchase import is not implemented and must not be advertised.
+	if caps := ProviderCapabilities("directo"); caps.SupportsPurchaseCreate || caps.Supports(OpCreatePurchase) {
+		t.Error("directo advertises CreatePurchase")
+	}
+	// Prepayment support follows the PrepaymentProvider interface.
+	if !ProviderCapabilities("merit").Supports(OpCreatePrepayment) || ProviderCapabilities("smartaccounts").Supports(OpCreatePrepayment) {
+		t.Error("prepayment operations not derived from PrepaymentProvider")
+	}
+}
@@ -0,0 +1,459 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+	"time"
+)
+
+// refreshCredentials wraps p, built from cfg, so that an ErrAuthFailed
+// re-resolves cfg.Credentials: when the source has new credentials the
+// provider is rebuilt with them and the call repeated once. A rejected
+// login was not processed, so repeating a write is safe.
+func refreshCredentials(p Provider, cfg Config) Provider {
+	cp := &credentialedProvider{inner: p, cfg: cfg}
+	if _, ok := p.(PrepaymentProvider); ok {
+		return &credentialedPrepaymentProvider{cp}
+	}
+	return cp
+}
+
+var (
+	_ Provider           = (*credentialedProvider)(nil)
+	_ PrepaymentProvider = (*credentialedPrepaymentProvider)(nil)
+)
+
+type credentialedProvider struct {
+	mu    sync.Mutex
+	inner Provider
+	cfg   Config // APIID and APIKey are the credentials inner was built with
+}
+
+type credentialedPrepaymentProvider struct {
+	*credentialedProvider
+}
+
+func (p *credentialedProvider) Unwrap() Provider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inner
+}
+
+// call runs f on the current provider, refreshing the credentials and
+// running it again if it fails with ErrAuthFailed.
+func (p *credentialedProvider) call(ctx context.Context, f func(Provider) error) error {
+	inner := p.Unwrap()
+	err := f(inner)
+	if !errors.Is(err, ErrAuthFailed) {
+		return err
+	}
+	fresh, ok := p.refresh(ctx, inner)
+	if !ok {
+		return err
+	}
+	return f(fresh)
+}
+
+// refresh rebuilds the provider if the credential source has changed
+// since stale was built. Concurrent failures of the same stale provider
+// resolve the source once; ok is false when there is nothing new to try.
+func (p *credentialedProvider) refresh(ctx context.Context, stale Provider) (_ Provider, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inner != stale {
+		return p.inner, true
+	}
+	creds, err := p.cfg.credentials(ctx)
+	if err != nil || (creds.APIID == p.cfg.APIID && creds.APIKey == p.cfg.APIKey) {
+		return nil, false
+	}
+	cfg := p.cfg
+	cfg.APIID, cfg.APIKey = creds.APIID, creds.APIKey
+	fresh, err := newProvider(cfg)
+	if err != nil {
+		return nil, false
+	}
+	p.inner, p.cfg = fresh, cfg
+	return fresh, true
+}
+
+func (p *credentialedProvider) TestConnection(ctx context.Context) error {
+	return p.call(ctx, func(inner Provider) error {
+		return inner.TestConnection(ctx)
+	})
+}
+
+func (p *credentialedProvider) CreateInvoice(ctx context.Context, input CreateInvoiceInput) (out *Invoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.CreateInvoice(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) GetInvoice(ctx context.Context, id string) (out *Invoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.GetInvoice(ctx, id)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) GetInvoicePDF(ctx context.Context, id string, deliveryNote bool) (out *InvoicePDF, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.GetInvoicePDF(ctx, id, deliveryNote)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListInvoices(ctx context.Context, input ListInvoicesInput) (out []Invoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListInvoices(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) FindInvoiceByRef(ctx context.Context, refStr string) (out *Invoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.FindInvoiceByRef(ctx, refStr)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) DeleteInvoice(ctx context.Context, id string) error {
+	return p.call(ctx, func(inner Provider) error {
+		return inner.DeleteInvoice(ctx, id)
+	})
+}
+
+func (p *credentialedProvider) CreateCustomer(ctx context.Context, input CreateCustomerInput) (out *Customer, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.CreateCustomer(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) UpdateCustomer(ctx context.Context, input UpdateCustomerInput) error {
+	return p.call(ctx, func(inner Provider) error {
+		return inner.UpdateCustomer(ctx, input)
+	})
+}
+
+func (p *credentialedProvider) ListCustomers(ctx context.Context, input ListCustomersInput) (out []Customer, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListCustomers(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) FindCustomerByEmail(ctx context.Context, email string) (out *Customer, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.FindCustomerByEmail(ctx, email)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) GetCustomer(ctx context.Context, id string) (out *Customer, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.GetCustomer(ctx, id)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) CreatePayment(ctx context.Context, input CreatePaymentInput) error {
+	return p.call(ctx, func(inner Provider) error {
+		return inner.CreatePayment(ctx, input)
+	})
+}
+
+func (p *credentialedProvider) ListPayments(ctx context.Context, input ListPaymentsInput) (out []Payment, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListPayments(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) DeletePayment(ctx context.Context, id string) error {
+	return p.call(ctx, func(inner Provider) error {
+		return inner.DeletePayment(ctx, id)
+	})
+}
+
+func (p *credentialedProvider) CreateItem(ctx context.Context, input CreateItemInput) (out *Item, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.CreateItem(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListItems(ctx context.Context, input ListItemsInput) (out []Item, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListItems(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) UpdateItem(ctx context.Context, input UpdateItemInput) error {
+	return p.call(ctx, func(inner Provider) error {
+		return inner.UpdateItem(ctx, input)
+	})
+}
+
+func (p *credentialedProvider) CreateCreditNote(ctx context.Context, input CreateCreditNoteInput) (out *Invoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.CreateCreditNote(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) CreatePurchase(ctx context.Context, input CreatePurchaseInput) (out *PurchaseInvoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.CreatePurchase(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) GetPurchase(ctx context.Context, id string) (out *PurchaseInvoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.GetPurchase(ctx, id)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListPurchases(ctx context.Context, input ListPurchasesInput) (out []PurchaseInvoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListPurchases(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) DeletePurchase(ctx context.Context, id string) error {
+	return p.call(ctx, func(inner Provider) error {
+		return inner.DeletePurchase(ctx, id)
+	})
+}
+
+func (p *credentialedProvider) ListTaxes(ctx context.Context) (out []Tax, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListTaxes(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListAccounts(ctx context.Context) (out []Account, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListAccounts(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListDimensions(ctx context.Context) (out *DimensionList, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListDimensions(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListBanks(ctx context.Context) (out []Bank, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListBanks(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListPaymentTerms(ctx context.Context) (out []PaymentTerm, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListPaymentTerms(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) CustomerDebts(ctx context.Context, customerName string, overdueDays *int) (out []CustomerDebt, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.CustomerDebts(ctx, customerName, overdueDays)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListInvoicesSince(ctx context.Context, since time.Time, until time.Time) (out []Invoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListInvoicesSince(ctx, since, until)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListPaymentsSince(ctx context.Context, since time.Time, until time.Time) (out []Payment, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListPaymentsSince(ctx, since, until)
+		return err
+	})
+	return out, err
+}
+
+// credentialedPages pages through the current provider. When the first
+// page fails with ErrAuthFailed, the credentials are refreshed and the
+// listing starts over once, like call; later pages are passed on as they
+// come, since the records before them were already yielded.
+func credentialedPages[T any](ctx context.Context, p *credentialedProvider, pages func(Provider) iter.Seq2[[]T, error]) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		inner := p.Unwrap()
+		started := false
+		for page, err := range pages(inner) {
+			if !started && errors.Is(err, ErrAuthFailed) {
+				if fresh, ok := p.refresh(ctx, inner); ok {
+					for page, err := range pages(fresh) {
+						if !yield(page, err) {
+							return
+						}
+					}
+					return
+				}
+			}
+			started = true
+			if !yield(page, err) {
+				return
+			}
+		}
+	}
+}
+
+func (p *credentialedProvider) InvoicePages(ctx context.Context, input ListInvoicesInput) iter.Seq2[[]Invoice, error] {
+	return credentialedPages(ctx, p, func(inner Provider) iter.Seq2[[]Invoice, error] {
+		return inner.InvoicePages(ctx, input)
+	})
+}
+
+func (p *credentialedProvider) CustomerPages(ctx context.Context, input ListCustomersInput) iter.Seq2[[]Customer, error] {
+	return credentialedPages(ctx, p, func(inner Provider) iter.Seq2[[]Customer, error] {
+		return inner.CustomerPages(ctx, input)
+	})
+}
+
+func (p *credentialedProvider) PaymentPages(ctx context.Context, input ListPaymentsInput) iter.Seq2[[]Payment, error] {
+	return credentialedPages(ctx, p, func(inner Provider) iter.Seq2[[]Payment, error] {
+		return inner.PaymentPages(ctx, input)
+	})
+}
+
+func (p *credentialedProvider) ItemPages(ctx context.Context, input ListItemsInput) iter.Seq2[[]Item, error] {
+	return credentialedPages(ctx, p, func(inner Provider) iter.Seq2[[]Item, error] {
+		return inner.ItemPages(ctx, input)
+	})
+}
+
+func (p *credentialedProvider) LocateInvoice(ctx context.Context, input CreateInvoiceInput) (out *Invoice, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.LocateInvoice(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) LocatePayment(ctx context.Context, input CreatePaymentInput) (out *Payment, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.LocatePayment(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListInvoiceChanges(ctx context.Context, since time.Time, until time.Time) (out InvoiceChanges, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListInvoiceChanges(ctx, since, until)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) ListPaymentChanges(ctx context.Context, since time.Time, until time.Time) (out PaymentChanges, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.ListPaymentChanges(ctx, since, until)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedProvider) InvoiceChangesAfter(ctx context.Context, cursor string, since time.Time) (out InvoiceChanges, next string, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, next, err = inner.InvoiceChangesAfter(ctx, cursor, since)
+		return err
+	})
+	return out, next, err
+}
+
+func (p *credentialedProvider) PaymentChangesAfter(ctx context.Context, cursor string, since time.Time) (out PaymentChanges, next string, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, next, err = inner.PaymentChangesAfter(ctx, cursor, since)
+		return err
+	})
+	return out, next, err
+}
+
+func (p *credentialedProvider) CustomerChangesAfter(ctx context.Context, cursor string, since time.Time) (out CustomerChanges, next string, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, next, err = inner.CustomerChangesAfter(ctx, cursor, since)
+		return err
+	})
+	return out, next, err
+}
+
+func (p *credentialedProvider) ItemChangesAfter(ctx context.Context, cursor string, since time.Time) (out ItemChanges, next string, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, next, err = inner.ItemChangesAfter(ctx, cursor, since)
+		return err
+	})
+	return out, next, err
+}
+
+func (p *credentialedPrepaymentProvider) CreatePrepayment(ctx context.Context, input CreatePrepaymentInput) (out *Prepayment, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.(PrepaymentProvider).CreatePrepayment(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedPrepaymentProvider) ApplyPrepayment(ctx context.Context, input ApplyPrepaymentInput) error {
+	return p.call(ctx, func(inner Provider) error {
+		return inner.(PrepaymentProvider).ApplyPrepayment(ctx, input)
+	})
+}
+
+func (p *credentialedPrepaymentProvider) UnallocateToPrepayment(ctx context.Context, input UnallocateToPrepaymentInput) (out *Prepayment, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.(PrepaymentProvider).UnallocateToPrepayment(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (p *credentialedPrepaymentProvider) ListPrepayments(ctx context.Context, input ListPrepaymentsInput) (out []Prepayment, err error) {
+	err = p.call(ctx, func(inner Provider) (err error) {
+		out, err = inner.(PrepaymentProvider).ListPrepayments(ctx, input)
+		return err
+	})
+	return out, err
+}
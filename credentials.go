@@ -0,0 +1,148 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/qbitsoftware/accounting-service/redact"
+)
+
+// Credentials are a provider login: Config.APIID and Config.APIKey. They
+// format with APIKey masked, so they are safe to log.
+type Credentials struct {
+	APIID  string
+	APIKey string
+}
+
+func (c Credentials) String() string {
+	return fmt.Sprintf("{APIID:%s APIKey:%s}", c.APIID, redact.Secret(c.APIKey))
+}
+
+func (c Credentials) GoString() string {
+	return fmt.Sprintf("accounting.Credentials{APIID:%q, APIKey:%q}", c.APIID, redact.Secret(c.APIKey))
+}
+
+// CredentialSource supplies provider credentials. NewClient (and so
+// ClientPool.Get and Rotate) resolves Config.Credentials when it builds a
+// client, and again whenever the provider answers with ErrAuthFailed: if the
+// source then returns different credentials the client switches to them and
+// repeats the call once.
+//
+// Empty fields of the resolved Credentials fall back to Config.APIID and
+// Config.APIKey, so a source may supply just the secret.
+type CredentialSource interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialFunc adapts a function, e.g. a secrets-manager lookup, to a
+// CredentialSource.
+type CredentialFunc func(ctx context.Context) (Credentials, error)
+
+func (f CredentialFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// EnvCredentials reads the API ID and key from the named environment
+// variables. An empty name skips that field; a named variable that is
+// unset or empty is an error.
+func EnvCredentials(idVar, keyVar string) CredentialSource {
+	return envCredentials{idVar: idVar, keyVar: keyVar}
+}
+
+type envCredentials struct{ idVar, keyVar string }
+
+func (s envCredentials) Credentials(context.Context) (Credentials, error) {
+	var c Credentials
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{{s.idVar, &c.APIID}, {s.keyVar, &c.APIKey}} {
+		if f.name == "" {
+			continue
+		}
+		if *f.dst = os.Getenv(f.name); *f.dst == "" {
+			return Credentials{}, fmt.Errorf("accounting: credentials: $%s is not set", f.name)
+		}
+	}
+	return c, nil
+}
+
+func (s envCredentials) String() string {
+	return fmt.Sprintf("env($%s, $%s)", s.idVar, s.keyVar)
+}
+
+// FileCredentials reads the API ID and key from files holding one value
+// each, such as mounted Docker or Kubernetes secrets; surrounding
+// whitespace is trimmed. An empty path skips that field. The files are
+// re-read on every resolution, so replacing them rotates the credentials.
+func FileCredentials(idPath, keyPath string) CredentialSource {
+	return fileCredentials{idPath: idPath, keyPath: keyPath}
+}
+
+type fileCredentials struct{ idPath, keyPath string }
+
+func (s fileCredentials) Credentials(context.Context) (Credentials, error) {
+	var c Credentials
+	for _, f := range []struct {
+		path string
+		dst  *string
+	}{{s.idPath, &c.APIID}, {s.keyPath, &c.APIKey}} {
+		if f.path == "" {
+			continue
+		}
+		b, err := os.ReadFile(f.path)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("accounting: credentials: %w", err)
+		}
+		if *f.dst = strings.TrimSpace(string(b)); *f.dst == "" {
+			return Credentials{}, fmt.Errorf("accounting: credentials: %s is empty", f.path)
+		}
+	}
+	return c, nil
+}
+
+func (s fileCredentials) String() string {
+	return fmt.Sprintf("file(%s, %s)", s.idPath, s.keyPath)
+}
+
+// credentials returns the credentials cfg uses once Credentials is
+// resolved.
+func (c Config) credentials(ctx context.Context) (Credentials, error) {
+	creds := Credentials{APIID: c.APIID, APIKey: c.APIKey}
+	if c.Credentials == nil {
+		return creds, nil
+	}
+	resolved, err := c.Credentials.Credentials(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if resolved.APIID != "" {
+		creds.APIID = resolved.APIID
+	}
+	if resolved.APIKey != "" {
+		creds.APIKey = resolved.APIKey
+	}
+	return creds, nil
+}
+
+// Format prints c with APIKey and the credential-bearing Extra entries
+// masked, so a logged %+v does not leak them.
+func (c Config) Format(f fmt.State, verb rune) {
+	type config Config // drops the methods, so formatting does not recurse
+	out := config(c)
+	out.APIKey = redact.Secret(c.APIKey)
+	if c.Extra != nil {
+		out.Extra = c.Redaction.Map(c.Extra)
+	}
+	fmt.Fprintf(f, fmt.FormatString(f, verb), out)
+}
+
+// Format prints o with RESTAPIKey masked.
+func (o DirectoOptions) Format(f fmt.State, verb rune) {
+	type options DirectoOptions
+	out := options(o)
+	out.RESTAPIKey = redact.Secret(o.RESTAPIKey)
+	fmt.Fprintf(f, fmt.FormatString(f, verb), out)
+}
@@ -0,0 +1,121 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/qbitsoftware/accounting-service/merit"
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+)
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("MERIT_API_ID", "id-1")
+	t.Setenv("MERIT_API_KEY", "key-1")
+	got, err := EnvCredentials("MERIT_API_ID", "MERIT_API_KEY").Credentials(context.Background())
+	if err != nil {
+		t.Fatalf("Credentials: %v", err)
+	}
+	if got != (Credentials{APIID: "id-1", APIKey: "key-1"}) {
+		t.Errorf("Credentials = %#v", got)
+	}
+	if _, err := EnvCredentials("", "MERIT_API_SECRET_UNSET").Credentials(context.Background()); err == nil {
+		t.Error("unset variable: err = nil")
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "api_key")
+	if err := os.WriteFile(keyPath, []byte("key-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{APIID: "id-1", Credentials: FileCredentials("", keyPath)}
+	got, err := cfg.credentials(context.Background())
+	if err != nil {
+		t.Fatalf("credentials: %v", err)
+	}
+	if got != (Credentials{APIID: "id-1", APIKey: "key-1"}) {
+		t.Errorf("credentials = %#v, want the Config APIID and the file's key", got)
+	}
+	if _, err := FileCredentials("", filepath.Join(dir, "missing")).Credentials(context.Background()); err == nil {
+		t.Error("missing file: err = nil")
+	}
+}
+
+func TestCredentialsRedacted(t *testing.T) {
+	const secret = "s3cr3t-value"
+	values := []any{
+		Credentials{APIID: "id", APIKey: secret},
+		Config{Provider: "directo", APIID: "club", APIKey: secret, Extra: map[string]string{"rest_api_key": secret}},
+		DirectoOptions{RESTAPIKey: secret},
+		merit.Config{APIID: "id", APIKey: secret},
+		merit.New(merit.Config{APIID: "id", APIKey: secret}),
+	}
+	for _, v := range values {
+		for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+			if s := fmt.Sprintf(verb, v); strings.Contains(s, secret) {
+				t.Errorf("%s of %T leaks the secret: %s", verb, v, s)
+			}
+		}
+	}
+	if s := fmt.Sprintf("%+v", Config{Provider: "merit", APIID: "id-1"}); !strings.Contains(s, "APIID:id-1") {
+		t.Errorf("%%+v of Config = %s, want the APIID shown", s)
+	}
+}
+
+func TestCredentialsRefreshOnAuthFailure(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+
+	var resolved atomic.Int32
+	source := CredentialFunc(func(context.Context) (Credentials, error) {
+		if resolved.Add(1) == 1 {
+			return Credentials{APIID: mc.APIID, APIKey: "rotated-away"}, nil
+		}
+		return Credentials{APIID: mc.APIID, APIKey: mc.APIKey}, nil
+	})
+	client := newEmulatorClient(t, Config{
+		Provider:    "merit",
+		Credentials: source,
+		Extra:       map[string]string{"api_url": mc.APIURL},
+	})
+	if err := client.TestConnection(ctx); err != nil {
+		t.Fatalf("TestConnection: %v", err)
+	}
+	if err := client.TestConnection(ctx); err != nil {
+		t.Fatalf("TestConnection after refresh: %v", err)
+	}
+	if n := resolved.Load(); n != 2 {
+		t.Errorf("credentials resolved %d times, want 2", n)
+	}
+}
+
+func TestCredentialsRefreshUnchanged(t *testing.T) {
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+
+	var resolved atomic.Int32
+	client := newEmulatorClient(t, Config{
+		Provider: "merit",
+		Credentials: CredentialFunc(func(context.Context) (Credentials, error) {
+			resolved.Add(1)
+			return Credentials{APIID: mc.APIID, APIKey: "revoked"}, nil
+		}),
+		Extra: map[string]string{"api_url": mc.APIURL},
+	})
+	if err := client.TestConnection(context.Background()); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("err = %v, want ErrAuthFailed", err)
+	}
+	if n := resolved.Load(); n != 2 {
+		t.Errorf("credentials resolved %d times, want 2", n)
+	}
+}
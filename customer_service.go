@@ -1,6 +1,9 @@
 package accounting
 
-import "context"
+import (
+	"context"
+	"iter"
+)
 
 type CustomerService struct {
 	provider Provider
@@ -15,7 +18,18 @@ func (s *CustomerService) Update(ctx context.Context, input UpdateCustomerInput)
 }
 
 func (s *CustomerService) List(ctx context.Context, input ListCustomersInput) ([]Customer, error) {
-	return s.provider.ListCustomers(ctx, input)
+	list, err := s.provider.ListCustomers(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return filterList(list, input.matches), nil
+}
+
+// All iterates over the customers matching input, fetching them a page at
+// a time where the provider supports it. Breaking out of the loop stops
+// fetching.
+func (s *CustomerService) All(ctx context.Context, input ListCustomersInput) iter.Seq2[Customer, error] {
+	return filterSeq(flatten(s.provider.CustomerPages(ctx, input)), input.matches)
 }
 
 // Get fetches a single customer by its provider-side ID/code. Returns
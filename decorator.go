@@ -0,0 +1,74 @@
+package accounting
+
+import "fmt"
+
+//go:generate go run ./internal/gendecorator
+
+// Middleware wraps a Provider with cross-cutting behaviour — logging,
+// caching, a read-only guard, auditing, a dry run — and is set with
+// Config.Middleware. Embed Decorator in the returned provider and
+// override only the methods it intercepts:
+//
+//	type readOnly struct{ accounting.Decorator }
+//
+//	func (readOnly) CreateInvoice(context.Context, accounting.CreateInvoiceInput) (*accounting.Invoice, error) {
+//	    return nil, errReadOnly
+//	}
+//
+//	cfg.Middleware = func(next accounting.Provider) accounting.Provider {
+//	    return readOnly{accounting.Decorator{Next: next}}
+//	}
+//
+// Every service call reaches the middleware as the Provider method it
+// maps to, and so reaches the override of that method: Hydrate's
+// GetInvoice calls, the *Pages methods behind the services' All, the
+// LocateInvoice and LocatePayment lookups before an idempotent create,
+// and the ListInvoiceChanges and *ChangesAfter calls SyncService and the
+// SyncEngine make. A middleware intercepting Invoices.List and
+// Invoices.All therefore overrides both ListInvoices and InvoicePages.
+// A provider that does not embed Decorator implements all of Provider
+// itself, so a method added to the interface breaks its build instead of
+// passing it by.
+// Capability checks — Prepayments.Supported and Client.CircuitState —
+// look beneath the middleware through Unwrap.
+type Middleware func(Provider) Provider
+
+// Chain returns a Middleware applying mws in order, the first outermost:
+// a call passes through mws[0], then mws[1], and so on to the provider.
+func Chain(mws ...Middleware) Middleware {
+	return func(p Provider) Provider {
+		for i := len(mws) - 1; i >= 0; i-- {
+			p = mws[i](p)
+		}
+		return p
+	}
+}
+
+// Decorator forwards every Provider and PrepaymentProvider method to
+// Next. It is the base to embed in a Middleware's provider, so only the
+// intercepted methods need writing; the forwarding methods are generated
+// from the interfaces, so a method added to either reaches Next unchanged.
+//
+// The prepayment methods return an error wrapping ErrNotSupported when
+// Next does not implement PrepaymentProvider. Client.Prepayments reports
+// support from the provider beneath the decorators, found through Unwrap,
+// so embedding Decorator does not make a provider appear to support
+// prepayments; the same lookup finds the adapter's circuit state.
+type Decorator struct {
+	Next Provider
+}
+
+var (
+	_ Provider           = Decorator{}
+	_ PrepaymentProvider = Decorator{}
+)
+
+// Unwrap returns Next.
+func (d Decorator) Unwrap() Provider { return d.Next }
+
+func (d Decorator) asPrepaymentProvider(op string) (PrepaymentProvider, error) {
+	if pp, ok := d.Next.(PrepaymentProvider); ok {
+		return pp, nil
+	}
+	return nil, fmt.Errorf("accounting: %s: %w: %w", op, ErrNotSupported, ErrUnsupportedProvider)
+}
@@ -0,0 +1,213 @@
+// Code generated by go run ./internal/gendecorator; DO NOT EDIT.
+
+package accounting
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+func (d Decorator) TestConnection(ctx context.Context) error {
+	return d.Next.TestConnection(ctx)
+}
+
+func (d Decorator) CreateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error) {
+	return d.Next.CreateInvoice(ctx, input)
+}
+
+func (d Decorator) LocateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error) {
+	return d.Next.LocateInvoice(ctx, input)
+}
+
+func (d Decorator) GetInvoice(ctx context.Context, id string) (*Invoice, error) {
+	return d.Next.GetInvoice(ctx, id)
+}
+
+func (d Decorator) GetInvoicePDF(ctx context.Context, id string, deliveryNote bool) (*InvoicePDF, error) {
+	return d.Next.GetInvoicePDF(ctx, id, deliveryNote)
+}
+
+func (d Decorator) ListInvoices(ctx context.Context, input ListInvoicesInput) ([]Invoice, error) {
+	return d.Next.ListInvoices(ctx, input)
+}
+
+func (d Decorator) InvoicePages(ctx context.Context, input ListInvoicesInput) iter.Seq2[[]Invoice, error] {
+	return d.Next.InvoicePages(ctx, input)
+}
+
+func (d Decorator) FindInvoiceByRef(ctx context.Context, refStr string) (*Invoice, error) {
+	return d.Next.FindInvoiceByRef(ctx, refStr)
+}
+
+func (d Decorator) DeleteInvoice(ctx context.Context, id string) error {
+	return d.Next.DeleteInvoice(ctx, id)
+}
+
+func (d Decorator) CreateCustomer(ctx context.Context, input CreateCustomerInput) (*Customer, error) {
+	return d.Next.CreateCustomer(ctx, input)
+}
+
+func (d Decorator) UpdateCustomer(ctx context.Context, input UpdateCustomerInput) error {
+	return d.Next.UpdateCustomer(ctx, input)
+}
+
+func (d Decorator) ListCustomers(ctx context.Context, input ListCustomersInput) ([]Customer, error) {
+	return d.Next.ListCustomers(ctx, input)
+}
+
+func (d Decorator) CustomerPages(ctx context.Context, input ListCustomersInput) iter.Seq2[[]Customer, error] {
+	return d.Next.CustomerPages(ctx, input)
+}
+
+func (d Decorator) FindCustomerByEmail(ctx context.Context, email string) (*Customer, error) {
+	return d.Next.FindCustomerByEmail(ctx, email)
+}
+
+func (d Decorator) GetCustomer(ctx context.Context, id string) (*Customer, error) {
+	return d.Next.GetCustomer(ctx, id)
+}
+
+func (d Decorator) CreatePayment(ctx context.Context, input CreatePaymentInput) error {
+	return d.Next.CreatePayment(ctx, input)
+}
+
+func (d Decorator) LocatePayment(ctx context.Context, input CreatePaymentInput) (*Payment, error) {
+	return d.Next.LocatePayment(ctx, input)
+}
+
+func (d Decorator) ListPayments(ctx context.Context, input ListPaymentsInput) ([]Payment, error) {
+	return d.Next.ListPayments(ctx, input)
+}
+
+func (d Decorator) PaymentPages(ctx context.Context, input ListPaymentsInput) iter.Seq2[[]Payment, error] {
+	return d.Next.PaymentPages(ctx, input)
+}
+
+func (d Decorator) DeletePayment(ctx context.Context, id string) error {
+	return d.Next.DeletePayment(ctx, id)
+}
+
+func (d Decorator) CreateItem(ctx context.Context, input CreateItemInput) (*Item, error) {
+	return d.Next.CreateItem(ctx, input)
+}
+
+func (d Decorator) ListItems(ctx context.Context, input ListItemsInput) ([]Item, error) {
+	return d.Next.ListItems(ctx, input)
+}
+
+func (d Decorator) ItemPages(ctx context.Context, input ListItemsInput) iter.Seq2[[]Item, error] {
+	return d.Next.ItemPages(ctx, input)
+}
+
+func (d Decorator) UpdateItem(ctx context.Context, input UpdateItemInput) error {
+	return d.Next.UpdateItem(ctx, input)
+}
+
+func (d Decorator) CreateCreditNote(ctx context.Context, input CreateCreditNoteInput) (*Invoice, error) {
+	return d.Next.CreateCreditNote(ctx, input)
+}
+
+func (d Decorator) CreatePurchase(ctx context.Context, input CreatePurchaseInput) (*PurchaseInvoice, error) {
+	return d.Next.CreatePurchase(ctx, input)
+}
+
+func (d Decorator) GetPurchase(ctx context.Context, id string) (*PurchaseInvoice, error) {
+	return d.Next.GetPurchase(ctx, id)
+}
+
+func (d Decorator) ListPurchases(ctx context.Context, input ListPurchasesInput) ([]PurchaseInvoice, error) {
+	return d.Next.ListPurchases(ctx, input)
+}
+
+func (d Decorator) DeletePurchase(ctx context.Context, id string) error {
+	return d.Next.DeletePurchase(ctx, id)
+}
+
+func (d Decorator) ListTaxes(ctx context.Context) ([]Tax, error) {
+	return d.Next.ListTaxes(ctx)
+}
+
+func (d Decorator) ListAccounts(ctx context.Context) ([]Account, error) {
+	return d.Next.ListAccounts(ctx)
+}
+
+func (d Decorator) ListDimensions(ctx context.Context) (*DimensionList, error) {
+	return d.Next.ListDimensions(ctx)
+}
+
+func (d Decorator) ListBanks(ctx context.Context) ([]Bank, error) {
+	return d.Next.ListBanks(ctx)
+}
+
+func (d Decorator) ListPaymentTerms(ctx context.Context) ([]PaymentTerm, error) {
+	return d.Next.ListPaymentTerms(ctx)
+}
+
+func (d Decorator) CustomerDebts(ctx context.Context, customerName string, overdueDays *int) ([]CustomerDebt, error) {
+	return d.Next.CustomerDebts(ctx, customerName, overdueDays)
+}
+
+func (d Decorator) ListInvoicesSince(ctx context.Context, since time.Time, until time.Time) ([]Invoice, error) {
+	return d.Next.ListInvoicesSince(ctx, since, until)
+}
+
+func (d Decorator) ListPaymentsSince(ctx context.Context, since time.Time, until time.Time) ([]Payment, error) {
+	return d.Next.ListPaymentsSince(ctx, since, until)
+}
+
+func (d Decorator) ListInvoiceChanges(ctx context.Context, since time.Time, until time.Time) (InvoiceChanges, error) {
+	return d.Next.ListInvoiceChanges(ctx, since, until)
+}
+
+func (d Decorator) ListPaymentChanges(ctx context.Context, since time.Time, until time.Time) (PaymentChanges, error) {
+	return d.Next.ListPaymentChanges(ctx, since, until)
+}
+
+func (d Decorator) InvoiceChangesAfter(ctx context.Context, cursor string, since time.Time) (InvoiceChanges, string, error) {
+	return d.Next.InvoiceChangesAfter(ctx, cursor, since)
+}
+
+func (d Decorator) PaymentChangesAfter(ctx context.Context, cursor string, since time.Time) (PaymentChanges, string, error) {
+	return d.Next.PaymentChangesAfter(ctx, cursor, since)
+}
+
+func (d Decorator) CustomerChangesAfter(ctx context.Context, cursor string, since time.Time) (CustomerChanges, string, error) {
+	return d.Next.CustomerChangesAfter(ctx, cursor, since)
+}
+
+func (d Decorator) ItemChangesAfter(ctx context.Context, cursor string, since time.Time) (ItemChanges, string, error) {
+	return d.Next.ItemChangesAfter(ctx, cursor, since)
+}
+
+func (d Decorator) CreatePrepayment(ctx context.Context, input CreatePrepaymentInput) (*Prepayment, error) {
+	inner, err := d.asPrepaymentProvider("CreatePrepayment")
+	if err != nil {
+		return nil, err
+	}
+	return inner.CreatePrepayment(ctx, input)
+}
+
+func (d Decorator) ApplyPrepayment(ctx context.Context, input ApplyPrepaymentInput) error {
+	inner, err := d.asPrepaymentProvider("ApplyPrepayment")
+	if err != nil {
+		return err
+	}
+	return inner.ApplyPrepayment(ctx, input)
+}
+
+func (d Decorator) UnallocateToPrepayment(ctx context.Context, input UnallocateToPrepaymentInput) (*Prepayment, error) {
+	inner, err := d.asPrepaymentProvider("UnallocateToPrepayment")
+	if err != nil {
+		return nil, err
+	}
+	return inner.UnallocateToPrepayment(ctx, input)
+}
+
+func (d Decorator) ListPrepayments(ctx context.Context, input ListPrepaymentsInput) ([]Prepayment, error) {
+	inner, err := d.asPrepaymentProvider("ListPrepayments")
+	if err != nil {
+		return nil, err
+	}
+	return inner.ListPrepayments(ctx, input)
+}
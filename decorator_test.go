@@ -0,0 +1,221 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+)
+
+var errReadOnly = errors.New("read-only client")
+
+type readOnlyProvider struct{ Decorator }
+
+func (readOnlyProvider) CreateInvoice(context.Context, CreateInvoiceInput) (*Invoice, error) {
+	return nil, errReadOnly
+}
+
+type countingProvider struct {
+	Decorator
+	calls map[string]int
+}
+
+func (p countingProvider) CreateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error) {
+	p.calls["CreateInvoice"]++
+	return p.Next.CreateInvoice(ctx, input)
+}
+
+func (p countingProvider) ListTaxes(ctx context.Context) ([]Tax, error) {
+	p.calls["ListTaxes"]++
+	return p.Next.ListTaxes(ctx)
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+
+	calls := map[string]int{}
+	client := newEmulatorClient(t, Config{
+		Provider: "merit",
+		APIID:    mc.APIID,
+		APIKey:   mc.APIKey,
+		Extra:    map[string]string{"api_url": mc.APIURL},
+		Observer: &recordingObserver{},
+		Middleware: Chain(
+			func(next Provider) Provider { return countingProvider{Decorator{Next: next}, calls} },
+			func(next Provider) Provider { return readOnlyProvider{Decorator{Next: next}} },
+		),
+	})
+
+	if _, err := client.Taxes.List(ctx); err != nil {
+		t.Fatalf("Taxes.List: %v", err)
+	}
+	if _, err := client.Invoices.Create(ctx, e2eInvoice("", "Acme OÜ", "M-1", srv.TaxID(22))); !errors.Is(err, errReadOnly) {
+		t.Fatalf("Invoices.Create: err = %v, want the read-only guard's", err)
+	}
+	if calls["ListTaxes"] != 1 || calls["CreateInvoice"] != 1 {
+		t.Errorf("outer middleware saw %v, want one ListTaxes and one CreateInvoice", calls)
+	}
+	if n := countRequests(srv.Requests(), "v2/sendinvoice"); n != 0 {
+		t.Errorf("%d sendinvoice requests past the guard, want 0", n)
+	}
+
+	// Decorating must not hide optional capabilities.
+	if !client.Prepayments.Supported() {
+		t.Error("Prepayments.Supported() = false behind the middleware, want true")
+	}
+	if got := client.CircuitState(); got != resilience.StateClosed {
+		t.Errorf("CircuitState() = %s, want closed", got)
+	}
+}
+
+func TestMiddleware_NoPrepayments(t *testing.T) {
+	RegisterProvider("middleware-test-stub", func(Config) (Provider, error) {
+		return &stubProvider{}, nil
+	}, Capabilities{})
+
+	client, err := NewClient(Config{
+		Provider:   "middleware-test-stub",
+		Middleware: func(next Provider) Provider { return Decorator{Next: next} },
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if client.Prepayments.Supported() {
+		t.Error("Prepayments.Supported() = true for a provider without prepayments")
+	}
+	if _, err := client.Prepayments.List(context.Background(), ListPrepaymentsInput{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Prepayments.List: err = %v, want ErrNotSupported", err)
+	}
+	if err := (Decorator{Next: &stubProvider{}}).ApplyPrepayment(context.Background(), ApplyPrepaymentInput{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Decorator.ApplyPrepayment: err = %v, want ErrNotSupported", err)
+	}
+
+	_, err = NewClient(Config{
+		Provider:   "middleware-test-stub",
+		Middleware: func(Provider) Provider { return nil },
+	})
+	var cerr *ConfigError
+	if !errors.As(err, &cerr) || cerr.Field != "Middleware" {
+		t.Errorf("NewClient with a nil-returning Middleware: err = %v, want a ConfigError", err)
+	}
+}
+
+func TestMiddleware_IdempotentCreates(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+	newClient := func(mw Middleware) *Client {
+		return newEmulatorClient(t, Config{
+			Provider:          "merit",
+			APIID:             mc.APIID,
+			APIKey:            mc.APIKey,
+			Extra:             map[string]string{"api_url": mc.APIURL},
+			Observer:          &recordingObserver{},
+			Middleware:        mw,
+			IdempotentCreates: true,
+		})
+	}
+
+	// The lookup passes through a Decorator-based middleware.
+	client := newClient(func(next Provider) Provider { return Decorator{Next: next} })
+	in := e2eInvoice("", "Acme OÜ", "ORDER-1", srv.TaxID(22))
+	for range 2 {
+		if _, err := client.Invoices.Create(ctx, in); err != nil {
+			t.Fatalf("Invoices.Create: %v", err)
+		}
+	}
+	if n := countRequests(srv.Requests(), "v2/sendinvoice"); n != 1 {
+		t.Errorf("%d sendinvoice requests through Decorator, want 1", n)
+	}
+
+	// A middleware overriding the lookup sees it.
+	locates := 0
+	counted := newClient(func(next Provider) Provider { return locateCounter{Decorator{Next: next}, &locates} })
+	if _, err := counted.Invoices.Create(ctx, in); err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+	if locates != 1 {
+		t.Errorf("middleware saw %d LocateInvoice calls, want 1", locates)
+	}
+	if n := countRequests(srv.Requests(), "v2/sendinvoice"); n != 1 {
+		t.Errorf("%d sendinvoice requests, want 1", n)
+	}
+}
+
+type locateCounter struct {
+	Decorator
+	n *int
+}
+
+func (p locateCounter) LocateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error) {
+	*p.n++
+	return p.Next.LocateInvoice(ctx, input)
+}
+
+type feedCounter struct {
+	Decorator
+	n *int
+}
+
+func (p feedCounter) InvoiceChangesAfter(ctx context.Context, cursor string, since time.Time) (InvoiceChanges, string, error) {
+	*p.n++
+	return p.Next.InvoiceChangesAfter(ctx, cursor, since)
+}
+
+func TestMiddleware_SyncFeed(t *testing.T) {
+	ctx := context.Background()
+	feed := &feedProvider{}
+	RegisterProvider("middleware-feed-stub", func(Config) (Provider, error) {
+		return feed, nil
+	}, Capabilities{Operations: NewOpSet(OpInvoiceChangesAfter)})
+	store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore: %v", err)
+	}
+	engine, err := NewSyncEngine(SyncEngineConfig{Store: store})
+	if err != nil {
+		t.Fatalf("NewSyncEngine: %v", err)
+	}
+	sync := func(tenant string, mw Middleware) *recordingObserver {
+		rec := &recordingObserver{}
+		client, err := NewClient(Config{Provider: "middleware-feed-stub", Observer: rec, Middleware: mw})
+		if err != nil {
+			t.Fatalf("NewClient: %v", err)
+		}
+		for range 2 {
+			if err := engine.SyncInvoices(ctx, tenant, client, nil); err != nil {
+				t.Fatalf("SyncInvoices: %v", err)
+			}
+		}
+		return rec
+	}
+
+	// The feed passes through a Decorator-based middleware and the
+	// observer beneath it.
+	rec := sync("club-1", func(next Provider) Provider { return Decorator{Next: next} })
+	if want := []string{"", "+"}; !slices.Equal(feed.cursors, want) {
+		t.Errorf("cursors = %q, want %q", feed.cursors, want)
+	}
+	if len(rec.started) != 2 || rec.started[0].Name != "InvoiceChangesAfter" {
+		t.Errorf("observed %v, want two InvoiceChangesAfter", rec.started)
+	}
+
+	// A middleware overriding the feed sees every read of it.
+	reads := 0
+	sync("club-2", func(next Provider) Provider { return feedCounter{Decorator{Next: next}, &reads} })
+	if reads != 2 {
+		t.Errorf("middleware saw %d InvoiceChangesAfter calls, want 2", reads)
+	}
+	if want := []string{"", "+", "", "+"}; !slices.Equal(feed.cursors, want) {
+		t.Errorf("cursors = %q, want %q", feed.cursors, want)
+	}
+}
@@ -35,6 +35,20 @@ func (c *Client) ListProjects(ctx context.Context) ([]ProjectREST, error) {
 	return result, nil
 }
 
+// ListDeleted retrieves the documents deleted after a timestamp.
+func (c *Client) ListDeleted(ctx context.Context, tsFrom string) ([]DeletedREST, error) {
+	params := url.Values{}
+	if tsFrom != "" {
+		params.Set("ts", ">"+tsFrom)
+	}
+	var result []DeletedREST
+	err := c.rest.get(ctx, "deleted", params, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // ListDeletedRecords retrieves deleted records since a timestamp.
 func (c *Client) ListDeletedRecords(ctx context.Context, tsFrom string) ([]map[string]any, error) {
 	params := url.Values{}
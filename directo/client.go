@@ -9,7 +9,11 @@ package directo
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
+
+	"github.com/qbitsoftware/accounting-service/redact"
+	"github.com/qbitsoftware/accounting-service/resilience"
 )
 
 const (
@@ -32,6 +36,10 @@ type Config struct {
 	// RestAPIKey is the REST API key (for read operations via X-Directo-Key header).
 	RestAPIKey string
 
+	// RESTBaseURL overrides the default REST API base URL.
+	// Defaults to https://login.directo.ee/apidirect/v1/
+	RESTBaseURL string
+
 	// XMLBaseURL overrides the default XML Direct endpoint.
 	// Defaults to https://login.directo.ee/xmlcore/cap_xml_direct/xmlcore.asp
 	XMLBaseURL string
@@ -39,12 +47,28 @@ type Config struct {
 	// HTTPClient is an optional HTTP client for making requests.
 	// Defaults to http.DefaultClient if nil.
 	HTTPClient *http.Client
+
+	// Resilience configures retries, throttling, attempt timeouts and the
+	// circuit breaker, shared by the REST and XML Direct APIs. Nil uses the
+	// resilience package defaults. Reads are retried after ambiguous
+	// failures; XML Direct puts only when Directo rate-limits them.
+	Resilience *resilience.Policy
+
+	// Logger receives request and response logs. Nil uses slog.Default().
+	// XML payloads and response bodies are logged at Debug only.
+	Logger *slog.Logger
+
+	// Redaction selects the fields masked in logged payloads and URLs
+	// (the XML Direct token travels in the query string on reads). Nil
+	// uses redact.Default().
+	Redaction *redact.Policy
 }
 
 // Client is a Directo API client that manages both REST and XML Direct APIs.
 type Client struct {
 	rest *restClient
 	xml  *xmlClient
+	exec *resilience.Executor
 }
 
 // New creates a new Directo API client with the given configuration.
@@ -61,21 +85,67 @@ func New(cfg Config) (*Client, error) {
 		httpClient = http.DefaultClient
 	}
 
+	restBaseURL := cfg.RESTBaseURL
+	if restBaseURL == "" {
+		restBaseURL = DefaultRESTBaseURL
+	}
+
 	xmlBaseURL := cfg.XMLBaseURL
 	if xmlBaseURL == "" {
 		xmlBaseURL = DefaultXMLBaseURL
 	}
 
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var policy resilience.Policy
+	if cfg.Resilience != nil {
+		policy = *cfg.Resilience
+	}
+	if policy.Logger == nil {
+		policy.Logger = logger
+	}
+	exec := resilience.New("directo", policy, httpClient)
+
 	return &Client{
 		rest: &restClient{
-			baseURL:    DefaultRESTBaseURL,
-			apiKey:     cfg.RestAPIKey,
-			httpClient: httpClient,
+			baseURL: restBaseURL,
+			apiKey:  cfg.RestAPIKey,
+			exec:    exec,
+			logger:  logger,
+			redact:  cfg.Redaction,
 		},
 		xml: &xmlClient{
-			baseURL:    xmlBaseURL,
-			token:      cfg.Token,
-			httpClient: httpClient,
+			baseURL: xmlBaseURL,
+			token:   cfg.Token,
+			exec:    exec,
+			logger:  logger,
+			redact:  cfg.Redaction,
 		},
+		exec: exec,
 	}, nil
 }
+
+// CircuitState reports the circuit breaker state for this client's
+// provider; StateOpen means Directo is currently considered unhealthy.
+func (c *Client) CircuitState() resilience.State {
+	return c.exec.State()
+}
+
+// Format prints c with Token and RestAPIKey masked.
+func (c Config) Format(f fmt.State, verb rune) {
+	type config Config // drops the methods, so formatting does not recurse
+	out := config(c)
+	out.Token = redact.Secret(c.Token)
+	out.RestAPIKey = redact.Secret(c.RestAPIKey)
+	fmt.Fprintf(f, fmt.FormatString(f, verb), out)
+}
+
+// String identifies the client without its secrets.
+func (c *Client) String() string {
+	return fmt.Sprintf("directo.Client{REST: %s, XML: %s}", c.rest.baseURL, c.xml.baseURL)
+}
+
+func (c *Client) GoString() string { return c.String() }
@@ -3,6 +3,7 @@ package directo
 import (
 	"context"
 	"encoding/xml"
+	"iter"
 	"net/url"
 )
 
@@ -35,14 +36,30 @@ type customersXMLWrapper struct {
 	Customers []CustomerXML `xml:"customer"`
 }
 
-// ListCustomers retrieves all customers via REST API.
-func (c *Client) ListCustomers(ctx context.Context) ([]CustomerREST, error) {
-	var result []CustomerREST
-	err := c.rest.get(ctx, "customers", nil, &result)
-	if err != nil {
-		return nil, err
+// ListCustomers retrieves the customers matching params via REST API.
+func (c *Client) ListCustomers(ctx context.Context, params CustomerListParams) ([]CustomerREST, error) {
+	return list[CustomerREST](ctx, c.rest, "customers", params.values())
+}
+
+// StreamCustomers yields the customers ListCustomers returns one at a
+// time, decoding the response as it arrives.
+func (c *Client) StreamCustomers(ctx context.Context, params CustomerListParams) iter.Seq2[CustomerREST, error] {
+	return stream[CustomerREST](ctx, c.rest, "customers", params.values())
+}
+
+// values encodes p as REST query filters.
+func (p CustomerListParams) values() url.Values {
+	qp := url.Values{}
+	if p.Code != "" {
+		qp.Set("code", p.Code)
 	}
-	return result, nil
+	if p.Email != "" {
+		qp.Set("email", p.Email)
+	}
+	if p.TSFrom != "" {
+		qp.Set("ts", ">"+p.TSFrom)
+	}
+	return qp
 }
 
 // ListCustomersSince retrieves customers changed since the given timestamp via REST API.
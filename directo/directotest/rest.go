@@ -0,0 +1,165 @@
+package directotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/directo"
+)
+
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, restPrefix), "/")
+	if f := s.begin(endpoint); f != nil {
+		writeFault(w, f)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("X-Directo-Key") != s.RestAPIKey {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	q := r.URL.Query()
+	s.mu.Lock()
+	var result any
+	switch endpoint {
+	case "customers":
+		result = s.listCustomers(q)
+	case "invoices":
+		result = s.listInvoices(q)
+	case "receipts":
+		result = s.listReceipts(q)
+	case "items":
+		result = s.listItems(q)
+	case "accounts":
+		result = s.accounts
+	case "objects":
+		result = []directo.ObjectREST{}
+	case "projects":
+		result = []directo.ProjectREST{}
+	case "deleted":
+		result = s.listDeleted(q)
+	}
+	s.mu.Unlock()
+
+	if result == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown endpoint " + endpoint})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// matches reports whether value satisfies every filter given for a
+// field. Directo filters are "=x" (the default), ">x" or "<x"; dates and
+// timestamps compare chronologically, everything else as strings.
+func matches(filters []string, value string) bool {
+	for _, f := range filters {
+		op := "="
+		if f != "" && (f[0] == '>' || f[0] == '<') {
+			op, f = f[:1], f[1:]
+		}
+		a, b := parseTime(value), parseTime(f)
+		var cmp int
+		switch {
+		case !a.IsZero() && !b.IsZero():
+			cmp = a.Compare(b)
+		case op == "=":
+			if !strings.EqualFold(value, f) {
+				return false
+			}
+			continue
+		default:
+			cmp = strings.Compare(value, f)
+		}
+		switch op {
+		case "=":
+			if cmp != 0 {
+				return false
+			}
+		case ">":
+			if cmp < 0 {
+				return false
+			}
+		case "<":
+			if cmp > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func parseTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", dateFormat} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Server) listCustomers(q url.Values) []directo.CustomerREST {
+	out := []directo.CustomerREST{}
+	for _, c := range s.customers {
+		if matches(q["code"], c.Code) && matches(q["email"], c.Email) && matches(q["ts"], c.Timestamp) {
+			out = append(out, *c)
+		}
+	}
+	return out
+}
+
+func (s *Server) listInvoices(q url.Values) []directo.InvoiceREST {
+	out := []directo.InvoiceREST{}
+	for _, inv := range s.invoices {
+		r := inv.rest
+		if matches(q["number"], r.Number) && matches(q["date"], r.Date) &&
+			matches(q["ts"], r.Timestamp) && matches(q["status"], r.Status) &&
+			matches(q["customer_code"], r.CustomerCode) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (s *Server) listReceipts(q url.Values) []directo.ReceiptREST {
+	out := []directo.ReceiptREST{}
+	for _, rc := range s.receipts {
+		r := rc.rest
+		if matches(q["date"], r.Date) && matches(q["ts"], r.Timestamp) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (s *Server) listItems(q url.Values) []directo.ItemREST {
+	out := []directo.ItemREST{}
+	for _, it := range s.items {
+		if matches(q["code"], it.Code) && matches(q["class"], it.Class) &&
+			matches(q["status"], it.Status) && matches(q["ts"], it.Timestamp) {
+			out = append(out, *it)
+		}
+	}
+	return out
+}
+
+func (s *Server) listDeleted(q url.Values) []directo.DeletedREST {
+	out := []directo.DeletedREST{}
+	for _, d := range s.deleted {
+		if matches(q["ts"], d.Timestamp) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
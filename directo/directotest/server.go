@@ -0,0 +1,226 @@
+// Package directotest provides a local emulator of Directo's two APIs for
+// end-to-end tests of the directo client and the accounting adapter.
+//
+// The REST API is served under /apidirect/v1/ and authenticates with the
+// X-Directo-Key header; list endpoints honour Directo's operator-prefixed
+// filters (date=>2025-01-01T00:00:00Z, ts=>...). XML Direct is served at
+// /xmlcore/cap_xml_direct/xmlcore.asp with routing (put/get, what) in the
+// query string and token+xmldata in the form body, answering with
+// <results><result type=".." desc=".."/></results> documents. Routing
+// params sent in the body are rejected with type 404 "Invalid url given",
+// exactly like the real router.
+//
+// Usage:
+//
+//	srv := directotest.NewServer()
+//	defer srv.Close()
+//	client, _ := directo.New(srv.Config())
+package directotest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/directo"
+)
+
+// Default credentials accepted by a Server.
+const (
+	DefaultCompany    = "DIRECTOTEST"
+	DefaultToken      = "directotest-token"
+	DefaultRestAPIKey = "directotest-rest-key"
+)
+
+const (
+	restPrefix = "/apidirect/v1/"
+	xmlPath    = "/xmlcore/cap_xml_direct/xmlcore.asp"
+	dateFormat = "2006-01-02"
+)
+
+// Fault is a canned failure returned instead of the normal response.
+type Fault struct {
+	Status int
+	Body   string
+	Header http.Header
+	// Delay is slept before replying, to exercise client timeouts.
+	Delay time.Duration
+}
+
+// ResultFault returns a Fault that answers HTTP 200 with a single XML
+// Direct <result> of the given type, the way Directo reports rejections.
+func ResultFault(typ, desc string) Fault {
+	return Fault{Status: http.StatusOK, Body: resultsXML(directo.XMLResult{Type: typ, Desc: desc})}
+}
+
+// Server is a running Directo emulator. Its state is safe for concurrent
+// use.
+type Server struct {
+	*httptest.Server
+
+	Company    string
+	Token      string
+	RestAPIKey string
+	// Now is the clock used for ts stamps. Defaults to time.Now.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	nextNo    int
+	faults    map[string][]Fault
+	customers []*directo.CustomerREST
+	invoices  []*invoice
+	receipts  []*receipt
+	items     []*directo.ItemREST
+	accounts  []directo.AccountREST
+	taxes     []directo.TaxXML
+	deleted   []directo.DeletedREST
+	requests  []string
+}
+
+type invoice struct {
+	rest directo.InvoiceREST
+	// confirmed invoices are immutable: re-sending the number is a
+	// duplicate and deleting requires a credit note.
+	confirmed bool
+}
+
+type receipt struct {
+	rest      directo.ReceiptREST
+	rows      []directo.ReceiptRowXML
+	confirmed bool
+}
+
+// NewServer starts an emulator seeded with the Estonian VAT codes and a
+// small chart of accounts.
+func NewServer() *Server {
+	s := &Server{
+		Company:    DefaultCompany,
+		Token:      DefaultToken,
+		RestAPIKey: DefaultRestAPIKey,
+		Now:        time.Now,
+		nextNo:     100001,
+		faults:     map[string][]Fault{},
+		taxes: []directo.TaxXML{
+			{Code: "1", Name: "Käibemaks 22%", Pct: "22"},
+			{Code: "2", Name: "Käibemaks 9%", Pct: "9"},
+			{Code: "0", Name: "Käibemaks 0%", Pct: "0"},
+		},
+		accounts: []directo.AccountREST{
+			{Code: "1000", Name: "Kassa", Type: "A", Status: "1"},
+			{Code: "1200", Name: "Ostjate tasumata arved", Type: "A", Status: "1"},
+			{Code: "3000", Name: "Müügitulu", Type: "T", Status: "1"},
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(restPrefix, s.serveREST)
+	mux.HandleFunc(xmlPath, s.serveXML)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Config returns a directo.Config pointed at the emulator.
+func (s *Server) Config() directo.Config {
+	return directo.Config{
+		Company:     s.Company,
+		Token:       s.Token,
+		RestAPIKey:  s.RestAPIKey,
+		RESTBaseURL: s.URL + restPrefix,
+		XMLBaseURL:  s.URL + xmlPath,
+	}
+}
+
+// InjectFault queues f for the next call to key. REST endpoints are keyed
+// by name ("invoices", "receipts"); XML Direct calls by operation and
+// component ("put:invoice", "get:tax"). Queued faults are consumed one per
+// call, in order.
+func (s *Server) InjectFault(key string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[key] = append(s.faults[key], f)
+}
+
+// Requests returns the fault keys of the calls made so far, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// ConfirmInvoice marks an invoice as confirmed, as an accountant pressing
+// Kinnita in the Directo UI would.
+func (s *Server) ConfirmInvoice(number string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv := s.findInvoice(number)
+	if inv == nil {
+		return false
+	}
+	inv.confirmed = true
+	inv.rest.Confirmed = "1"
+	inv.rest.Timestamp = s.ts()
+	return true
+}
+
+// begin records the call and pops a queued fault for key, if any.
+func (s *Server) begin(key string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, key)
+	q := s.faults[key]
+	if len(q) == 0 {
+		return nil
+	}
+	s.faults[key] = q[1:]
+	return &q[0]
+}
+
+func writeFault(w http.ResponseWriter, f *Fault) {
+	time.Sleep(f.Delay)
+	for k, vs := range f.Header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(f.Status)
+	io.WriteString(w, f.Body)
+}
+
+func (s *Server) ts() string {
+	return s.Now().UTC().Format(time.RFC3339)
+}
+
+func (s *Server) nextNumber() string {
+	for {
+		no := fmt.Sprint(s.nextNo)
+		s.nextNo++
+		if s.findInvoice(no) == nil {
+			return no
+		}
+	}
+}
+
+func (s *Server) findInvoice(number string) *invoice {
+	for _, inv := range s.invoices {
+		if inv.rest.Number == number {
+			return inv
+		}
+	}
+	return nil
+}
+
+func (s *Server) findCustomer(code string) *directo.CustomerREST {
+	for _, c := range s.customers {
+		if strings.EqualFold(c.Code, code) {
+			return c
+		}
+	}
+	return nil
+}
+
+func resultsXML(results ...directo.XMLResult) string {
+	out, _ := xml.Marshal(directo.XMLResults{Results: results})
+	return xml.Header + string(out)
+}
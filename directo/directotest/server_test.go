@@ -0,0 +1,122 @@
+package directotest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/directo"
+	"github.com/qbitsoftware/accounting-service/directo/directotest"
+)
+
+func newClient(t *testing.T, srv *directotest.Server) *directo.Client {
+	t.Helper()
+	client, err := directo.New(srv.Config())
+	if err != nil {
+		t.Fatalf("directo.New: %v", err)
+	}
+	return client
+}
+
+func testInvoice(number string) directo.InvoiceXML {
+	return directo.InvoiceXML{
+		Number:       number,
+		CustomerCode: "ACME",
+		Date:         time.Now().Format("2006-01-02"),
+		Rows: directo.NewInvoiceRows([]directo.InvoiceLineXML{
+			{ItemCode: "FEE", Quantity: "1", Price: "100", VatCode: "1"},
+		}),
+	}
+}
+
+func TestServer_RejectsWrongToken(t *testing.T) {
+	srv := directotest.NewServer()
+	defer srv.Close()
+	cfg := srv.Config()
+	cfg.Token = "wrong"
+	client, err := directo.New(cfg)
+	if err != nil {
+		t.Fatalf("directo.New: %v", err)
+	}
+	if _, err := client.CreateCustomer(context.Background(), directo.CustomerXML{Code: "ACME", Name: "Acme"}); err == nil {
+		t.Fatal("want auth error")
+	}
+}
+
+func TestServer_PutThenREST(t *testing.T) {
+	ctx := context.Background()
+	srv := directotest.NewServer()
+	defer srv.Close()
+	client := newClient(t, srv)
+
+	if _, err := client.CreateCustomer(ctx, directo.CustomerXML{Code: "ACME", Name: "Acme OÜ", Email: "a@example.com"}); err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	if _, err := client.CreateInvoice(ctx, testInvoice("I-1"), nil); err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	inv, err := client.GetInvoice(ctx, "I-1")
+	if err != nil {
+		t.Fatalf("GetInvoice: %v", err)
+	}
+	if inv.CustomerCode != "ACME" || inv.Total != "122.00" {
+		t.Errorf("invoice = %+v, want ACME / 122.00", inv)
+	}
+
+	// Confirmed invoices are immutable: a re-send is rejected.
+	if !srv.ConfirmInvoice("I-1") {
+		t.Fatal("ConfirmInvoice: invoice not found")
+	}
+	if _, err := client.CreateInvoice(ctx, testInvoice("I-1"), nil); err == nil {
+		t.Error("re-sending a confirmed invoice: want error")
+	}
+	if _, err := client.DeleteInvoice(ctx, "I-1"); err == nil {
+		t.Error("deleting a confirmed invoice: want error")
+	}
+}
+
+func TestServer_DeletedRecords(t *testing.T) {
+	ctx := context.Background()
+	srv := directotest.NewServer()
+	defer srv.Close()
+	client := newClient(t, srv)
+
+	if _, err := client.CreateCustomer(ctx, directo.CustomerXML{Code: "ACME", Name: "Acme OÜ"}); err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	if _, err := client.CreateInvoice(ctx, testInvoice("I-2"), nil); err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	if _, err := client.DeleteInvoice(ctx, "I-2"); err != nil {
+		t.Fatalf("DeleteInvoice: %v", err)
+	}
+	deleted, err := client.ListDeleted(ctx, "")
+	if err != nil {
+		t.Fatalf("ListDeleted: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].Table != "invoices" || deleted[0].Number != "I-2" || deleted[0].Timestamp == "" {
+		t.Fatalf("deleted = %+v, want one invoices tombstone for I-2", deleted)
+	}
+	// The wire keys, as the untyped listing sees them.
+	records, err := client.ListDeletedRecords(ctx, "")
+	if err != nil {
+		t.Fatalf("ListDeletedRecords: %v", err)
+	}
+	if len(records) != 1 || records[0]["table"] != "invoices" || records[0]["number"] != "I-2" || records[0]["ts"] != deleted[0].Timestamp {
+		t.Errorf("records = %v, want table, number and ts keys", records)
+	}
+	if _, err := client.GetInvoice(ctx, "I-2"); err == nil {
+		t.Error("GetInvoice after delete: want error")
+	}
+}
+
+func TestServer_ResultFault(t *testing.T) {
+	srv := directotest.NewServer()
+	defer srv.Close()
+	srv.InjectFault("put:customer", directotest.ResultFault("5", "Unauthorized"))
+	client := newClient(t, srv)
+
+	if _, err := client.CreateCustomer(context.Background(), directo.CustomerXML{Code: "ACME", Name: "Acme"}); err == nil {
+		t.Fatal("want injected result error")
+	}
+}
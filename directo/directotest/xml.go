@@ -0,0 +1,444 @@
+package directotest
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/qbitsoftware/accounting-service/directo"
+	"github.com/shopspring/decimal"
+)
+
+// Result types the emulator answers with. See directo.xmlResultsError for
+// how the client classifies them.
+const (
+	resultOK         = "0"
+	resultValidation = "1"
+	resultAuth       = "5"
+	resultDuplicate  = "11"
+	resultNoID       = "12"
+	resultNoCustomer = "13"
+	resultCreated    = "30"
+)
+
+func (s *Server) serveXML(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	op := "get"
+	if q.Get("put") == "1" {
+		op = "put"
+	}
+	what := q.Get("what")
+	if f := s.begin(op + ":" + what); f != nil {
+		writeFault(w, f)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	// The router only looks at the query string; routing params in the
+	// body never reach a component.
+	if what == "" || r.PostForm.Has("what") || r.PostForm.Has("put") || r.PostForm.Has("get") {
+		writeXML(w, resultsXML(directo.XMLResult{Type: "404", Desc: "Invalid url given"}))
+		return
+	}
+
+	token := q.Get("token")
+	if op == "put" {
+		token = r.PostForm.Get("token")
+	}
+	if token == "" {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "token required")
+		return
+	}
+	if token != s.Token {
+		writeXML(w, resultsXML(directo.XMLResult{Type: resultAuth, Desc: "Unauthorized"}))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if op == "get" {
+		s.xmlGet(w, what)
+		return
+	}
+
+	data := []byte(r.PostForm.Get("xmldata"))
+	var results []directo.XMLResult
+	switch what {
+	case "customer":
+		results = s.putCustomers(data)
+	case "invoice":
+		results = s.putInvoices(data)
+	case "receipt":
+		results = s.putReceipts(data)
+	case "item":
+		results = s.putItems(data)
+	default:
+		results = []directo.XMLResult{{Type: "404", Desc: "Invalid url given"}}
+	}
+	writeXML(w, resultsXML(results...))
+}
+
+func writeXML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	io.WriteString(w, body)
+}
+
+func (s *Server) xmlGet(w http.ResponseWriter, what string) {
+	switch what {
+	case "tax":
+		out, _ := xml.Marshal(struct {
+			XMLName xml.Name         `xml:"taxes"`
+			Taxes   []directo.TaxXML `xml:"tax"`
+		}{Taxes: s.taxes})
+		writeXML(w, xml.Header+string(out))
+	default:
+		writeXML(w, resultsXML(directo.XMLResult{Type: "404", Desc: "Invalid url given"}))
+	}
+}
+
+func malformed(what string, err error) []directo.XMLResult {
+	return []directo.XMLResult{{What: what, Type: resultValidation, Desc: "XML parse error: " + err.Error()}}
+}
+
+// deleteMarker picks the delete="1" flag the typed structs don't carry.
+type deleteMarker struct {
+	Number string `xml:"number,attr"`
+	Delete string `xml:"delete,attr"`
+}
+
+// --- Customers ---
+
+func (s *Server) putCustomers(data []byte) []directo.XMLResult {
+	var doc struct {
+		Customers []directo.CustomerXML `xml:"customer"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return malformed("customer", err)
+	}
+	var results []directo.XMLResult
+	for _, in := range doc.Customers {
+		if in.Code == "" {
+			results = append(results, directo.XMLResult{What: "customer", Type: resultNoID, Desc: "Missing document identificator"})
+			continue
+		}
+		c := s.findCustomer(in.Code)
+		typ, desc := resultOK, "OK"
+		if c == nil {
+			c = &directo.CustomerREST{Code: in.Code, Currency: "EUR"}
+			s.customers = append(s.customers, c)
+			typ, desc = resultCreated, "Created"
+		}
+		set := func(dst *string, v string) {
+			if v != "" {
+				*dst = v
+			}
+		}
+		set(&c.Name, in.Name)
+		set(&c.RegNo, in.RegNo)
+		set(&c.VATNo, in.VATNo)
+		set(&c.Email, in.Email)
+		set(&c.Phone, in.Phone)
+		set(&c.Address, in.Address1)
+		set(&c.City, in.Address2)
+		set(&c.PostalCode, in.Address3)
+		set(&c.Country, in.Country)
+		set(&c.County, in.County)
+		set(&c.Currency, in.Currency)
+		set(&c.Contact, in.Contact)
+		set(&c.HomePage, in.URL)
+		if in.PayTerm != "" {
+			if d, err := decimal.NewFromString(in.PayTerm); err == nil {
+				c.PaymentDays = int(d.IntPart())
+			}
+		}
+		c.Timestamp = s.ts()
+		results = append(results, directo.XMLResult{What: "customer", Type: typ, Desc: desc, Code: c.Code})
+	}
+	return results
+}
+
+// --- Invoices ---
+
+func (s *Server) putInvoices(data []byte) []directo.XMLResult {
+	var doc struct {
+		Invoices []directo.InvoiceXML `xml:"invoice"`
+	}
+	var markers struct {
+		Invoices []deleteMarker `xml:"invoice"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return malformed("invoice", err)
+	}
+	xml.Unmarshal(data, &markers)
+
+	var results []directo.XMLResult
+	for i, in := range doc.Invoices {
+		if markers.Invoices[i].Delete == "1" {
+			results = append(results, s.deleteInvoice(in.Number))
+			continue
+		}
+		results = append(results, s.putInvoice(in))
+	}
+	return results
+}
+
+func (s *Server) putInvoice(in directo.InvoiceXML) directo.XMLResult {
+	existing := s.findInvoice(in.Number)
+	if existing != nil && existing.confirmed {
+		return directo.XMLResult{What: "invoice", Type: resultDuplicate, Desc: "Duplicate: invoice " + in.Number + " is confirmed"}
+	}
+
+	cust := s.findCustomer(in.CustomerCode)
+	if cust == nil && in.Email == "" && in.CustomerRegNo == "" {
+		return directo.XMLResult{What: "invoice", Type: resultNoCustomer, Desc: "Missing customer e-mail or reg.no"}
+	}
+	name := in.CustomerName
+	if cust != nil && name == "" {
+		name = cust.Name
+	}
+
+	total, tax := decimal.Zero, decimal.Zero
+	for _, row := range in.Rows.Rows {
+		qty, err1 := decimal.NewFromString(row.Quantity)
+		price, err2 := decimal.NewFromString(row.Price)
+		if err1 != nil || err2 != nil {
+			return directo.XMLResult{What: "invoice", Type: resultValidation, Desc: "Invalid quantity or price"}
+		}
+		net := qty.Mul(price).Round(2)
+		vat := net.Mul(s.taxPct(row.VatCode)).Div(decimal.NewFromInt(100)).Round(2)
+		total = total.Add(net).Add(vat)
+		tax = tax.Add(vat)
+	}
+
+	typ, desc := resultOK, "OK"
+	if existing == nil {
+		if in.Number == "" {
+			in.Number = s.nextNumber()
+		}
+		existing = &invoice{}
+		s.invoices = append(s.invoices, existing)
+		typ, desc = resultCreated, "Created"
+	}
+	currency := in.Currency
+	if currency == "" {
+		currency = "EUR"
+	}
+	paid := existing.rest.PaidAmount
+	if paid == "" {
+		paid = "0"
+	}
+	existing.rest = directo.InvoiceREST{
+		Number:       in.Number,
+		CustomerCode: in.CustomerCode,
+		CustomerName: name,
+		Date:         in.Date,
+		Deadline:     in.Deadline,
+		Total:        total.StringFixed(2),
+		TotalTax:     tax.StringFixed(2),
+		PaidAmount:   paid,
+		Currency:     currency,
+		Comment:      in.Comment,
+		Confirmed:    "0",
+		Timestamp:    s.ts(),
+	}
+	if in.Confirm == "1" {
+		existing.confirmed = true
+		existing.rest.Confirmed = "1"
+	}
+	return directo.XMLResult{What: "invoice", Type: typ, Desc: desc, Code: in.Number}
+}
+
+func (s *Server) taxPct(code string) decimal.Decimal {
+	for _, t := range s.taxes {
+		if t.Code == code {
+			pct, _ := decimal.NewFromString(t.Pct)
+			return pct
+		}
+	}
+	return decimal.Zero
+}
+
+func (s *Server) deleteInvoice(number string) directo.XMLResult {
+	for i, inv := range s.invoices {
+		if inv.rest.Number != number {
+			continue
+		}
+		if inv.confirmed {
+			return directo.XMLResult{What: "invoice", Type: resultValidation, Desc: "Confirmed document can not be deleted"}
+		}
+		s.invoices = append(s.invoices[:i], s.invoices[i+1:]...)
+		s.deleted = append(s.deleted, directo.DeletedREST{Table: "invoices", Number: number, Timestamp: s.ts()})
+		return directo.XMLResult{What: "invoice", Type: resultOK, Desc: "Deleted"}
+	}
+	return directo.XMLResult{What: "invoice", Type: resultValidation, Desc: "Document " + number + " not found"}
+}
+
+// --- Receipts ---
+
+func (s *Server) putReceipts(data []byte) []directo.XMLResult {
+	var doc struct {
+		Receipts []directo.ReceiptXML `xml:"receipt"`
+	}
+	var markers struct {
+		Receipts []deleteMarker `xml:"receipt"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return malformed("receipt", err)
+	}
+	xml.Unmarshal(data, &markers)
+
+	var results []directo.XMLResult
+	for i, in := range doc.Receipts {
+		if in.Number == "" {
+			results = append(results, directo.XMLResult{What: "receipt", Type: resultNoID, Desc: "Missing document identificator"})
+			continue
+		}
+		if markers.Receipts[i].Delete == "1" {
+			results = append(results, s.deleteReceipt(in.Number))
+			continue
+		}
+		results = append(results, s.putReceipt(in))
+	}
+	return results
+}
+
+func (s *Server) findReceipt(number string) (int, *receipt) {
+	for i, rc := range s.receipts {
+		if rc.rest.Number == number {
+			return i, rc
+		}
+	}
+	return -1, nil
+}
+
+func (s *Server) putReceipt(in directo.ReceiptXML) directo.XMLResult {
+	_, existing := s.findReceipt(in.Number)
+	if existing != nil && existing.confirmed {
+		return directo.XMLResult{What: "receipt", Type: resultDuplicate, Desc: "Duplicate: receipt " + in.Number + " is confirmed"}
+	}
+	if in.Confirm == "1" && in.PaymentMode == "" {
+		return directo.XMLResult{What: "receipt", Type: resultValidation, Desc: "Tasumisviisi ei leitud / Deebet on vale või puudu"}
+	}
+
+	amount := decimal.Zero
+	var first directo.ReceiptRowXML
+	for i, row := range in.Rows.Rows {
+		v := row.Received
+		if v == "" {
+			v = row.Payment
+		}
+		d, err := decimal.NewFromString(v)
+		if err != nil {
+			return directo.XMLResult{What: "receipt", Type: resultValidation, Desc: "Invalid amount"}
+		}
+		if row.InvoiceNo != "" && s.findInvoice(row.InvoiceNo) == nil {
+			return directo.XMLResult{What: "receipt", Type: resultValidation, Desc: "Invoice " + row.InvoiceNo + " not found"}
+		}
+		amount = amount.Add(d)
+		if i == 0 {
+			first = row
+		}
+	}
+
+	typ, desc := resultOK, "OK"
+	if existing == nil {
+		existing = &receipt{}
+		s.receipts = append(s.receipts, existing)
+		typ, desc = resultCreated, "Created"
+	}
+	name := ""
+	if c := s.findCustomer(first.CustomerCode); c != nil {
+		name = c.Name
+	}
+	currency := first.BankCurrency
+	if currency == "" {
+		currency = "EUR"
+	}
+	existing.rows = in.Rows.Rows
+	existing.rest = directo.ReceiptREST{
+		Number:       in.Number,
+		Date:         in.Date,
+		Amount:       amount.StringFixed(2),
+		Currency:     currency,
+		CustomerCode: first.CustomerCode,
+		CustomerName: name,
+		InvoiceNo:    first.InvoiceNo,
+		Timestamp:    s.ts(),
+	}
+	if in.Confirm == "1" {
+		existing.confirmed = true
+		s.settle(existing.rows, 1)
+	}
+	return directo.XMLResult{What: "receipt", Type: typ, Desc: desc, Code: in.Number}
+}
+
+// settle applies (sign 1) or reverses (sign -1) a confirmed receipt's
+// rows on the invoices they reference.
+func (s *Server) settle(rows []directo.ReceiptRowXML, sign int64) {
+	for _, row := range rows {
+		inv := s.findInvoice(row.InvoiceNo)
+		if inv == nil {
+			continue
+		}
+		v := row.Payment
+		if v == "" {
+			v = row.Received
+		}
+		d, _ := decimal.NewFromString(v)
+		paid, _ := decimal.NewFromString(inv.rest.PaidAmount)
+		inv.rest.PaidAmount = paid.Add(d.Mul(decimal.NewFromInt(sign))).StringFixed(2)
+		inv.rest.Timestamp = s.ts()
+	}
+}
+
+func (s *Server) deleteReceipt(number string) directo.XMLResult {
+	i, rc := s.findReceipt(number)
+	if rc == nil {
+		return directo.XMLResult{What: "receipt", Type: resultValidation, Desc: "Document " + number + " not found"}
+	}
+	if rc.confirmed {
+		s.settle(rc.rows, -1)
+	}
+	s.receipts = append(s.receipts[:i], s.receipts[i+1:]...)
+	s.deleted = append(s.deleted, directo.DeletedREST{Table: "receipts", Number: number, Timestamp: s.ts()})
+	return directo.XMLResult{What: "receipt", Type: resultOK, Desc: "Deleted"}
+}
+
+// --- Items ---
+
+func (s *Server) putItems(data []byte) []directo.XMLResult {
+	var doc struct {
+		Items []directo.ItemXML `xml:"item"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return malformed("item", err)
+	}
+	var results []directo.XMLResult
+	for _, in := range doc.Items {
+		if in.Code == "" {
+			results = append(results, directo.XMLResult{What: "item", Type: resultNoID, Desc: "Missing document identificator"})
+			continue
+		}
+		var it *directo.ItemREST
+		for _, candidate := range s.items {
+			if strings.EqualFold(candidate.Code, in.Code) {
+				it = candidate
+			}
+		}
+		typ, desc := resultOK, "OK"
+		if it == nil {
+			it = &directo.ItemREST{Code: in.Code, Status: "1"}
+			s.items = append(s.items, it)
+			typ, desc = resultCreated, "Created"
+		}
+		it.Name, it.Description, it.Unit, it.Price, it.Class, it.Barcode =
+			in.Name, in.Description, in.Unit, in.Price, in.Class, in.Barcode
+		it.Timestamp = s.ts()
+		results = append(results, directo.XMLResult{What: "item", Type: typ, Desc: desc, Code: in.Code})
+	}
+	return results
+}
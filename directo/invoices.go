@@ -3,6 +3,7 @@ package directo
 import (
 	"context"
 	"encoding/xml"
+	"iter"
 	"net/url"
 )
 
@@ -77,26 +78,43 @@ type invoicesXMLWrapper struct {
 
 // ListInvoices retrieves invoices via REST API.
 func (c *Client) ListInvoices(ctx context.Context, params InvoiceListParams) ([]InvoiceREST, error) {
+	return list[InvoiceREST](ctx, c.rest, "invoices", params.values())
+}
+
+// StreamInvoices yields the invoices ListInvoices returns one at a time,
+// decoding the response as it arrives.
+func (c *Client) StreamInvoices(ctx context.Context, params InvoiceListParams) iter.Seq2[InvoiceREST, error] {
+	return stream[InvoiceREST](ctx, c.rest, "invoices", params.values())
+}
+
+// values encodes p as REST query filters.
+func (p InvoiceListParams) values() url.Values {
 	qp := url.Values{}
-	if params.DateFrom != "" {
-		qp.Set("date", ">"+params.DateFrom)
+	if p.DateFrom != "" {
+		qp.Set("date", ">"+p.DateFrom)
 	}
-	if params.DateTo != "" {
-		qp.Add("date", "<"+params.DateTo)
+	if p.DateTo != "" {
+		qp.Add("date", "<"+p.DateTo)
 	}
-	if params.TSFrom != "" {
-		qp.Set("ts", ">"+params.TSFrom)
+	if p.TSFrom != "" {
+		qp.Set("ts", ">"+p.TSFrom)
 	}
-	if params.Status != "" {
-		qp.Set("status", params.Status)
+	if p.TSTo != "" {
+		qp.Add("ts", "<"+p.TSTo)
 	}
-
-	var result []InvoiceREST
-	err := c.rest.get(ctx, "invoices", qp, &result)
-	if err != nil {
-		return nil, err
+	if p.Status != "" {
+		qp.Set("status", p.Status)
+	}
+	if p.CustomerCode != "" {
+		qp.Set("customer_code", p.CustomerCode)
+	}
+	if p.NumberFrom != "" {
+		qp.Set("number", ">"+p.NumberFrom)
+	}
+	if p.NumberTo != "" {
+		qp.Add("number", "<"+p.NumberTo)
 	}
-	return result, nil
+	return qp
 }
 
 // GetInvoice retrieves a single invoice by number via REST API.
@@ -3,6 +3,7 @@ package directo
 import (
 	"context"
 	"encoding/xml"
+	"iter"
 	"net/url"
 )
 
@@ -30,26 +31,31 @@ type itemsXMLWrapper struct {
 
 // ListItems retrieves items via REST API.
 func (c *Client) ListItems(ctx context.Context, params ItemListParams) ([]ItemREST, error) {
+	return list[ItemREST](ctx, c.rest, "items", params.values())
+}
+
+// StreamItems yields the items ListItems returns one at a time, decoding
+// the response as it arrives.
+func (c *Client) StreamItems(ctx context.Context, params ItemListParams) iter.Seq2[ItemREST, error] {
+	return stream[ItemREST](ctx, c.rest, "items", params.values())
+}
+
+// values encodes p as REST query filters.
+func (p ItemListParams) values() url.Values {
 	qp := url.Values{}
-	if params.Code != "" {
-		qp.Set("code", params.Code)
-	}
-	if params.Class != "" {
-		qp.Set("class", params.Class)
+	if p.Code != "" {
+		qp.Set("code", p.Code)
 	}
-	if params.Status != "" {
-		qp.Set("status", params.Status)
+	if p.Class != "" {
+		qp.Set("class", p.Class)
 	}
-	if params.TSFrom != "" {
-		qp.Set("ts", ">"+params.TSFrom)
+	if p.Status != "" {
+		qp.Set("status", p.Status)
 	}
-
-	var result []ItemREST
-	err := c.rest.get(ctx, "items", qp, &result)
-	if err != nil {
-		return nil, err
+	if p.TSFrom != "" {
+		qp.Set("ts", ">"+p.TSFrom)
 	}
-	return result, nil
+	return qp
 }
 
 // GetItem retrieves a single item by code via REST API.
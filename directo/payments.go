@@ -3,6 +3,7 @@ package directo
 import (
 	"context"
 	"encoding/xml"
+	"iter"
 	"net/url"
 )
 
@@ -61,23 +62,31 @@ type receiptsXMLWrapper struct {
 
 // ListPayments retrieves receipts/payments via REST API.
 func (c *Client) ListPayments(ctx context.Context, params PaymentListParams) ([]ReceiptREST, error) {
+	return list[ReceiptREST](ctx, c.rest, "receipts", params.values())
+}
+
+// StreamPayments yields the receipts ListPayments returns one at a time,
+// decoding the response as it arrives.
+func (c *Client) StreamPayments(ctx context.Context, params PaymentListParams) iter.Seq2[ReceiptREST, error] {
+	return stream[ReceiptREST](ctx, c.rest, "receipts", params.values())
+}
+
+// values encodes p as REST query filters.
+func (p PaymentListParams) values() url.Values {
 	qp := url.Values{}
-	if params.DateFrom != "" {
-		qp.Set("date", ">"+params.DateFrom)
+	if p.DateFrom != "" {
+		qp.Set("date", ">"+p.DateFrom)
 	}
-	if params.DateTo != "" {
-		qp.Add("date", "<"+params.DateTo)
+	if p.DateTo != "" {
+		qp.Add("date", "<"+p.DateTo)
 	}
-	if params.TSFrom != "" {
-		qp.Set("ts", ">"+params.TSFrom)
+	if p.TSFrom != "" {
+		qp.Set("ts", ">"+p.TSFrom)
 	}
-
-	var result []ReceiptREST
-	err := c.rest.get(ctx, "receipts", qp, &result)
-	if err != nil {
-		return nil, err
+	if p.TSTo != "" {
+		qp.Add("ts", "<"+p.TSTo)
 	}
-	return result, nil
+	return qp
 }
 
 // CreatePayment creates a receipt/payment via XML Direct.
@@ -3,48 +3,38 @@ package directo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log/slog"
 	"net/http"
 	"net/url"
+
+	"github.com/qbitsoftware/accounting-service/jsonstream"
+	"github.com/qbitsoftware/accounting-service/redact"
+	"github.com/qbitsoftware/accounting-service/resilience"
 )
 
 // restClient handles read operations via the Directo REST API.
 type restClient struct {
-	baseURL    string
-	apiKey     string
-	httpClient *http.Client
+	baseURL string
+	apiKey  string
+	exec    *resilience.Executor
+	logger  *slog.Logger
+	redact  *redact.Policy
 }
 
 // get performs a GET request to the REST API.
 func (c *restClient) get(ctx context.Context, endpoint string, params url.Values, result any) error {
-	reqURL := c.baseURL + endpoint
-	if params != nil && len(params) > 0 {
-		reqURL += "?" + params.Encode()
-	}
-
-	slog.Info("directo rest request", "endpoint", endpoint, "url", reqURL)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-	if err != nil {
-		return fmt.Errorf("directo rest: create request: %w", err)
-	}
-	req.Header.Set("X-Directo-Key", c.apiKey)
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.exec.Do(ctx, endpoint, true, c.request(endpoint, params))
 	if err != nil {
 		return fmt.Errorf("directo rest: send request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("directo rest: read response: %w", err)
-	}
+	body := resp.Body
 
-	slog.Info("directo rest response", "endpoint", endpoint, "status", resp.StatusCode, "body_len", len(body))
+	c.logger.Info("directo rest response", "endpoint", endpoint, "status", resp.StatusCode, "body_len", len(body))
+	c.logger.Debug("directo rest response payload", "endpoint", endpoint, "body", c.redact.Body(body))
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return &APIError{
@@ -62,3 +52,80 @@ func (c *restClient) get(ctx context.Context, endpoint string, params url.Values
 
 	return nil
 }
+
+// getStream is get for the list endpoints: it decodes the JSON array
+// response as it arrives, calling each with every record, and stops when
+// each returns false. The whole response is never held in memory.
+func getStream[T any](ctx context.Context, c *restClient, endpoint string, params url.Values, each func(T) bool) error {
+	resp, err := c.exec.Stream(ctx, endpoint, true, c.request(endpoint, params))
+	if err != nil {
+		return fmt.Errorf("directo rest: send request: %w", err)
+	}
+	if resp.Stream == nil {
+		c.logger.Info("directo rest response", "endpoint", endpoint, "status", resp.StatusCode, "body_len", len(resp.Body))
+		c.logger.Debug("directo rest response payload", "endpoint", endpoint, "body", c.redact.Body(resp.Body))
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    string(resp.Body),
+			Source:     "rest",
+		}
+	}
+	defer resp.Stream.Close()
+
+	rec := jsonstream.NewRecorder(resp.Stream, c.logger.Enabled(ctx, slog.LevelDebug))
+	err = jsonstream.Array(json.NewDecoder(rec), each)
+	c.logger.Info("directo rest response", "endpoint", endpoint, "status", resp.StatusCode, "body_len", rec.Len())
+	c.logger.Debug("directo rest response payload", "endpoint", endpoint, "body", c.redact.Body(rec.Bytes()))
+	if err != nil && !(errors.Is(err, io.EOF) && rec.Len() == 0) {
+		return fmt.Errorf("directo rest: decode response: %w", err)
+	}
+	return nil
+}
+
+// stream presents a list endpoint as an iterator over its records,
+// decoded as they arrive. Stopping the loop stops reading.
+func stream[T any](ctx context.Context, c *restClient, endpoint string, params url.Values) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		stopped := false
+		err := getStream(ctx, c, endpoint, params, func(v T) bool {
+			stopped = !yield(v, nil)
+			return !stopped
+		})
+		if err != nil && !stopped {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// list collects every record of a list endpoint.
+func list[T any](ctx context.Context, c *restClient, endpoint string, params url.Values) ([]T, error) {
+	out := []T{}
+	if err := getStream(ctx, c, endpoint, params, func(v T) bool {
+		out = append(out, v)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// request builds the attempts of a GET and logs the call.
+func (c *restClient) request(endpoint string, params url.Values) func(ctx context.Context) (*http.Request, error) {
+	reqURL := c.baseURL + endpoint
+	if params != nil && len(params) > 0 {
+		reqURL += "?" + params.Encode()
+	}
+
+	c.logger.Info("directo rest request", "endpoint", endpoint, "url", c.redact.URL(reqURL))
+
+	return func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("directo rest: create request: %w", err)
+		}
+		req.Header.Set("X-Directo-Key", c.apiKey)
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	}
+}
@@ -7,6 +7,7 @@ type APIError struct {
 	StatusCode int
 	Message    string
 	Source     string // "rest" or "xml"
+	ResultType string // XML Direct <result type="..."> of a rejected write, e.g. "12"
 }
 
 func (e *APIError) Error() string {
@@ -108,6 +109,15 @@ type ReceiptREST struct {
 	Timestamp    string `json:"ts"`
 }
 
+// DeletedREST is an entry of the register of deleted documents (GET
+// /deleted): the table the document was deleted from ("invoices",
+// "receipts"), its number and when it was deleted.
+type DeletedREST struct {
+	Table     string `json:"table"`
+	Number    string `json:"number"`
+	Timestamp string `json:"ts"`
+}
+
 // ObjectREST represents an object/dimension from the REST API.
 type ObjectREST struct {
 	Code   string `json:"code"`
@@ -127,10 +137,21 @@ type ProjectREST struct {
 
 // InvoiceListParams specifies parameters for listing invoices via REST.
 type InvoiceListParams struct {
-	DateFrom string // Filter: date=>YYYY-MM-DDTHH:mm:ss
-	DateTo   string // Filter: date=<YYYY-MM-DDTHH:mm:ss
-	TSFrom   string // Filter: ts=>timestamp (for incremental sync)
-	Status   string // Filter: status=X
+	DateFrom     string // Filter: date=>YYYY-MM-DDTHH:mm:ss
+	DateTo       string // Filter: date=<YYYY-MM-DDTHH:mm:ss
+	TSFrom       string // Filter: ts=>timestamp (for incremental sync)
+	TSTo         string // Filter: ts=<timestamp
+	Status       string // Filter: status=X
+	CustomerCode string // Filter: customer_code=X
+	NumberFrom   string // Filter: number=>X
+	NumberTo     string // Filter: number=<X
+}
+
+// CustomerListParams specifies parameters for listing customers via REST.
+type CustomerListParams struct {
+	Code   string
+	Email  string
+	TSFrom string
 }
 
 // ItemListParams specifies parameters for listing items via REST.
@@ -146,4 +167,5 @@ type PaymentListParams struct {
 	DateFrom string
 	DateTo   string
 	TSFrom   string
+	TSTo     string
 }
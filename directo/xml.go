@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/qbitsoftware/accounting-service/redact"
+	"github.com/qbitsoftware/accounting-service/resilience"
 )
 
 // xmlClient handles read+write operations via the Directo XML Direct API.
@@ -17,9 +19,11 @@ import (
 // router rejects requests with routing params in the body, returning
 // <result type="404" desc="Invalid url given"/>.
 type xmlClient struct {
-	baseURL    string
-	token      string
-	httpClient *http.Client
+	baseURL string
+	token   string
+	exec    *resilience.Executor
+	logger  *slog.Logger
+	redact  *redact.Policy
 }
 
 // XMLResult represents a single result entry from the XML Direct API response.
@@ -60,26 +64,26 @@ func (c *xmlClient) xmlPut(ctx context.Context, what string, xmlData string, ext
 	}
 
 	reqURL := c.baseURL + "?" + query.Encode()
-	slog.Info("directo xml put", "url", reqURL, "what", what, "xmldata", xmlData)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, strings.NewReader(postBody.Encode()))
-	if err != nil {
-		return nil, fmt.Errorf("directo xml: create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := c.httpClient.Do(req)
+	c.logger.Info("directo xml put", "url", c.redact.URL(reqURL), "what", what)
+	c.logger.Debug("directo xml put payload", "what", what, "xmldata", c.redact.XML(xmlData))
+
+	// Puts are not idempotent (a confirmed document re-sent is rejected as
+	// a duplicate), so they are only retried when rate-limited.
+	resp, err := c.exec.Do(ctx, "put:"+what, false, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, strings.NewReader(postBody.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("directo xml: create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("directo xml: send request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("directo xml: read response: %w", err)
-	}
+	body := resp.Body
 
-	slog.Info("directo xml put response", "what", what, "status", resp.StatusCode, "body", string(body))
+	c.logger.Info("directo xml put response", "what", what, "status", resp.StatusCode, "body_len", len(body))
+	c.logger.Debug("directo xml put response payload", "what", what, "body", c.redact.Body(body))
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return nil, &APIError{
@@ -144,6 +148,7 @@ func xmlResultsError(results XMLResults) error {
 				StatusCode: 401,
 				Message:    r.Desc,
 				Source:     "xml",
+				ResultType: r.Type,
 			}
 		}
 		if !directoSuccessResultTypes[r.Type] {
@@ -158,6 +163,7 @@ func xmlResultsError(results XMLResults) error {
 				StatusCode: 400,
 				Message:    fmt.Sprintf("directo result type %s: %s", r.Type, msg),
 				Source:     "xml",
+				ResultType: r.Type,
 			}
 		}
 		if r.Error != "" {
@@ -186,25 +192,22 @@ func (c *xmlClient) xmlGet(ctx context.Context, what string, extraParams url.Val
 
 	reqURL := c.baseURL + "?" + params.Encode()
 
-	slog.Info("directo xml get", "what", what, "url", reqURL)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("directo xml: create request: %w", err)
-	}
+	c.logger.Info("directo xml get", "what", what, "url", c.redact.URL(reqURL))
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.exec.Do(ctx, "get:"+what, true, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("directo xml: create request: %w", err)
+		}
+		return req, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("directo xml: send request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("directo xml: read response: %w", err)
-	}
+	body := resp.Body
 
-	slog.Info("directo xml get response", "what", what, "status", resp.StatusCode, "body_len", len(body))
+	c.logger.Info("directo xml get response", "what", what, "status", resp.StatusCode, "body_len", len(body))
+	c.logger.Debug("directo xml get response payload", "what", what, "body", c.redact.Body(body))
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return nil, &APIError{
@@ -0,0 +1,326 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/directo/directotest"
+	"github.com/qbitsoftware/accounting-service/excellentbooks/ebtest"
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/qbitsoftware/accounting-service/smartaccounts/satest"
+)
+
+// End-to-end adapter tests against the local wire-protocol emulators: the
+// full path from Client through adapter, provider client, signing and
+// encoding to an httptest server, with no live accounts.
+
+var (
+	e2eDocDate = time.Now().UTC().Truncate(24 * time.Hour)
+	e2eDueDate = e2eDocDate.AddDate(0, 0, 14)
+)
+
+func newEmulatorClient(t *testing.T, cfg Config) *Client {
+	t.Helper()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate(%s): %v", cfg.Provider, err)
+	}
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient(%s): %v", cfg.Provider, err)
+	}
+	return client
+}
+
+func e2eInvoice(customerID, customerName, no, taxID string) CreateInvoiceInput {
+	return CreateInvoiceInput{
+		CustomerID:   customerID,
+		CustomerName: customerName,
+		DocDate:      e2eDocDate,
+		DueDate:      e2eDueDate,
+		InvoiceNo:    no,
+		Currency:     "EUR",
+		Lines: []CreateInvoiceLineInput{
+			{Code: "FEE", Description: "Membership fee", Quantity: d("2"), UnitPrice: d("50"), TaxID: taxID},
+		},
+	}
+}
+
+func TestEmulator_Merit(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "merit",
+		APIID:    mc.APIID,
+		APIKey:   mc.APIKey,
+		Extra:    map[string]string{"api_url": mc.APIURL},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	inv, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "M-1", srv.TaxID(22)))
+	if err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+
+	_, err = client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "M-1", srv.TaxID(22)))
+	if !IsDuplicateInvoiceError(err) {
+		t.Errorf("duplicate invoice: err = %v, want IsDuplicateInvoiceError", err)
+	}
+
+	if err := client.Payments.Create(ctx, CreatePaymentInput{
+		CustomerName: cust.Name,
+		InvoiceNo:    "M-1",
+		PaymentDate:  e2eDocDate,
+		Amount:       d("122"),
+		Currency:     "EUR",
+	}); err != nil {
+		t.Fatalf("Payments.Create: %v", err)
+	}
+	got, err := client.Invoices.Get(ctx, inv.ID)
+	if err != nil {
+		t.Fatalf("Invoices.Get: %v", err)
+	}
+	// Merit's TotalAmount is net of VAT.
+	if !got.TotalAmount.Equal(d("100")) || !got.TaxAmount.Equal(d("22")) || !got.Paid {
+		t.Errorf("invoice total=%s tax=%s paid=%v, want 100+22 paid", got.TotalAmount, got.TaxAmount, got.Paid)
+	}
+
+	// A single 429 is retried transparently; with retries disabled it
+	// surfaces as ErrRateLimit.
+	srv.InjectFault("v2/getinvoice", merittest.Fault{Status: http.StatusTooManyRequests, Body: "Too many requests"})
+	if _, err := client.Invoices.Get(ctx, inv.ID); err != nil {
+		t.Errorf("rate-limited Get: err = %v, want retried success", err)
+	}
+	noRetry := newEmulatorClient(t, Config{
+		Provider:   "merit",
+		APIID:      mc.APIID,
+		APIKey:     mc.APIKey,
+		Extra:      map[string]string{"api_url": mc.APIURL},
+		Resilience: &resilience.Policy{MaxRetries: -1},
+	})
+	srv.InjectFault("v2/getinvoice", merittest.Fault{Status: http.StatusTooManyRequests, Body: "Too many requests"})
+	if _, err := noRetry.Invoices.Get(ctx, inv.ID); !errors.Is(err, ErrRateLimit) {
+		t.Errorf("rate-limited Get without retries: err = %v, want ErrRateLimit", err)
+	}
+
+	bad := newEmulatorClient(t, Config{
+		Provider: "merit",
+		APIID:    mc.APIID,
+		APIKey:   "wrong",
+		Extra:    map[string]string{"api_url": mc.APIURL},
+	})
+	if err := bad.TestConnection(ctx); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("bad key: err = %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestEmulator_Directo(t *testing.T) {
+	ctx := context.Background()
+	srv := directotest.NewServer()
+	defer srv.Close()
+	dc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "directo",
+		APIID:    dc.Company,
+		APIKey:   dc.Token,
+		Extra: map[string]string{
+			"rest_api_key":  dc.RestAPIKey,
+			"rest_base_url": dc.RESTBaseURL,
+			"xml_base_url":  dc.XMLBaseURL,
+		},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Code: "C1", Name: "Acme OÜ", Email: "acme@example.com"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	input := e2eInvoice(cust.ID, cust.Name, "D-1", "1")
+	input.AutoConfirm = true
+	inv, err := client.Invoices.Create(ctx, input)
+	if err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+	if err := client.Payments.Create(ctx, CreatePaymentInput{
+		CustomerCode: cust.ID,
+		PaymentNo:    "R-" + inv.Number,
+		InvoiceNo:    inv.Number,
+		PaymentDate:  e2eDocDate,
+		Amount:       d("122"),
+		Currency:     "EUR",
+		BankID:       "K",
+		AutoConfirm:  true,
+	}); err != nil {
+		t.Fatalf("Payments.Create: %v", err)
+	}
+	got, err := client.Invoices.Get(ctx, inv.Number)
+	if err != nil {
+		t.Fatalf("Invoices.Get: %v", err)
+	}
+	if !got.TotalAmount.Equal(d("122")) || !got.Paid {
+		t.Errorf("invoice total=%s paid=%v, want 122 paid", got.TotalAmount, got.Paid)
+	}
+
+	srv.InjectFault("put:receipt", directotest.ResultFault("12", "Missing document identificator"))
+	err = client.Payments.Create(ctx, CreatePaymentInput{
+		CustomerCode: cust.ID,
+		PaymentNo:    "R-2",
+		InvoiceNo:    inv.Number,
+		PaymentDate:  e2eDocDate,
+		Amount:       d("1"),
+		BankID:       "K",
+	})
+	if err == nil || !strings.Contains(err.Error(), "Missing document identificator") {
+		t.Errorf("result type 12: err = %v, want the Directo result description", err)
+	}
+}
+
+func TestEmulator_ExcellentBooks(t *testing.T) {
+	ctx := context.Background()
+	srv := ebtest.NewServer()
+	defer srv.Close()
+	ec := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "excellentbooks",
+		APIID:    ec.Username,
+		APIKey:   ec.Password,
+		Extra:    map[string]string{"base_url": ec.BaseURL, "company_code": ec.CompanyCode},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Code: "C1", Name: "Acme OÜ"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	inv, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "", "1"))
+	if err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+	if !inv.TotalAmount.Equal(d("122")) {
+		t.Errorf("invoice total = %s, want 122", inv.TotalAmount)
+	}
+	if err := client.Payments.Create(ctx, CreatePaymentInput{
+		CustomerCode: cust.ID,
+		InvoiceNo:    inv.Number,
+		PaymentDate:  e2eDocDate,
+		Amount:       d("122"),
+		BankID:       "PANK",
+	}); err != nil {
+		t.Fatalf("Payments.Create: %v", err)
+	}
+
+	// EB reports validation failures in 200 responses.
+	srv.InjectFault("POST CUVc", ebtest.ErrorPayload(ebtest.CodeInvalidValue, "Name", "Invalid value"))
+	if _, err := client.Customers.Create(ctx, CreateCustomerInput{Code: "C2", Name: "Beta AS"}); err == nil {
+		t.Error("error payload in 200 response: want error")
+	}
+
+	// A PATCH whose write landed but whose response body is garbage is
+	// treated as success; a truncated nested error is not.
+	renamed, regrouped := "Acme AS", "Acme Grupp"
+	srv.InjectFault("PATCH CUVc", ebtest.MalformedPATCH())
+	if err := client.Customers.Update(ctx, UpdateCustomerInput{ID: cust.ID, Name: &renamed}); err != nil {
+		t.Errorf("malformed PATCH body: err = %v, want nil", err)
+	}
+	srv.InjectFault("PATCH CUVc", ebtest.NestedPATCHError(ebtest.CodeInvalidValue, "Name", "Invalid value"))
+	if err := client.Customers.Update(ctx, UpdateCustomerInput{ID: cust.ID, Name: &regrouped}); err == nil {
+		t.Error("nested PATCH error: want error")
+	}
+}
+
+func TestEmulator_SmartAccounts(t *testing.T) {
+	ctx := context.Background()
+	srv := satest.NewServer()
+	defer srv.Close()
+	sc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider:   "smartaccounts",
+		APIID:      sc.APIKey,
+		APIKey:     sc.SecretKey,
+		Region:     sc.Host,
+		HTTPClient: sc.HTTPClient,
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	// A 503 with Retry-After is retried transparently.
+	srv.Throttle(1, time.Second)
+	inv, err := client.Invoices.Create(ctx, e2eInvoice("", cust.Name, "S-1", "KM22"))
+	if err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+	if !inv.TotalAmount.Equal(d("122")) {
+		t.Errorf("invoice total = %s, want 122", inv.TotalAmount)
+	}
+	if err := client.Payments.Create(ctx, CreatePaymentInput{
+		InvoiceNo:   "S-1",
+		PaymentDate: e2eDocDate,
+		Amount:      d("122"),
+		Currency:    "EUR",
+		BankID:      "LHV",
+	}); err != nil {
+		t.Fatalf("Payments.Create: %v", err)
+	}
+	got, err := client.Invoices.Get(ctx, inv.ID)
+	if err != nil {
+		t.Fatalf("Invoices.Get: %v", err)
+	}
+	if !got.Paid {
+		t.Errorf("invoice status = %s, want paid", got.Status)
+	}
+
+	bad := newEmulatorClient(t, Config{
+		Provider:   "smartaccounts",
+		APIID:      sc.APIKey,
+		APIKey:     "wrong",
+		Region:     sc.Host,
+		HTTPClient: sc.HTTPClient,
+	})
+	if err := bad.TestConnection(ctx); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("bad secret: err = %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestEmulator_LogRedaction(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+
+	var buf bytes.Buffer
+	client := newEmulatorClient(t, Config{
+		Provider: "merit",
+		APIID:    mc.APIID,
+		APIKey:   mc.APIKey,
+		Extra:    map[string]string{"api_url": mc.APIURL},
+		Logger:   slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678", Email: "billing@acme.example"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	if _, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "M-1", srv.TaxID(22))); err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, "merit api request payload") {
+		t.Fatalf("no Debug payload logged:\n%s", logs)
+	}
+	for _, pii := range []string{"Acme", "12345678", "billing@acme.example", mc.APIID} {
+		if strings.Contains(logs, pii) {
+			t.Errorf("logs leak %q:\n%s", pii, logs)
+		}
+	}
+}
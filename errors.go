@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/qbitsoftware/accounting-service/resilience"
 )
 
 var (
@@ -12,8 +14,70 @@ var (
 	ErrRateLimit           = errors.New("rate limit exceeded")
 	ErrUnsupportedProvider = errors.New("unsupported provider")
 	ErrInvalidInput        = errors.New("invalid input")
+
+	// ErrNotSupported means the provider does not implement the operation;
+	// Capabilities reports the same ahead of the call.
+	ErrNotSupported = errors.New("not supported")
+	// ErrDuplicate means a document or register entry with the same number
+	// or code already exists (Merit "Korduv arve", Directo result type 11).
+	ErrDuplicate = errors.New("duplicate")
+	// ErrValidation means the provider rejected the input. The error is a
+	// *ValidationError, which names the field when the provider does.
+	ErrValidation = errors.New("validation failed")
+	// ErrConflict means the request clashes with the document's current
+	// state, e.g. changing an approved invoice or deleting a paid one.
+	ErrConflict = errors.New("conflict")
+	// ErrPeriodClosed means the document date falls in a closed (locked)
+	// accounting period.
+	ErrPeriodClosed = errors.New("accounting period closed")
+	// ErrTransient is a provider-side failure (5xx) that may succeed when
+	// retried.
+	ErrTransient = errors.New("transient provider error")
 )
 
+// ValidationError is a provider's rejection of the input. errors.Is matches
+// it against ErrValidation; errors.As exposes the field.
+type ValidationError struct {
+	Field   string // Provider field the error refers to (e.g. EB "CustCode"); empty when not reported
+	Code    string // Native error code (EB @code, Directo result type, SmartAccounts code)
+	Message string
+	Err     error // The provider client's error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%v: field %s: %s", ErrValidation, e.Field, e.Message)
+	}
+	return fmt.Sprintf("%v: %s", ErrValidation, e.Message)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// withKind tags a provider client's error with one of the sentinels above,
+// keeping the native message: errors.Is matches both.
+func withKind(kind, err error) error {
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+// periodClosedMessage reports whether a provider message says the document
+// date is in a closed period. None of the providers has a dedicated code
+// for it, so the English and Estonian wordings are matched.
+func periodClosedMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, s := range []string{"period is closed", "period closed", "closed period", "periood on suletud", "suletud periood", "periood on lukus"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
 // ProviderError wraps an error with provider and operation context.
 type ProviderError struct {
 	Provider string
@@ -41,21 +105,54 @@ func IsRateLimit(err error) bool {
 	return errors.Is(err, ErrRateLimit)
 }
 
+func IsNotSupported(err error) bool {
+	return errors.Is(err, ErrNotSupported)
+}
+
+func IsDuplicate(err error) bool {
+	return errors.Is(err, ErrDuplicate)
+}
+
+func IsValidation(err error) bool {
+	return errors.Is(err, ErrValidation)
+}
+
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+func IsPeriodClosed(err error) bool {
+	return errors.Is(err, ErrPeriodClosed)
+}
+
+// IsRetryable reports whether repeating the call later may succeed: rate
+// limiting, transient provider failures and an open circuit breaker.
+// Validation, duplicate and other definitive rejections are not.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimit) || errors.Is(err, ErrTransient) || errors.Is(err, resilience.ErrCircuitOpen)
+}
+
 // IsCustomerExistsError reports whether err is a Merit "customer already
-// exists" error. Merit returns these as plain-text body containing
-// "custexists" rather than a structured status — string matching is the
-// only reliable signal today.
+// exists" error. The adapter maps Merit's plain-text "custexists" body to
+// ErrDuplicate; the text is still matched for errors that did not pass
+// through an adapter.
 func IsCustomerExistsError(err error) bool {
 	return err != nil && strings.Contains(err.Error(), "custexists")
 }
 
 // IsDuplicateInvoiceError reports whether err signals that the provider
-// already has an invoice with the same number. Merit returns "Korduv arve"
-// (Estonian); other providers may surface English variants — we match both.
+// already has an invoice with the same number: an ErrDuplicate from
+// creating an invoice or credit note. Merit's "Korduv arve" and English
+// "duplicate invoice" texts are still matched for errors that did not pass
+// through an adapter.
 func IsDuplicateInvoiceError(err error) bool {
 	if err == nil {
 		return false
 	}
+	var pe *ProviderError
+	if errors.Is(err, ErrDuplicate) && errors.As(err, &pe) && (pe.Op == "CreateInvoice" || pe.Op == "CreateCreditNote") {
+		return true
+	}
 	msg := strings.ToLower(err.Error())
 	return strings.Contains(msg, "korduv arve") || strings.Contains(msg, "duplicate invoice")
 }
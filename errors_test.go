@@ -0,0 +1,134 @@
+package accounting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qbitsoftware/accounting-service/directo"
+	"github.com/qbitsoftware/accounting-service/excellentbooks"
+	"github.com/qbitsoftware/accounting-service/merit"
+	"github.com/qbitsoftware/accounting-service/smartaccounts"
+)
+
+func TestProviderErrorKinds(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		kind      error
+		field     string // ValidationError.Field, when kind is ErrValidation
+		retryable bool
+	}{
+		{
+			name: "merit duplicate invoice",
+			err:  (&meritProvider{}).wrapError("CreateInvoice", &merit.APIError{StatusCode: 400, Message: "Korduv arve number M-1"}),
+			kind: ErrDuplicate,
+		},
+		{
+			name: "merit closed period",
+			err:  (&meritProvider{}).wrapError("CreateInvoice", &merit.APIError{StatusCode: 400, Message: "Periood on suletud"}),
+			kind: ErrPeriodClosed,
+		},
+		{
+			name: "merit bad request",
+			err:  (&meritProvider{}).wrapError("CreateInvoice", &merit.APIError{StatusCode: 400, Message: "Vigane kuupäev"}),
+			kind: ErrValidation,
+		},
+		{
+			name:      "merit server error",
+			err:       (&meritProvider{}).wrapError("ListInvoices", &merit.APIError{StatusCode: 502, Message: "Bad Gateway"}),
+			kind:      ErrTransient,
+			retryable: true,
+		},
+		{
+			name:  "directo missing document number",
+			err:   (&directoProvider{}).wrapError("CreateInvoice", &directo.APIError{StatusCode: 400, Message: "directo result type 12: Missing document identificator", Source: "xml", ResultType: "12"}),
+			kind:  ErrValidation,
+			field: "number",
+		},
+		{
+			name: "directo duplicate",
+			err:  (&directoProvider{}).wrapError("CreateInvoice", &directo.APIError{StatusCode: 400, Source: "xml", ResultType: "11"}),
+			kind: ErrDuplicate,
+		},
+		{
+			name: "directo auth",
+			err:  (&directoProvider{}).wrapError("CreateInvoice", &directo.APIError{StatusCode: 401, Source: "xml", ResultType: "5"}),
+			kind: ErrAuthFailed,
+		},
+		{
+			name:  "excellentbooks value not in use",
+			err:   (&excellentProvider{}).wrapError("CreateInvoice", &excellentbooks.APIError{StatusCode: 200, Message: "Väärtust ei ole kasutusel", ErrorCode: "1256", ErrorField: "CustCode"}),
+			kind:  ErrValidation,
+			field: "CustCode",
+		},
+		{
+			name: "excellentbooks duplicate",
+			err:  (&excellentProvider{}).wrapError("CreateInvoice", &excellentbooks.APIError{StatusCode: 200, Message: "Kood on juba kasutusel", ErrorCode: "1557", ErrorField: "SerNr"}),
+			kind: ErrDuplicate,
+		},
+		{
+			name:  "excellentbooks no number series",
+			err:   (&excellentProvider{}).wrapError("CreateInvoice", &excellentbooks.APIError{StatusCode: 200, Message: "Ei ole määratud numbriseerias", ErrorCode: "1557", ErrorField: "SerNr"}),
+			kind:  ErrValidation,
+			field: "SerNr",
+		},
+		{
+			name: "excellentbooks approved record",
+			err:  (&excellentProvider{}).wrapError("UpdateCustomer", &excellentbooks.APIError{StatusCode: 200, ErrorCode: "20060"}),
+			kind: ErrConflict,
+		},
+		{
+			name: "smartaccounts duplicate number",
+			err:  (&smartProvider{}).wrapError("CreateInvoice", &smartaccounts.APIError{StatusCode: 400, Code: "INVOICE-NUMBER-EXISTS"}),
+			kind: ErrDuplicate,
+		},
+		{
+			name:  "smartaccounts missing field",
+			err:   (&smartProvider{}).wrapError("ListInvoices", &smartaccounts.APIError{StatusCode: 400, Code: "DATE-FROM-MISSING"}),
+			kind:  ErrValidation,
+			field: "dateFrom",
+		},
+		{
+			name: "smartaccounts invoice has payments",
+			err:  (&smartProvider{}).wrapError("DeleteInvoice", &smartaccounts.APIError{StatusCode: 400, Code: "INVOICE-HAS-PAYMENTS"}),
+			kind: ErrConflict,
+		},
+		{
+			name:      "smartaccounts unavailable",
+			err:       (&smartProvider{}).wrapError("ListInvoices", &smartaccounts.APIError{StatusCode: 503}),
+			kind:      ErrRateLimit,
+			retryable: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, tt.kind) {
+				t.Fatalf("err = %v, want %v", tt.err, tt.kind)
+			}
+			if got := IsRetryable(tt.err); got != tt.retryable {
+				t.Errorf("IsRetryable = %v, want %v", got, tt.retryable)
+			}
+			if tt.kind != ErrValidation {
+				return
+			}
+			var ve *ValidationError
+			if !errors.As(tt.err, &ve) {
+				t.Fatalf("err = %v, want a *ValidationError", tt.err)
+			}
+			if ve.Field != tt.field {
+				t.Errorf("Field = %q, want %q", ve.Field, tt.field)
+			}
+		})
+	}
+}
+
+func TestIsDuplicateInvoiceError_Typed(t *testing.T) {
+	err := (&smartProvider{}).wrapError("CreateInvoice", &smartaccounts.APIError{StatusCode: 400, Code: "INVOICE-NUMBER-EXISTS"})
+	if !IsDuplicateInvoiceError(err) {
+		t.Errorf("IsDuplicateInvoiceError(%v) = false, want true", err)
+	}
+	err = (&smartProvider{}).wrapError("CreateCustomer", &smartaccounts.APIError{StatusCode: 400, Code: "CLIENT-EXISTS"})
+	if IsDuplicateInvoiceError(err) {
+		t.Errorf("IsDuplicateInvoiceError(%v) = true for a customer", err)
+	}
+}
@@ -17,16 +17,16 @@ func (c *Client) ListGLAccounts(ctx context.Context, params ListParams) ([]GLAcc
 	if err != nil {
 		return nil, "", err
 	}
-	return parseGLAccountResponse(resp)
+	return c.parseGLAccountResponse(resp)
 }
 
-func parseGLAccountResponse(resp *Response) ([]GLAccount, string, error) {
+func (c *Client) parseGLAccountResponse(resp *Response) ([]GLAccount, string, error) {
 	var envelope struct {
 		ResponseMeta
 		AccVc []GLAccount `json:"AccVc"`
 	}
 	if err := json.Unmarshal(resp.Data, &envelope); err != nil {
-		slog.Error("excellentbooks: parse gl accounts failed", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelError, "excellentbooks: parse gl accounts failed", resp.Data, "error", err)
 		return nil, "", fmt.Errorf("excellentbooks: parse gl accounts: %w", err)
 	}
 
@@ -42,12 +42,13 @@ func parseGLAccountResponse(resp *Response) ([]GLAccount, string, error) {
 		for k := range first {
 			keys = append(keys, k)
 		}
-		slog.Info("excellentbooks: AccVc first-row field probe (Phase 3 diagnostic)",
-			"keys", keys, "first_row", first)
+		row, _ := json.Marshal(first)
+		c.logger.Debug("excellentbooks: AccVc first-row field probe (Phase 3 diagnostic)",
+			"keys", keys, "first_row", c.redact.JSON(row))
 	}
 
 	if len(envelope.AccVc) == 0 {
-		slog.Warn("excellentbooks: AccVc returned 0 items", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelWarn, "excellentbooks: AccVc returned 0 items", resp.Data)
 	}
 	return envelope.AccVc, envelope.Sequence, nil
 }
@@ -14,7 +14,14 @@
 //	customers, err := client.ListCustomers(ctx, excellentbooks.ListParams{Limit: 100})
 package excellentbooks
 
-import "net/http"
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/qbitsoftware/accounting-service/redact"
+	"github.com/qbitsoftware/accounting-service/resilience"
+)
 
 // Config holds the configuration for an Excellent Books API client.
 type Config struct {
@@ -35,6 +42,19 @@ type Config struct {
 	// HTTPClient is an optional HTTP client for making requests.
 	// Defaults to http.DefaultClient if nil.
 	HTTPClient *http.Client
+
+	// Resilience configures retries, throttling, attempt timeouts and the
+	// circuit breaker. Nil uses the resilience package defaults. Only GETs
+	// are retried after ambiguous failures.
+	Resilience *resilience.Policy
+
+	// Logger receives request and response logs. Nil uses slog.Default().
+	// Form payloads and response bodies are logged at Debug only.
+	Logger *slog.Logger
+
+	// Redaction selects the fields masked in logged payloads and URLs. Nil
+	// uses redact.Default().
+	Redaction *redact.Policy
 }
 
 // Client is an Excellent Books API client.
@@ -43,7 +63,9 @@ type Client struct {
 	companyCode string
 	username    string
 	password    string
-	httpClient  *http.Client
+	exec        *resilience.Executor
+	logger      *slog.Logger
+	redact      *redact.Policy
 }
 
 // New creates a new Excellent Books API client.
@@ -57,11 +79,52 @@ func New(cfg Config) *Client {
 		companyCode = "1"
 	}
 
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var policy resilience.Policy
+	if cfg.Resilience != nil {
+		policy = *cfg.Resilience
+	}
+	if policy.Logger == nil {
+		policy.Logger = logger
+	}
+
 	return &Client{
 		baseURL:     cfg.BaseURL,
 		companyCode: companyCode,
 		username:    cfg.Username,
 		password:    cfg.Password,
-		httpClient:  httpClient,
+		exec:        resilience.New("excellentbooks", policy, httpClient),
+		logger:      logger,
+		redact:      cfg.Redaction,
 	}
 }
+
+// Logger returns the logger the client was configured with, so callers
+// layering on the client can log to the same destination.
+func (c *Client) Logger() *slog.Logger { return c.logger }
+
+// CircuitState reports the circuit breaker state for this client's
+// provider; StateOpen means Excellent Books is currently considered
+// unhealthy.
+func (c *Client) CircuitState() resilience.State {
+	return c.exec.State()
+}
+
+// Format prints c with Password masked.
+func (c Config) Format(f fmt.State, verb rune) {
+	type config Config // drops the methods, so formatting does not recurse
+	out := config(c)
+	out.Password = redact.Secret(c.Password)
+	fmt.Fprintf(f, fmt.FormatString(f, verb), out)
+}
+
+// String identifies the client without its secrets.
+func (c *Client) String() string {
+	return fmt.Sprintf("excellentbooks.Client{BaseURL: %s, CompanyCode: %s, Username: %s}", c.baseURL, c.companyCode, c.username)
+}
+
+func (c *Client) GoString() string { return c.String() }
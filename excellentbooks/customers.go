@@ -4,17 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 )
 
 const registerCustomer = "CUVc"
 
 // ListCustomers retrieves contacts/customers.
 func (c *Client) ListCustomers(ctx context.Context, params ListParams) ([]Customer, string, error) {
-	resp, err := c.get(ctx, registerCustomer, params)
-	if err != nil {
-		return nil, "", err
-	}
-	return parseCustomerResponse(resp)
+	return list[Customer](ctx, c, registerCustomer, params)
+}
+
+// StreamCustomers yields the customers ListCustomers returns one at a time, decoding the
+// response as it arrives.
+func (c *Client) StreamCustomers(ctx context.Context, params ListParams) iter.Seq2[Customer, error] {
+	return stream[Customer](ctx, c, registerCustomer, params)
 }
 
 // GetCustomer retrieves a single customer by code.
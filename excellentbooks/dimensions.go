@@ -24,11 +24,11 @@ func (c *Client) ListObjects(ctx context.Context, params ListParams) ([]Object,
 		ObjVc []Object `json:"ObjVc"`
 	}
 	if err := json.Unmarshal(resp.Data, &envelope); err != nil {
-		slog.Error("excellentbooks: parse objects failed", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelError, "excellentbooks: parse objects failed", resp.Data, "error", err)
 		return nil, "", fmt.Errorf("excellentbooks: parse objects: %w", err)
 	}
 	if len(envelope.ObjVc) == 0 {
-		slog.Warn("excellentbooks: ObjVc returned 0 items", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelWarn, "excellentbooks: ObjVc returned 0 items", resp.Data)
 	}
 	return envelope.ObjVc, envelope.Sequence, nil
 }
@@ -44,11 +44,11 @@ func (c *Client) ListProjects(ctx context.Context, params ListParams) ([]Project
 		PRVc []Project `json:"PRVc"`
 	}
 	if err := json.Unmarshal(resp.Data, &envelope); err != nil {
-		slog.Error("excellentbooks: parse projects failed", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelError, "excellentbooks: parse projects failed", resp.Data, "error", err)
 		return nil, "", fmt.Errorf("excellentbooks: parse projects: %w", err)
 	}
 	if len(envelope.PRVc) == 0 {
-		slog.Warn("excellentbooks: PRVc returned 0 items", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelWarn, "excellentbooks: PRVc returned 0 items", resp.Data)
 	}
 	return envelope.PRVc, envelope.Sequence, nil
 }
@@ -64,11 +64,11 @@ func (c *Client) ListDepartments(ctx context.Context, params ListParams) ([]Depa
 		DepVc []Department `json:"DepVc"`
 	}
 	if err := json.Unmarshal(resp.Data, &envelope); err != nil {
-		slog.Error("excellentbooks: parse departments failed", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelError, "excellentbooks: parse departments failed", resp.Data, "error", err)
 		return nil, "", fmt.Errorf("excellentbooks: parse departments: %w", err)
 	}
 	if len(envelope.DepVc) == 0 {
-		slog.Warn("excellentbooks: DepVc returned 0 items", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelWarn, "excellentbooks: DepVc returned 0 items", resp.Data)
 	}
 	return envelope.DepVc, envelope.Sequence, nil
 }
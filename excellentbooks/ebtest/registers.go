@@ -0,0 +1,339 @@
+package ebtest
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ebError is a rejected write. Validation failures use HTTP 200, the way
+// EB reports them.
+type ebError struct {
+	status int
+	code   string
+	field  string
+	desc   string
+}
+
+func invalid(field, value string) *ebError {
+	return &ebError{status: http.StatusOK, code: CodeInvalidValue, field: field, desc: "Code " + value + " not in use"}
+}
+
+// list applies updates_after, filter.X, sort/range, offset/limit and
+// fields to a register's records.
+func (s *Server) list(register string, q url.Values) []*record {
+	var after int64 = -1
+	if v := q.Get("updates_after"); v != "" {
+		after, _ = strconv.ParseInt(v, 10, 64)
+	}
+	filters := map[string]string{}
+	for k, vs := range q {
+		if strings.HasPrefix(k, "filter.") && len(vs) > 0 {
+			filters[strings.TrimPrefix(k, "filter.")] = vs[0]
+		}
+	}
+	sortField := q.Get("sort")
+	rangeField := sortField
+	if rangeField == "" {
+		rangeField = registers[register].key
+	}
+	lo, hi, hasRange := strings.Cut(q.Get("range"), ":")
+
+	var out []*record
+	for _, r := range s.data[register] {
+		if after >= 0 {
+			seq, _ := strconv.ParseInt(r.fields["@sequence"], 10, 64)
+			if seq <= after {
+				continue
+			}
+		}
+		ok := true
+		for f, v := range filters {
+			if r.fields[f] != v {
+				ok = false
+				break
+			}
+		}
+		if ok && hasRange {
+			v := r.fields[rangeField]
+			if (lo != "" && compare(v, lo) < 0) || (hi != "" && compare(v, hi) > 0) {
+				ok = false
+			}
+		}
+		if ok {
+			out = append(out, r)
+		}
+	}
+	switch {
+	case sortField != "":
+		sort.SliceStable(out, func(i, j int) bool {
+			return compare(out[i].fields[sortField], out[j].fields[sortField]) < 0
+		})
+	case after >= 0:
+		sort.SliceStable(out, func(i, j int) bool {
+			return compare(out[i].fields["@sequence"], out[j].fields["@sequence"]) < 0
+		})
+	}
+
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	if offset >= len(out) {
+		out = nil
+	} else {
+		out = out[offset:]
+	}
+	if limit, _ := strconv.Atoi(q.Get("limit")); limit > 0 && limit < len(out) {
+		out = out[:limit]
+	}
+	if fields := q.Get("fields"); fields != "" {
+		out = project(out, strings.Split(fields, ","))
+	}
+	if out == nil {
+		out = []*record{}
+	}
+	return out
+}
+
+// compare orders numerically when both values are numbers and as strings
+// otherwise; ISO dates order correctly either way.
+func compare(a, b string) int {
+	x, errA := decimal.NewFromString(a)
+	y, errB := decimal.NewFromString(b)
+	if errA == nil && errB == nil {
+		return x.Cmp(y)
+	}
+	return strings.Compare(a, b)
+}
+
+func project(recs []*record, fields []string) []*record {
+	out := make([]*record, len(recs))
+	for i, r := range recs {
+		p := &record{fields: map[string]string{}}
+		for _, f := range fields {
+			f = strings.TrimSpace(f)
+			if f == "rows" {
+				p.rows = r.rows
+			} else if v, ok := r.fields[f]; ok {
+				p.fields[f] = v
+			}
+		}
+		out[i] = p
+	}
+	return out
+}
+
+func (s *Server) create(register string, header map[string]string, rows []map[string]string) (*record, *ebError) {
+	spec := registers[register]
+	key := header[spec.key]
+	if key != "" && s.find(register, key) != nil {
+		return nil, &ebError{status: http.StatusOK, code: CodeDuplicate, field: spec.key, desc: "Duplicate " + spec.key + " " + key}
+	}
+	if key == "" {
+		if !spec.serial {
+			return nil, &ebError{status: http.StatusOK, code: CodeInvalidValue, field: spec.key, desc: spec.key + " is required"}
+		}
+		for key == "" || s.find(register, key) != nil {
+			key = strconv.Itoa(s.serial[register])
+			s.serial[register]++
+		}
+	}
+	r := &record{fields: map[string]string{spec.key: key}}
+	if spec.rows {
+		r.rows = []map[string]string{}
+	}
+	if err := s.apply(register, r, header, rows, true); err != nil {
+		return nil, err
+	}
+	s.touch(register, r)
+	s.data[register] = append(s.data[register], r)
+	return r, nil
+}
+
+func (s *Server) update(register, id string, header map[string]string, rows []map[string]string) (*record, *ebError) {
+	r := s.find(register, id)
+	if r == nil {
+		return nil, &ebError{status: http.StatusNotFound, code: "404", desc: "Record " + id + " not found"}
+	}
+	if r.fields["OKFlag"] == "1" {
+		return nil, &ebError{status: http.StatusOK, code: CodeApproved, field: "OKFlag", desc: "Record is approved and can not be changed"}
+	}
+	if k, ok := header[registers[register].key]; ok && k != id {
+		return nil, &ebError{status: http.StatusOK, code: CodeInvalidValue, field: registers[register].key, desc: "Key fields can not be changed"}
+	}
+	// Validate against a copy so a rejected PATCH leaves the record intact.
+	next := &record{fields: map[string]string{}}
+	for k, v := range r.fields {
+		next.fields[k] = v
+	}
+	for _, row := range r.rows {
+		cp := map[string]string{}
+		for k, v := range row {
+			cp[k] = v
+		}
+		next.rows = append(next.rows, cp)
+	}
+	if err := s.apply(register, next, header, rows, false); err != nil {
+		return nil, err
+	}
+	r.fields, r.rows = next.fields, next.rows
+	s.touch(register, r)
+	return r, nil
+}
+
+// apply merges header and row values into r and runs the register's
+// validation and derived-field rules.
+func (s *Server) apply(register string, r *record, header map[string]string, rows []map[string]string, created bool) *ebError {
+	for k, v := range header {
+		r.fields[k] = v
+	}
+	for i, row := range rows {
+		for len(r.rows) <= i {
+			r.rows = append(r.rows, map[string]string{})
+		}
+		for k, v := range row {
+			r.rows[i][k] = v
+		}
+	}
+	today := s.Now().Format(dateFormat)
+	switch register {
+	case "CUVc":
+		if created {
+			r.fields["DateCreated"] = today
+			defaultField(r.fields, "CustType", "0")
+			defaultField(r.fields, "blockedFlag", "0")
+		}
+		r.fields["DateChanged"] = today
+		if pd := r.fields["PayDeal"]; pd != "" && s.find("PDVc", pd) == nil {
+			return invalid("PayDeal", pd)
+		}
+	case "INVc":
+		if vc := r.fields["VATCode"]; vc != "" && s.vatCode(vc) == nil {
+			return invalid("VATCode", vc)
+		}
+		defaultField(r.fields, "Terminated", "0")
+	case "IVVc":
+		return s.applyInvoice(r, today)
+	case "IPVc":
+		return s.applyReceipt(r, today)
+	}
+	return nil
+}
+
+func defaultField(fields map[string]string, k, v string) {
+	if fields[k] == "" {
+		fields[k] = v
+	}
+}
+
+func (s *Server) vatCode(code string) *decimal.Decimal {
+	for _, vc := range s.vatCodes {
+		if vc.Code == code {
+			pct, _ := decimal.NewFromString(vc.ExVatpr)
+			return &pct
+		}
+	}
+	return nil
+}
+
+func (s *Server) applyInvoice(r *record, today string) *ebError {
+	f := r.fields
+	cust := s.find("CUVc", f["CustCode"])
+	if cust == nil {
+		return invalid("CustCode", f["CustCode"])
+	}
+	defaultField(f, "InvDate", today)
+	defaultField(f, "TransDate", f["InvDate"])
+	defaultField(f, "InvType", "1")
+	defaultField(f, "OKFlag", "0")
+	defaultField(f, "CurncyCode", cust.fields["CurncyCode"])
+	defaultField(f, "CurncyCode", "EUR")
+	defaultField(f, "PayDeal", cust.fields["PayDeal"])
+	defaultField(f, "PayDeal", "14")
+	defaultField(f, "Addr0", cust.fields["Name"])
+	defaultField(f, "RegNr1", cust.fields["RegNr1"])
+	defaultField(f, "VATNr", cust.fields["VATNr"])
+	pd := s.find("PDVc", f["PayDeal"])
+	if pd == nil {
+		return invalid("PayDeal", f["PayDeal"])
+	}
+	invDate, err := time.Parse(dateFormat, f["InvDate"])
+	if err != nil {
+		return &ebError{status: http.StatusOK, code: CodeInvalidValue, field: "InvDate", desc: "Invalid date"}
+	}
+	days, _ := strconv.Atoi(pd.fields["pdays"])
+	f["PayDate"] = invDate.AddDate(0, 0, days).Format(dateFormat)
+	if f["InvType"] == "3" && f["CredInv"] != "" && s.find("IVVc", f["CredInv"]) == nil {
+		return invalid("CredInv", f["CredInv"])
+	}
+
+	net, vat, discount := decimal.Zero, decimal.Zero, decimal.Zero
+	hundred := decimal.NewFromInt(100)
+	for i, row := range r.rows {
+		row["@rownumber"] = strconv.Itoa(i)
+		defaultField(row, "stp", "1")
+		if row["stp"] != "1" {
+			continue
+		}
+		qty, _ := decimal.NewFromString(row["Quant"])
+		price, _ := decimal.NewFromString(row["Price"])
+		rebate, _ := decimal.NewFromString(row["vRebate"])
+		gross := qty.Mul(price)
+		disc := gross.Mul(rebate).Div(hundred).Round(2)
+		sum := gross.Sub(disc).Round(2)
+		row["Sum"] = sum.StringFixed(2)
+		pct := decimal.Zero
+		if code := row["VATCode"]; code != "" {
+			p := s.vatCode(code)
+			if p == nil {
+				return invalid("VATCode", code)
+			}
+			pct = *p
+		}
+		net = net.Add(sum)
+		discount = discount.Add(disc)
+		vat = vat.Add(sum.Mul(pct).Div(hundred).Round(2))
+	}
+	f["Sum0"] = discount.StringFixed(2)
+	f["Sum1"] = net.StringFixed(2)
+	f["Sum3"] = vat.StringFixed(2)
+	f["Sum4"] = net.Add(vat).StringFixed(2)
+	f["BaseSum4"] = f["Sum4"]
+	return nil
+}
+
+func (s *Server) applyReceipt(r *record, today string) *ebError {
+	defaultField(r.fields, "TransDate", today)
+	defaultField(r.fields, "OKFlag", "0")
+	defaultField(r.fields, "PayCurCode", "EUR")
+	if len(r.rows) == 0 {
+		return &ebError{status: http.StatusOK, code: CodeInvalidValue, field: "rows", desc: "Receipt has no rows"}
+	}
+	for i, row := range r.rows {
+		row["@rownumber"] = strconv.Itoa(i)
+		defaultField(row, "stp", "1")
+		if _, err := decimal.NewFromString(row["RecVal"]); err != nil {
+			return &ebError{status: http.StatusOK, code: CodeInvalidValue, field: "RecVal", desc: "Invalid amount"}
+		}
+		defaultField(row, "BankVal", row["RecVal"])
+		defaultField(row, "PayDate", r.fields["TransDate"])
+		defaultField(row, "RecCurncy", r.fields["PayCurCode"])
+		cust := s.find("CUVc", row["CustCode"])
+		if cust == nil {
+			return invalid("CustCode", row["CustCode"])
+		}
+		row["CustName"] = cust.fields["Name"]
+		switch inv := row["InvoiceNr"]; {
+		case inv != "":
+			if s.find("IVVc", inv) == nil {
+				return invalid("InvoiceNr", inv)
+			}
+		case row["CUPNr"] == "":
+			return &ebError{status: http.StatusOK, code: CodeMissingPrepayment, field: "CUPNr", desc: "Prepayment number is required on unallocated rows"}
+		}
+	}
+	return nil
+}
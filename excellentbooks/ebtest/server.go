@@ -0,0 +1,427 @@
+// Package ebtest provides a local emulator of the Excellent Books
+// (Standard Books) REST API for end-to-end tests of the excellentbooks
+// client and the accounting adapter.
+//
+// Records live at /api/{company}/{Register}[/{id}] behind HTTP Basic Auth.
+// Writes are form-encoded set_field.X / set_row_field.N.X pairs, every
+// record carries an @sequence that updates_after filters on, and list
+// queries honour limit, offset, sort, range and filter.X. Validation
+// failures come back the way EB reports them: HTTP 200 with an
+// {"error":{"@code":..}} payload. Malformed and nested-error PATCH bodies
+// can be injected with MalformedPATCH and NestedPATCHError.
+//
+// Records are stored as field maps rather than typed structs, so any
+// field the client sets is echoed back under the same name.
+//
+// Usage:
+//
+//	srv := ebtest.NewServer()
+//	defer srv.Close()
+//	client := excellentbooks.New(srv.Config())
+package ebtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/excellentbooks"
+)
+
+// Default credentials accepted by a Server.
+const (
+	DefaultCompany  = "1"
+	DefaultUsername = "API"
+	DefaultPassword = "ebtest-password"
+)
+
+const dateFormat = "2006-01-02"
+
+// EB error codes the emulator produces.
+const (
+	// CodeInvalidValue rejects a field whose value is not in use in the
+	// referenced register (unknown CustCode, VATCode, PayDeal, ...).
+	CodeInvalidValue = "1256"
+	// CodeDuplicate rejects a record whose key already exists.
+	CodeDuplicate = "1557"
+	// CodeMissingPrepayment rejects an unallocated receipt row without a
+	// CUPNr.
+	CodeMissingPrepayment = "1289"
+	// CodeApproved rejects changes to an approved (OKFlag=1) record.
+	CodeApproved = "20060"
+)
+
+// Fault is a canned response returned instead of the normal one.
+type Fault struct {
+	Status int
+	Body   string
+	Header http.Header
+	// Delay is slept before replying, to exercise client timeouts.
+	Delay time.Duration
+	// AfterWrite applies the request normally and only then replaces the
+	// response, modelling EB writes that succeed but answer garbage.
+	AfterWrite bool
+}
+
+// ErrorPayload returns a Fault answering HTTP 200 with an EB error
+// payload, the shape EB uses for validation failures.
+func ErrorPayload(code, field, description string) Fault {
+	return Fault{Status: http.StatusOK, Body: errorBody(code, field, description)}
+}
+
+// MalformedPATCH returns a Fault that applies the PATCH and then answers
+// with the unkeyed-object-literal body EB occasionally produces.
+func MalformedPATCH() Fault {
+	return Fault{
+		Status:     http.StatusOK,
+		Body:       `{"data":{"@register":"X",{"SerNr":"?"}}}`,
+		AfterWrite: true,
+	}
+}
+
+// NestedPATCHError returns a Fault that rejects a PATCH with the
+// truncated nested-error body EB sends for some validation failures.
+func NestedPATCHError(code, field, message string) Fault {
+	return Fault{
+		Status: http.StatusOK,
+		Body:   fmt.Sprintf(`{"data":{"messages":[%q],"error":{"@code":%q,"@field":%q}}`, message, code, field),
+	}
+}
+
+func errorBody(code, field, description string) string {
+	var e struct {
+		Error struct {
+			Code        string `json:"@code"`
+			Description string `json:"@description"`
+			Field       string `json:"@field,omitempty"`
+		} `json:"error"`
+	}
+	e.Error.Code, e.Error.Field, e.Error.Description = code, field, description
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+// record is one register entry: header fields plus optional rows.
+type record struct {
+	fields map[string]string
+	rows   []map[string]string
+}
+
+func (r *record) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(r.fields)+1)
+	for k, v := range r.fields {
+		out[k] = v
+	}
+	if r.rows != nil {
+		out["rows"] = r.rows
+	}
+	return json.Marshal(out)
+}
+
+// registerSpec describes how a register keys and numbers its records.
+type registerSpec struct {
+	key string
+	// serial registers number records themselves when the key is unset.
+	serial bool
+	rows   bool
+}
+
+var registers = map[string]registerSpec{
+	"CUVc":  {key: "Code", serial: true},
+	"IVVc":  {key: "SerNr", serial: true, rows: true},
+	"IPVc":  {key: "SerNr", serial: true, rows: true},
+	"INVc":  {key: "Code"},
+	"AccVc": {key: "AccNumber"},
+	"ObjVc": {key: "Code"},
+	"PRVc":  {key: "Code"},
+	"DepVc": {key: "Code"},
+	"PDVc":  {key: "Code"},
+	"VIVc":  {key: "SerNr", serial: true},
+}
+
+// Server is a running Excellent Books emulator. Its state is safe for
+// concurrent use.
+type Server struct {
+	*httptest.Server
+
+	Company  string
+	Username string
+	Password string
+	// Now is the clock used for DateCreated/DateChanged stamps and
+	// default dates. Defaults to time.Now.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	sequence int64
+	serial   map[string]int
+	data     map[string][]*record
+	vatCodes []excellentbooks.VATCode
+	faults   map[string][]Fault
+	requests []string
+}
+
+// NewServer starts an emulator seeded with the Estonian VAT codes, a
+// small chart of accounts and the usual payment terms.
+func NewServer() *Server {
+	s := &Server{
+		Company:  DefaultCompany,
+		Username: DefaultUsername,
+		Password: DefaultPassword,
+		Now:      time.Now,
+		serial:   map[string]int{"CUVc": 10001, "IVVc": 1000001, "IPVc": 2000001, "VIVc": 3000001},
+		data:     map[string][]*record{},
+		faults:   map[string][]Fault{},
+		vatCodes: []excellentbooks.VATCode{
+			{Code: "1", Comment: "Käibemaksuga 22%", ExVatpr: "22.00", IncVatpr: "18.03", SalesVATAcc: "2610"},
+			{Code: "2", Comment: "Käibemaksuga 9%", ExVatpr: "9.00", IncVatpr: "8.26", SalesVATAcc: "2610"},
+			{Code: "0", Comment: "Käibemaksuta 0%", ExVatpr: "0.00", IncVatpr: "0.00", SalesVATAcc: "2610"},
+		},
+	}
+	for _, acc := range []struct{ code, name, typ string }{
+		{"1000", "Kassa", "0"}, {"1210", "Ostjate tasumata arved", "0"}, {"3000", "Müügitulu", "3"},
+	} {
+		s.seed("AccVc", map[string]string{"AccNumber": acc.code, "Comment": acc.name, "AccType": acc.typ, "blockedFlag": "0"})
+	}
+	for _, pd := range []struct{ code, days string }{{"0", "0"}, {"7", "7"}, {"14", "14"}, {"30", "30"}} {
+		s.seed("PDVc", map[string]string{"Code": pd.code, "pdComment": pd.days + " päeva", "PDType": "1", "pdays": pd.days})
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Config returns an excellentbooks.Config pointed at the emulator.
+func (s *Server) Config() excellentbooks.Config {
+	return excellentbooks.Config{
+		BaseURL:     s.URL,
+		CompanyCode: s.Company,
+		Username:    s.Username,
+		Password:    s.Password,
+	}
+}
+
+// Seed inserts a record directly into register, bypassing validation.
+// It returns the record's @sequence.
+func (s *Server) Seed(register string, fields map[string]string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seed(register, fields)
+}
+
+func (s *Server) seed(register string, fields map[string]string) string {
+	r := &record{fields: map[string]string{}}
+	for k, v := range fields {
+		r.fields[k] = v
+	}
+	s.touch(register, r)
+	s.data[register] = append(s.data[register], r)
+	return r.fields["@sequence"]
+}
+
+// Sequence returns the current high-water @sequence across all
+// registers.
+func (s *Server) Sequence() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strconv.FormatInt(s.sequence, 10)
+}
+
+// InjectFault queues f for the next request matching key, written as
+// "METHOD Register" (e.g. "PATCH IVVc", "GET CUVc"). Queued faults are
+// consumed one per request, in order.
+func (s *Server) InjectFault(key string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[key] = append(s.faults[key], f)
+}
+
+// Requests returns the "METHOD Register" keys of the requests made so
+// far, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// touch bumps the record's @sequence and URL.
+func (s *Server) touch(register string, r *record) {
+	s.sequence++
+	r.fields["@sequence"] = strconv.FormatInt(s.sequence, 10)
+	if key := registers[register].key; key != "" {
+		r.fields["@url"] = fmt.Sprintf("/api/%s/%s/%s", s.Company, register, r.fields[key])
+	}
+}
+
+func (s *Server) find(register, id string) *record {
+	key := registers[register].key
+	for _, r := range s.data[register] {
+		if r.fields[key] == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || parts[0] != "api" {
+		writeError(w, http.StatusNotFound, "404", "", "Unknown path")
+		return
+	}
+	company, register := parts[1], parts[2]
+	id := strings.Join(parts[3:], "/")
+
+	key := r.Method + " " + register
+	s.mu.Lock()
+	s.requests = append(s.requests, key)
+	var fault *Fault
+	if q := s.faults[key]; len(q) > 0 {
+		fault = &q[0]
+		s.faults[key] = q[1:]
+	}
+	s.mu.Unlock()
+	if fault != nil && !fault.AfterWrite {
+		writeFault(w, fault)
+		return
+	}
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != s.Username || pass != s.Password {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Standard ERP"`)
+		writeError(w, http.StatusUnauthorized, "401", "", "Unauthorized")
+		return
+	}
+	if company != s.Company {
+		writeError(w, http.StatusNotFound, "404", "", "Company "+company+" not found")
+		return
+	}
+	if _, ok := registers[register]; !ok && register != "VATCodeBlock" {
+		writeError(w, http.StatusNotFound, "404", "", "Register "+register+" not found")
+		return
+	}
+
+	s.mu.Lock()
+	status, body := s.handle(r, register, id)
+	s.mu.Unlock()
+
+	if fault != nil {
+		writeFault(w, fault)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+func (s *Server) handle(r *http.Request, register, id string) (int, string) {
+	switch r.Method {
+	case http.MethodGet:
+		if register == "VATCodeBlock" {
+			return s.envelope(register, map[string]any{"rows": s.vatCodes})
+		}
+		if id != "" {
+			rec := s.find(register, id)
+			if rec == nil {
+				return http.StatusNotFound, errorBody("404", "", "Record "+id+" not found")
+			}
+			return s.envelope(register, []*record{rec})
+		}
+		return s.envelope(register, s.list(register, r.URL.Query()))
+	case http.MethodPost, http.MethodPatch:
+		if err := r.ParseForm(); err != nil {
+			return http.StatusBadRequest, errorBody("400", "", "Malformed form body")
+		}
+		header, rows := parseSetFields(r.PostForm)
+		var rec *record
+		var err *ebError
+		if r.Method == http.MethodPost {
+			if id != "" {
+				return http.StatusMethodNotAllowed, errorBody("405", "", "POST to a record URL")
+			}
+			rec, err = s.create(register, header, rows)
+		} else {
+			if id == "" {
+				return http.StatusMethodNotAllowed, errorBody("405", "", "PATCH requires a record id")
+			}
+			rec, err = s.update(register, id, header, rows)
+		}
+		if err != nil {
+			return err.status, errorBody(err.code, err.field, err.desc)
+		}
+		return s.envelope(register, []*record{rec})
+	default:
+		return http.StatusMethodNotAllowed, errorBody("405", "", "Method not allowed")
+	}
+}
+
+func (s *Server) envelope(register string, records any) (int, string) {
+	data := map[string]any{
+		"@register":      register,
+		"@sequence":      strconv.FormatInt(s.sequence, 10),
+		"@systemversion": "8.5 ebtest",
+		register:         records,
+	}
+	b, err := json.Marshal(map[string]any{"data": data})
+	if err != nil {
+		return http.StatusInternalServerError, errorBody("500", "", err.Error())
+	}
+	return http.StatusOK, string(b)
+}
+
+// parseSetFields splits a form into set_field header values and
+// set_row_field row values, rows ordered by index.
+func parseSetFields(form map[string][]string) (map[string]string, []map[string]string) {
+	header := map[string]string{}
+	byIndex := map[int]map[string]string{}
+	for k, vs := range form {
+		v := ""
+		if len(vs) > 0 {
+			v = vs[0]
+		}
+		switch {
+		case strings.HasPrefix(k, "set_field."):
+			header[strings.TrimPrefix(k, "set_field.")] = v
+		case strings.HasPrefix(k, "set_row_field."):
+			rest := strings.TrimPrefix(k, "set_row_field.")
+			idx, field, ok := strings.Cut(rest, ".")
+			n, err := strconv.Atoi(idx)
+			if !ok || err != nil {
+				continue
+			}
+			if byIndex[n] == nil {
+				byIndex[n] = map[string]string{}
+			}
+			byIndex[n][field] = v
+		}
+	}
+	indexes := make([]int, 0, len(byIndex))
+	for n := range byIndex {
+		indexes = append(indexes, n)
+	}
+	sort.Ints(indexes)
+	rows := make([]map[string]string, 0, len(indexes))
+	for _, n := range indexes {
+		rows = append(rows, byIndex[n])
+	}
+	return header, rows
+}
+
+func writeError(w http.ResponseWriter, status int, code, field, desc string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	io.WriteString(w, errorBody(code, field, desc))
+}
+
+func writeFault(w http.ResponseWriter, f *Fault) {
+	time.Sleep(f.Delay)
+	for k, vs := range f.Header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(f.Status)
+	io.WriteString(w, f.Body)
+}
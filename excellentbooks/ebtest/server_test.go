@@ -0,0 +1,85 @@
+package ebtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/qbitsoftware/accounting-service/excellentbooks"
+	"github.com/qbitsoftware/accounting-service/excellentbooks/ebtest"
+)
+
+func TestServer_RejectsBadPassword(t *testing.T) {
+	srv := ebtest.NewServer()
+	defer srv.Close()
+	cfg := srv.Config()
+	cfg.Password = "wrong"
+
+	_, _, err := excellentbooks.New(cfg).ListCustomers(context.Background(), excellentbooks.ListParams{})
+	var apiErr *excellentbooks.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+		t.Fatalf("err = %v, want 401 APIError", err)
+	}
+}
+
+func TestServer_UpdatesAfter(t *testing.T) {
+	ctx := context.Background()
+	srv := ebtest.NewServer()
+	defer srv.Close()
+	client := excellentbooks.New(srv.Config())
+
+	if _, err := client.CreateCustomer(ctx, map[string]string{"set_field.Code": "C1", "set_field.Name": "Acme"}); err != nil {
+		t.Fatalf("CreateCustomer C1: %v", err)
+	}
+	mark := srv.Sequence()
+	if _, err := client.CreateCustomer(ctx, map[string]string{"set_field.Code": "C2", "set_field.Name": "Beta"}); err != nil {
+		t.Fatalf("CreateCustomer C2: %v", err)
+	}
+
+	custs, seq, err := client.ListCustomers(ctx, excellentbooks.ListParams{UpdatesAfter: mark})
+	if err != nil {
+		t.Fatalf("ListCustomers: %v", err)
+	}
+	if len(custs) != 1 || custs[0].Code != "C2" {
+		t.Errorf("updates_after=%s returned %+v, want only C2", mark, custs)
+	}
+	if seq != srv.Sequence() {
+		t.Errorf("@sequence = %s, want %s", seq, srv.Sequence())
+	}
+}
+
+func TestServer_Validation(t *testing.T) {
+	ctx := context.Background()
+	srv := ebtest.NewServer()
+	defer srv.Close()
+	client := excellentbooks.New(srv.Config())
+
+	if _, err := client.CreateCustomer(ctx, map[string]string{"set_field.Code": "C1", "set_field.Name": "Acme"}); err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	_, err := client.CreateCustomer(ctx, map[string]string{"set_field.Code": "C1", "set_field.Name": "Acme again"})
+	var apiErr *excellentbooks.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode != ebtest.CodeDuplicate {
+		t.Errorf("duplicate code: err = %v, want error code %s", err, ebtest.CodeDuplicate)
+	}
+
+	_, err = client.CreateInvoice(ctx, map[string]string{
+		"set_field.CustCode":      "NOPE",
+		"set_row_field.0.ArtCode": "FEE",
+		"set_row_field.0.Quant":   "1",
+		"set_row_field.0.Price":   "10",
+		"set_row_field.0.VATCode": "1",
+	})
+	if !errors.As(err, &apiErr) || apiErr.ErrorField != "CustCode" {
+		t.Errorf("unknown customer: err = %v, want a CustCode field error", err)
+	}
+
+	_, err = client.CreateReceipt(ctx, map[string]string{
+		"set_field.PayMode":        "PANK",
+		"set_row_field.0.CustCode": "C1",
+		"set_row_field.0.RecVal":   "10",
+	})
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode != ebtest.CodeMissingPrepayment {
+		t.Errorf("receipt without invoice: err = %v, want error code %s", err, ebtest.CodeMissingPrepayment)
+	}
+}
@@ -4,17 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 )
 
 const registerInvoice = "IVVc"
 
 // ListInvoices retrieves sales invoices.
 func (c *Client) ListInvoices(ctx context.Context, params ListParams) ([]Invoice, string, error) {
-	resp, err := c.get(ctx, registerInvoice, params)
-	if err != nil {
-		return nil, "", err
-	}
-	return parseInvoiceResponse(resp)
+	return list[Invoice](ctx, c, registerInvoice, params)
+}
+
+// StreamInvoices yields the invoices ListInvoices returns one at a time, decoding the
+// response as it arrives.
+func (c *Client) StreamInvoices(ctx context.Context, params ListParams) iter.Seq2[Invoice, error] {
+	return stream[Invoice](ctx, c, registerInvoice, params)
 }
 
 // GetInvoice retrieves a single invoice by serial number.
@@ -4,17 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 )
 
 const registerItem = "INVc"
 
 // ListItems retrieves items/articles.
 func (c *Client) ListItems(ctx context.Context, params ListParams) ([]Item, string, error) {
-	resp, err := c.get(ctx, registerItem, params)
-	if err != nil {
-		return nil, "", err
-	}
-	return parseItemResponse(resp)
+	return list[Item](ctx, c, registerItem, params)
+}
+
+// StreamItems yields the items ListItems returns one at a time, decoding the
+// response as it arrives.
+func (c *Client) StreamItems(ctx context.Context, params ListParams) iter.Seq2[Item, error] {
+	return stream[Item](ctx, c, registerItem, params)
 }
 
 // GetItem retrieves a single item by code.
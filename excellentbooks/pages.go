@@ -0,0 +1,77 @@
+package excellentbooks
+
+import (
+	"context"
+	"iter"
+)
+
+// DefaultPageSize is the number of records Pages requests at a time when
+// ListParams.Limit is unset.
+const DefaultPageSize = 1000
+
+// Pages walks a register with offset/limit paging: it calls list for
+// params.Limit records (DefaultPageSize when unset) at a time, starting at
+// params.Offset, and yields each non-empty page. It stops after a page
+// shorter than the limit, at the first error, or when the caller stops
+// iterating.
+//
+//	for page, err := range excellentbooks.Pages(ctx, params, client.ListInvoices) {
+//	    ...
+//	}
+func Pages[T any](ctx context.Context, params ListParams, list func(context.Context, ListParams) ([]T, string, error)) iter.Seq2[[]T, error] {
+	if params.Limit <= 0 {
+		params.Limit = DefaultPageSize
+	}
+	return func(yield func([]T, error) bool) {
+		for {
+			page, _, err := list(ctx, params)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(page) == 0 || !yield(page, nil) || len(page) < params.Limit {
+				return
+			}
+			params.Offset += len(page)
+		}
+	}
+}
+
+// All collects every page of Pages into one slice.
+func All[T any](ctx context.Context, params ListParams, list func(context.Context, ListParams) ([]T, string, error)) ([]T, error) {
+	var all []T
+	for page, err := range Pages(ctx, params, list) {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+	}
+	return all, nil
+}
+
+// Changes walks the records of a register changed after the sequence
+// number after, oldest change first. Rather than offsets it pages by
+// sequence: each request asks for the changes after the last record of
+// the previous page, so a record changed while Changes runs moves to the
+// end instead of shifting the rest past a page boundary. seq returns a
+// record's @sequence. It stops like Pages.
+func Changes[T any](ctx context.Context, after string, params ListParams, list func(context.Context, ListParams) ([]T, string, error), seq func(*T) string) iter.Seq2[[]T, error] {
+	if params.Limit <= 0 {
+		params.Limit = DefaultPageSize
+	}
+	params.Offset, params.Sort, params.Range = 0, "", ""
+	params.UpdatesAfter = after
+	return func(yield func([]T, error) bool) {
+		for {
+			page, _, err := list(ctx, params)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(page) == 0 || !yield(page, nil) || len(page) < params.Limit {
+				return
+			}
+			params.UpdatesAfter = seq(&page[len(page)-1])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package excellentbooks
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPages(t *testing.T) {
+	records := []int{1, 2, 3, 4, 5}
+	var offsets []int
+	list := func(_ context.Context, p ListParams) ([]int, string, error) {
+		offsets = append(offsets, p.Offset)
+		if p.Offset >= len(records) {
+			return nil, "", nil
+		}
+		return records[p.Offset:min(p.Offset+p.Limit, len(records))], "", nil
+	}
+
+	got, err := All(context.Background(), ListParams{Limit: 2}, list)
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if !slices.Equal(got, records) {
+		t.Errorf("All = %v, want %v", got, records)
+	}
+	// The short third page ends paging without another request.
+	if want := []int{0, 2, 4}; !slices.Equal(offsets, want) {
+		t.Errorf("offsets = %v, want %v", offsets, want)
+	}
+
+	offsets = nil
+	for range Pages(context.Background(), ListParams{Limit: 2}, list) {
+		break
+	}
+	if len(offsets) != 1 {
+		t.Errorf("stopping after the first page made %d requests, want 1", len(offsets))
+	}
+
+	offsets = nil
+	if _, err := All(context.Background(), ListParams{Limit: 5}, list); err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	// A full last page needs one more, empty, page to end.
+	if want := []int{0, 5}; !slices.Equal(offsets, want) {
+		t.Errorf("offsets = %v, want %v", offsets, want)
+	}
+
+	boom := errors.New("boom")
+	failing := func(_ context.Context, p ListParams) ([]int, string, error) {
+		if p.Offset > 0 {
+			return nil, "", boom
+		}
+		return records[:2], "", nil
+	}
+	if _, err := All(context.Background(), ListParams{Limit: 2}, failing); !errors.Is(err, boom) {
+		t.Errorf("All with a failing page: err = %v, want %v", err, boom)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	type rec struct{ id, seq string }
+	var afters []string
+	records := []rec{{"a", "3"}, {"b", "5"}, {"c", "8"}}
+	list := func(_ context.Context, p ListParams) ([]rec, string, error) {
+		afters = append(afters, p.UpdatesAfter)
+		var out []rec
+		for _, r := range records {
+			if r.seq > p.UpdatesAfter && len(out) < p.Limit {
+				out = append(out, r)
+			}
+		}
+		return out, "8", nil
+	}
+
+	var got []string
+	for page, err := range Changes(context.Background(), "2", ListParams{Limit: 2, Offset: 7}, list, func(r *rec) string { return r.seq }) {
+		if err != nil {
+			t.Fatalf("Changes: %v", err)
+		}
+		for _, r := range page {
+			got = append(got, r.id)
+		}
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("Changes = %v, want %v", got, want)
+	}
+	// The second page continues after the last record of the first.
+	if want := []string{"2", "5"}; !slices.Equal(afters, want) {
+		t.Errorf("updates_after = %v, want %v", afters, want)
+	}
+}
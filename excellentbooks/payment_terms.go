@@ -22,11 +22,11 @@ func (c *Client) ListPaymentTerms(ctx context.Context, params ListParams) ([]Pay
 		PDVc []PaymentTerm `json:"PDVc"`
 	}
 	if err := json.Unmarshal(resp.Data, &envelope); err != nil {
-		slog.Error("excellentbooks: parse payment terms failed", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelError, "excellentbooks: parse payment terms failed", resp.Data, "error", err)
 		return nil, "", fmt.Errorf("excellentbooks: parse payment terms: %w", err)
 	}
 	if len(envelope.PDVc) == 0 {
-		slog.Warn("excellentbooks: PDVc returned 0 items", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelWarn, "excellentbooks: PDVc returned 0 items", resp.Data)
 	}
 	return envelope.PDVc, envelope.Sequence, nil
 }
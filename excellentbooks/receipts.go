@@ -4,17 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 )
 
 const registerReceipt = "IPVc"
 
 // ListReceipts retrieves incoming payments/receipts.
 func (c *Client) ListReceipts(ctx context.Context, params ListParams) ([]Receipt, string, error) {
-	resp, err := c.get(ctx, registerReceipt, params)
-	if err != nil {
-		return nil, "", err
-	}
-	return parseReceiptResponse(resp)
+	return list[Receipt](ctx, c, registerReceipt, params)
+}
+
+// StreamReceipts yields the receipts ListReceipts returns one at a time, decoding the
+// response as it arrives.
+func (c *Client) StreamReceipts(ctx context.Context, params ListParams) iter.Seq2[Receipt, error] {
+	return stream[Receipt](ctx, c, registerReceipt, params)
 }
 
 // GetReceipt retrieves a single receipt by serial number.
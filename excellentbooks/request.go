@@ -3,16 +3,27 @@ package excellentbooks
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"iter"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"regexp"
 	"sort"
 	"strings"
+
+	"github.com/qbitsoftware/accounting-service/jsonstream"
 )
 
+// logRawData logs an unexpected register payload: msg and args at level,
+// with the payload itself — redacted — at Debug only, since register data
+// carries customer details.
+func (c *Client) logRawData(level slog.Level, msg string, data []byte, args ...any) {
+	c.logger.Log(context.Background(), level, msg, append(args, "data_len", len(data))...)
+	c.logger.Debug(msg, "raw_data", c.redact.Body(data))
+}
+
 // dumpFields renders a field map as a stable, sorted "k=v | k=v" string so the
 // exact payload sent to EB is visible in logs (compare against the API docs).
 func dumpFields(fields map[string]string) string {
@@ -130,13 +141,22 @@ func encodeForm(fields map[string]string) string {
 
 // get performs a GET request and decodes the JSON response.
 func (c *Client) get(ctx context.Context, register string, params ListParams) (*Response, error) {
+	req, err := c.listRequest(ctx, register, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.doRequest(register, req)
+}
+
+// listRequest builds the GET of a register listing.
+func (c *Client) listRequest(ctx context.Context, register string, params ListParams) (*http.Request, error) {
 	reqURL := fmt.Sprintf("%s/api/%s/%s", c.baseURL, c.companyCode, register)
 	qp := params.toValues()
 	if len(qp) > 0 {
 		reqURL += "?" + qp.Encode()
 	}
 
-	slog.Info("excellentbooks request", "method", "GET", "register", register, "url", reqURL)
+	c.logger.Info("excellentbooks request", "method", "GET", "register", register, "url", c.redact.URL(reqURL))
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
@@ -144,8 +164,112 @@ func (c *Client) get(ctx context.Context, register string, params ListParams) (*
 	}
 	req.SetBasicAuth(c.username, c.password)
 	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// getStream is get for the list registers: it decodes the response as it
+// arrives, calling each with every record of register, and stops when
+// each returns false, so a large register is never held in memory as a
+// whole. It returns the envelope's metadata. Error payloads are detected
+// as by doRequest, also inside 200 responses.
+func getStream[T any](ctx context.Context, c *Client, register string, params ListParams, each func(T) bool) (ResponseMeta, error) {
+	var meta ResponseMeta
+	req, err := c.listRequest(ctx, register, params)
+	if err != nil {
+		return meta, err
+	}
+	resp, err := c.exec.Stream(ctx, "GET "+register, true, attempts(req))
+	if err != nil {
+		return meta, fmt.Errorf("excellentbooks: send request: %w", err)
+	}
+	if resp.Stream == nil {
+		c.logger.Info("excellentbooks response", "status", resp.StatusCode, "body_len", len(resp.Body))
+		c.logger.Debug("excellentbooks response payload", "status", resp.StatusCode, "raw_body", c.redact.Body(resp.Body))
+		return meta, c.statusError(resp.StatusCode, resp.Body)
+	}
+	defer resp.Stream.Close()
+
+	rec := jsonstream.NewRecorder(resp.Stream, c.logger.Enabled(ctx, slog.LevelDebug))
+	dec := json.NewDecoder(rec)
+	var (
+		errResp errorResponse
+		stopped bool
+	)
+	err = jsonstream.Object(dec, func(key string) error {
+		switch key {
+		case "data":
+			return jsonstream.Object(dec, func(key string) error {
+				switch key {
+				case register:
+					err := jsonstream.ArrayOrObject(dec, func(v T) bool {
+						stopped = !each(v)
+						return !stopped
+					})
+					if err == nil && stopped {
+						return errStopped
+					}
+					return err
+				case "@register":
+					return dec.Decode(&meta.Register)
+				case "@sequence":
+					return dec.Decode(&meta.Sequence)
+				case "@systemversion":
+					return dec.Decode(&meta.SystemVersion)
+				case "error":
+					// The nested shape of some rejections.
+					return dec.Decode(&errResp.Error)
+				}
+				return jsonstream.Skip(dec)
+			})
+		case "error":
+			return dec.Decode(&errResp.Error)
+		case "messages":
+			return dec.Decode(&errResp.Messages)
+		}
+		return jsonstream.Skip(dec)
+	})
+	c.logger.Info("excellentbooks response", "status", resp.StatusCode, "body_len", rec.Len())
+	c.logger.Debug("excellentbooks response payload", "status", resp.StatusCode, "raw_body", c.redact.Body(rec.Bytes()))
+	if errResp.Error.Code != "" {
+		return meta, c.payloadError(resp.StatusCode, errResp)
+	}
+	if err != nil && !errors.Is(err, errStopped) {
+		return meta, fmt.Errorf("excellentbooks: decode response: %w", err)
+	}
+	return meta, nil
+}
+
+// errStopped ends a getStream walk when the caller wants no more records.
+var errStopped = errors.New("excellentbooks: stopped")
+
+// stream presents a list register as an iterator over its records,
+// decoded as they arrive. Stopping the loop stops reading.
+func stream[T any](ctx context.Context, c *Client, register string, params ListParams) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		stopped := false
+		_, err := getStream(ctx, c, register, params, func(v T) bool {
+			stopped = !yield(v, nil)
+			return !stopped
+		})
+		if err != nil && !stopped {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
 
-	return c.doRequest(req)
+// list collects the records of a list register and the response's
+// sequence.
+func list[T any](ctx context.Context, c *Client, register string, params ListParams) ([]T, string, error) {
+	out := []T{}
+	meta, err := getStream(ctx, c, register, params, func(v T) bool {
+		out = append(out, v)
+		return true
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return out, meta.Sequence, nil
 }
 
 // getOne performs a GET request for a single record by ID.
@@ -156,7 +280,7 @@ func (c *Client) getOne(ctx context.Context, register string, id string) (*Respo
 	// charset=UTF-8 fix on form bodies.
 	reqURL := fmt.Sprintf("%s/api/%s/%s/%s", c.baseURL, c.companyCode, register, url.PathEscape(id))
 
-	slog.Info("excellentbooks request", "method", "GET", "register", register, "id", id)
+	c.logger.Info("excellentbooks request", "method", "GET", "register", register, "id", id)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
@@ -165,17 +289,17 @@ func (c *Client) getOne(ctx context.Context, register string, id string) (*Respo
 	req.SetBasicAuth(c.username, c.password)
 	req.Header.Set("Accept", "application/json")
 
-	return c.doRequest(req)
+	return c.doRequest(register, req)
 }
 
 // post performs a POST request with form-encoded body.
 func (c *Client) post(ctx context.Context, register string, fields map[string]string) (*Response, error) {
 	reqURL := fmt.Sprintf("%s/api/%s/%s", c.baseURL, c.companyCode, register)
 
-	slog.Info("excellentbooks request", "method", "POST", "register", register, "fields", len(fields))
+	c.logger.Info("excellentbooks request", "method", "POST", "register", register, "fields", len(fields))
 	// Full payload so the exact set_field/set_row_field format can be compared
 	// against the EB API docs when debugging (e.g. credit-note row rejections).
-	slog.Info("excellentbooks request payload", "register", register, "form", dumpFields(fields))
+	c.logger.Debug("excellentbooks request payload", "register", register, "form", dumpFields(c.redact.Map(fields)))
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, strings.NewReader(encodeForm(fields)))
 	if err != nil {
@@ -188,7 +312,7 @@ func (c *Client) post(ctx context.Context, register string, fields map[string]st
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
 	req.Header.Set("Accept", "application/json")
 
-	return c.doRequest(req)
+	return c.doRequest(register, req)
 }
 
 // patch performs a PATCH request with form-encoded body.
@@ -196,8 +320,8 @@ func (c *Client) patch(ctx context.Context, register string, id string, fields m
 	// PathEscape — see getOne above for rationale.
 	reqURL := fmt.Sprintf("%s/api/%s/%s/%s", c.baseURL, c.companyCode, register, url.PathEscape(id))
 
-	slog.Info("excellentbooks request", "method", "PATCH", "register", register, "id", id)
-	slog.Info("excellentbooks request payload", "register", register, "id", id, "form", dumpFields(fields))
+	c.logger.Info("excellentbooks request", "method", "PATCH", "register", register, "id", id)
+	c.logger.Debug("excellentbooks request payload", "register", register, "id", id, "form", dumpFields(c.redact.Map(fields)))
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, reqURL, strings.NewReader(encodeForm(fields)))
 	if err != nil {
@@ -210,67 +334,33 @@ func (c *Client) patch(ctx context.Context, register string, id string, fields m
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
 	req.Header.Set("Accept", "application/json")
 
-	return c.doRequest(req)
+	return c.doRequest(register, req)
 }
 
-// doRequest executes the HTTP request and parses the response.
-func (c *Client) doRequest(req *http.Request) (*Response, error) {
-	resp, err := c.httpClient.Do(req)
+// doRequest executes the HTTP request and parses the response. Only GETs
+// are retried after ambiguous failures; POST and PATCH writes are retried
+// only when EB rate-limits them.
+func (c *Client) doRequest(register string, req *http.Request) (*Response, error) {
+	resp, err := c.exec.Do(req.Context(), req.Method+" "+register, req.Method == http.MethodGet, attempts(req))
 	if err != nil {
 		return nil, fmt.Errorf("excellentbooks: send request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("excellentbooks: read response: %w", err)
-	}
+	body := resp.Body
 
-	slog.Info("excellentbooks response", "status", resp.StatusCode, "body_len", len(body))
+	c.logger.Info("excellentbooks response", "status", resp.StatusCode, "body_len", len(body))
+	// The full body captures the actual EB error even when the structured
+	// error fields are terse/empty, but it echoes customer data back, so it
+	// is logged redacted and at Debug only.
+	c.logger.Debug("excellentbooks response payload", "status", resp.StatusCode, "raw_body", c.redact.Body(body))
 
-	// Try to parse error response
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// Log the full body so the actual EB error is captured even on non-2xx
-		// (the structured error fields are often terse/empty).
-		slog.Error("excellentbooks: non-2xx response", "status", resp.StatusCode, "raw_body", string(body))
-		var errResp errorResponse
-		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Code != "" {
-			return nil, &APIError{
-				StatusCode: resp.StatusCode,
-				Message:    errResp.Error.Description,
-				ErrorCode:  errResp.Error.Code,
-				ErrorField: errResp.Error.Field,
-			}
-		}
-		return nil, &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    string(body),
-		}
+		return nil, c.statusError(resp.StatusCode, body)
 	}
 
 	// EB sometimes returns 200 with an error payload — check before treating as success
 	var errCheck errorResponse
 	if json.Unmarshal(body, &errCheck) == nil && errCheck.Error.Code != "" {
-		// Log the full body so we can see what EB really said. The structured
-		// description field is often terse / cryptic — the raw body usually
-		// contains the actual problem.
-		slog.Error("excellentbooks: API returned error payload",
-			"status", resp.StatusCode,
-			"error_code", errCheck.Error.Code,
-			"error_field", errCheck.Error.Field,
-			"error_description", errCheck.Error.Description,
-			"messages", errCheck.Messages,
-			"raw_body", string(body))
-		message := errCheck.Error.Description
-		if message == "" && len(errCheck.Messages) > 0 {
-			message = strings.Join(errCheck.Messages, "; ")
-		}
-		return nil, &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    message,
-			ErrorCode:  errCheck.Error.Code,
-			ErrorField: errCheck.Error.Field,
-		}
+		return nil, c.payloadError(resp.StatusCode, errCheck)
 	}
 
 	var result Response
@@ -290,7 +380,7 @@ func (c *Client) doRequest(req *http.Request) (*Response, error) {
 			// errors get logged as "treating as success" and callers have
 			// no idea their write was rejected.
 			if errCode, errField, errMsg := extractNestedPATCHError(body); errCode != "" {
-				slog.Warn("excellentbooks: PATCH rejected with nested-error payload",
+				c.logger.Warn("excellentbooks: PATCH rejected with nested-error payload",
 					"method", req.Method,
 					"url", req.URL.String(),
 					"error_code", errCode,
@@ -303,19 +393,14 @@ func (c *Client) doRequest(req *http.Request) (*Response, error) {
 					ErrorField: errField,
 				}
 			}
-			// Log the raw body so we can diagnose whether EB actually
+			// The raw body (logged at Debug above) shows whether EB actually
 			// rejected the change (silently) vs succeeded with a truncated
-			// response. Bounded to first 500 bytes to keep log lines sane.
-			rawSnippet := string(body)
-			if len(rawSnippet) > 500 {
-				rawSnippet = rawSnippet[:500] + "...(truncated)"
-			}
-			slog.Warn("excellentbooks: PATCH succeeded but response body was malformed; treating as success",
+			// response.
+			c.logger.Warn("excellentbooks: PATCH succeeded but response body was malformed; treating as success",
 				"method", req.Method,
 				"url", req.URL.String(),
 				"body_len", len(body),
-				"unmarshal_error", err,
-				"raw_body", rawSnippet)
+				"unmarshal_error", err)
 			return &Response{Data: []byte("{}")}, nil
 		}
 		return nil, fmt.Errorf("excellentbooks: unmarshal response: %w (body: %s)", err, string(body))
@@ -324,6 +409,61 @@ func (c *Client) doRequest(req *http.Request) (*Response, error) {
 	return &result, nil
 }
 
+// attempts builds the attempts of req: clones with a fresh body.
+func attempts(req *http.Request) func(ctx context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		attempt := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("excellentbooks: create request: %w", err)
+			}
+			attempt.Body = body
+		}
+		return attempt, nil
+	}
+}
+
+// statusError is the error of a non-2xx response.
+func (c *Client) statusError(status int, body []byte) *APIError {
+	c.logger.Error("excellentbooks: non-2xx response", "status", status, "body_len", len(body))
+	var errResp errorResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Code != "" {
+		return &APIError{
+			StatusCode: status,
+			Message:    errResp.Error.Description,
+			ErrorCode:  errResp.Error.Code,
+			ErrorField: errResp.Error.Field,
+		}
+	}
+	return &APIError{
+		StatusCode: status,
+		Message:    string(body),
+	}
+}
+
+// payloadError is the error of a 2xx response carrying an error payload.
+func (c *Client) payloadError(status int, errResp errorResponse) *APIError {
+	// The structured description field is often terse / cryptic — the
+	// raw body (logged at Debug) usually contains the actual problem.
+	c.logger.Error("excellentbooks: API returned error payload",
+		"status", status,
+		"error_code", errResp.Error.Code,
+		"error_field", errResp.Error.Field,
+		"error_description", errResp.Error.Description,
+		"messages", errResp.Messages)
+	message := errResp.Error.Description
+	if message == "" && len(errResp.Messages) > 0 {
+		message = strings.Join(errResp.Messages, "; ")
+	}
+	return &APIError{
+		StatusCode: status,
+		Message:    message,
+		ErrorCode:  errResp.Error.Code,
+		ErrorField: errResp.Error.Field,
+	}
+}
+
 // errorResponse is the JSON error format from Excellent Books.
 type errorResponse struct {
 	Messages []string `json:"messages"`
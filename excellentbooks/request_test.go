@@ -0,0 +1,74 @@
+package excellentbooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newStreamServer(t *testing.T, body string) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return New(Config{BaseURL: srv.URL, CompanyCode: "1", Username: "u", Password: "p"})
+}
+
+// TestListInvoices_Streamed decodes the register envelope as it arrives:
+// records in order, the sequence wherever it sits, and a lone record
+// unwrapped.
+func TestListInvoices_Streamed(t *testing.T) {
+	client := newStreamServer(t, `{"data":{"IVVc":[{"SerNr":"1"},{"SerNr":"2"},{"SerNr":"3"}],"@register":"IVVc","@sequence":"77"},"messages":[]}`)
+	invoices, seq, err := client.ListInvoices(context.Background(), ListParams{})
+	if err != nil {
+		t.Fatalf("ListInvoices: %v", err)
+	}
+	if len(invoices) != 3 || invoices[2].SerNr != "3" || seq != "77" {
+		t.Errorf("ListInvoices = %d invoices, sequence %q; want 3, 77", len(invoices), seq)
+	}
+
+	var got []string
+	for inv, err := range client.StreamInvoices(context.Background(), ListParams{}) {
+		if err != nil {
+			t.Fatalf("StreamInvoices: %v", err)
+		}
+		got = append(got, inv.SerNr)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 {
+		t.Errorf("stopped StreamInvoices yielded %v, want two invoices", got)
+	}
+
+	client = newStreamServer(t, `{"data":{"@register":"IVVc","IVVc":{"SerNr":"9"}}}`)
+	invoices, _, err = client.ListInvoices(context.Background(), ListParams{})
+	if err != nil || len(invoices) != 1 || invoices[0].SerNr != "9" {
+		t.Errorf("lone record: %v, err = %v; want invoice 9", invoices, err)
+	}
+}
+
+// TestListInvoices_ErrorPayload keeps rejecting the error payloads EB
+// sends with status 200.
+func TestListInvoices_ErrorPayload(t *testing.T) {
+	for _, body := range []string{
+		`{"error":{"@code":"5","@description":"Server busy"},"messages":["busy"]}`,
+		`{"data":{"messages":["Access denied"],"error":{"@code":"21","@field":""}}}`,
+	} {
+		client := newStreamServer(t, body)
+		_, _, err := client.ListInvoices(context.Background(), ListParams{})
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusOK || apiErr.ErrorCode == "" {
+			t.Errorf("%s: err = %v, want an APIError with the code", body, err)
+		}
+	}
+
+	client := newStreamServer(t, `{"data":{"IVVc":[{"SerNr":"1"},{"SerNr":`)
+	if _, _, err := client.ListInvoices(context.Background(), ListParams{}); err == nil {
+		t.Error("truncated body: no error")
+	}
+}
@@ -15,10 +15,10 @@ func (c *Client) ListVATCodes(ctx context.Context, params ListParams) ([]VATCode
 	if err != nil {
 		return nil, "", err
 	}
-	return parseVATCodeResponse(resp)
+	return c.parseVATCodeResponse(resp)
 }
 
-func parseVATCodeResponse(resp *Response) ([]VATCode, string, error) {
+func (c *Client) parseVATCodeResponse(resp *Response) ([]VATCode, string, error) {
 	// Standard Books wraps VAT codes in a {rows: [...]} object rather than
 	// returning a direct array (unlike most other registers).
 	var envelope struct {
@@ -28,11 +28,11 @@ func parseVATCodeResponse(resp *Response) ([]VATCode, string, error) {
 		} `json:"VATCodeBlock"`
 	}
 	if err := json.Unmarshal(resp.Data, &envelope); err != nil {
-		slog.Error("excellentbooks: parse vat codes failed", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelError, "excellentbooks: parse vat codes failed", resp.Data, "error", err)
 		return nil, "", fmt.Errorf("excellentbooks: parse vat codes: %w", err)
 	}
 	if len(envelope.VATCodeBlock.Rows) == 0 {
-		slog.Warn("excellentbooks: VATCodeBlock returned 0 items", "raw_data", string(resp.Data))
+		c.logRawData(slog.LevelWarn, "excellentbooks: VATCodeBlock returned 0 items", resp.Data)
 	}
 	return envelope.VATCodeBlock.Rows, envelope.Sequence, nil
 }
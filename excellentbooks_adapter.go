@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log/slog"
+	"iter"
 	"strconv"
 	"strings"
 	"time"
@@ -20,18 +20,44 @@ type excellentProvider struct {
 	client *excellentbooks.Client
 }
 
+func init() {
+	RegisterProvider("excellentbooks", func(cfg Config) (Provider, error) {
+		return newExcellentProvider(cfg), nil
+	}, excellentCapabilities)
+}
+
+// excellentCapabilities is the feature set registered for the "excellentbooks" provider.
+// Operations are derived from the adapter; the ones it does not implement
+// are listed here.
+var excellentCapabilities = adapterCapabilities((*excellentProvider)(nil),
+	Capabilities{SupportsIncrementalSync: true},
+	OpLocateInvoice,
+	OpGetInvoicePDF,
+	OpDeleteInvoice,
+	OpDeletePayment,
+	OpCreatePurchase,
+	OpGetPurchase,
+	OpDeletePurchase,
+	OpListBanks,
+	OpCustomerDebts,
+)
+
 func newExcellentProvider(cfg Config) *excellentProvider {
-	baseURL := strings.TrimRight(cfg.Extra["base_url"], "/")
+	opts := cfg.excellentBooksOptions()
+	baseURL := strings.TrimRight(opts.BaseURL, "/")
 	if baseURL != "" && !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
 		baseURL = "https://" + baseURL
 	}
 	return &excellentProvider{
 		client: excellentbooks.New(excellentbooks.Config{
 			BaseURL:     baseURL,
-			CompanyCode: cfg.Extra["company_code"],
+			CompanyCode: opts.CompanyCode,
 			Username:    cfg.APIID,
 			Password:    cfg.APIKey,
 			HTTPClient:  cfg.HTTPClient,
+			Resilience:  cfg.Resilience,
+			Logger:      cfg.Logger,
+			Redaction:   cfg.Redaction,
 		}),
 	}
 }
@@ -96,39 +122,57 @@ func (p *excellentProvider) GetInvoice(ctx context.Context, id string) (*Invoice
 }
 
 func (p *excellentProvider) GetInvoicePDF(_ context.Context, _ string, _ bool) (*InvoicePDF, error) {
-	return nil, p.wrapError("GetInvoicePDF", fmt.Errorf("not supported by Excellent Books API"))
+	return nil, p.wrapError("GetInvoicePDF", fmt.Errorf("%w by Excellent Books API", ErrNotSupported))
 }
 
 func (p *excellentProvider) ListInvoices(ctx context.Context, input ListInvoicesInput) ([]Invoice, error) {
-	params := excellentbooks.ListParams{Limit: 5000}
-	if !input.PeriodStart.IsZero() {
+	return collectPages(p.InvoicePages(ctx, input))
+}
+
+// listsInvoiceDetails: IVVc list responses carry the rows GetInvoice
+// returns.
+func (p *excellentProvider) listsInvoiceDetails() {}
+
+// InvoicePages pages through the IVVc register with offset/limit. EB
+// sorts and ranges on one field only, so a number range is pushed down
+// only when there is no period.
+func (p *excellentProvider) InvoicePages(ctx context.Context, input ListInvoicesInput) iter.Seq2[[]Invoice, error] {
+	if input.filtersStatus() {
+		return onePage(func() ([]Invoice, error) {
+			return nil, p.wrapError("ListInvoices", fmt.Errorf("%w: Excellent Books does not report invoice payment status", ErrNotSupported))
+		})
+	}
+	if !input.ChangedSince.IsZero() {
+		return onePage(func() ([]Invoice, error) {
+			return nil, p.wrapError("ListInvoices", fmt.Errorf("%w: Excellent Books records no invoice change date", ErrNotSupported))
+		})
+	}
+	params := excellentbooks.ListParams{Filter: map[string]string{}}
+	switch {
+	case !input.PeriodStart.IsZero():
 		params.Sort = "InvDate"
 		params.Range = formatExcellentDate(input.PeriodStart) + ":"
 		if !input.PeriodEnd.IsZero() {
 			params.Range += formatExcellentDate(input.PeriodEnd)
 		}
+	case input.NumberFrom != "" || input.NumberTo != "":
+		params.Sort = "SerNr"
+		params.Range = input.NumberFrom + ":" + input.NumberTo
 	}
 	if input.CustomerCode != "" {
-		if params.Filter == nil {
-			params.Filter = map[string]string{}
-		}
 		params.Filter["CustCode"] = input.CustomerCode
 	}
-
-	items, _, err := p.client.ListInvoices(ctx, params)
-	if err != nil {
-		return nil, p.wrapError("ListInvoices", err)
+	if input.ReferenceNo != "" {
+		params.Filter["RefStr"] = input.ReferenceNo
 	}
 
-	invoices := make([]Invoice, len(items))
-	for i := range items {
-		invoices[i] = *mapExcellentInvoice(&items[i])
-	}
-	return invoices, nil
+	return mapPages(excellentbooks.Pages(ctx, params, p.client.ListInvoices),
+		func(inv *excellentbooks.Invoice) Invoice { return *mapExcellentInvoice(inv) },
+		func(err error) error { return p.wrapError("ListInvoices", err) })
 }
 
 func (p *excellentProvider) DeleteInvoice(_ context.Context, _ string) error {
-	return p.wrapError("DeleteInvoice", fmt.Errorf("not supported by Excellent Books API"))
+	return p.wrapError("DeleteInvoice", fmt.Errorf("%w by Excellent Books API", ErrNotSupported))
 }
 
 func (p *excellentProvider) FindInvoiceByRef(ctx context.Context, refStr string) (*Invoice, error) {
@@ -145,6 +189,13 @@ func (p *excellentProvider) FindInvoiceByRef(ctx context.Context, refStr string)
 	return mapExcellentInvoice(&items[0]), nil
 }
 
+// LocateInvoice is not supported: EB assigns SerNr itself, and neither
+// the reference, which repeats per customer, nor any other field is
+// reserved for an IdempotencyKey.
+func (p *excellentProvider) LocateInvoice(_ context.Context, _ CreateInvoiceInput) (*Invoice, error) {
+	return nil, p.wrapError("LocateInvoice", fmt.Errorf("%w by Excellent Books API", ErrNotSupported))
+}
+
 // --- Customers ---
 
 func (p *excellentProvider) CreateCustomer(ctx context.Context, input CreateCustomerInput) (*Customer, error) {
@@ -232,17 +283,24 @@ func (p *excellentProvider) UpdateCustomer(ctx context.Context, input UpdateCust
 	return p.wrapError("UpdateCustomer", p.client.UpdateCustomer(ctx, input.ID, fields))
 }
 
-func (p *excellentProvider) ListCustomers(ctx context.Context, _ ListCustomersInput) ([]Customer, error) {
-	items, _, err := p.client.ListCustomers(ctx, excellentbooks.ListParams{Limit: 5000})
-	if err != nil {
-		return nil, p.wrapError("ListCustomers", err)
-	}
+func (p *excellentProvider) ListCustomers(ctx context.Context, input ListCustomersInput) ([]Customer, error) {
+	return collectPages(p.CustomerPages(ctx, input))
+}
 
-	customers := make([]Customer, len(items))
-	for i := range items {
-		customers[i] = *mapExcellentCustomer(&items[i])
+// CustomerPages pushes down the registry code and change date; name and
+// e-mail are matched case-insensitively, which EB filters cannot do.
+func (p *excellentProvider) CustomerPages(ctx context.Context, input ListCustomersInput) iter.Seq2[[]Customer, error] {
+	params := excellentbooks.ListParams{Filter: map[string]string{}}
+	if input.RegNo != "" {
+		params.Filter["RegNr1"] = strings.TrimSpace(input.RegNo)
+	}
+	if !input.ChangedSince.IsZero() {
+		params.Sort = "DateChanged"
+		params.Range = formatExcellentDate(input.ChangedSince) + ":"
 	}
-	return customers, nil
+	return mapPages(excellentbooks.Pages(ctx, params, p.client.ListCustomers),
+		func(cust *excellentbooks.Customer) Customer { return *mapExcellentCustomer(cust) },
+		func(err error) error { return p.wrapError("ListCustomers", err) })
 }
 
 // GetCustomer fetches a single EB customer card by code. Returns
@@ -276,14 +334,14 @@ func (p *excellentProvider) FindCustomerByEmail(ctx context.Context, email strin
 		return nil, &ProviderError{Provider: "excellentbooks", Op: "FindCustomerByEmail", Err: ErrNotFound}
 	}
 
-	items, _, err := p.client.ListCustomers(ctx, excellentbooks.ListParams{Limit: 5000})
-	if err != nil {
-		return nil, p.wrapError("FindCustomerByEmail", err)
-	}
-
-	for _, item := range items {
-		if strings.ToLower(strings.TrimSpace(item.Email)) == email {
-			return mapExcellentCustomer(&item), nil
+	for items, err := range excellentbooks.Pages(ctx, excellentbooks.ListParams{}, p.client.ListCustomers) {
+		if err != nil {
+			return nil, p.wrapError("FindCustomerByEmail", err)
+		}
+		for _, item := range items {
+			if strings.ToLower(strings.TrimSpace(item.Email)) == email {
+				return mapExcellentCustomer(&item), nil
+			}
 		}
 	}
 
@@ -307,6 +365,9 @@ func (p *excellentProvider) CreatePayment(ctx context.Context, input CreatePayme
 	if input.Currency != "" {
 		fields["set_field.PayCurCode"] = input.Currency
 	}
+	if input.PaymentNo != "" {
+		fields["set_field.Comment"] = input.PaymentNo
+	}
 
 	// Excellent Books receipt rows expect a customer code (not name) in CustCode.
 	custCode := input.CustomerCode
@@ -327,7 +388,11 @@ func (p *excellentProvider) CreatePayment(ctx context.Context, input CreatePayme
 }
 
 func (p *excellentProvider) ListPayments(ctx context.Context, input ListPaymentsInput) ([]Payment, error) {
-	params := excellentbooks.ListParams{Limit: 5000}
+	return collectPages(p.PaymentPages(ctx, input))
+}
+
+func (p *excellentProvider) PaymentPages(ctx context.Context, input ListPaymentsInput) iter.Seq2[[]Payment, error] {
+	var params excellentbooks.ListParams
 	if !input.PeriodStart.IsZero() {
 		params.Sort = "TransDate"
 		params.Range = formatExcellentDate(input.PeriodStart) + ":"
@@ -336,42 +401,57 @@ func (p *excellentProvider) ListPayments(ctx context.Context, input ListPayments
 		}
 	}
 
-	receipts, _, err := p.client.ListReceipts(ctx, params)
-	if err != nil {
-		return nil, p.wrapError("ListPayments", err)
-	}
-
-	payments := make([]Payment, len(receipts))
-	for i, r := range receipts {
-		amount := decimal.Zero
-		var links []PaymentInvoiceLink
-		for _, row := range r.Rows {
-			rowAmt, _ := decimal.NewFromString(row.RecVal)
-			amount = amount.Add(rowAmt)
-			if row.InvoiceNr != "" {
-				links = append(links, PaymentInvoiceLink{
-					InvoiceID: row.InvoiceNr,
-					InvoiceNo: row.InvoiceNr,
-					Amount:    rowAmt,
-				})
-			}
-		}
-		payments[i] = Payment{
-			ID:              r.SerNr,
-			DocumentNo:      r.SerNr,
-			DocumentDate:    parseExcellentDate(r.TransDate),
-			Amount:          amount,
-			Currency:        r.PayCurCode,
-			Direction:       PaymentDirectionCustomer,
-			InvoiceLinks:    links,
-			ExternalPayMode: r.PayMode,
+	return mapPages(excellentbooks.Pages(ctx, params, p.client.ListReceipts), mapExcellentReceipt,
+		func(err error) error { return p.wrapError("ListPayments", err) })
+}
+
+func mapExcellentReceipt(r *excellentbooks.Receipt) Payment {
+	amount := decimal.Zero
+	var links []PaymentInvoiceLink
+	for _, row := range r.Rows {
+		rowAmt, _ := decimal.NewFromString(row.RecVal)
+		amount = amount.Add(rowAmt)
+		if row.InvoiceNr != "" {
+			links = append(links, PaymentInvoiceLink{
+				InvoiceID: row.InvoiceNr,
+				InvoiceNo: row.InvoiceNr,
+				Amount:    rowAmt,
+			})
 		}
 	}
-	return payments, nil
+	return Payment{
+		ID:              r.SerNr,
+		DocumentNo:      r.SerNr,
+		DocumentDate:    parseExcellentDate(r.TransDate),
+		Amount:          amount,
+		Currency:        r.PayCurCode,
+		Direction:       PaymentDirectionCustomer,
+		InvoiceLinks:    links,
+		Reference:       r.Comment,
+		ExternalPayMode: r.PayMode,
+	}
 }
 
 func (p *excellentProvider) DeletePayment(_ context.Context, _ string) error {
-	return p.wrapError("DeletePayment", fmt.Errorf("not supported by Excellent Books API"))
+	return p.wrapError("DeletePayment", fmt.Errorf("%w by Excellent Books API", ErrNotSupported))
+}
+
+// LocatePayment finds the receipt by PaymentNo. EB numbers receipts
+// itself; CreatePayment keeps PaymentNo in the receipt comment, which is
+// searched among the receipts of the transaction date.
+func (p *excellentProvider) LocatePayment(ctx context.Context, input CreatePaymentInput) (*Payment, error) {
+	if input.IdempotencyKey != "" {
+		return nil, p.wrapError("LocatePayment", errKeyNotStored)
+	}
+	if input.PaymentNo == "" {
+		return nil, nil
+	}
+	start, end := documentDay(input.PaymentDate)
+	payments, err := p.ListPayments(ctx, ListPaymentsInput{PeriodStart: start, PeriodEnd: end})
+	if err != nil {
+		return nil, err
+	}
+	return findPaymentNo(payments, input.PaymentNo, func(p *Payment) string { return p.Reference }), nil
 }
 
 // --- Items ---
@@ -407,17 +487,18 @@ func (p *excellentProvider) CreateItem(ctx context.Context, input CreateItemInpu
 	return mapExcellentItem(item), nil
 }
 
-func (p *excellentProvider) ListItems(ctx context.Context, _ ListItemsInput) ([]Item, error) {
-	items, _, err := p.client.ListItems(ctx, excellentbooks.ListParams{Limit: 5000})
-	if err != nil {
-		return nil, p.wrapError("ListItems", err)
-	}
+func (p *excellentProvider) ListItems(ctx context.Context, input ListItemsInput) ([]Item, error) {
+	return collectPages(p.ItemPages(ctx, input))
+}
 
-	result := make([]Item, len(items))
-	for i := range items {
-		result[i] = *mapExcellentItem(&items[i])
+func (p *excellentProvider) ItemPages(ctx context.Context, input ListItemsInput) iter.Seq2[[]Item, error] {
+	params := excellentbooks.ListParams{Filter: map[string]string{}}
+	if input.Code != "" {
+		params.Filter["Code"] = input.Code
 	}
-	return result, nil
+	return mapPages(excellentbooks.Pages(ctx, params, p.client.ListItems),
+		func(item *excellentbooks.Item) Item { return *mapExcellentItem(item) },
+		func(err error) error { return p.wrapError("ListItems", err) })
 }
 
 // UpdateItem updates an existing INVc item in Excellent Books. Field coverage
@@ -543,7 +624,7 @@ func (p *excellentProvider) CreateCreditNote(ctx context.Context, input CreateCr
 	// cash note and strips the OrdRow link), it will NOT reduce the original
 	// invoice and the invoice will silently stay open. Surface it loudly.
 	if inv.InvType != "3" {
-		slog.Warn("excellentbooks: credit note did not stay a kreeditarve (InvType != 3) — it will NOT reduce the original invoice, which stays open",
+		p.client.Logger().Warn("excellentbooks: credit note did not stay a kreeditarve (InvType != 3) — it will NOT reduce the original invoice, which stays open",
 			"ser_nr", inv.SerNr, "inv_type", inv.InvType, "cred_inv", input.OriginalInvoiceNo)
 	}
 	return mapExcellentInvoice(inv), nil
@@ -552,15 +633,15 @@ func (p *excellentProvider) CreateCreditNote(ctx context.Context, input CreateCr
 // --- Purchases ---
 
 func (p *excellentProvider) CreatePurchase(_ context.Context, _ CreatePurchaseInput) (*PurchaseInvoice, error) {
-	return nil, p.wrapError("CreatePurchase", fmt.Errorf("not yet implemented"))
+	return nil, p.wrapError("CreatePurchase", fmt.Errorf("%w: not yet implemented", ErrNotSupported))
 }
 
 func (p *excellentProvider) GetPurchase(_ context.Context, _ string) (*PurchaseInvoice, error) {
-	return nil, p.wrapError("GetPurchase", fmt.Errorf("not yet implemented"))
+	return nil, p.wrapError("GetPurchase", fmt.Errorf("%w: not yet implemented", ErrNotSupported))
 }
 
 func (p *excellentProvider) ListPurchases(ctx context.Context, input ListPurchasesInput) ([]PurchaseInvoice, error) {
-	params := excellentbooks.ListParams{Limit: 5000}
+	var params excellentbooks.ListParams
 	if !input.PeriodStart.IsZero() {
 		params.Sort = "InvDate"
 		params.Range = formatExcellentDate(input.PeriodStart) + ":"
@@ -569,7 +650,7 @@ func (p *excellentProvider) ListPurchases(ctx context.Context, input ListPurchas
 		}
 	}
 
-	items, _, err := p.client.ListPurchases(ctx, params)
+	items, err := excellentbooks.All(ctx, params, p.client.ListPurchases)
 	if err != nil {
 		return nil, p.wrapError("ListPurchases", err)
 	}
@@ -597,13 +678,14 @@ func (p *excellentProvider) ListPurchases(ctx context.Context, input ListPurchas
 }
 
 func (p *excellentProvider) DeletePurchase(_ context.Context, _ string) error {
-	return p.wrapError("DeletePurchase", fmt.Errorf("not supported by Excellent Books API"))
+	return p.wrapError("DeletePurchase", fmt.Errorf("%w by Excellent Books API", ErrNotSupported))
 }
 
 // --- Reference data ---
 
 func (p *excellentProvider) ListTaxes(ctx context.Context) ([]Tax, error) {
-	codes, _, err := p.client.ListVATCodes(ctx, excellentbooks.ListParams{Limit: 5000})
+	// VATCodeBlock is a single settings record, so there is nothing to page.
+	codes, _, err := p.client.ListVATCodes(ctx, excellentbooks.ListParams{})
 	if err != nil {
 		return nil, p.wrapError("ListTaxes", err)
 	}
@@ -630,7 +712,7 @@ func (p *excellentProvider) ListTaxes(ctx context.Context) ([]Tax, error) {
 }
 
 func (p *excellentProvider) ListPaymentTerms(ctx context.Context) ([]PaymentTerm, error) {
-	rows, _, err := p.client.ListPaymentTerms(ctx, excellentbooks.ListParams{Limit: 1000})
+	rows, err := excellentbooks.All(ctx, excellentbooks.ListParams{}, p.client.ListPaymentTerms)
 	if err != nil {
 		return nil, p.wrapError("ListPaymentTerms", err)
 	}
@@ -656,7 +738,7 @@ func (p *excellentProvider) ListPaymentTerms(ctx context.Context) ([]PaymentTerm
 }
 
 func (p *excellentProvider) ListAccounts(ctx context.Context) ([]Account, error) {
-	accs, _, err := p.client.ListGLAccounts(ctx, excellentbooks.ListParams{Limit: 5000})
+	accs, err := excellentbooks.All(ctx, excellentbooks.ListParams{}, p.client.ListGLAccounts)
 	if err != nil {
 		return nil, p.wrapError("ListAccounts", err)
 	}
@@ -685,15 +767,15 @@ func (p *excellentProvider) ListBanks(_ context.Context) ([]Bank, error) {
 }
 
 func (p *excellentProvider) ListDimensions(ctx context.Context) (*DimensionList, error) {
-	objects, _, err := p.client.ListObjects(ctx, excellentbooks.ListParams{Limit: 5000})
+	objects, err := excellentbooks.All(ctx, excellentbooks.ListParams{}, p.client.ListObjects)
 	if err != nil {
 		return nil, p.wrapError("ListDimensions", err)
 	}
-	projects, _, err := p.client.ListProjects(ctx, excellentbooks.ListParams{Limit: 5000})
+	projects, err := excellentbooks.All(ctx, excellentbooks.ListParams{}, p.client.ListProjects)
 	if err != nil {
 		return nil, p.wrapError("ListDimensions", err)
 	}
-	departments, _, err := p.client.ListDepartments(ctx, excellentbooks.ListParams{Limit: 5000})
+	departments, err := excellentbooks.All(ctx, excellentbooks.ListParams{}, p.client.ListDepartments)
 	if err != nil {
 		return nil, p.wrapError("ListDimensions", err)
 	}
@@ -727,23 +809,39 @@ func (p *excellentProvider) ListDimensions(ctx context.Context) (*DimensionList,
 // --- Reports ---
 
 func (p *excellentProvider) CustomerDebts(_ context.Context, _ string, _ *int) ([]CustomerDebt, error) {
-	return nil, p.wrapError("CustomerDebts", fmt.Errorf("not yet implemented"))
+	return nil, p.wrapError("CustomerDebts", fmt.Errorf("%w: not yet implemented", ErrNotSupported))
 }
 
 // --- Sync ---
 
+// ListInvoicesSince delegates to ListInvoices with a date range, so it is
+// document-date-based. The SyncEngine tracks changes through @sequence
+// instead (InvoiceChangesAfter).
 func (p *excellentProvider) ListInvoicesSince(ctx context.Context, since time.Time, until time.Time) ([]Invoice, error) {
 	return p.ListInvoices(ctx, ListInvoicesInput{PeriodStart: since, PeriodEnd: until})
 }
 
-// ListPaymentsSince delegates to ListPayments with a date range.
-// Excellent Books does not expose change-tracking on payments via the REST API,
-// so this is document-date-based, not "changed since" semantics. Callers should
-// use a window large enough to catch any back-dated entries.
+// ListPaymentsSince delegates to ListPayments with a date range, so this
+// is document-date-based, not "changed since" semantics: a time cannot be
+// mapped to an @sequence. Callers should use a window large enough to
+// catch any back-dated entries, or the SyncEngine, which follows @sequence
+// (PaymentChangesAfter).
 func (p *excellentProvider) ListPaymentsSince(ctx context.Context, since time.Time, until time.Time) ([]Payment, error) {
 	return p.ListPayments(ctx, ListPaymentsInput{PeriodStart: since, PeriodEnd: until})
 }
 
+// ListInvoiceChanges is ListInvoicesSince, without tombstones.
+func (p *excellentProvider) ListInvoiceChanges(ctx context.Context, since time.Time, until time.Time) (InvoiceChanges, error) {
+	invoices, err := p.ListInvoicesSince(ctx, since, until)
+	return InvoiceChanges{Invoices: invoices}, err
+}
+
+// ListPaymentChanges is ListPaymentsSince, without tombstones.
+func (p *excellentProvider) ListPaymentChanges(ctx context.Context, since time.Time, until time.Time) (PaymentChanges, error) {
+	payments, err := p.ListPaymentsSince(ctx, since, until)
+	return PaymentChanges{Payments: payments}, err
+}
+
 // --- Mapping helpers ---
 
 func mapExcellentInvoice(inv *excellentbooks.Invoice) *Invoice {
@@ -870,19 +968,49 @@ func (p *excellentProvider) wrapError(op string, err error) error {
 
 	var apiErr *excellentbooks.APIError
 	if errors.As(err, &apiErr) {
-		var sentinel error
-		switch apiErr.StatusCode {
-		case 401, 403:
-			sentinel = ErrAuthFailed
-		case 404:
-			sentinel = ErrNotFound
-		case 429:
-			sentinel = ErrRateLimit
-		default:
-			sentinel = err
-		}
-		return &ProviderError{Provider: "excellentbooks", Op: op, Err: sentinel}
+		return &ProviderError{Provider: "excellentbooks", Op: op, Err: excellentErrorKind(apiErr, err)}
 	}
 
 	return &ProviderError{Provider: "excellentbooks", Op: op, Err: err}
 }
+
+// excellentErrorKind maps an Excellent Books error onto the error taxonomy
+// using the @code and @field of EB's error payload.
+func excellentErrorKind(apiErr *excellentbooks.APIError, err error) error {
+	switch {
+	case apiErr.StatusCode == 401, apiErr.StatusCode == 403:
+		return ErrAuthFailed
+	case apiErr.StatusCode == 404:
+		return ErrNotFound
+	case apiErr.StatusCode == 429:
+		return ErrRateLimit
+	case periodClosedMessage(apiErr.Message):
+		return withKind(ErrPeriodClosed, err)
+	}
+	invalid := &ValidationError{Field: apiErr.ErrorField, Code: apiErr.ErrorCode, Message: apiErr.Message, Err: err}
+	switch apiErr.ErrorCode {
+	case "":
+	case "1557":
+		// The record key is rejected: either it is taken, or the register
+		// has no number series to assign one from ("Ei ole määratud
+		// numbriseerias"), which the caller fixes by sending a code.
+		if msg := strings.ToLower(apiErr.Message); strings.Contains(msg, "numbriseeria") || strings.Contains(msg, "number series") {
+			return invalid
+		}
+		return withKind(ErrDuplicate, err)
+	case "20060":
+		// The record is approved (OKFlag=1) or settled and can't change.
+		return withKind(ErrConflict, err)
+	default:
+		// 1256 (value not in use in the referenced register), 1289 (missing
+		// CUPNr) and the other coded rejections concern one input field.
+		return invalid
+	}
+	switch {
+	case apiErr.StatusCode == 409:
+		return withKind(ErrConflict, err)
+	case apiErr.StatusCode >= 500:
+		return withKind(ErrTransient, err)
+	}
+	return err
+}
@@ -149,7 +149,7 @@ func (p *excellentProvider) UnallocateToPrepayment(ctx context.Context, input Un
 // not expose a prepayment register directly, so this is derived from receipts
 // in the window.
 func (p *excellentProvider) ListPrepayments(ctx context.Context, input ListPrepaymentsInput) ([]Prepayment, error) {
-	params := excellentbooks.ListParams{Limit: 5000}
+	var params excellentbooks.ListParams
 	if !input.Since.IsZero() {
 		params.Sort = "TransDate"
 		params.Range = formatExcellentDate(input.Since) + ":"
@@ -158,41 +158,41 @@ func (p *excellentProvider) ListPrepayments(ctx context.Context, input ListPrepa
 		}
 	}
 
-	receipts, _, err := p.client.ListReceipts(ctx, params)
-	if err != nil {
-		return nil, p.wrapError("ListPrepayments", err)
-	}
-
 	type agg struct {
 		pp    Prepayment
 		order int
 	}
 	byCUPNr := make(map[string]*agg)
 	next := 0
-	for _, r := range receipts {
-		for _, row := range r.Rows {
-			if row.CUPNr == "" {
-				continue
-			}
-			if input.CustomerCode != "" && row.CustCode != input.CustomerCode {
-				continue
-			}
-			val, _ := decimal.NewFromString(row.RecVal)
-			a, ok := byCUPNr[row.CUPNr]
-			if !ok {
-				a = &agg{order: next, pp: Prepayment{
-					Number:       row.CUPNr,
-					CustomerCode: row.CustCode,
-					Currency:     r.PayCurCode,
-					Date:         parseExcellentDate(r.TransDate),
-					Comment:      row.Comment,
-				}}
-				byCUPNr[row.CUPNr] = a
-				next++
-			}
-			a.pp.Remaining = a.pp.Remaining.Add(val)
-			if val.IsPositive() {
-				a.pp.Amount = a.pp.Amount.Add(val)
+	for receipts, err := range excellentbooks.Pages(ctx, params, p.client.ListReceipts) {
+		if err != nil {
+			return nil, p.wrapError("ListPrepayments", err)
+		}
+		for _, r := range receipts {
+			for _, row := range r.Rows {
+				if row.CUPNr == "" {
+					continue
+				}
+				if input.CustomerCode != "" && row.CustCode != input.CustomerCode {
+					continue
+				}
+				val, _ := decimal.NewFromString(row.RecVal)
+				a, ok := byCUPNr[row.CUPNr]
+				if !ok {
+					a = &agg{order: next, pp: Prepayment{
+						Number:       row.CUPNr,
+						CustomerCode: row.CustCode,
+						Currency:     r.PayCurCode,
+						Date:         parseExcellentDate(r.TransDate),
+						Comment:      row.Comment,
+					}}
+					byCUPNr[row.CUPNr] = a
+					next++
+				}
+				a.pp.Remaining = a.pp.Remaining.Add(val)
+				if val.IsPositive() {
+					a.pp.Amount = a.pp.Amount.Add(val)
+				}
 			}
 		}
 	}
@@ -0,0 +1,126 @@
+package accounting
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/excellentbooks/ebtest"
+)
+
+func TestSyncEngine_ExcellentBooksSequence(t *testing.T) {
+	ctx := context.Background()
+	srv := ebtest.NewServer()
+	defer srv.Close()
+	ec := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "excellentbooks",
+		APIID:    ec.Username,
+		APIKey:   ec.Password,
+		ExcellentBooks: &ExcellentBooksOptions{
+			BaseURL:     ec.BaseURL,
+			CompanyCode: ec.CompanyCode,
+		},
+	})
+	store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore: %v", err)
+	}
+	engine, err := NewSyncEngine(SyncEngineConfig{Store: store})
+	if err != nil {
+		t.Fatalf("NewSyncEngine: %v", err)
+	}
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Code: "C1", Name: "Acme OÜ"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	first, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "", "1"))
+	if err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+
+	var invoices []string
+	syncInvoices := func() {
+		t.Helper()
+		invoices = nil
+		err := engine.SyncInvoices(ctx, "club-1", client, func(_ context.Context, c InvoiceChanges) error {
+			for _, inv := range c.Invoices {
+				invoices = append(invoices, inv.Number)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("SyncInvoices: %v", err)
+		}
+	}
+	syncInvoices()
+	if want := []string{first.Number}; !slices.Equal(invoices, want) {
+		t.Errorf("first sync = %v, want %v", invoices, want)
+	}
+	cp, _ := store.Load(ctx, "club-1", SyncInvoices)
+	if cp.Cursor != srv.Sequence() {
+		t.Errorf("cursor after first sync = %q, want the register sequence %s", cp.Cursor, srv.Sequence())
+	}
+
+	// An invoice dated a year back is outside any date window since the
+	// checkpoint, but its @sequence is new.
+	backdated := e2eInvoice(cust.ID, cust.Name, "", "1")
+	backdated.DocDate = e2eDocDate.AddDate(-1, 0, 0)
+	backdated.DueDate = backdated.DocDate.AddDate(0, 0, 14)
+	second, err := client.Invoices.Create(ctx, backdated)
+	if err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+	syncInvoices()
+	if want := []string{second.Number}; !slices.Equal(invoices, want) {
+		t.Errorf("sync after a back-dated invoice = %v, want %v", invoices, want)
+	}
+	syncInvoices()
+	if len(invoices) != 0 {
+		t.Errorf("sync without changes = %v, want none", invoices)
+	}
+
+	var names []string
+	syncCustomers := func() {
+		t.Helper()
+		names = nil
+		err := engine.SyncCustomers(ctx, "club-1", client, func(_ context.Context, c CustomerChanges) error {
+			for _, cust := range c.Customers {
+				names = append(names, cust.Name)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("SyncCustomers: %v", err)
+		}
+	}
+	syncCustomers()
+	if want := []string{"Acme OÜ"}; !slices.Equal(names, want) {
+		t.Errorf("first customer sync = %v, want %v", names, want)
+	}
+	renamed := "Acme Grupp OÜ"
+	if err := client.Customers.Update(ctx, UpdateCustomerInput{ID: cust.ID, Name: &renamed}); err != nil {
+		t.Fatalf("Customers.Update: %v", err)
+	}
+	syncCustomers()
+	if want := []string{renamed}; !slices.Equal(names, want) {
+		t.Errorf("customer sync after a rename = %v, want %v", names, want)
+	}
+
+	// SyncService exposes the same feed directly.
+	changes, cursor, err := client.Sync.PaymentChangesAfter(ctx, "", e2eDocDate)
+	if err != nil || len(changes.Payments) != 0 || cursor == "" {
+		t.Errorf("PaymentChangesAfter from scratch = %d payments, cursor %q, err %v; want none and a cursor", len(changes.Payments), cursor, err)
+	}
+	inv, next, err := client.Sync.InvoiceChangesAfter(ctx, cp.Cursor, time.Time{})
+	if err != nil || len(inv.Invoices) != 1 || inv.Invoices[0].Number != second.Number || next == cp.Cursor {
+		t.Errorf("InvoiceChangesAfter(%s) = %d invoices, cursor %q, err %v; want %s", cp.Cursor, len(inv.Invoices), next, err, second.Number)
+	}
+
+	if !client.Capabilities().SupportsIncrementalSync {
+		t.Error("Excellent Books capabilities: want SupportsIncrementalSync")
+	}
+}
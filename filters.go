@@ -0,0 +1,122 @@
+package accounting
+
+import (
+	"iter"
+	"strings"
+	"time"
+)
+
+// The services apply the List*Input filters to every result: a provider
+// pushes down what its API can express and this catches the rest, so a
+// filter behaves the same whichever provider evaluated it. ChangedSince
+// is checked locally only against invoices whose provider reports
+// Invoice.ChangedAt; otherwise it is left to the providers.
+
+func (in ListInvoicesInput) matches(inv *Invoice) bool {
+	return (in.CustomerCode == "" || inv.CustomerID == in.CustomerCode) &&
+		(in.Status == "" || inv.Status == in.Status) &&
+		(!in.UnpaidOnly || inv.Status != InvoiceStatusPaid) &&
+		(in.NumberFrom == "" || compareNumbers(inv.Number, in.NumberFrom) >= 0) &&
+		(in.NumberTo == "" || compareNumbers(inv.Number, in.NumberTo) <= 0) &&
+		(in.ReferenceNo == "" || inv.ReferenceNo == in.ReferenceNo) &&
+		(in.ChangedSince.IsZero() || inv.ChangedAt.IsZero() || !beforeDay(inv.ChangedAt, in.ChangedSince))
+}
+
+// inPeriod reports whether inv's document date falls in the period, by
+// calendar day. Providers that range on the change date for ChangedSince
+// use it to apply the period themselves.
+func (in ListInvoicesInput) inPeriod(inv *Invoice) bool {
+	return (in.PeriodStart.IsZero() || !beforeDay(inv.DocDate, in.PeriodStart)) &&
+		(in.PeriodEnd.IsZero() || !beforeDay(in.PeriodEnd, inv.DocDate))
+}
+
+// beforeDay reports whether t falls on an earlier calendar day than day,
+// each read in its own location.
+func beforeDay(t, day time.Time) bool {
+	return t.Format(time.DateOnly) < day.Format(time.DateOnly)
+}
+
+// filtersStatus reports whether in filters on payment status.
+func (in ListInvoicesInput) filtersStatus() bool {
+	return in.Status != "" || in.UnpaidOnly
+}
+
+func (in ListCustomersInput) matches(c *Customer) bool {
+	return (in.Name == "" || containsFold(c.Name, in.Name)) &&
+		(in.RegNo == "" || strings.TrimSpace(c.RegNo) == strings.TrimSpace(in.RegNo)) &&
+		(in.Email == "" || strings.EqualFold(strings.TrimSpace(c.Email), strings.TrimSpace(in.Email)))
+}
+
+func (in ListPaymentsInput) matches(p *Payment) bool {
+	return in.Direction == "" || p.Direction == in.Direction
+}
+
+func (in ListItemsInput) matches(it *Item) bool {
+	return (in.Code == "" || it.Code == in.Code) &&
+		(in.Description == "" || containsFold(it.Name, in.Description) || containsFold(it.Description, in.Description)) &&
+		(in.Type == "" || it.Type == in.Type)
+}
+
+// filterList keeps the elements of list that match, reusing its array.
+func filterList[T any](list []T, match func(*T) bool) []T {
+	out := list[:0]
+	for i := range list {
+		if match(&list[i]) {
+			out = append(out, list[i])
+		}
+	}
+	return out
+}
+
+// filterSeq yields the values of seq that match, and any error.
+func filterSeq[T any](seq iter.Seq2[T, error], match func(*T) bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if err == nil && !match(&v) {
+				continue
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+// filterPages keeps the elements of each page of pages that match.
+func filterPages[T any](pages iter.Seq2[[]T, error], match func(*T) bool) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		for page, err := range pages {
+			if !yield(filterList(page, match), err) {
+				return
+			}
+		}
+	}
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// compareNumbers orders document numbers: numerically when both are all
+// digits, so "99" < "100", and as strings otherwise.
+func compareNumbers(a, b string) int {
+	if isDigits(a) && isDigits(b) {
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+package accounting
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/directo/directotest"
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/qbitsoftware/accounting-service/smartaccounts/satest"
+)
+
+func TestListInvoicesInput_Matches(t *testing.T) {
+	inv := Invoice{
+		Number:      "120",
+		CustomerID:  "C1",
+		Status:      InvoiceStatusPartial,
+		ReferenceNo: "77",
+		ChangedAt:   time.Date(2025, 3, 10, 9, 30, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name  string
+		input ListInvoicesInput
+		want  bool
+	}{
+		{"no filter", ListInvoicesInput{}, true},
+		{"customer", ListInvoicesInput{CustomerCode: "C1"}, true},
+		{"other customer", ListInvoicesInput{CustomerCode: "C2"}, false},
+		{"status", ListInvoicesInput{Status: InvoiceStatusPaid}, false},
+		{"unpaid only", ListInvoicesInput{UnpaidOnly: true}, true},
+		{"numeric range", ListInvoicesInput{NumberFrom: "99", NumberTo: "120"}, true},
+		{"below range", ListInvoicesInput{NumberFrom: "121"}, false},
+		{"reference", ListInvoicesInput{ReferenceNo: "78"}, false},
+		{"changed since", ListInvoicesInput{ChangedSince: time.Date(2025, 3, 10, 23, 0, 0, 0, time.UTC)}, true},
+		{"unchanged since", ListInvoicesInput{ChangedSince: time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.input.matches(&inv); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"99", "100", -1},
+		{"0100", "100", 0},
+		{"A-10", "A-9", -1}, // not all digits: text order
+		{"B-1", "A-9", 1},
+	}
+	for _, tt := range tests {
+		got := compareNumbers(tt.a, tt.b)
+		if (got < 0) != (tt.want < 0) || (got > 0) != (tt.want > 0) {
+			t.Errorf("compareNumbers(%q, %q) = %d, want sign of %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestCustomerFilters checks that each provider answers the customer
+// filters alike, whether it pushes them down or not.
+func TestCustomerFilters(t *testing.T) {
+	seed := []CreateCustomerInput{
+		{Code: "C1", Name: "Acme OÜ", RegNo: "10000001", Email: "info@acme.ee"},
+		{Code: "C2", Name: "Acme Grupp AS", RegNo: "10000002", Email: "arved@grupp.ee"},
+		{Code: "C3", Name: "Beta MTÜ", RegNo: "10000003", Email: "Beta@Example.ee"},
+	}
+	tests := []struct {
+		name  string
+		input ListCustomersInput
+		want  []string
+	}{
+		{"name substring", ListCustomersInput{Name: "acme"}, []string{"Acme Grupp AS", "Acme OÜ"}},
+		{"regno", ListCustomersInput{RegNo: "10000002"}, []string{"Acme Grupp AS"}},
+		{"email", ListCustomersInput{Email: "beta@example.ee"}, []string{"Beta MTÜ"}},
+		{"name and regno", ListCustomersInput{Name: "Beta", RegNo: "10000001"}, nil},
+	}
+	for name, client := range conformanceClients(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, in := range seed {
+				if _, err := client.Customers.Create(ctx, in); err != nil {
+					t.Fatalf("Customers.Create(%s): %v", in.Name, err)
+				}
+			}
+			for _, tt := range tests {
+				list, err := client.Customers.List(ctx, tt.input)
+				if err != nil {
+					t.Fatalf("%s: Customers.List: %v", tt.name, err)
+				}
+				var got []string
+				for _, c := range list {
+					got = append(got, c.Name)
+				}
+				slices.Sort(got)
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestInvoiceFilters_SmartAccounts(t *testing.T) {
+	ctx := context.Background()
+	srv := satest.NewServer()
+	defer srv.Close()
+	sc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider:   "smartaccounts",
+		APIID:      sc.APIKey,
+		APIKey:     sc.SecretKey,
+		Region:     sc.Host,
+		HTTPClient: sc.HTTPClient,
+		Resilience: &resilience.Policy{RatePerSecond: 1000},
+	})
+
+	acme, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "10000001"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	beta, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Beta AS", RegNo: "10000002"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	for _, inv := range []CreateInvoiceInput{
+		e2eInvoice(acme.ID, acme.Name, "9", "KM22"),
+		e2eInvoice(acme.ID, acme.Name, "10", "KM22"),
+		e2eInvoice(beta.ID, beta.Name, "11", "KM22"),
+	} {
+		if _, err := client.Invoices.Create(ctx, inv); err != nil {
+			t.Fatalf("Invoices.Create(%s): %v", inv.InvoiceNo, err)
+		}
+	}
+	if err := client.Payments.Create(ctx, CreatePaymentInput{
+		InvoiceNo:   "10",
+		PaymentDate: e2eDocDate,
+		Amount:      d("122"),
+		Currency:    "EUR",
+		BankID:      "LHV",
+	}); err != nil {
+		t.Fatalf("Payments.Create: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input ListInvoicesInput
+		want  []string
+	}{
+		{"customer", ListInvoicesInput{CustomerCode: acme.ID}, []string{"10", "9"}},
+		{"unpaid", ListInvoicesInput{UnpaidOnly: true}, []string{"11", "9"}},
+		{"number range", ListInvoicesInput{NumberFrom: "9", NumberTo: "10"}, []string{"10", "9"}},
+		{"single number", ListInvoicesInput{NumberFrom: "11", NumberTo: "11"}, []string{"11"}},
+		{"customer and status", ListInvoicesInput{CustomerCode: beta.ID, Status: InvoiceStatusPaid}, nil},
+		{"changed today", ListInvoicesInput{ChangedSince: e2eDocDate}, []string{"10", "11", "9"}},
+		{"changed since tomorrow", ListInvoicesInput{ChangedSince: e2eDocDate.AddDate(0, 0, 1)}, nil},
+	}
+	for _, tt := range tests {
+		// SmartAccounts only lists without a period when filtering on
+		// payment status.
+		tt.input.PeriodStart, tt.input.PeriodEnd = e2eDocDate, e2eDueDate
+		list, err := client.Invoices.List(ctx, tt.input)
+		if err != nil {
+			t.Fatalf("%s: Invoices.List: %v", tt.name, err)
+		}
+		var got []string
+		for _, inv := range list {
+			got = append(got, inv.Number)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInvoiceFilters_ExcellentBooksStatus(t *testing.T) {
+	client := conformanceClients(t)["excellentbooks"]
+	_, err := client.Invoices.List(context.Background(), ListInvoicesInput{UnpaidOnly: true})
+	if !IsNotSupported(err) {
+		t.Errorf("UnpaidOnly: err = %v, want ErrNotSupported", err)
+	}
+	_, err = client.Invoices.List(context.Background(), ListInvoicesInput{ChangedSince: e2eDocDate})
+	if !IsNotSupported(err) {
+		t.Errorf("ChangedSince: err = %v, want ErrNotSupported", err)
+	}
+}
+
+// TestInvoiceFilters_ChangedSince checks the providers that range on the
+// change date for ChangedSince still apply the document period.
+func TestInvoiceFilters_ChangedSince(t *testing.T) {
+	earlier := e2eDocDate.AddDate(0, 0, -5).Add(12 * time.Hour)
+	clock := earlier
+	now := func() time.Time { return clock }
+
+	ms := merittest.NewServer()
+	defer ms.Close()
+	ms.Now = now
+	mc := ms.Config()
+	ds := directotest.NewServer()
+	defer ds.Close()
+	ds.Now = now
+	dc := ds.Config()
+
+	clients := map[string]struct {
+		client *Client
+		taxID  string
+	}{
+		"merit": {newEmulatorClient(t, Config{
+			Provider: "merit",
+			APIID:    mc.APIID,
+			APIKey:   mc.APIKey,
+			Extra:    map[string]string{"api_url": mc.APIURL},
+		}), ms.TaxID(22)},
+		"directo": {newEmulatorClient(t, Config{
+			Provider: "directo",
+			APIID:    dc.Company,
+			APIKey:   dc.Token,
+			Directo: &DirectoOptions{
+				RESTAPIKey:  dc.RestAPIKey,
+				RESTBaseURL: dc.RESTBaseURL,
+				XMLBaseURL:  dc.XMLBaseURL,
+			},
+		}), "1"},
+	}
+	tests := []struct {
+		name  string
+		input ListInvoicesInput
+		want  []string
+	}{
+		{"today", ListInvoicesInput{ChangedSince: e2eDocDate}, []string{"CS-2"}},
+		{"earlier", ListInvoicesInput{ChangedSince: earlier}, []string{"CS-1", "CS-2"}},
+		{"outside the period", ListInvoicesInput{ChangedSince: earlier, PeriodEnd: e2eDocDate.AddDate(0, 0, -1)}, nil},
+	}
+	for name, c := range clients {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			clock = earlier
+			cust, err := c.client.Customers.Create(ctx, CreateCustomerInput{Code: "C1", Name: "Acme OÜ", RegNo: "10000001"})
+			if err != nil {
+				t.Fatalf("Customers.Create: %v", err)
+			}
+			for _, no := range []string{"CS-1", "CS-2"} {
+				if _, err := c.client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, no, c.taxID)); err != nil {
+					t.Fatalf("Invoices.Create(%s): %v", no, err)
+				}
+				clock = e2eDocDate.Add(12 * time.Hour)
+			}
+			for _, tt := range tests {
+				list, err := c.client.Invoices.List(ctx, tt.input)
+				if err != nil {
+					t.Fatalf("%s: Invoices.List: %v", tt.name, err)
+				}
+				var got []string
+				for _, inv := range list {
+					got = append(got, inv.Number)
+				}
+				slices.Sort(got)
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
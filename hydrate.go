@@ -0,0 +1,174 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"sync"
+
+	"github.com/qbitsoftware/accounting-service/resilience"
+)
+
+// hydrateConcurrency bounds how many invoices Hydrate fetches at once.
+const hydrateConcurrency = 4
+
+// invoiceDetailLister is implemented by adapters whose invoice lists
+// already return what GetInvoice does, so hydrating a list needs no
+// further requests: SmartAccounts and Excellent Books list the rows with
+// each invoice, and Directo's REST invoice query has no rows to add.
+type invoiceDetailLister interface {
+	listsInvoiceDetails()
+}
+
+// HydrationError reports the invoices Hydrate could not fetch; the others
+// were hydrated. errors.Is matches the causes, so IsRateLimit tells a
+// spent quota apart from invoices that no longer exist.
+type HydrationError struct {
+	Failed []HydrationFailure
+	// Total is the number of invoices Hydrate was given.
+	Total int
+}
+
+// HydrationFailure is one invoice Hydrate could not fetch.
+type HydrationFailure struct {
+	InvoiceID string
+	Err       error
+}
+
+func (e *HydrationError) Error() string {
+	msg := fmt.Sprintf("accounting: %d of %d invoices not hydrated: %s: %v", len(e.Failed), e.Total, e.Failed[0].InvoiceID, e.Failed[0].Err)
+	if len(e.Failed) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Failed)-1)
+	}
+	return msg
+}
+
+func (e *HydrationError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// Hydrate returns invoices with their details — lines and, where the
+// provider reports them, payments — as GetInvoice returns them. Lists
+// from Merit carry neither, so each invoice is fetched, at most
+// hydrateConcurrency at a time; for the other providers the list already
+// has everything and no request is made.
+//
+// Requests go through the client's rate limiter and retry policy. Once
+// one is rate limited or the circuit breaker opens, no further invoices
+// are fetched: they fail with the same error rather than spend the quota
+// that is left. Invoices that could not be fetched keep their list
+// version and are reported in a *HydrationError, returned with the
+// result.
+func (s *InvoiceService) Hydrate(ctx context.Context, invoices []Invoice) ([]Invoice, error) {
+	out := slices.Clone(invoices)
+	if _, ok := unwrapProvider(s.provider).(invoiceDetailLister); ok || len(out) == 0 {
+		return out, nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		halt error
+		errs = make([]error, len(out))
+		sem  = make(chan struct{}, hydrateConcurrency)
+	)
+	for i := range out {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := halt
+		mu.Unlock()
+		if stop == nil {
+			stop = ctx.Err()
+		}
+		if stop != nil {
+			<-sem
+			errs[i] = stop
+			continue
+		}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			inv, err := s.provider.GetInvoice(ctx, out[i].ID)
+			if err == nil && inv == nil {
+				err = ErrNotFound
+			}
+			if err != nil {
+				errs[i] = err
+				if haltsHydration(err) {
+					mu.Lock()
+					halt = err
+					mu.Unlock()
+				}
+				return
+			}
+			out[i] = *inv
+		})
+	}
+	wg.Wait()
+
+	herr := &HydrationError{Total: len(out)}
+	for i, err := range errs {
+		if err != nil {
+			herr.Failed = append(herr.Failed, HydrationFailure{InvoiceID: out[i].ID, Err: err})
+		}
+	}
+	if len(herr.Failed) > 0 {
+		return out, herr
+	}
+	return out, nil
+}
+
+// haltsHydration reports whether err means no more invoices should be
+// fetched: the quota is spent or the circuit breaker is open.
+func haltsHydration(err error) bool {
+	return errors.Is(err, ErrRateLimit) || errors.Is(err, resilience.ErrCircuitOpen)
+}
+
+// hydratePages keeps the invoices of each page that match and hydrates
+// them. As with Hydrate, an invoice that could not be fetched is yielded
+// in its list version and iteration goes on; the failures of all pages
+// are reported together at the end, as one *HydrationError. A failure
+// that halts fetching, or a canceled ctx, ends the iteration after its
+// page. An error listing the pages ends it too, joined with the failures
+// so far.
+func (s *InvoiceService) hydratePages(ctx context.Context, pages iter.Seq2[[]Invoice, error], match func(*Invoice) bool) iter.Seq2[[]Invoice, error] {
+	return func(yield func([]Invoice, error) bool) {
+		failed := &HydrationError{}
+		report := func(err error) {
+			switch {
+			case len(failed.Failed) == 0:
+			case err == nil:
+				err = failed
+			default:
+				err = errors.Join(err, failed)
+			}
+			if err != nil {
+				yield(nil, err)
+			}
+		}
+		for page, err := range pages {
+			if err != nil {
+				report(err)
+				return
+			}
+			page, err = s.Hydrate(ctx, filterList(page, match))
+			failed.Total += len(page)
+			var herr *HydrationError
+			if errors.As(err, &herr) {
+				failed.Failed = append(failed.Failed, herr.Failed...)
+			}
+			if !yield(page, nil) {
+				return
+			}
+			if haltsHydration(err) || ctx.Err() != nil {
+				break
+			}
+		}
+		report(nil)
+	}
+}
@@ -0,0 +1,237 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/qbitsoftware/accounting-service/smartaccounts/satest"
+)
+
+func TestInvoices_WithLines_Merit(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider:   "merit",
+		APIID:      mc.APIID,
+		APIKey:     mc.APIKey,
+		Extra:      map[string]string{"api_url": mc.APIURL},
+		Resilience: &resilience.Policy{MaxRetries: -1},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	const n = 9
+	for i := range n {
+		if _, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, fmt.Sprintf("H-%d", i+1), srv.TaxID(22))); err != nil {
+			t.Fatalf("Invoices.Create: %v", err)
+		}
+	}
+	input := ListInvoicesInput{PeriodStart: e2eDocDate, PeriodEnd: e2eDocDate}
+
+	listed, err := client.Invoices.List(ctx, input)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(listed) != n || len(listed[0].Lines) != 0 {
+		t.Fatalf("List: %d invoices with %d lines, want %d without", len(listed), len(listed[0].Lines), n)
+	}
+
+	input.WithLines = true
+	before := countRequests(srv.Requests(), "v2/getinvoice")
+	got, err := client.Invoices.List(ctx, input)
+	if err != nil {
+		t.Fatalf("List WithLines: %v", err)
+	}
+	if k := countRequests(srv.Requests(), "v2/getinvoice") - before; k != n {
+		t.Errorf("%d getinvoice requests, want %d", k, n)
+	}
+	for i, inv := range got {
+		if inv.ID != listed[i].ID || len(inv.Lines) != 1 {
+			t.Errorf("invoice %d: ID %s with %d lines, want %s with 1", i, inv.ID, len(inv.Lines), listed[i].ID)
+		}
+	}
+
+	var all int
+	for inv, err := range client.Invoices.All(ctx, input) {
+		if err != nil {
+			t.Fatalf("All WithLines: %v", err)
+		}
+		if len(inv.Lines) != 1 {
+			t.Errorf("All: invoice %s has %d lines, want 1", inv.Number, len(inv.Lines))
+		}
+		all++
+	}
+	if all != n {
+		t.Errorf("All yielded %d invoices, want %d", all, n)
+	}
+
+	// A failed invoice keeps its list version; the others are hydrated.
+	srv.InjectFault("v2/getinvoice", merittest.Fault{Status: http.StatusBadRequest, Body: "No such invoice"})
+	got, err = client.Invoices.Hydrate(ctx, listed)
+	var herr *HydrationError
+	if !errors.As(err, &herr) || len(herr.Failed) != 1 || herr.Total != n {
+		t.Fatalf("Hydrate with one failure: err = %v, want a HydrationError for 1 of %d", err, n)
+	}
+	if len(got) != n {
+		t.Fatalf("Hydrate returned %d invoices, want %d", len(got), n)
+	}
+	for _, inv := range got {
+		want := 1
+		if inv.ID == herr.Failed[0].InvoiceID {
+			want = 0
+		}
+		if len(inv.Lines) != want {
+			t.Errorf("invoice %s has %d lines, want %d", inv.ID, len(inv.Lines), want)
+		}
+	}
+
+	// All yields every invoice and reports the failures at the end.
+	srv.InjectFault("v2/getinvoice", merittest.Fault{Status: http.StatusBadRequest, Body: "No such invoice"})
+	var (
+		yielded, hydrated int
+		allErr            error
+	)
+	for inv, err := range client.Invoices.All(ctx, input) {
+		if err != nil {
+			allErr = err
+			break
+		}
+		yielded++
+		hydrated += len(inv.Lines)
+	}
+	if !errors.As(allErr, &herr) || len(herr.Failed) != 1 || herr.Total != n {
+		t.Errorf("All with one failure: err = %v, want a HydrationError for 1 of %d", allErr, n)
+	}
+	if yielded != n || hydrated != n-1 {
+		t.Errorf("All with one failure yielded %d invoices, %d hydrated; want %d, %d", yielded, hydrated, n, n-1)
+	}
+
+	// A spent quota stops fetching rather than spend more of it.
+	srv.InjectFault("v2/getinvoice", merittest.Fault{Status: http.StatusTooManyRequests, Body: "Too many requests"})
+	many := append(append(append([]Invoice(nil), listed...), listed...), listed...)
+	before = countRequests(srv.Requests(), "v2/getinvoice")
+	got, err = client.Invoices.Hydrate(ctx, many)
+	if !IsRateLimit(err) || !errors.As(err, &herr) {
+		t.Fatalf("rate-limited Hydrate: err = %v, want a HydrationError matching ErrRateLimit", err)
+	}
+	if len(got) != len(many) {
+		t.Errorf("rate-limited Hydrate returned %d invoices, want %d", len(got), len(many))
+	}
+	if k := countRequests(srv.Requests(), "v2/getinvoice") - before; k+len(herr.Failed) != len(many)+1 || k == len(many) {
+		t.Errorf("%d requests and %d failures after a 429, want fetching stopped", k, len(herr.Failed))
+	}
+}
+
+func TestInvoices_WithLines_ListedDetails(t *testing.T) {
+	ctx := context.Background()
+	srv := satest.NewServer()
+	defer srv.Close()
+	sc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider:   "smartaccounts",
+		APIID:      sc.APIKey,
+		APIKey:     sc.SecretKey,
+		Region:     sc.Host,
+		HTTPClient: sc.HTTPClient,
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	for _, no := range []string{"S-1", "S-2"} {
+		if _, err := client.Invoices.Create(ctx, e2eInvoice("", cust.Name, no, "KM22")); err != nil {
+			t.Fatalf("Invoices.Create: %v", err)
+		}
+	}
+
+	// SmartAccounts lists invoices with their rows: no request per invoice.
+	before := len(srv.Requests())
+	got, err := client.Invoices.List(ctx, ListInvoicesInput{PeriodStart: e2eDocDate, PeriodEnd: e2eDocDate, WithLines: true})
+	if err != nil {
+		t.Fatalf("List WithLines: %v", err)
+	}
+	if k := len(srv.Requests()) - before; k != 1 {
+		t.Errorf("%d requests, want the list call only", k)
+	}
+	if len(got) != 2 || len(got[0].Lines) != 1 || len(got[1].Lines) != 1 {
+		t.Errorf("got %d invoices, want 2 with a line each", len(got))
+	}
+}
+
+// inFlight is a transport whose response bodies fail once their request's
+// context is done, as a body still arriving over the network would.
+type inFlight struct{}
+
+func (inFlight) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		resp.Body = &inFlightBody{ReadCloser: resp.Body, ctx: req.Context()}
+	}
+	return resp, err
+}
+
+type inFlightBody struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (b *inFlightBody) Read(p []byte) (int, error) {
+	if err := context.Cause(b.ctx); err != nil {
+		return 0, err
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// TestInvoices_All_SlowConsumer checks that time spent on each invoice
+// between reads of the list does not run out its attempt timeout.
+func TestInvoices_All_SlowConsumer(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider:   "merit",
+		APIID:      mc.APIID,
+		APIKey:     mc.APIKey,
+		Extra:      map[string]string{"api_url": mc.APIURL},
+		HTTPClient: &http.Client{Transport: inFlight{}},
+		Resilience: &resilience.Policy{MaxRetries: -1, Timeout: 100 * time.Millisecond},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	n := 3*streamPageSize + 1
+	for i := range n {
+		if _, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, fmt.Sprintf("P-%d", i+1), srv.TaxID(22))); err != nil {
+			t.Fatalf("Invoices.Create: %v", err)
+		}
+	}
+
+	var got int
+	for _, err := range client.Invoices.All(ctx, ListInvoicesInput{PeriodStart: e2eDocDate, PeriodEnd: e2eDocDate}) {
+		if err != nil {
+			t.Fatalf("All after %d invoices: %v", got, err)
+		}
+		if got == 0 {
+			time.Sleep(150 * time.Millisecond)
+		}
+		got++
+	}
+	if got != n {
+		t.Errorf("All yielded %d invoices, want %d", got, n)
+	}
+}
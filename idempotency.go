@@ -0,0 +1,177 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Idempotent creates. With Config.IdempotentCreates, Invoices.Create,
+// Payments.Create and Prepayments.Create first look for the document an
+// earlier attempt produced, by the number the caller assigned it, and
+// return it instead of posting again. A failed create is checked once
+// more, so a duplicate rejection (Merit "Korduv arve", a Directo result
+// error) or a write whose response was lost also resolves to the existing
+// document. Numbers repeat across callers, so a document found by number
+// only counts when its customer, date and amount match the input; one
+// that does not is reported as ErrDuplicate rather than returned.
+//
+// How a document is found depends on the provider:
+//
+//   - Invoices: by InvoiceNo (Merit, Directo, SmartAccounts). Excellent
+//     Books numbers invoices itself, so its invoices are created without
+//     the check.
+//   - Payments: by PaymentNo among the payments of the payment date. It is
+//     the receipt number for Directo, and is kept in the receipt comment
+//     for Excellent Books and the document field for SmartAccounts. Merit
+//     keeps no caller-assigned text a payment list returns, so its
+//     payments are created without the check.
+//   - Prepayments: by PrepaymentNo among the customer's prepayments.
+//
+// An IdempotencyKey is looked up by itself, never through a document
+// number or reference, so it needs a field reserved for it. None of the
+// built-in providers has one: their Locate methods return errKeyNotStored,
+// and Create refuses the key rather than post unchecked.
+//
+// The lookups are the Provider's LocateInvoice and LocatePayment, so they
+// go through the client's decorators and any Config.Middleware like the
+// create that follows them.
+
+// errKeyNotStored is what the built-in adapters' Locate methods return for
+// an input with an IdempotencyKey.
+var errKeyNotStored = fmt.Errorf("%w: the provider has no field to keep an IdempotencyKey in", ErrNotSupported)
+
+// errNumberTaken is returned when the document found for a create is not
+// the one the input describes.
+var errNumberTaken = fmt.Errorf("accounting: a different document already has this number: %w", ErrDuplicate)
+
+// createOnce runs create unless locate finds the document already exists,
+// and re-checks after a failed create. A found document is only returned
+// when matches accepts it. When locate is not supported the create runs
+// unchecked, unless the call is keyed: a key that cannot be looked up is
+// refused.
+func createOnce[T any](ctx context.Context, keyed bool, locate, create func(context.Context) (*T, error), matches func(*T) bool) (*T, error) {
+	existing, err := locate(ctx)
+	if errors.Is(err, ErrNotSupported) && !keyed {
+		return create(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if !matches(existing) {
+			return nil, errNumberTaken
+		}
+		return existing, nil
+	}
+	created, err := create(ctx)
+	if err == nil {
+		return created, nil
+	}
+	if existing, lerr := locate(ctx); lerr == nil && existing != nil && matches(existing) {
+		return existing, nil
+	}
+	return nil, err
+}
+
+// invoiceMatches reports whether inv is the invoice input describes, as
+// far as both say: the same customer and document date.
+func invoiceMatches(inv *Invoice, input CreateInvoiceInput) bool {
+	switch {
+	case input.CustomerID != "" && inv.CustomerID != "":
+		if inv.CustomerID != input.CustomerID {
+			return false
+		}
+	case input.CustomerName != "" && inv.CustomerName != "":
+		if !strings.EqualFold(inv.CustomerName, input.CustomerName) {
+			return false
+		}
+	}
+	return sameDay(inv.DocDate, input.DocDate)
+}
+
+// paymentMatches reports whether p is the payment input describes: the
+// same amount and date, settling the same invoice where p's links name
+// invoices by number (SmartAccounts links them by ID only).
+func paymentMatches(p *Payment, input CreatePaymentInput) bool {
+	if !p.Amount.IsZero() && !p.Amount.Abs().Equal(input.Amount.Abs()) {
+		return false
+	}
+	numbered := slices.ContainsFunc(p.InvoiceLinks, func(l PaymentInvoiceLink) bool { return l.InvoiceNo != "" })
+	if input.InvoiceNo != "" && numbered &&
+		!slices.ContainsFunc(p.InvoiceLinks, func(l PaymentInvoiceLink) bool { return l.InvoiceNo == input.InvoiceNo }) {
+		return false
+	}
+	return sameDay(p.DocumentDate, input.PaymentDate)
+}
+
+// prepaymentMatches reports whether pp is the prepayment input describes:
+// the same customer, amount and date.
+func prepaymentMatches(pp *Prepayment, input CreatePrepaymentInput) bool {
+	if pp.CustomerCode != "" && input.CustomerCode != "" && pp.CustomerCode != input.CustomerCode {
+		return false
+	}
+	if !pp.Amount.IsZero() && !pp.Amount.Equal(input.Amount) {
+		return false
+	}
+	return sameDay(pp.Date, input.PaymentDate)
+}
+
+// sameDay reports whether a and b fall on the same date, or either is
+// unknown.
+func sameDay(a, b time.Time) bool {
+	return a.IsZero() || b.IsZero() || a.Format(time.DateOnly) == b.Format(time.DateOnly)
+}
+
+// absent maps a not-found lookup to (nil, nil), the Locate* convention.
+func absent[T any](v *T, err error) (*T, error) {
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return v, err
+}
+
+// documentDay returns the one-day window the Locate* methods search for a document
+// dated t; a zero date means today.
+func documentDay(t time.Time) (start, end time.Time) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// findPaymentNo returns the payment among payments whose number, as
+// number reads it, is paymentNo, or nil.
+func findPaymentNo(payments []Payment, paymentNo string, number func(*Payment) string) *Payment {
+	for i := range payments {
+		if number(&payments[i]) == paymentNo {
+			return &payments[i]
+		}
+	}
+	return nil
+}
+
+// locatePrepayment finds an earlier CreatePrepayment by its number.
+func locatePrepayment(ctx context.Context, pp PrepaymentProvider, input CreatePrepaymentInput) (*Prepayment, error) {
+	if input.IdempotencyKey != "" {
+		return nil, fmt.Errorf("accounting: locate prepayment: %w", errKeyNotStored)
+	}
+	if input.PrepaymentNo == "" {
+		return nil, nil
+	}
+	since, until := documentDay(input.PaymentDate)
+	list, err := pp.ListPrepayments(ctx, ListPrepaymentsInput{CustomerCode: input.CustomerCode, Since: since, Until: until})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Number == input.PrepaymentNo {
+			return &list[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,259 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/qbitsoftware/accounting-service/directo/directotest"
+	"github.com/qbitsoftware/accounting-service/excellentbooks/ebtest"
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/qbitsoftware/accounting-service/smartaccounts/satest"
+)
+
+// idempotencyCase drives the same create-twice scenario against one
+// provider's emulator.
+type idempotencyCase struct {
+	client   *Client
+	invoice  CreateInvoiceInput
+	payment  CreatePaymentInput
+	requests func() []string
+	// Emulator request keys of the invoice and payment writes.
+	createInvoice, createPayment string
+	// noInvoiceLookup and noPaymentLookup are set for providers that keep
+	// no InvoiceNo or PaymentNo, whose documents are posted on every
+	// attempt.
+	noInvoiceLookup, noPaymentLookup bool
+}
+
+func TestIdempotentCreates(t *testing.T) {
+	setups := map[string]func(t *testing.T) idempotencyCase{
+		"merit": func(t *testing.T) idempotencyCase {
+			srv := merittest.NewServer()
+			t.Cleanup(srv.Close)
+			mc := srv.Config()
+			client := newEmulatorClient(t, Config{
+				Provider:          "merit",
+				APIID:             mc.APIID,
+				APIKey:            mc.APIKey,
+				Extra:             map[string]string{"api_url": mc.APIURL},
+				IdempotentCreates: true,
+			})
+			cust, err := client.Customers.Create(context.Background(), CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+			if err != nil {
+				t.Fatalf("Customers.Create: %v", err)
+			}
+			return idempotencyCase{
+				client:          client,
+				invoice:         e2eInvoice(cust.ID, cust.Name, "ORDER-7", srv.TaxID(22)),
+				payment:         CreatePaymentInput{CustomerName: cust.Name, Currency: "EUR"},
+				requests:        srv.Requests,
+				createInvoice:   "v2/sendinvoice",
+				createPayment:   "v2/sendpayment",
+				noPaymentLookup: true,
+			}
+		},
+		"directo": func(t *testing.T) idempotencyCase {
+			srv := directotest.NewServer()
+			t.Cleanup(srv.Close)
+			dc := srv.Config()
+			client := newEmulatorClient(t, Config{
+				Provider: "directo",
+				APIID:    dc.Company,
+				APIKey:   dc.Token,
+				Extra: map[string]string{
+					"rest_api_key":  dc.RestAPIKey,
+					"rest_base_url": dc.RESTBaseURL,
+					"xml_base_url":  dc.XMLBaseURL,
+				},
+				IdempotentCreates: true,
+			})
+			cust, err := client.Customers.Create(context.Background(), CreateCustomerInput{Code: "C1", Name: "Acme OÜ"})
+			if err != nil {
+				t.Fatalf("Customers.Create: %v", err)
+			}
+			invoice := e2eInvoice(cust.ID, cust.Name, "ORDER-7", "1")
+			invoice.AutoConfirm = true
+			return idempotencyCase{
+				client:        client,
+				invoice:       invoice,
+				payment:       CreatePaymentInput{CustomerCode: cust.ID, Currency: "EUR", BankID: "K", AutoConfirm: true},
+				requests:      srv.Requests,
+				createInvoice: "put:invoice",
+				createPayment: "put:receipt",
+			}
+		},
+		"excellentbooks": func(t *testing.T) idempotencyCase {
+			srv := ebtest.NewServer()
+			t.Cleanup(srv.Close)
+			ec := srv.Config()
+			client := newEmulatorClient(t, Config{
+				Provider:          "excellentbooks",
+				APIID:             ec.Username,
+				APIKey:            ec.Password,
+				Extra:             map[string]string{"base_url": ec.BaseURL, "company_code": ec.CompanyCode},
+				IdempotentCreates: true,
+			})
+			cust, err := client.Customers.Create(context.Background(), CreateCustomerInput{Code: "C1", Name: "Acme OÜ"})
+			if err != nil {
+				t.Fatalf("Customers.Create: %v", err)
+			}
+			return idempotencyCase{
+				client:          client,
+				invoice:         e2eInvoice(cust.ID, cust.Name, "ORDER-7", "1"),
+				payment:         CreatePaymentInput{CustomerCode: cust.ID, BankID: "PANK"},
+				requests:        srv.Requests,
+				createInvoice:   "POST IVVc",
+				createPayment:   "POST IPVc",
+				noInvoiceLookup: true,
+			}
+		},
+		"smartaccounts": func(t *testing.T) idempotencyCase {
+			srv := satest.NewServer()
+			t.Cleanup(srv.Close)
+			sc := srv.Config()
+			client := newEmulatorClient(t, Config{
+				Provider:          "smartaccounts",
+				APIID:             sc.APIKey,
+				APIKey:            sc.SecretKey,
+				Region:            sc.Host,
+				HTTPClient:        sc.HTTPClient,
+				Resilience:        &resilience.Policy{RatePerSecond: 1000},
+				IdempotentCreates: true,
+			})
+			cust, err := client.Customers.Create(context.Background(), CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+			if err != nil {
+				t.Fatalf("Customers.Create: %v", err)
+			}
+			return idempotencyCase{
+				client:        client,
+				invoice:       e2eInvoice("", cust.Name, "ORDER-7", "KM22"),
+				payment:       CreatePaymentInput{Currency: "EUR", BankID: "LHV"},
+				requests:      srv.Requests,
+				createInvoice: "purchasesales/clientinvoices:add",
+				createPayment: "purchasesales/payments:add",
+			}
+		},
+	}
+
+	for name, setup := range setups {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			tc := setup(t)
+
+			first, err := tc.client.Invoices.Create(ctx, tc.invoice)
+			if err != nil {
+				t.Fatalf("Invoices.Create: %v", err)
+			}
+			again, err := tc.client.Invoices.Create(ctx, tc.invoice)
+			if err != nil {
+				t.Fatalf("retried Invoices.Create: %v", err)
+			}
+			if !tc.noInvoiceLookup && (again.ID != first.ID || again.Number != first.Number) {
+				t.Errorf("retried create = %s/%s, want the existing %s/%s", again.ID, again.Number, first.ID, first.Number)
+			}
+
+			// Two instalments alike but for their numbers, each sent twice.
+			pay := tc.payment
+			pay.InvoiceNo = first.Number
+			pay.PaymentDate = e2eDocDate
+			pay.Amount = d("61")
+			for _, no := range []string{"PAY-7", "PAY-8"} {
+				pay.PaymentNo = no
+				for range 2 {
+					if err := tc.client.Payments.Create(ctx, pay); err != nil {
+						t.Fatalf("Payments.Create(%s): %v", no, err)
+					}
+				}
+			}
+
+			// No provider has a field for an IdempotencyKey, so one is
+			// refused before anything is posted.
+			keyed := tc.invoice
+			keyed.InvoiceNo = ""
+			keyed.IdempotencyKey = "ORDER-8"
+			if _, err := tc.client.Invoices.Create(ctx, keyed); !errors.Is(err, ErrNotSupported) {
+				t.Errorf("Invoices.Create with a key: err = %v, want ErrNotSupported", err)
+			}
+			pay.PaymentNo = ""
+			pay.IdempotencyKey = "PAY-9"
+			if err := tc.client.Payments.Create(ctx, pay); !errors.Is(err, ErrNotSupported) {
+				t.Errorf("Payments.Create with a key: err = %v, want ErrNotSupported", err)
+			}
+
+			reqs := tc.requests()
+			want := 1
+			if tc.noInvoiceLookup {
+				want = 2
+			}
+			if n := countRequests(reqs, tc.createInvoice); n != want {
+				t.Errorf("%d invoice writes, want %d; requests = %v", n, want, reqs)
+			}
+			want = 2
+			if tc.noPaymentLookup {
+				want = 4
+			}
+			if n := countRequests(reqs, tc.createPayment); n != want {
+				t.Errorf("%d payment writes, want %d; requests = %v", n, want, reqs)
+			}
+		})
+	}
+}
+
+// TestIdempotentCreates_Config checks that Config.IdempotentCreates turns a
+// duplicate invoice number, which Merit rejects as "Korduv arve", into the
+// existing invoice, but only when it is the same invoice.
+func TestIdempotentCreates_Config(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider:          "merit",
+		APIID:             mc.APIID,
+		APIKey:            mc.APIKey,
+		Extra:             map[string]string{"api_url": mc.APIURL},
+		IdempotentCreates: true,
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	results := client.Invoices.BatchCreate(ctx, []CreateInvoiceInput{
+		e2eInvoice(cust.ID, cust.Name, "M-1", srv.TaxID(22)),
+	})
+	if results[0].Err != nil {
+		t.Fatalf("BatchCreate: %v", results[0].Err)
+	}
+	again, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "M-1", srv.TaxID(22)))
+	if err != nil {
+		t.Fatalf("duplicate number: err = %v, want the existing invoice", err)
+	}
+	if again.ID != results[0].Invoice.ID {
+		t.Errorf("duplicate number: ID = %s, want %s", again.ID, results[0].Invoice.ID)
+	}
+
+	// Another customer's invoice with the number is not this one.
+	other, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Beta AS", RegNo: "87654321"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	if _, err := client.Invoices.Create(ctx, e2eInvoice(other.ID, other.Name, "M-1", srv.TaxID(22))); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("another customer's number: err = %v, want ErrDuplicate", err)
+	}
+	if n := countRequests(srv.Requests(), "v2/sendinvoice"); n != 1 {
+		t.Errorf("%d sendinvoice requests, want 1", n)
+	}
+}
+
+func countRequests(reqs []string, key string) int {
+	n := 0
+	for _, r := range reqs {
+		if r == key {
+			n++
+		}
+	}
+	return n
+}
@@ -31,6 +31,13 @@ type CreateInvoiceInput struct {
 	// When empty the provider falls back to the customer's default term — which
 	// Directo flags as required ("T-tingimus puudu"), so set it.
 	PaymentTermCode string
+	// IdempotencyKey makes Invoices.Create idempotent for this call: a
+	// retry with the same key returns the invoice the first attempt
+	// created. The key is kept in a field the provider reserves for it,
+	// never in InvoiceNo or RefNo. None of the built-in providers has such
+	// a field, so there Create fails with ErrNotSupported; use
+	// Config.IdempotentCreates with a caller-assigned InvoiceNo instead.
+	IdempotencyKey string
 }
 
 // LineDimension represents a dimension to attach to a Merit invoice row.
@@ -97,9 +104,11 @@ type CreatePaymentInput struct {
 	CustomerCode string // Customer code/ID — required by Excellent Books receipts; Merit uses CustomerName
 	// PaymentNo is the receipt's own document number. REQUIRED for Directo:
 	// the XML Direct receipt import rejects documents without a number
-	// (result type 12, "Missing document identificator"). Callers should
-	// derive it deterministically (e.g. from the invoice number) so retries
-	// are idempotent.
+	// (result type 12, "Missing document identificator"). Excellent Books
+	// and SmartAccounts number receipts themselves and keep it in the
+	// receipt comment and document field (Payment.Reference); Merit does
+	// not store it. Callers should derive it deterministically (e.g. from
+	// the invoice number) so retries are idempotent.
 	PaymentNo   string
 	InvoiceNo   string
 	PaymentDate time.Time
@@ -109,23 +118,60 @@ type CreatePaymentInput struct {
 	// AutoConfirm books the receipt immediately (Directo: kinnitatud).
 	// An unconfirmed receipt does not settle its invoice.
 	AutoConfirm bool
+	// IdempotencyKey is the payments counterpart of
+	// CreateInvoiceInput.IdempotencyKey: it is never used as PaymentNo, and
+	// the built-in providers refuse it. Config.IdempotentCreates finds an
+	// earlier payment by PaymentNo instead.
+	IdempotencyKey string
 }
 
+// The filters of the List*Input types are pushed down to the provider's API
+// where it can express them and applied to the result otherwise, so they
+// mean the same on every provider. Zero values do not filter.
+
 type ListInvoicesInput struct {
 	PeriodStart time.Time
 	PeriodEnd   time.Time
 	// CustomerCode restricts the result to invoices belonging to a specific
-	// customer card. Currently honored by Excellent Books only — Merit/Directo
-	// ignore it and return the full date-range result.
+	// customer card (Invoice.CustomerID).
 	CustomerCode string
+	// Status keeps the invoices in that status; UnpaidOnly keeps those not
+	// fully paid. Excellent Books does not report payment status and
+	// rejects both with ErrNotSupported.
+	Status     InvoiceStatus
+	UnpaidOnly bool
+	// NumberFrom and NumberTo bound the invoice number, inclusive. Numbers
+	// compare numerically when both are digits and as text otherwise.
+	NumberFrom string
+	NumberTo   string
+	// ReferenceNo keeps the invoices with that payment reference.
+	ReferenceNo string
+	// ChangedSince keeps the invoices created or changed on or after that
+	// day. Merit, Directo and SmartAccounts filter on it server-side;
+	// Excellent Books records no change date and rejects it with
+	// ErrNotSupported (follow SyncService.InvoiceChangesAfter instead).
+	ChangedSince time.Time
+	// WithLines returns the invoices with their lines, as
+	// InvoiceService.Hydrate does. It only affects InvoiceService.List and
+	// All; adapters ignore it.
+	WithLines bool
 }
 
 type ListPaymentsInput struct {
 	PeriodStart time.Time
 	PeriodEnd   time.Time
+	// Direction keeps the payments of that direction.
+	Direction PaymentDirection
 }
 
-type ListCustomersInput struct{}
+type ListCustomersInput struct {
+	Name  string // case-insensitive substring of the name
+	RegNo string // registry code, exact
+	Email string // e-mail address, case-insensitive
+	// ChangedSince keeps the customers created or changed on or after
+	// that day. Every provider supports it server-side.
+	ChangedSince time.Time
+}
 
 type CreateItemInput struct {
 	Code                string
@@ -148,8 +194,8 @@ type UpdateItemInput struct {
 }
 
 type ListItemsInput struct {
-	Code        string
-	Description string
+	Code        string   // item code, exact
+	Description string   // case-insensitive substring of the name or description
 	Type        ItemType
 }
 
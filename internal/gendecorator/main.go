@@ -0,0 +1,221 @@
+// Command gendecorator writes decorator_gen.go: the methods of
+// accounting.Decorator, one per method of the Provider and
+// PrepaymentProvider interfaces, each forwarding to Decorator.Next. Run it
+// with go generate in the module root after changing either interface.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sources are the files declaring the forwarded interfaces, in the order
+// their methods are written.
+var sources = []struct {
+	file, iface string
+	optional    bool // forwarded only when Next implements it
+}{
+	{"provider.go", "Provider", false},
+	{"prepayments.go", "PrepaymentProvider", true},
+}
+
+const output = "decorator_gen.go"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gendecorator: ")
+
+	fset := token.NewFileSet()
+	var body bytes.Buffer
+	imports := map[string]string{} // name -> path
+	for _, src := range sources {
+		f, err := parser.ParseFile(fset, src.file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			log.Fatal(err)
+		}
+		it := findInterface(f, src.iface)
+		if it == nil {
+			log.Fatalf("%s: interface %s not found", src.file, src.iface)
+		}
+		for _, m := range it.Methods.List {
+			ft, ok := m.Type.(*ast.FuncType)
+			if !ok {
+				log.Fatalf("%s: %s embeds %s; only methods are supported", src.file, src.iface, expr(fset, m.Type))
+			}
+			for _, name := range m.Names {
+				writeMethod(&body, fset, src.iface, name.Name, ft, src.optional)
+			}
+			usedImports(f, ft, imports)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by go run ./internal/gendecorator; DO NOT EDIT.\n\npackage accounting\n\n")
+	if len(imports) > 0 {
+		out.WriteString("import (\n")
+		for _, name := range slices.Sorted(maps.Keys(imports)) {
+			fmt.Fprintf(&out, "\t%q\n", imports[name])
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatalf("format %s: %v", output, err)
+	}
+	if err := os.WriteFile(output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func findInterface(f *ast.File, name string) *ast.InterfaceType {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if it, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.Name == name {
+				return it
+			}
+		}
+	}
+	return nil
+}
+
+// writeMethod writes the Decorator method forwarding name to d.Next. An
+// optional interface's method returns an error wrapping ErrNotSupported
+// when Next does not implement it.
+func writeMethod(w *bytes.Buffer, fset *token.FileSet, iface, name string, ft *ast.FuncType, optional bool) {
+	var params, args []string
+	for i, p := range fieldNames(ft.Params) {
+		typ := expr(fset, p.Type)
+		arg := p.name
+		if arg == "" || arg == "_" {
+			arg = "p" + strconv.Itoa(i)
+		}
+		if _, ok := p.Type.(*ast.Ellipsis); ok {
+			args = append(args, arg+"...")
+		} else {
+			args = append(args, arg)
+		}
+		params = append(params, arg+" "+typ)
+	}
+
+	var results []string
+	for _, r := range fieldNames(ft.Results) {
+		results = append(results, expr(fset, r.Type))
+	}
+	sig := strings.Join(results, ", ")
+	if len(results) > 1 {
+		sig = "(" + sig + ")"
+	}
+
+	call := fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+	fmt.Fprintf(w, "func (d Decorator) %s(%s) %s {\n", name, strings.Join(params, ", "), sig)
+	if optional {
+		zeros := make([]string, len(results))
+		for i, r := range fieldNames(ft.Results) {
+			zeros[i] = zero(fset, r.Type)
+		}
+		if n := len(zeros); n == 0 || expr(fset, fieldNames(ft.Results)[n-1].Type) != "error" {
+			log.Fatalf("%s.%s must return an error to be forwarded optionally", iface, name)
+		}
+		zeros[len(zeros)-1] = "err"
+		fmt.Fprintf(w, "\tinner, err := d.as%s(%q)\n\tif err != nil {\n\t\treturn %s\n\t}\n", iface, name, strings.Join(zeros, ", "))
+		fmt.Fprintf(w, "\treturn inner.%s\n}\n\n", call)
+		return
+	}
+	fmt.Fprintf(w, "\treturn d.Next.%s\n}\n\n", call)
+}
+
+type field struct {
+	name string
+	Type ast.Expr
+}
+
+// fieldNames flattens a field list to one entry per parameter or result.
+func fieldNames(fl *ast.FieldList) []field {
+	if fl == nil {
+		return nil
+	}
+	var out []field
+	for _, f := range fl.List {
+		if len(f.Names) == 0 {
+			out = append(out, field{Type: f.Type})
+			continue
+		}
+		for _, n := range f.Names {
+			out = append(out, field{name: n.Name, Type: f.Type})
+		}
+	}
+	return out
+}
+
+// zero returns the zero value of typ as an expression.
+func zero(fset *token.FileSet, typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "nil"
+		}
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return "nil"
+	case *ast.Ident:
+		switch t.Name {
+		case "error", "any":
+			return "nil"
+		case "string":
+			return `""`
+		case "bool":
+			return "false"
+		}
+	}
+	return "*new(" + expr(fset, typ) + ")"
+}
+
+// usedImports records the imports of f that the signature ft refers to.
+func usedImports(f *ast.File, ft *ast.FuncType, imports map[string]string) {
+	ast.Inspect(ft, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		for _, imp := range f.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			name := path[strings.LastIndex(path, "/")+1:]
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			if name == pkg.Name {
+				imports[name] = path
+			}
+		}
+		return false
+	})
+}
+
+func expr(fset *token.FileSet, e ast.Expr) string {
+	var b bytes.Buffer
+	if err := printer.Fprint(&b, fset, e); err != nil {
+		log.Fatal(err)
+	}
+	return b.String()
+}
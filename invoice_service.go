@@ -2,15 +2,29 @@ package accounting
 
 import (
 	"context"
+	"iter"
 	"sync"
 )
 
 type InvoiceService struct {
-	provider Provider
+	provider   Provider
+	idempotent bool
 }
 
+// Create creates an invoice. When the call is idempotent — an
+// IdempotencyKey is set or the client was built with
+// Config.IdempotentCreates — an invoice an earlier attempt already created
+// is returned instead of posting a duplicate. A key the provider has no
+// field for is refused with ErrNotSupported before anything is posted.
 func (s *InvoiceService) Create(ctx context.Context, input CreateInvoiceInput) (*Invoice, error) {
-	return s.provider.CreateInvoice(ctx, input)
+	if !s.idempotent && input.IdempotencyKey == "" {
+		return s.provider.CreateInvoice(ctx, input)
+	}
+	return createOnce(ctx, input.IdempotencyKey != "",
+		func(ctx context.Context) (*Invoice, error) { return s.provider.LocateInvoice(ctx, input) },
+		func(ctx context.Context) (*Invoice, error) { return s.provider.CreateInvoice(ctx, input) },
+		func(inv *Invoice) bool { return invoiceMatches(inv, input) },
+	)
 }
 
 func (s *InvoiceService) Get(ctx context.Context, id string) (*Invoice, error) {
@@ -21,8 +35,35 @@ func (s *InvoiceService) GetPDF(ctx context.Context, id string, deliveryNote boo
 	return s.provider.GetInvoicePDF(ctx, id, deliveryNote)
 }
 
+// List returns the invoices matching input. With input.WithLines they are
+// hydrated; invoices that could not be are returned as listed, together
+// with a *HydrationError.
 func (s *InvoiceService) List(ctx context.Context, input ListInvoicesInput) ([]Invoice, error) {
-	return s.provider.ListInvoices(ctx, input)
+	list, err := s.provider.ListInvoices(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	list = filterList(list, input.matches)
+	if input.WithLines {
+		return s.Hydrate(ctx, list)
+	}
+	return list, nil
+}
+
+// All iterates over the invoices matching input, fetching them with
+// Provider.InvoicePages: a page at a time where the provider pages
+// natively (Excellent Books offset/limit, SmartAccounts page numbers,
+// Merit one-month periods), in one page otherwise. Breaking out of the
+// loop stops fetching. With
+// input.WithLines each page is hydrated before it is yielded; invoices
+// that could not be fetched are yielded as listed, and a *HydrationError
+// naming them ends the iteration.
+func (s *InvoiceService) All(ctx context.Context, input ListInvoicesInput) iter.Seq2[Invoice, error] {
+	pages := s.provider.InvoicePages(ctx, input)
+	if input.WithLines {
+		return flatten(s.hydratePages(ctx, pages, input.matches))
+	}
+	return filterSeq(flatten(pages), input.matches)
 }
 
 func (s *InvoiceService) Delete(ctx context.Context, id string) error {
@@ -49,7 +90,7 @@ func (s *InvoiceService) BatchCreate(ctx context.Context, inputs []CreateInvoice
 			defer wg.Done()
 			sem <- struct{}{}
 			defer func() { <-sem }()
-			inv, err := s.provider.CreateInvoice(ctx, in)
+			inv, err := s.Create(ctx, in)
 			results[idx].Invoice = inv
 			results[idx].Err = err
 		}(i, input)
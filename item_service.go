@@ -1,6 +1,9 @@
 package accounting
 
-import "context"
+import (
+	"context"
+	"iter"
+)
 
 type ItemService struct {
 	provider Provider
@@ -11,7 +14,18 @@ func (s *ItemService) Create(ctx context.Context, input CreateItemInput) (*Item,
 }
 
 func (s *ItemService) List(ctx context.Context, input ListItemsInput) ([]Item, error) {
-	return s.provider.ListItems(ctx, input)
+	list, err := s.provider.ListItems(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return filterList(list, input.matches), nil
+}
+
+// All iterates over the items matching input, fetching them a page at a
+// time where the provider supports it. Breaking out of the loop stops
+// fetching.
+func (s *ItemService) All(ctx context.Context, input ListItemsInput) iter.Seq2[Item, error] {
+	return filterSeq(flatten(s.provider.ItemPages(ctx, input)), input.matches)
 }
 
 func (s *ItemService) Update(ctx context.Context, input UpdateItemInput) error {
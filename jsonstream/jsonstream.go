@@ -0,0 +1,178 @@
+// Package jsonstream decodes large JSON responses a value at a time, so
+// the provider clients can hand records on as they arrive instead of
+// holding the whole body, and a decoded copy of it, in memory.
+//
+// Usage:
+//
+//	dec := json.NewDecoder(body)
+//	err := jsonstream.Array(dec, func(inv merit.InvoiceListItem) bool {
+//	    return yield(inv, nil)
+//	})
+package jsonstream
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Array decodes the next value of dec as a list of T, calling yield with
+// each element as soon as it is decoded, and stops early when yield
+// returns false. null is an empty list; anything else that is not an
+// array, such as an error object sent with status 200, is an error.
+func Array[T any](dec *json.Decoder, yield func(T) bool) error {
+	return array(dec, false, yield)
+}
+
+// ArrayOrObject is Array that also takes an object as a list of one, for
+// Excellent Books, which returns a lone record unwrapped.
+func ArrayOrObject[T any](dec *json.Decoder, yield func(T) bool) error {
+	return array(dec, true, yield)
+}
+
+func array[T any](dec *json.Decoder, single bool, yield func(T) bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('['):
+		for dec.More() {
+			var v T
+			if err := dec.Decode(&v); err != nil {
+				return err
+			}
+			if !yield(v) {
+				return nil
+			}
+		}
+		_, err := dec.Token()
+		return err
+	case json.Delim('{'):
+		if !single {
+			break
+		}
+		// The opening brace is consumed; gather the fields and decode them
+		// as the object they were.
+		fields := map[string]json.RawMessage{}
+		if err := objectFields(dec, func(key string) error {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			fields[key] = raw
+			return nil
+		}); err != nil {
+			return err
+		}
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		var v T
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		yield(v)
+		return nil
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("jsonstream: expected an array, got %v", tok)
+}
+
+// Object reads the next value of dec, which must be an object, calling
+// field with each key in turn. field must consume the key's value from
+// dec: with dec.Decode, Skip, or a nested Array or Object. An error from
+// field stops the walk and is returned.
+func Object(dec *json.Decoder, field func(key string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("jsonstream: expected an object, got %v", tok)
+	}
+	return objectFields(dec, field)
+}
+
+// objectFields walks the fields of an object whose opening brace dec has
+// already read, and reads its closing brace.
+func objectFields(dec *json.Decoder, field func(key string) error) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("jsonstream: expected an object key, got %v", tok)
+		}
+		if err := field(key); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// Skip consumes the next value of dec without decoding it, a token at a
+// time, so skipping a large array costs no memory.
+func Skip(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('['), json.Delim('{'):
+			depth++
+		case json.Delim(']'), json.Delim('}'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// Recorder passes reads through, counting the bytes read and, if asked
+// to, keeping a copy of them for a debug log. Keeping the copy gives up
+// the memory streaming saves, so clients do it only at Debug level.
+type Recorder struct {
+	r    io.Reader
+	n    int
+	kept *bytes.Buffer
+}
+
+// NewRecorder returns a Recorder reading from r that keeps a copy of what
+// it reads when keep is set.
+func NewRecorder(r io.Reader, keep bool) *Recorder {
+	rec := &Recorder{r: r}
+	if keep {
+		rec.kept = new(bytes.Buffer)
+	}
+	return rec
+}
+
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	if r.kept != nil {
+		r.kept.Write(p[:n])
+	}
+	return n, err
+}
+
+// Len returns the number of bytes read so far.
+func (r *Recorder) Len() int { return r.n }
+
+// Bytes returns the bytes read so far, or nil when none are kept.
+func (r *Recorder) Bytes() []byte {
+	if r.kept == nil {
+		return nil
+	}
+	return r.kept.Bytes()
+}
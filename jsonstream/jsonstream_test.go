@@ -0,0 +1,94 @@
+package jsonstream
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type rec struct {
+	No  string `json:"no"`
+	Sum int    `json:"sum"`
+}
+
+func collect(t *testing.T, body string) []rec {
+	t.Helper()
+	var out []rec
+	if err := ArrayOrObject(json.NewDecoder(strings.NewReader(body)), func(r rec) bool {
+		out = append(out, r)
+		return true
+	}); err != nil {
+		t.Fatalf("Array(%s): %v", body, err)
+	}
+	return out
+}
+
+func TestArray(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		want []rec
+	}{
+		{`[{"no":"1","sum":10},{"no":"2","sum":20}]`, []rec{{"1", 10}, {"2", 20}}},
+		{`{"sum":10,"no":"1"}`, []rec{{"1", 10}}},
+		{`[]`, nil},
+		{`null`, nil},
+	} {
+		if got := collect(t, tc.body); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Array(%s) = %v, want %v", tc.body, got, tc.want)
+		}
+	}
+
+	if err := Array(json.NewDecoder(strings.NewReader(`"x"`)), func(rec) bool { return true }); err == nil {
+		t.Error(`Array("x"): no error`)
+	}
+	// Only ArrayOrObject takes an object for a list of one: elsewhere it is
+	// an error payload, not a record.
+	var n int
+	if err := Array(json.NewDecoder(strings.NewReader(`{"Message":"Invalid request"}`)), func(rec) bool { n++; return true }); err == nil || n != 0 {
+		t.Errorf("Array of an object: %d records, err = %v; want an error", n, err)
+	}
+	if err := Array(json.NewDecoder(strings.NewReader(`[{"no":"1"},{"no":`)), func(rec) bool { return true }); err == nil {
+		t.Error("Array of a truncated body: no error")
+	}
+
+	// Stopping early reads no further.
+	n = 0
+	dec := json.NewDecoder(strings.NewReader(`[{"no":"1"},{"no":"2"},{"no":`))
+	if err := Array(dec, func(rec) bool { n++; return false }); err != nil || n != 1 {
+		t.Errorf("stopped Array: %d records, err = %v; want 1, nil", n, err)
+	}
+}
+
+func TestObject(t *testing.T) {
+	body := `{"@sequence":"42","skipped":{"deep":[1,[2,{"x":3}]]},"IVVc":[{"no":"1"},{"no":"2"}],"tail":true}`
+	dec := json.NewDecoder(strings.NewReader(body))
+	var (
+		seq  string
+		recs []rec
+		keys []string
+	)
+	err := Object(dec, func(key string) error {
+		keys = append(keys, key)
+		switch key {
+		case "@sequence":
+			return dec.Decode(&seq)
+		case "IVVc":
+			return Array(dec, func(r rec) bool { recs = append(recs, r); return true })
+		}
+		return Skip(dec)
+	})
+	if err != nil {
+		t.Fatalf("Object: %v", err)
+	}
+	if seq != "42" || len(recs) != 2 || !reflect.DeepEqual(keys, []string{"@sequence", "skipped", "IVVc", "tail"}) {
+		t.Errorf("Object: seq %q, %d records, keys %v", seq, len(recs), keys)
+	}
+	if dec.More() {
+		t.Error("Object left input unread")
+	}
+
+	if err := Object(json.NewDecoder(strings.NewReader(`[]`)), func(string) error { return nil }); err == nil {
+		t.Error("Object([]): no error")
+	}
+}
@@ -16,7 +16,14 @@
 //	})
 package merit
 
-import "net/http"
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/qbitsoftware/accounting-service/redact"
+	"github.com/qbitsoftware/accounting-service/resilience"
+)
 
 // Regional API base URLs.
 const (
@@ -39,6 +46,20 @@ type Config struct {
 	// HTTPClient is an optional HTTP client for making requests.
 	// Defaults to http.DefaultClient if nil.
 	HTTPClient *http.Client
+
+	// Resilience configures retries, throttling, attempt timeouts and the
+	// circuit breaker. Nil uses the resilience package defaults. Only the
+	// get* endpoints are retried after ambiguous failures; send*, update*
+	// and delete* calls are retried only when Merit rate-limits them.
+	Resilience *resilience.Policy
+
+	// Logger receives request and response logs. Nil uses slog.Default().
+	// Request and response bodies are logged at Debug only.
+	Logger *slog.Logger
+
+	// Redaction selects the fields masked in logged bodies and URLs. Nil
+	// uses redact.Default().
+	Redaction *redact.Policy
 }
 
 // Client is a Merit Aktiva API client.
@@ -47,6 +68,9 @@ type Client struct {
 	apiID      string
 	apiKey     string
 	httpClient *http.Client
+	exec       *resilience.Executor
+	logger     *slog.Logger
+	redact     *redact.Policy
 }
 
 // New creates a new Merit Aktiva API client with the given configuration.
@@ -61,10 +85,47 @@ func New(cfg Config) *Client {
 		httpClient = http.DefaultClient
 	}
 
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var policy resilience.Policy
+	if cfg.Resilience != nil {
+		policy = *cfg.Resilience
+	}
+	if policy.Logger == nil {
+		policy.Logger = logger
+	}
+
 	return &Client{
 		apiURL:     apiURL,
 		apiID:      cfg.APIID,
 		apiKey:     cfg.APIKey,
 		httpClient: httpClient,
+		exec:       resilience.New("merit", policy, httpClient),
+		logger:     logger,
+		redact:     cfg.Redaction,
 	}
 }
+
+// CircuitState reports the circuit breaker state for this client's
+// provider; StateOpen means Merit is currently considered unhealthy.
+func (c *Client) CircuitState() resilience.State {
+	return c.exec.State()
+}
+
+// Format prints c with APIKey masked.
+func (c Config) Format(f fmt.State, verb rune) {
+	type config Config // drops the methods, so formatting does not recurse
+	out := config(c)
+	out.APIKey = redact.Secret(c.APIKey)
+	fmt.Fprintf(f, fmt.FormatString(f, verb), out)
+}
+
+// String identifies the client without its secrets.
+func (c *Client) String() string {
+	return fmt.Sprintf("merit.Client{APIURL: %s, APIID: %s}", c.apiURL, c.apiID)
+}
+
+func (c *Client) GoString() string { return c.String() }
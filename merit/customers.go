@@ -1,14 +1,19 @@
 package merit
 
-import "context"
+import (
+	"context"
+	"iter"
+)
 
 // ListCustomers retrieves a list of customers matching the given criteria.
 func (c *Client) ListCustomers(ctx context.Context, params ListCustomersParams) ([]CustomerListItem, error) {
-	var result []CustomerListItem
-	if err := c.post(ctx, "v1/getcustomers", params, &result); err != nil {
-		return nil, err
-	}
-	return result, nil
+	return list[CustomerListItem](ctx, c, "v1/getcustomers", params)
+}
+
+// StreamCustomers yields the customers ListCustomers returns one at a
+// time, decoding the response as it arrives.
+func (c *Client) StreamCustomers(ctx context.Context, params ListCustomersParams) iter.Seq2[CustomerListItem, error] {
+	return stream[CustomerListItem](ctx, c, "v1/getcustomers", params)
 }
 
 // CreateCustomer creates a new customer.
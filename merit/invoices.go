@@ -1,15 +1,20 @@
 package merit
 
-import "context"
+import (
+	"context"
+	"iter"
+)
 
 // ListInvoices retrieves a list of sales invoices for the given period.
 // The period may not span more than 3 months.
 func (c *Client) ListInvoices(ctx context.Context, params ListInvoicesParams) ([]InvoiceListItem, error) {
-	var result []InvoiceListItem
-	if err := c.post(ctx, "v2/getinvoices", params, &result); err != nil {
-		return nil, err
-	}
-	return result, nil
+	return list[InvoiceListItem](ctx, c, "v2/getinvoices", params)
+}
+
+// StreamInvoices yields the invoices ListInvoices returns one at a time,
+// decoding the response as it arrives.
+func (c *Client) StreamInvoices(ctx context.Context, params ListInvoicesParams) iter.Seq2[InvoiceListItem, error] {
+	return stream[InvoiceListItem](ctx, c, "v2/getinvoices", params)
 }
 
 // GetInvoice retrieves detailed information for a single sales invoice.
@@ -1,14 +1,19 @@
 package merit
 
-import "context"
+import (
+	"context"
+	"iter"
+)
 
 // ListItems retrieves a list of items/products matching the given criteria.
 func (c *Client) ListItems(ctx context.Context, params ListItemsParams) ([]ItemListItem, error) {
-	var result []ItemListItem
-	if err := c.post(ctx, "v1/getitems", params, &result); err != nil {
-		return nil, err
-	}
-	return result, nil
+	return list[ItemListItem](ctx, c, "v1/getitems", params)
+}
+
+// StreamItems yields the items ListItems returns one at a time, decoding
+// the response as it arrives.
+func (c *Client) StreamItems(ctx context.Context, params ListItemsParams) iter.Seq2[ItemListItem, error] {
+	return stream[ItemListItem](ctx, c, "v1/getitems", params)
 }
 
 // CreateItems creates one or more new items.
@@ -209,6 +209,63 @@ func TestListInvoices(t *testing.T) {
 	}
 }
 
+func TestStreamInvoices(t *testing.T) {
+	client, srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"SIHId":"inv-1","InvoiceNo":"INV-001"},{"SIHId":"inv-2","InvoiceNo":"INV-002"},{"SIHId":"inv-3"`))
+	})
+	defer srv.Close()
+
+	// Records arrive as they are decoded, before the truncated tail fails.
+	var got []string
+	var err error
+	for inv, e := range client.StreamInvoices(context.Background(), ListInvoicesParams{}) {
+		if e != nil {
+			err = e
+			break
+		}
+		got = append(got, inv.InvoiceNo)
+	}
+	if len(got) != 2 || got[1] != "INV-002" || err == nil {
+		t.Errorf("got %v, err = %v; want INV-001, INV-002 then an error", got, err)
+	}
+
+	got = nil
+	for inv, e := range client.StreamInvoices(context.Background(), ListInvoicesParams{}) {
+		if e != nil {
+			t.Fatalf("stopped stream: %v", e)
+		}
+		got = append(got, inv.InvoiceNo)
+		break
+	}
+	if len(got) != 1 {
+		t.Errorf("stopped stream yielded %v, want one invoice", got)
+	}
+}
+
+func TestStreamInvoices_APIError(t *testing.T) {
+	client, srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Period too long"))
+	})
+	defer srv.Close()
+
+	_, err := client.ListInvoices(context.Background(), ListInvoicesParams{})
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Period too long" {
+		t.Fatalf("err = %v, want the 400 APIError", err)
+	}
+
+	// An error object sent with status 200 is not an invoice.
+	client, srv = newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Message":"An error has occurred."}`))
+	})
+	defer srv.Close()
+	if invoices, err := client.ListInvoices(context.Background(), ListInvoicesParams{}); err == nil {
+		t.Errorf("error object with status 200: %d invoices, no error", len(invoices))
+	}
+}
+
 func TestGetInvoice(t *testing.T) {
 	detail := InvoiceDetail{
 		SIHId:     "inv-1",
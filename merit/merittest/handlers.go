@@ -0,0 +1,516 @@
+package merittest
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/merit"
+	"github.com/shopspring/decimal"
+)
+
+// --- Reference data ---
+
+func (s *Server) getTaxes(_ []byte) (any, *apiError) {
+	return s.taxes, nil
+}
+
+func (s *Server) getBanks(_ []byte) (any, *apiError) {
+	return s.banks, nil
+}
+
+// --- Customers ---
+
+func (s *Server) getCustomers(body []byte) (any, *apiError) {
+	var p merit.ListCustomersParams
+	if err := decode(body, &p); err != nil {
+		return nil, err
+	}
+	out := []merit.CustomerListItem{}
+	for _, c := range s.customers {
+		if p.ID != "" && !strings.EqualFold(c.CustomerID, p.ID) {
+			continue
+		}
+		if p.RegNo != "" && c.RegNo != p.RegNo {
+			continue
+		}
+		if p.VatRegNo != "" && c.VatRegNo != p.VatRegNo {
+			continue
+		}
+		if p.Name != "" && !strings.Contains(strings.ToLower(c.Name), strings.ToLower(p.Name)) {
+			continue
+		}
+		if p.ChangedDate != "" && c.ChangedDate < p.ChangedDate {
+			continue
+		}
+		out = append(out, *c)
+	}
+	return out, nil
+}
+
+func (s *Server) findCustomer(id, name string) *merit.CustomerListItem {
+	for _, c := range s.customers {
+		if id != "" && strings.EqualFold(c.CustomerID, id) {
+			return c
+		}
+		if id == "" && name != "" && strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *Server) addCustomer(req merit.CreateCustomerRequest) (*merit.CustomerListItem, *apiError) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, badRequest("Name is required")
+	}
+	for _, c := range s.customers {
+		if strings.EqualFold(c.Name, req.Name) || (req.RegNo != "" && c.RegNo == req.RegNo) {
+			return nil, badRequest("custexists: customer %q already exists", c.Name)
+		}
+	}
+	c := &merit.CustomerListItem{
+		CustomerID:   s.newID(),
+		Name:         req.Name,
+		RegNo:        req.RegNo,
+		VatRegNo:     req.VatRegNo,
+		CurrencyCode: req.CurrencyCode,
+		Address:      req.Address,
+		City:         req.City,
+		County:       req.County,
+		PostalCode:   req.PostalCode,
+		CountryCode:  req.CountryCode,
+		PhoneNo:      req.PhoneNo,
+		PhoneNo2:     req.PhoneNo2,
+		HomePage:     req.HomePage,
+		Email:        req.Email,
+		Contact:      req.Contact,
+		RefNoBase:    req.RefNoBase,
+		SalesInvLang: req.SalesInvLang,
+		BankAccount:  req.BankAccount,
+		ChangedDate:  s.Now().Format(dateFormat),
+	}
+	if req.PaymentDeadLine != nil {
+		c.PaymentDeadLine = *req.PaymentDeadLine
+	}
+	if c.CurrencyCode == "" {
+		c.CurrencyCode = "EUR"
+	}
+	s.customers = append(s.customers, c)
+	return c, nil
+}
+
+func (s *Server) sendCustomer(body []byte) (any, *apiError) {
+	var req merit.CreateCustomerRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	c, err := s.addCustomer(req)
+	if err != nil {
+		return nil, err
+	}
+	return merit.CreateCustomerResponse{ID: c.CustomerID, Name: c.Name}, nil
+}
+
+func (s *Server) updateCustomer(body []byte) (any, *apiError) {
+	var req merit.UpdateCustomerRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	c := s.findCustomer(req.ID, "")
+	if c == nil {
+		return nil, badRequest("Customer not found")
+	}
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&c.Name, req.Name)
+	set(&c.CountryCode, req.CountryCode)
+	set(&c.Address, req.Address)
+	set(&c.City, req.City)
+	set(&c.PostalCode, req.PostalCode)
+	set(&c.PhoneNo, req.PhoneNo)
+	set(&c.PhoneNo2, req.PhoneNo2)
+	set(&c.Email, req.Email)
+	set(&c.RegNo, req.RegNo)
+	set(&c.VatRegNo, req.VatRegNo)
+	set(&c.RefNoBase, req.RefNoBase)
+	set(&c.Contact, req.Contact)
+	if req.PaymentDeadLine != nil {
+		c.PaymentDeadLine = *req.PaymentDeadLine
+	}
+	c.ChangedDate = s.Now().Format(dateFormat)
+	return nil, nil
+}
+
+// --- Invoices ---
+
+func (s *Server) findInvoice(id string) *invoice {
+	for _, inv := range s.invoices {
+		if strings.EqualFold(inv.detail.SIHId, id) {
+			return inv
+		}
+	}
+	return nil
+}
+
+func (s *Server) sendInvoice(body []byte) (any, *apiError) {
+	var req merit.CreateInvoiceRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if len(req.InvoiceRow) == 0 {
+		return nil, badRequest("InvoiceRow is required")
+	}
+	if req.InvoiceNo == "" {
+		for req.InvoiceNo == "" || s.invoiceNoTaken(req.InvoiceNo) {
+			req.InvoiceNo = strconv.Itoa(s.nextNo)
+			s.nextNo++
+		}
+	} else if s.invoiceNoTaken(req.InvoiceNo) {
+		return nil, badRequest("Korduv arve number %s", req.InvoiceNo)
+	}
+
+	docDate := s.Now()
+	if req.DocDate != "" {
+		t, err := time.Parse(dateFormat, req.DocDate)
+		if err != nil {
+			return nil, badRequest("DocDate is not a valid date")
+		}
+		docDate = t
+	}
+
+	newCustomer := false
+	cust := s.findCustomer(req.Customer.ID, req.Customer.Name)
+	if cust == nil {
+		if req.Customer.ID != "" {
+			return nil, badRequest("Customer not found")
+		}
+		c, err := s.addCustomer(merit.CreateCustomerRequest{
+			Name:        req.Customer.Name,
+			RegNo:       req.Customer.RegNo,
+			Email:       req.Customer.Email,
+			Address:     req.Customer.Address,
+			CountryCode: req.Customer.CountryCode,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cust, newCustomer = c, true
+	}
+
+	d := merit.InvoiceDetail{
+		SIHId:         s.newID(),
+		InvoiceNo:     req.InvoiceNo,
+		DocumentDate:  docDate.Format(dateFormat),
+		DueDate:       req.DueDate,
+		CustomerID:    cust.CustomerID,
+		CustomerName:  cust.Name,
+		CustomerRegNo: cust.RegNo,
+		HComment:      req.Hcomment,
+		FComment:      req.Fcomment,
+		CurrencyCode:  req.CurrencyCode,
+		CurrencyRate:  decimal.NewFromInt(1),
+		ReferenceNo:   req.RefNo,
+		VatRegNo:      cust.VatRegNo,
+		Lines:         []merit.InvoiceDetailRow{},
+		Payments:      []merit.PaymentInfo{},
+	}
+	if d.CurrencyCode == "" {
+		d.CurrencyCode = "EUR"
+	}
+	if d.DueDate == "" {
+		d.DueDate = docDate.AddDate(0, 0, cust.PaymentDeadLine).Format(dateFormat)
+	}
+	if d.ReferenceNo == "" {
+		d.ReferenceNo = req.InvoiceNo
+	}
+	for _, row := range req.InvoiceRow {
+		line := merit.InvoiceDetailRow{
+			SILId:       s.newID(),
+			ArticleCode: row.Item.Code,
+			Description: row.Item.Description,
+			UOMName:     row.Item.UOMName,
+			Quantity:    row.Quantity,
+			Price:       row.Price,
+			AccountCode: row.GLAccountCode,
+		}
+		if row.TaxID != "" {
+			tax, ok := s.tax(row.TaxID)
+			if !ok {
+				return nil, badRequest("Tax with id %s not found", row.TaxID)
+			}
+			line.TaxID, line.TaxName, line.TaxPct = tax.TaxID, tax.Name, tax.TaxPct
+		}
+		line.AmountExclVat = row.Quantity.Mul(row.Price).Round(2)
+		line.VatAmount = line.AmountExclVat.Mul(line.TaxPct).Div(decimal.NewFromInt(100)).Round(2)
+		line.AmountInclVat = line.AmountExclVat.Add(line.VatAmount)
+		d.TotalAmount = d.TotalAmount.Add(line.AmountExclVat)
+		d.TaxAmount = d.TaxAmount.Add(line.VatAmount)
+		d.Lines = append(d.Lines, line)
+	}
+	d.TotalSum = d.TotalAmount.Add(d.TaxAmount)
+
+	s.invoices = append(s.invoices, &invoice{detail: d, doc: docOr(req.AccountingDoc), changed: s.Now()})
+	return merit.CreateInvoiceResponse{
+		CustomerID:  cust.CustomerID,
+		InvoiceID:   d.SIHId,
+		InvoiceNo:   d.InvoiceNo,
+		RefNo:       d.ReferenceNo,
+		NewCustomer: newCustomer,
+	}, nil
+}
+
+func docOr(doc int) int {
+	if doc == 0 {
+		return merit.DocInvoice
+	}
+	return doc
+}
+
+func (s *Server) invoiceNoTaken(no string) bool {
+	for _, inv := range s.invoices {
+		if inv.detail.InvoiceNo == no {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) tax(id string) (merit.TaxItem, bool) {
+	for _, t := range s.taxes {
+		if strings.EqualFold(t.TaxID, id) {
+			return t, true
+		}
+	}
+	return merit.TaxItem{}, false
+}
+
+func (s *Server) getInvoices(body []byte) (any, *apiError) {
+	var p merit.ListInvoicesParams
+	if err := decode(body, &p); err != nil {
+		return nil, err
+	}
+	from, to, perr := parsePeriod(p.PeriodStart, p.PeriodEnd)
+	if perr != nil {
+		return nil, perr
+	}
+	byChanged := p.DateType != nil && *p.DateType == 1
+	out := []merit.InvoiceListItem{}
+	for _, inv := range s.invoices {
+		t, _ := time.Parse(dateFormat, inv.detail.DocumentDate)
+		if byChanged {
+			t = inv.changed
+		}
+		if !within(t, from, to) || (p.UnPaid && inv.detail.Paid) {
+			continue
+		}
+		out = append(out, inv.listItem())
+	}
+	return out, nil
+}
+
+func (inv *invoice) listItem() merit.InvoiceListItem {
+	d := inv.detail
+	return merit.InvoiceListItem{
+		SIHId:         d.SIHId,
+		InvoiceNo:     d.InvoiceNo,
+		DocumentDate:  d.DocumentDate,
+		CustomerName:  d.CustomerName,
+		CustomerRegNo: d.CustomerRegNo,
+		CustomerID:    d.CustomerID,
+		HComment:      d.HComment,
+		FComment:      d.FComment,
+		DueDate:       d.DueDate,
+		CurrencyCode:  d.CurrencyCode,
+		CurrencyRate:  d.CurrencyRate,
+		TaxAmount:     d.TaxAmount,
+		TotalAmount:   d.TotalAmount,
+		TotalSum:      d.TotalSum,
+		ReferenceNo:   d.ReferenceNo,
+		VatRegNo:      d.VatRegNo,
+		PaidAmount:    d.PaidAmount,
+		Paid:          d.Paid,
+		ChangedDate:   inv.changed.Format(dateFormat),
+		AccountingDoc: inv.doc,
+	}
+}
+
+func (s *Server) getInvoice(body []byte) (any, *apiError) {
+	var p merit.GetInvoiceParams
+	if err := decode(body, &p); err != nil {
+		return nil, err
+	}
+	inv := s.findInvoice(p.ID)
+	if inv == nil {
+		return nil, &apiError{status: http.StatusNotFound, msg: "Invoice not found"}
+	}
+	return inv.detail, nil
+}
+
+func (s *Server) deleteInvoice(body []byte) (any, *apiError) {
+	var p merit.DeleteInvoiceParams
+	if err := decode(body, &p); err != nil {
+		return nil, err
+	}
+	for i, inv := range s.invoices {
+		if strings.EqualFold(inv.detail.SIHId, p.ID) {
+			if len(inv.detail.Payments) > 0 {
+				return nil, badRequest("Invoice has payments and can not be deleted")
+			}
+			s.invoices = append(s.invoices[:i], s.invoices[i+1:]...)
+			return nil, nil
+		}
+	}
+	return nil, &apiError{status: http.StatusNotFound, msg: "Invoice not found"}
+}
+
+// minimalPDF is a one-page blank PDF served for every invoice.
+const minimalPDF = "%PDF-1.4\n1 0 obj<</Type/Catalog/Pages 2 0 R>>endobj\n" +
+	"2 0 obj<</Type/Pages/Kids[3 0 R]/Count 1>>endobj\n" +
+	"3 0 obj<</Type/Page/Parent 2 0 R/MediaBox[0 0 595 842]>>endobj\n" +
+	"trailer<</Root 1 0 R>>\n%%EOF\n"
+
+func (s *Server) getInvoicePDF(body []byte) (any, *apiError) {
+	var p merit.GetInvoicePDFParams
+	if err := decode(body, &p); err != nil {
+		return nil, err
+	}
+	inv := s.findInvoice(p.ID)
+	if inv == nil {
+		return nil, &apiError{status: http.StatusNotFound, msg: "Invoice not found"}
+	}
+	return merit.Attachment{
+		FileName:    "Arve_" + inv.detail.InvoiceNo + ".pdf",
+		FileContent: base64.StdEncoding.EncodeToString([]byte(minimalPDF)),
+	}, nil
+}
+
+// --- Payments ---
+
+func (s *Server) sendPayment(body []byte) (any, *apiError) {
+	var req merit.CreatePaymentRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	var inv *invoice
+	for _, candidate := range s.invoices {
+		if candidate.detail.InvoiceNo == req.InvoiceNo && strings.EqualFold(candidate.detail.CustomerName, req.CustomerName) {
+			inv = candidate
+			break
+		}
+	}
+	if inv == nil {
+		return nil, badRequest("Invoice %s for customer %s not found", req.InvoiceNo, req.CustomerName)
+	}
+	payDate, err := time.Parse(dateFormat, req.PaymentDate)
+	if err != nil {
+		return nil, badRequest("PaymentDate is not a valid date")
+	}
+	bankName := ""
+	for _, b := range s.banks {
+		if req.BankID == "" || strings.EqualFold(b.BankID, req.BankID) {
+			bankName = b.Name
+			break
+		}
+	}
+
+	p := &merit.PaymentListItem{
+		PIHId:           s.newID(),
+		BankName:        bankName,
+		CounterPartType: merit.CounterPartCustomer,
+		CounterPartName: inv.detail.CustomerName,
+		CounterPartID:   inv.detail.CustomerID,
+		CurrencyCode:    inv.detail.CurrencyCode,
+		CurrencyRate:    decimal.NewFromInt(1),
+		DocumentDate:    payDate.Format(dateFormat),
+		DocumentNo:      inv.detail.InvoiceNo,
+		Direction:       merit.DirectionCustomers,
+		Amount:          req.Amount,
+		DocID:           inv.detail.SIHId,
+		ChangedDate:     s.Now().Format(dateFormat),
+		PaymAPIDetails: []merit.PaymAPIDetail{{
+			DocNo:        inv.detail.InvoiceNo,
+			DocAmount:    inv.detail.TotalSum,
+			PaidAmount:   req.Amount,
+			CurrencyCode: inv.detail.CurrencyCode,
+			CurrencyRate: decimal.NewFromInt(1),
+			DocID:        inv.detail.SIHId,
+		}},
+	}
+	p.PaymAPIDetails[0].PaymID = p.PIHId
+	s.payments = append(s.payments, p)
+
+	inv.detail.Payments = append(inv.detail.Payments, merit.PaymentInfo{
+		PaymDate:      p.DocumentDate,
+		Amount:        req.Amount,
+		PaymentMethod: bankName,
+		PaymentID:     p.PIHId,
+	})
+	inv.settle(s.Now())
+	return nil, nil
+}
+
+// settle recomputes the paid state from the invoice's payments.
+func (inv *invoice) settle(now time.Time) {
+	paid := decimal.Zero
+	for _, p := range inv.detail.Payments {
+		paid = paid.Add(p.Amount)
+	}
+	inv.detail.PaidAmount = paid
+	inv.detail.Paid = paid.GreaterThanOrEqual(inv.detail.TotalSum)
+	inv.changed = now
+}
+
+func (s *Server) getPayments(body []byte) (any, *apiError) {
+	var p merit.ListPaymentsParams
+	if err := decode(body, &p); err != nil {
+		return nil, err
+	}
+	from, to, perr := parsePeriod(p.PeriodStart, p.PeriodEnd)
+	if perr != nil {
+		return nil, perr
+	}
+	byChanged := p.DateType != nil && *p.DateType == 1
+	out := []merit.PaymentListItem{}
+	for _, pm := range s.payments {
+		date := pm.DocumentDate
+		if byChanged {
+			date = pm.ChangedDate
+		}
+		t, _ := time.Parse(dateFormat, date)
+		if within(t, from, to) {
+			out = append(out, *pm)
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) deletePayment(body []byte) (any, *apiError) {
+	var p merit.DeletePaymentParams
+	if err := decode(body, &p); err != nil {
+		return nil, err
+	}
+	for i, pm := range s.payments {
+		if !strings.EqualFold(pm.PIHId, p.ID) {
+			continue
+		}
+		s.payments = append(s.payments[:i], s.payments[i+1:]...)
+		if inv := s.findInvoice(pm.DocID); inv != nil {
+			kept := inv.detail.Payments[:0]
+			for _, info := range inv.detail.Payments {
+				if info.PaymentID != pm.PIHId {
+					kept = append(kept, info)
+				}
+			}
+			inv.detail.Payments = kept
+			inv.settle(s.Now())
+		}
+		return nil, nil
+	}
+	return nil, &apiError{status: http.StatusNotFound, msg: "Payment not found"}
+}
@@ -0,0 +1,276 @@
+// Package merittest provides a local emulator of the Merit Aktiva API for
+// end-to-end tests of the merit client and the accounting adapter.
+//
+// The emulator speaks Merit's wire format: every call is a POST to
+// {base}/api/{version}/{endpoint}?ApiId=..&timestamp=..&signature=.. whose
+// HMAC-SHA256 signature over ApiId+timestamp+body is verified, JSON bodies
+// use Merit's field names and yyyyMMdd dates, and failures come back as
+// plain-text bodies ("Korduv arve", "custexists") the way the real API
+// reports them.
+//
+// Usage:
+//
+//	srv := merittest.NewServer()
+//	defer srv.Close()
+//	client := merit.New(srv.Config())
+package merittest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/merit"
+	"github.com/shopspring/decimal"
+)
+
+// Default credentials accepted by a Server.
+const (
+	DefaultAPIID  = "merittest-api-id"
+	DefaultAPIKey = "merittest-api-key"
+)
+
+const dateFormat = "20060102"
+
+// maxPeriod mirrors Merit's cap on getinvoices/getpayments windows: a
+// period longer than three months is rejected.
+const maxPeriodMonths = 3
+
+// Fault is a canned failure returned instead of the normal response.
+type Fault struct {
+	Status int
+	Body   string
+	Header http.Header
+	// Delay is slept before replying, to exercise client timeouts.
+	Delay time.Duration
+}
+
+// Server is a running Merit emulator. Its state is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	APIID  string
+	APIKey string
+	// Now is the clock used for ChangedDate stamps. Defaults to time.Now.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	nextID    int
+	nextNo    int
+	faults    map[string][]Fault
+	customers []*merit.CustomerListItem
+	invoices  []*invoice
+	payments  []*merit.PaymentListItem
+	taxes     []merit.TaxItem
+	banks     []merit.BankItem
+	requests  []string
+}
+
+type invoice struct {
+	detail  merit.InvoiceDetail
+	doc     int
+	changed time.Time
+}
+
+// NewServer starts an emulator seeded with the Estonian 22% and 0% VAT
+// rates and one bank account.
+func NewServer() *Server {
+	s := &Server{
+		APIID:  DefaultAPIID,
+		APIKey: DefaultAPIKey,
+		Now:    time.Now,
+		nextNo: 1,
+		faults: map[string][]Fault{},
+		taxes: []merit.TaxItem{
+			{TaxID: "b9b25735-6a15-4d4e-8720-25b254ae3d21", Code: "22%", Name: "Käibemaks 22%", TaxPct: decimal.NewFromInt(22)},
+			{TaxID: "973a4395-665f-47a6-a5b6-5384dd24f8d0", Code: "0%", Name: "Käibemaks 0%", TaxPct: decimal.Zero},
+		},
+		banks: []merit.BankItem{
+			{BankID: "5d5d2f6e-0000-4000-8000-000000000001", Name: "LHV", IBANCode: "EE717700771001735865", CurrencyCode: "EUR", AccountCode: "1020"},
+		},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Config returns a merit.Config pointed at the emulator.
+func (s *Server) Config() merit.Config {
+	return merit.Config{APIURL: s.URL + "/api/", APIID: s.APIID, APIKey: s.APIKey}
+}
+
+// TaxID returns the Merit TaxId of the seeded VAT rate with the given
+// percentage, or "" when none matches.
+func (s *Server) TaxID(pct int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.taxes {
+		if t.TaxPct.Equal(decimal.NewFromInt(pct)) {
+			return t.TaxID
+		}
+	}
+	return ""
+}
+
+// InjectFault queues f for the next call to endpoint (e.g.
+// "v2/sendinvoice"). Queued faults are consumed one per call, in order.
+func (s *Server) InjectFault(endpoint string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[endpoint] = append(s.faults[endpoint], f)
+}
+
+// Requests returns the endpoints called so far, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", s.nextID)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/api/")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, endpoint)
+	var fault *Fault
+	if q := s.faults[endpoint]; len(q) > 0 {
+		fault = &q[0]
+		s.faults[endpoint] = q[1:]
+	}
+	s.mu.Unlock()
+
+	if fault != nil {
+		time.Sleep(fault.Delay)
+		for k, vs := range fault.Header {
+			w.Header()[k] = vs
+		}
+		w.WriteHeader(fault.Status)
+		io.WriteString(w, fault.Body)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeText(w, http.StatusMethodNotAllowed, "The requested resource does not support http method '"+r.Method+"'.")
+		return
+	}
+	if status, msg := s.authenticate(r, body); status != 0 {
+		writeText(w, status, msg)
+		return
+	}
+
+	h, ok := handlers[endpoint]
+	if !ok {
+		writeText(w, http.StatusNotFound, "No HTTP resource was found that matches the request URI '"+r.URL.Path+"'.")
+		return
+	}
+	s.mu.Lock()
+	result, herr := h(s, body)
+	s.mu.Unlock()
+	if herr != nil {
+		writeText(w, herr.status, herr.msg)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if result == nil {
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// authenticate checks ApiId and the HMAC signature the way Merit does:
+// base64(HMAC-SHA256(key, ApiId + timestamp + body)).
+func (s *Server) authenticate(r *http.Request, body []byte) (int, string) {
+	q := r.URL.Query()
+	if q.Get("ApiId") != s.APIID {
+		return http.StatusUnauthorized, "api-noapiid"
+	}
+	ts := q.Get("timestamp")
+	if _, err := time.Parse("20060102150405", ts); err != nil {
+		return http.StatusUnauthorized, "api-wrongtimestamp"
+	}
+	mac := hmac.New(sha256.New, []byte(s.APIKey))
+	mac.Write([]byte(s.APIID + ts + string(body)))
+	want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(q.Get("signature")), []byte(want)) {
+		return http.StatusUnauthorized, "api-wrongsignature"
+	}
+	return 0, ""
+}
+
+type apiError struct {
+	status int
+	msg    string
+}
+
+func badRequest(format string, args ...any) *apiError {
+	return &apiError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+func writeText(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	io.WriteString(w, msg)
+}
+
+type handler func(s *Server, body []byte) (any, *apiError)
+
+var handlers = map[string]handler{
+	"v1/gettaxes":       (*Server).getTaxes,
+	"v1/getbanks":       (*Server).getBanks,
+	"v1/getcustomers":   (*Server).getCustomers,
+	"v2/sendcustomer":   (*Server).sendCustomer,
+	"v1/updatecustomer": (*Server).updateCustomer,
+	"v2/sendinvoice":    (*Server).sendInvoice,
+	"v2/getinvoices":    (*Server).getInvoices,
+	"v2/getinvoice":     (*Server).getInvoice,
+	"v1/deleteinvoice":  (*Server).deleteInvoice,
+	"v2/getsalesinvpdf": (*Server).getInvoicePDF,
+	"v2/sendpayment":    (*Server).sendPayment,
+	"v2/getpayments":    (*Server).getPayments,
+	"v1/deletepayment":  (*Server).deletePayment,
+}
+
+func decode(body []byte, v any) *apiError {
+	if err := json.Unmarshal(body, v); err != nil {
+		return badRequest("Invalid JSON: %v", err)
+	}
+	return nil
+}
+
+// parsePeriod validates a PeriodStart/PeriodEnd pair against Merit's
+// three-month window limit.
+func parsePeriod(start, end string) (time.Time, time.Time, *apiError) {
+	from, err := time.Parse(dateFormat, start)
+	if err != nil {
+		return time.Time{}, time.Time{}, badRequest("PeriodStart is not a valid date")
+	}
+	to, err := time.Parse(dateFormat, end)
+	if err != nil {
+		return time.Time{}, time.Time{}, badRequest("PeriodEnd is not a valid date")
+	}
+	if to.After(from.AddDate(0, maxPeriodMonths, 0)) {
+		return time.Time{}, time.Time{}, badRequest("Period can not be longer than %d months", maxPeriodMonths)
+	}
+	return from, to.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+func within(t, from, to time.Time) bool {
+	return !t.Before(from) && !t.After(to)
+}
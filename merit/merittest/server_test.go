@@ -0,0 +1,96 @@
+package merittest_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/merit"
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/shopspring/decimal"
+)
+
+func TestServer_RejectsBadSignature(t *testing.T) {
+	srv := merittest.NewServer()
+	defer srv.Close()
+	cfg := srv.Config()
+	cfg.APIKey = "wrong"
+
+	_, err := merit.New(cfg).ListTaxes(context.Background())
+	var apiErr *merit.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+		t.Fatalf("err = %v, want 401 APIError", err)
+	}
+}
+
+func TestServer_InvoiceLifecycle(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	client := merit.New(srv.Config())
+
+	tax := srv.TaxID(22)
+	req := merit.CreateInvoiceRequest{
+		Customer:  merit.CustomerRef{Name: "Acme OÜ"},
+		DocDate:   time.Now().Format("20060102"),
+		InvoiceNo: "A-1",
+		InvoiceRow: []merit.InvoiceRow{{
+			Item:     merit.ItemRef{Code: "FEE", Description: "Fee"},
+			Quantity: decimal.NewFromInt(1),
+			Price:    decimal.NewFromInt(100),
+			TaxID:    tax,
+		}},
+		TaxAmount: []merit.TaxAmountEntry{{TaxID: tax, Amount: decimal.NewFromInt(22)}},
+	}
+	resp, err := client.CreateInvoice(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	if !resp.NewCustomer || resp.CustomerID == "" {
+		t.Errorf("resp = %+v, want an inline-created customer", resp)
+	}
+
+	if _, err := client.CreateInvoice(ctx, req); err == nil || !strings.Contains(err.Error(), "Korduv arve") {
+		t.Errorf("duplicate: err = %v, want Korduv arve", err)
+	}
+
+	today := time.Now().Format("20060102")
+	list, err := client.ListInvoices(ctx, merit.ListInvoicesParams{PeriodStart: today, PeriodEnd: today})
+	if err != nil || len(list) != 1 || list[0].InvoiceNo != "A-1" {
+		t.Fatalf("ListInvoices = %+v, %v; want A-1", list, err)
+	}
+
+	_, err = client.ListInvoices(ctx, merit.ListInvoicesParams{PeriodStart: "20250101", PeriodEnd: "20250601"})
+	if err == nil || !strings.Contains(err.Error(), "3 months") {
+		t.Errorf("long period: err = %v, want the 3 month limit", err)
+	}
+
+	if err := client.DeleteInvoice(ctx, merit.DeleteInvoiceParams{ID: resp.InvoiceID}); err != nil {
+		t.Fatalf("DeleteInvoice: %v", err)
+	}
+	if _, err := client.GetInvoice(ctx, merit.GetInvoiceParams{ID: resp.InvoiceID}); err == nil {
+		t.Error("GetInvoice after delete: want error")
+	}
+}
+
+func TestServer_InjectFault(t *testing.T) {
+	srv := merittest.NewServer()
+	defer srv.Close()
+	srv.InjectFault("v1/gettaxes", merittest.Fault{Status: 500, Body: "boom"})
+	cfg := srv.Config()
+	cfg.Resilience = &resilience.Policy{MaxRetries: -1}
+	client := merit.New(cfg)
+
+	if _, err := client.ListTaxes(context.Background()); err == nil {
+		t.Fatal("first call: want injected fault")
+	}
+	if _, err := client.ListTaxes(context.Background()); err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if got := srv.Requests(); len(got) != 2 || got[0] != "v1/gettaxes" {
+		t.Errorf("Requests() = %v", got)
+	}
+}
@@ -1,15 +1,20 @@
 package merit
 
-import "context"
+import (
+	"context"
+	"iter"
+)
 
 // ListPayments retrieves a list of payments for the given period.
 // The period may not span more than 3 months.
 func (c *Client) ListPayments(ctx context.Context, params ListPaymentsParams) ([]PaymentListItem, error) {
-	var result []PaymentListItem
-	if err := c.post(ctx, "v2/getpayments", params, &result); err != nil {
-		return nil, err
-	}
-	return result, nil
+	return list[PaymentListItem](ctx, c, "v2/getpayments", params)
+}
+
+// StreamPayments yields the payments ListPayments returns one at a time,
+// decoding the response as it arrives.
+func (c *Client) StreamPayments(ctx context.Context, params ListPaymentsParams) iter.Seq2[PaymentListItem, error] {
+	return stream[PaymentListItem](ctx, c, "v2/getpayments", params)
 }
 
 // CreatePayment creates a payment for a sales invoice.
@@ -4,26 +4,58 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/qbitsoftware/accounting-service/cassette"
 )
 
-// Phase 0 live verification harness. Read-only probe. Runs only when
+// Phase 0 live verification harness. Read-only probe. Runs live when
 // MERIT_API_ID / MERIT_API_KEY are set in the environment.
 //
 //	MERIT_API_ID=... MERIT_API_KEY=... go test ./merit -run TestPhase0Probe -v
+//
+// Adding CASSETTE_MODE=record captures the traffic (credentials scrubbed) to
+// testdata/cassettes/<TestName>.json; once committed, the test replays from
+// that cassette when no credentials are set. None is committed yet, so
+// without credentials these tests skip. Requests are matched on their
+// body, so a probe that derives its periods from today's date matches its
+// recording only on the day it was made; pin the dates before committing
+// one.
 func phase0Client(t *testing.T) *Client {
 	t.Helper()
+	path := filepath.Join("testdata", "cassettes", t.Name()+".json")
 	id := os.Getenv("MERIT_API_ID")
 	key := os.Getenv("MERIT_API_KEY")
 	if id == "" || key == "" {
-		t.Skip("MERIT_API_ID / MERIT_API_KEY not set")
+		if _, err := os.Stat(path); err != nil {
+			t.Skip("MERIT_API_ID / MERIT_API_KEY not set and no recorded cassette")
+		}
+		rec, err := cassette.New(path, cassette.ModeReplay)
+		if err != nil {
+			t.Fatalf("open cassette: %v", err)
+		}
+		return New(Config{APIID: "replay", APIKey: "replay", HTTPClient: rec.Client()})
 	}
 	url := os.Getenv("MERIT_API_URL")
 	if url == "" {
 		url = EstoniaURL
 	}
-	return New(Config{APIURL: url, APIID: id, APIKey: key})
+	cfg := Config{APIURL: url, APIID: id, APIKey: key}
+	if cassette.ModeFromEnv("CASSETTE_MODE", cassette.ModeReplay) == cassette.ModeRecord {
+		rec, err := cassette.New(path, cassette.ModeRecord)
+		if err != nil {
+			t.Fatalf("open cassette: %v", err)
+		}
+		t.Cleanup(func() {
+			if err := rec.Stop(); err != nil {
+				t.Errorf("save cassette: %v", err)
+			}
+		})
+		cfg.HTTPClient = rec.Client()
+	}
+	return New(cfg)
 }
 
 func dump(t *testing.T, label string, v any) {
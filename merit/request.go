@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log/slog"
 	"net/http"
+	"strings"
+
+	"github.com/qbitsoftware/accounting-service/jsonstream"
 )
 
 // APIError represents an error response from the Merit API.
@@ -20,42 +25,32 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("merit api: status %d: %s", e.StatusCode, e.Message)
 }
 
+// isReadEndpoint reports whether endpoint only reads data. Every Merit call
+// is a POST, so idempotency follows the method name: get* endpoints are
+// safe to retry, send*/update*/delete* are not.
+func isReadEndpoint(endpoint string) bool {
+	_, method, _ := strings.Cut(endpoint, "/")
+	return strings.HasPrefix(strings.ToLower(method), "get")
+}
+
 // post sends a signed POST request to the Merit API and decodes the JSON response.
 // The endpoint should be the path suffix (e.g., "v2/getinvoices").
 // The payload is JSON-encoded and included in the signature.
 // The result parameter should be a pointer to the expected response type.
 func (c *Client) post(ctx context.Context, endpoint string, payload any, result any) error {
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("merit: marshal request: %w", err)
-	}
-
-	ts := timestamp()
-	sig := sign(c.apiID, c.apiKey, ts, string(body))
-
-	reqURL := fmt.Sprintf("%s%s?ApiId=%s&timestamp=%s&signature=%s",
-		c.apiURL, endpoint, c.apiID, ts, urlEncodeSignature(sig))
-
-	slog.Info("merit api request", "endpoint", endpoint, "body", string(body))
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(body))
+	body, err := c.marshal(endpoint, payload)
 	if err != nil {
-		return fmt.Errorf("merit: create request: %w", err)
+		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.exec.Do(ctx, endpoint, isReadEndpoint(endpoint), c.request(endpoint, body))
 	if err != nil {
 		return fmt.Errorf("merit: send request: %w", err)
 	}
-	defer resp.Body.Close()
+	respBody := resp.Body
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("merit: read response: %w", err)
-	}
-
-	slog.Info("merit api response", "endpoint", endpoint, "status", resp.StatusCode, "body", string(respBody))
+	c.logger.Info("merit api response", "endpoint", endpoint, "status", resp.StatusCode, "body_len", len(respBody))
+	c.logger.Debug("merit api response payload", "endpoint", endpoint, "body", c.redact.Body(respBody))
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return &APIError{
@@ -72,3 +67,94 @@ func (c *Client) post(ctx context.Context, endpoint string, payload any, result
 
 	return nil
 }
+
+// postStream is post for the list endpoints: it decodes the JSON array
+// response as it arrives, calling each with every element, and stops when
+// each returns false. The whole response is never held in memory.
+func postStream[T any](ctx context.Context, c *Client, endpoint string, payload any, each func(T) bool) error {
+	body, err := c.marshal(endpoint, payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.exec.Stream(ctx, endpoint, isReadEndpoint(endpoint), c.request(endpoint, body))
+	if err != nil {
+		return fmt.Errorf("merit: send request: %w", err)
+	}
+	if resp.Stream == nil {
+		c.logger.Info("merit api response", "endpoint", endpoint, "status", resp.StatusCode, "body_len", len(resp.Body))
+		c.logger.Debug("merit api response payload", "endpoint", endpoint, "body", c.redact.Body(resp.Body))
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    string(resp.Body),
+		}
+	}
+	defer resp.Stream.Close()
+
+	rec := jsonstream.NewRecorder(resp.Stream, c.logger.Enabled(ctx, slog.LevelDebug))
+	err = jsonstream.Array(json.NewDecoder(rec), each)
+	c.logger.Info("merit api response", "endpoint", endpoint, "status", resp.StatusCode, "body_len", rec.Len())
+	c.logger.Debug("merit api response payload", "endpoint", endpoint, "body", c.redact.Body(rec.Bytes()))
+	if err != nil && !(errors.Is(err, io.EOF) && rec.Len() == 0) {
+		return fmt.Errorf("merit: decode response: %w", err)
+	}
+	return nil
+}
+
+// stream presents a list endpoint as an iterator over its records,
+// decoded as they arrive. Stopping the loop stops reading.
+func stream[T any](ctx context.Context, c *Client, endpoint string, payload any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		stopped := false
+		err := postStream(ctx, c, endpoint, payload, func(v T) bool {
+			stopped = !yield(v, nil)
+			return !stopped
+		})
+		if err != nil && !stopped {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// list collects every record of a list endpoint.
+func list[T any](ctx context.Context, c *Client, endpoint string, payload any) ([]T, error) {
+	out := []T{}
+	if err := postStream(ctx, c, endpoint, payload, func(v T) bool {
+		out = append(out, v)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// marshal encodes a request payload and logs the request.
+func (c *Client) marshal(endpoint string, payload any) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("merit: marshal request: %w", err)
+	}
+
+	c.logger.Info("merit api request", "endpoint", endpoint)
+	c.logger.Debug("merit api request payload", "endpoint", endpoint, "body", c.redact.Body(body))
+	return body, nil
+}
+
+// request builds the attempts of a call. It re-signs each one: the
+// signature embeds a timestamp.
+func (c *Client) request(endpoint string, body []byte) func(ctx context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		ts := timestamp()
+		sig := sign(c.apiID, c.apiKey, ts, string(body))
+		reqURL := fmt.Sprintf("%s%s?ApiId=%s&timestamp=%s&signature=%s",
+			c.apiURL, endpoint, c.apiID, ts, urlEncodeSignature(sig))
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("merit: create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	}
+}
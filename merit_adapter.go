@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"iter"
 	"strings"
 	"time"
 
@@ -17,12 +18,38 @@ type meritProvider struct {
 	client *merit.Client
 }
 
+func init() {
+	RegisterProvider("merit", func(cfg Config) (Provider, error) {
+		return newMeritProvider(cfg), nil
+	}, meritCapabilities)
+}
+
+// meritCapabilities is the feature set registered for the "merit" provider.
+// Operations are derived from the adapter; the ones it does not implement
+// are listed here.
+var meritCapabilities = adapterCapabilities((*meritProvider)(nil),
+	Capabilities{SupportsVendorPayments: true, SupportsIncrementalSync: true},
+	OpFindInvoiceByRef,
+	OpGetCustomer,
+	OpLocatePayment,
+	OpListPaymentTerms,
+	OpInvoiceChangesAfter,
+	OpPaymentChangesAfter,
+	OpCustomerChangesAfter,
+	OpItemChangesAfter,
+	OpApplyPrepayment,
+	OpUnallocateToPrepayment,
+)
+
 func newMeritProvider(cfg Config) *meritProvider {
 	apiURL := merit.EstoniaURL
 	switch strings.ToLower(cfg.Region) {
 	case "pl", "poland":
 		apiURL = merit.PolandURL
 	}
+	if u := cfg.meritOptions().APIURL; u != "" {
+		apiURL = u
+	}
 
 	return &meritProvider{
 		client: merit.New(merit.Config{
@@ -30,6 +57,9 @@ func newMeritProvider(cfg Config) *meritProvider {
 			APIID:      cfg.APIID,
 			APIKey:     cfg.APIKey,
 			HTTPClient: cfg.HTTPClient,
+			Resilience: cfg.Resilience,
+			Logger:     cfg.Logger,
+			Redaction:  cfg.Redaction,
 		}),
 	}
 }
@@ -146,24 +176,68 @@ func (p *meritProvider) GetInvoicePDF(ctx context.Context, id string, deliveryNo
 	}, nil
 }
 
+// ListInvoices splits periods longer than Merit allows into windows.
 func (p *meritProvider) ListInvoices(ctx context.Context, input ListInvoicesInput) ([]Invoice, error) {
-	items, err := p.client.ListInvoices(ctx, merit.ListInvoicesParams{
-		PeriodStart: formatDate(input.PeriodStart),
-		PeriodEnd:   formatDate(input.PeriodEnd),
-	})
+	start, end, params := meritInvoiceQuery(input)
+	invoices, err := p.listInvoices(ctx, start, end, params)
 	if err != nil {
 		return nil, p.wrapError("ListInvoices", err)
 	}
-
-	invoices := make([]Invoice, len(items))
-	for i, item := range items {
-		invoices[i] = mapInvoiceListItem(item)
+	if !input.ChangedSince.IsZero() {
+		invoices = filterList(invoices, input.inPeriod)
 	}
 	return invoices, nil
 }
 
+// listInvoices lists the invoices of params from start to end, a window
+// at a time.
+func (p *meritProvider) listInvoices(ctx context.Context, start, end time.Time, params merit.ListInvoicesParams) ([]Invoice, error) {
+	return listWindows(ctx, start, end, meritMaxPeriodMonths, func(ctx context.Context, start, end time.Time) ([]Invoice, error) {
+		params := params
+		params.PeriodStart, params.PeriodEnd = formatDate(start), formatDate(end)
+		items, err := p.client.ListInvoices(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		invoices := make([]Invoice, len(items))
+		for i, item := range items {
+			invoices[i] = mapInvoiceListItem(item)
+		}
+		return invoices, nil
+	}, invoiceKey)
+}
+
+// meritInvoiceQuery returns the period to list and its params, pushing
+// down the unpaid filter, which includes partly paid invoices. Merit
+// ranges on one date at a time, so with ChangedSince the invoices changed
+// from that day to today are listed and the caller applies the document
+// period.
+func meritInvoiceQuery(input ListInvoicesInput) (start, end time.Time, params merit.ListInvoicesParams) {
+	params.UnPaid = input.UnpaidOnly || input.Status == InvoiceStatusUnpaid || input.Status == InvoiceStatusPartial
+	if !input.ChangedSince.IsZero() {
+		params.DateType = intPtr(1) // 1 = changed date
+		return input.ChangedSince, time.Time{}, params
+	}
+	return input.PeriodStart, input.PeriodEnd, params
+}
+
+// InvoicePages lists one month of invoices per request.
+func (p *meritProvider) InvoicePages(ctx context.Context, input ListInvoicesInput) iter.Seq2[[]Invoice, error] {
+	start, end, params := meritInvoiceQuery(input)
+	return meritPeriods(start, end, func(start, end time.Time) iter.Seq2[[]Invoice, error] {
+		params.PeriodStart, params.PeriodEnd = formatDate(start), formatDate(end)
+		pages := streamPages(p.client.StreamInvoices(ctx, params),
+			func(item *merit.InvoiceListItem) Invoice { return mapInvoiceListItem(*item) },
+			func(err error) error { return p.wrapError("ListInvoices", err) })
+		if !input.ChangedSince.IsZero() {
+			pages = filterPages(pages, input.inPeriod)
+		}
+		return pages
+	})
+}
+
 func (p *meritProvider) FindInvoiceByRef(_ context.Context, _ string) (*Invoice, error) {
-	return nil, p.wrapError("FindInvoiceByRef", fmt.Errorf("not yet implemented"))
+	return nil, p.wrapError("FindInvoiceByRef", fmt.Errorf("%w: not yet implemented", ErrNotSupported))
 }
 
 func (p *meritProvider) DeleteInvoice(ctx context.Context, id string) error {
@@ -171,6 +245,28 @@ func (p *meritProvider) DeleteInvoice(ctx context.Context, id string) error {
 	return p.wrapError("DeleteInvoice", err)
 }
 
+// LocateInvoice finds the invoice by number. Merit has no lookup by
+// number, so the invoices of the document date are searched instead.
+func (p *meritProvider) LocateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error) {
+	if input.IdempotencyKey != "" {
+		return nil, p.wrapError("LocateInvoice", errKeyNotStored)
+	}
+	if input.InvoiceNo == "" {
+		return nil, nil
+	}
+	start, end := documentDay(input.DocDate)
+	invoices, err := p.ListInvoices(ctx, ListInvoicesInput{PeriodStart: start, PeriodEnd: end})
+	if err != nil {
+		return nil, err
+	}
+	for _, inv := range invoices {
+		if inv.Number == input.InvoiceNo {
+			return p.GetInvoice(ctx, inv.ID)
+		}
+	}
+	return nil, nil
+}
+
 // --- Customers ---
 
 func (p *meritProvider) CreateCustomer(ctx context.Context, input CreateCustomerInput) (*Customer, error) {
@@ -254,7 +350,11 @@ func (p *meritProvider) UpdateCustomer(ctx context.Context, input UpdateCustomer
 }
 
 func (p *meritProvider) ListCustomers(ctx context.Context, input ListCustomersInput) ([]Customer, error) {
-	items, err := p.client.ListCustomers(ctx, merit.ListCustomersParams{})
+	items, err := p.client.ListCustomers(ctx, merit.ListCustomersParams{
+		Name:        input.Name,
+		RegNo:       strings.TrimSpace(input.RegNo),
+		ChangedDate: formatDate(input.ChangedSince),
+	})
 	if err != nil {
 		return nil, p.wrapError("ListCustomers", err)
 	}
@@ -266,11 +366,17 @@ func (p *meritProvider) ListCustomers(ctx context.Context, input ListCustomersIn
 	return customers, nil
 }
 
+// CustomerPages yields the customer list as one page: Merit returns it in
+// a single response.
+func (p *meritProvider) CustomerPages(ctx context.Context, input ListCustomersInput) iter.Seq2[[]Customer, error] {
+	return onePage(func() ([]Customer, error) { return p.ListCustomers(ctx, input) })
+}
+
 // GetCustomer is not supported by Merit Aktiva — its API doesn't expose a
 // fetch-by-ID endpoint for customers in a way the preview flow can use.
 // Callers should rely on FindCustomerByEmail / ListCustomers instead.
 func (p *meritProvider) GetCustomer(_ context.Context, _ string) (*Customer, error) {
-	return nil, p.wrapError("GetCustomer", fmt.Errorf("not supported by Merit Aktiva API"))
+	return nil, p.wrapError("GetCustomer", fmt.Errorf("%w by Merit Aktiva API", ErrNotSupported))
 }
 
 func (p *meritProvider) FindCustomerByEmail(ctx context.Context, email string) (*Customer, error) {
@@ -305,20 +411,65 @@ func (p *meritProvider) CreatePayment(ctx context.Context, input CreatePaymentIn
 	return p.wrapError("CreatePayment", err)
 }
 
+// ListPayments splits periods longer than Merit allows into windows.
 func (p *meritProvider) ListPayments(ctx context.Context, input ListPaymentsInput) ([]Payment, error) {
-	items, err := p.client.ListPayments(ctx, merit.ListPaymentsParams{
-		PeriodStart: formatDate(input.PeriodStart),
-		PeriodEnd:   formatDate(input.PeriodEnd),
-	})
+	payments, err := p.listPayments(ctx, input.PeriodStart, input.PeriodEnd, merit.ListPaymentsParams{})
 	if err != nil {
 		return nil, p.wrapError("ListPayments", err)
 	}
+	return payments, nil
+}
 
-	payments := make([]Payment, len(items))
-	for i, item := range items {
-		payments[i] = mapPaymentListItem(item)
+// listPayments lists the payments of params from start to end, a window
+// at a time.
+func (p *meritProvider) listPayments(ctx context.Context, start, end time.Time, params merit.ListPaymentsParams) ([]Payment, error) {
+	return listWindows(ctx, start, end, meritMaxPeriodMonths, func(ctx context.Context, start, end time.Time) ([]Payment, error) {
+		params := params
+		params.PeriodStart, params.PeriodEnd = formatDate(start), formatDate(end)
+		items, err := p.client.ListPayments(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		payments := make([]Payment, len(items))
+		for i, item := range items {
+			payments[i] = mapPaymentListItem(item)
+		}
+		return payments, nil
+	}, paymentKey)
+}
+
+// PaymentPages lists one month of payments per request.
+func (p *meritProvider) PaymentPages(ctx context.Context, input ListPaymentsInput) iter.Seq2[[]Payment, error] {
+	var params merit.ListPaymentsParams
+	return meritPeriods(input.PeriodStart, input.PeriodEnd, func(start, end time.Time) iter.Seq2[[]Payment, error] {
+		params.PeriodStart, params.PeriodEnd = formatDate(start), formatDate(end)
+		return streamPages(p.client.StreamPayments(ctx, params),
+			func(item *merit.PaymentListItem) Payment { return mapPaymentListItem(*item) },
+			func(err error) error { return p.wrapError("ListPayments", err) })
+	})
+}
+
+// meritMaxPeriodMonths is the longest period Merit accepts on
+// getinvoices, getpayments and getpurchorders.
+const meritMaxPeriodMonths = 3
+
+// meritPeriods pages a Merit list by month, keeping each request
+// within Merit's three-month period limit and its response small; pages
+// yields the records of one period. Without a start there is no range to
+// split, and pages is called once.
+func meritPeriods[T any](start, end time.Time, pages func(start, end time.Time) iter.Seq2[[]T, error]) iter.Seq2[[]T, error] {
+	if start.IsZero() {
+		return pages(start, end)
+	}
+	return func(yield func([]T, error) bool) {
+		for from, to := range dateWindows(start, end, 1) {
+			for page, err := range pages(from, to) {
+				if !yield(page, err) || err != nil {
+					return
+				}
+			}
+		}
 	}
-	return payments, nil
 }
 
 func (p *meritProvider) DeletePayment(ctx context.Context, id string) error {
@@ -326,6 +477,12 @@ func (p *meritProvider) DeletePayment(ctx context.Context, id string) error {
 	return p.wrapError("DeletePayment", err)
 }
 
+// LocatePayment is not supported: Merit keeps no caller-assigned text that
+// its payment list returns, so an earlier create cannot be recognised.
+func (p *meritProvider) LocatePayment(_ context.Context, _ CreatePaymentInput) (*Payment, error) {
+	return nil, p.wrapError("LocatePayment", fmt.Errorf("%w by Merit Aktiva API", ErrNotSupported))
+}
+
 // --- Items ---
 
 func (p *meritProvider) CreateItem(ctx context.Context, input CreateItemInput) (*Item, error) {
@@ -381,6 +538,11 @@ func (p *meritProvider) ListItems(ctx context.Context, input ListItemsInput) ([]
 	return items, nil
 }
 
+// ItemPages yields the item list as one page.
+func (p *meritProvider) ItemPages(ctx context.Context, input ListItemsInput) iter.Seq2[[]Item, error] {
+	return onePage(func() ([]Item, error) { return p.ListItems(ctx, input) })
+}
+
 func (p *meritProvider) UpdateItem(ctx context.Context, input UpdateItemInput) error {
 	req := merit.UpdateItemRequest{
 		ID: input.ID,
@@ -501,19 +663,25 @@ func (p *meritProvider) GetPurchase(ctx context.Context, id string) (*PurchaseIn
 	return mapPurchaseDetail(detail), nil
 }
 
+// ListPurchases splits periods longer than Merit allows into windows.
 func (p *meritProvider) ListPurchases(ctx context.Context, input ListPurchasesInput) ([]PurchaseInvoice, error) {
-	items, err := p.client.ListPurchases(ctx, merit.ListPurchasesParams{
-		PeriodStart: formatDate(input.PeriodStart),
-		PeriodEnd:   formatDate(input.PeriodEnd),
-	})
+	purchases, err := listWindows(ctx, input.PeriodStart, input.PeriodEnd, meritMaxPeriodMonths, func(ctx context.Context, start, end time.Time) ([]PurchaseInvoice, error) {
+		items, err := p.client.ListPurchases(ctx, merit.ListPurchasesParams{
+			PeriodStart: formatDate(start),
+			PeriodEnd:   formatDate(end),
+		})
+		if err != nil {
+			return nil, err
+		}
+		purchases := make([]PurchaseInvoice, len(items))
+		for i, item := range items {
+			purchases[i] = mapPurchaseListItem(item)
+		}
+		return purchases, nil
+	}, purchaseKey)
 	if err != nil {
 		return nil, p.wrapError("ListPurchases", err)
 	}
-
-	purchases := make([]PurchaseInvoice, len(items))
-	for i, item := range items {
-		purchases[i] = mapPurchaseListItem(item)
-	}
 	return purchases, nil
 }
 
@@ -680,40 +848,60 @@ func (p *meritProvider) CustomerDebts(ctx context.Context, customerName string,
 
 // --- Sync ---
 
+// ListInvoicesSince filters on the changed date by day, so the whole
+// until day is included. A zero until means today.
 func (p *meritProvider) ListInvoicesSince(ctx context.Context, since time.Time, until time.Time) ([]Invoice, error) {
-	items, err := p.client.ListInvoices(ctx, merit.ListInvoicesParams{
-		PeriodStart: formatDate(since),
-		PeriodEnd:   formatDate(until),
-		DateType:    intPtr(1), // 1 = changed date
+	invoices, err := p.listInvoices(ctx, since, until, merit.ListInvoicesParams{
+		DateType: intPtr(1), // 1 = changed date
 	})
 	if err != nil {
 		return nil, p.wrapError("ListInvoicesSince", err)
 	}
-
-	invoices := make([]Invoice, len(items))
-	for i, item := range items {
-		invoices[i] = mapInvoiceListItem(item)
-	}
 	return invoices, nil
 }
 
+// ListPaymentsSince filters on the changed date by day, so the whole
+// until day is included. A zero until means today.
 func (p *meritProvider) ListPaymentsSince(ctx context.Context, since time.Time, until time.Time) ([]Payment, error) {
-	items, err := p.client.ListPayments(ctx, merit.ListPaymentsParams{
-		PeriodStart: formatDate(since),
-		PeriodEnd:   formatDate(until),
-		DateType:    intPtr(1), // 1 = changed date
+	payments, err := p.listPayments(ctx, since, until, merit.ListPaymentsParams{
+		DateType: intPtr(1), // 1 = changed date
 	})
 	if err != nil {
 		return nil, p.wrapError("ListPaymentsSince", err)
 	}
-
-	payments := make([]Payment, len(items))
-	for i, item := range items {
-		payments[i] = mapPaymentListItem(item)
-	}
 	return payments, nil
 }
 
+// ListInvoiceChanges is ListInvoicesSince: Merit reports no deletions.
+func (p *meritProvider) ListInvoiceChanges(ctx context.Context, since time.Time, until time.Time) (InvoiceChanges, error) {
+	invoices, err := p.ListInvoicesSince(ctx, since, until)
+	return InvoiceChanges{Invoices: invoices}, err
+}
+
+// ListPaymentChanges is ListPaymentsSince: Merit reports no deletions.
+func (p *meritProvider) ListPaymentChanges(ctx context.Context, since time.Time, until time.Time) (PaymentChanges, error) {
+	payments, err := p.ListPaymentsSince(ctx, since, until)
+	return PaymentChanges{Payments: payments}, err
+}
+
+// Merit has no change feed; the SyncEngine syncs it by changed date.
+
+func (p *meritProvider) InvoiceChangesAfter(_ context.Context, _ string, _ time.Time) (InvoiceChanges, string, error) {
+	return InvoiceChanges{}, "", p.wrapError("InvoiceChangesAfter", fmt.Errorf("%w by Merit Aktiva API", ErrNotSupported))
+}
+
+func (p *meritProvider) PaymentChangesAfter(_ context.Context, _ string, _ time.Time) (PaymentChanges, string, error) {
+	return PaymentChanges{}, "", p.wrapError("PaymentChangesAfter", fmt.Errorf("%w by Merit Aktiva API", ErrNotSupported))
+}
+
+func (p *meritProvider) CustomerChangesAfter(_ context.Context, _ string, _ time.Time) (CustomerChanges, string, error) {
+	return CustomerChanges{}, "", p.wrapError("CustomerChangesAfter", fmt.Errorf("%w by Merit Aktiva API", ErrNotSupported))
+}
+
+func (p *meritProvider) ItemChangesAfter(_ context.Context, _ string, _ time.Time) (ItemChanges, string, error) {
+	return ItemChanges{}, "", p.wrapError("ItemChangesAfter", fmt.Errorf("%w by Merit Aktiva API", ErrNotSupported))
+}
+
 // --- Mapping helpers ---
 
 func mapInvoiceListItem(item merit.InvoiceListItem) Invoice {
@@ -731,6 +919,7 @@ func mapInvoiceListItem(item merit.InvoiceListItem) Invoice {
 		Paid:         item.Paid,
 		Status:       deriveInvoiceStatus(item.Paid, item.PaidAmount),
 		ReferenceNo:  item.ReferenceNo,
+		ChangedAt:    parseDate(item.ChangedDate),
 	}
 }
 
@@ -934,19 +1123,34 @@ func (p *meritProvider) wrapError(op string, err error) error {
 
 	var apiErr *merit.APIError
 	if errors.As(err, &apiErr) {
-		var sentinel error
-		switch apiErr.StatusCode {
-		case 401, 403:
-			sentinel = ErrAuthFailed
-		case 404:
-			sentinel = ErrNotFound
-		case 429:
-			sentinel = ErrRateLimit
-		default:
-			sentinel = err
-		}
-		return &ProviderError{Provider: "merit", Op: op, Err: sentinel}
+		return &ProviderError{Provider: "merit", Op: op, Err: meritErrorKind(apiErr, err)}
 	}
 
 	return &ProviderError{Provider: "merit", Op: op, Err: err}
 }
+
+// meritErrorKind maps a Merit API error onto the error taxonomy. Merit
+// reports failures as plain-text bodies ("Korduv arve", "custexists"), so
+// past the status code the message is the only signal.
+func meritErrorKind(apiErr *merit.APIError, err error) error {
+	msg := strings.ToLower(apiErr.Message)
+	switch {
+	case apiErr.StatusCode == 401, apiErr.StatusCode == 403:
+		return ErrAuthFailed
+	case apiErr.StatusCode == 404:
+		return ErrNotFound
+	case apiErr.StatusCode == 429:
+		return ErrRateLimit
+	case strings.Contains(msg, "korduv arve"), strings.Contains(msg, "custexists"):
+		return withKind(ErrDuplicate, err)
+	case periodClosedMessage(msg):
+		return withKind(ErrPeriodClosed, err)
+	case apiErr.StatusCode == 409:
+		return withKind(ErrConflict, err)
+	case apiErr.StatusCode >= 500:
+		return withKind(ErrTransient, err)
+	case apiErr.StatusCode == 400:
+		return &ValidationError{Message: apiErr.Message, Err: err}
+	}
+	return err
+}
@@ -2,6 +2,7 @@ package accounting
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/qbitsoftware/accounting-service/merit"
@@ -96,12 +97,12 @@ func meritAdvancesFromDebts(items []merit.CustomerDebtItem) []Prepayment {
 
 // ApplyPrepayment is not supported on Merit (v1). See SCOPE DECISION.
 func (p *meritProvider) ApplyPrepayment(ctx context.Context, input ApplyPrepaymentInput) error {
-	return p.wrapError("ApplyPrepayment", ErrUnsupportedProvider)
+	return p.wrapError("ApplyPrepayment", fmt.Errorf("%w: %w", ErrNotSupported, ErrUnsupportedProvider))
 }
 
 // UnallocateToPrepayment is not supported on Merit (v1). See SCOPE DECISION.
 func (p *meritProvider) UnallocateToPrepayment(ctx context.Context, input UnallocateToPrepaymentInput) (*Prepayment, error) {
-	return nil, p.wrapError("UnallocateToPrepayment", ErrUnsupportedProvider)
+	return nil, p.wrapError("UnallocateToPrepayment", fmt.Errorf("%w: %w", ErrNotSupported, ErrUnsupportedProvider))
 }
 
 // parseDebtDate parses the ISO timestamps returned by getcustdebtrep
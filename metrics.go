@@ -0,0 +1,148 @@
+package accounting
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metrics is a dependency-free Observer that aggregates operation and
+// HTTP-endpoint statistics in memory. Export them by polling Operations
+// and Endpoints, e.g. from a Prometheus collector or an expvar.Func.
+//
+//	m := accounting.NewMetrics()
+//	client, err := accounting.NewClient(accounting.Config{..., Tenant: "club-42", Observer: m})
+type Metrics struct {
+	mu        sync.Mutex
+	ops       map[opKey]*OperationStats
+	endpoints map[endpointKey]*EndpointStats
+}
+
+type opKey struct{ provider, tenant, name string }
+
+type endpointKey struct{ provider, tenant, endpoint string }
+
+// OperationStats aggregates the calls of one operation for one provider
+// and tenant.
+type OperationStats struct {
+	Provider  string
+	Tenant    string
+	Operation string
+	Count     int64
+	Errors    map[ErrorClass]int64
+	Total     time.Duration
+	Max       time.Duration
+}
+
+// EndpointStats aggregates the HTTP exchanges with one provider endpoint
+// for one tenant. Retries count as separate exchanges.
+type EndpointStats struct {
+	Provider string
+	Tenant   string
+	Endpoint string
+	Count    int64
+	Statuses map[int]int64 // 0 counts exchanges that failed without a response
+	Total    time.Duration
+	Max      time.Duration
+}
+
+// NewMetrics returns an empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		ops:       map[opKey]*OperationStats{},
+		endpoints: map[endpointKey]*EndpointStats{},
+	}
+}
+
+// StartOperation implements Observer.
+func (m *Metrics) StartOperation(ctx context.Context, op Operation) (context.Context, func(OperationResult)) {
+	return ctx, m.record
+}
+
+func (m *Metrics) record(r OperationResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := opKey{r.Provider, r.Tenant, r.Name}
+	s := m.ops[k]
+	if s == nil {
+		s = &OperationStats{Provider: r.Provider, Tenant: r.Tenant, Operation: r.Name, Errors: map[ErrorClass]int64{}}
+		m.ops[k] = s
+	}
+	s.Count++
+	if r.ErrorClass != "" {
+		s.Errors[r.ErrorClass]++
+	}
+	s.Total += r.Duration
+	s.Max = max(s.Max, r.Duration)
+}
+
+// ObserveHTTP implements HTTPObserver.
+func (m *Metrics) ObserveHTTP(_ context.Context, a HTTPAttempt) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := endpointKey{a.Provider, a.Tenant, a.Endpoint}
+	s := m.endpoints[k]
+	if s == nil {
+		s = &EndpointStats{Provider: a.Provider, Tenant: a.Tenant, Endpoint: a.Endpoint, Statuses: map[int]int64{}}
+		m.endpoints[k] = s
+	}
+	s.Count++
+	s.Statuses[a.StatusCode]++
+	s.Total += a.Duration
+	s.Max = max(s.Max, a.Duration)
+}
+
+// Operations returns a snapshot of the operation statistics, sorted by
+// provider, tenant and operation.
+func (m *Metrics) Operations() []OperationStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]OperationStats, 0, len(m.ops))
+	for _, s := range m.ops {
+		c := *s
+		c.Errors = make(map[ErrorClass]int64, len(s.Errors))
+		for k, v := range s.Errors {
+			c.Errors[k] = v
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Tenant != b.Tenant {
+			return a.Tenant < b.Tenant
+		}
+		return a.Operation < b.Operation
+	})
+	return out
+}
+
+// Endpoints returns a snapshot of the HTTP endpoint statistics, sorted by
+// provider, tenant and endpoint.
+func (m *Metrics) Endpoints() []EndpointStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]EndpointStats, 0, len(m.endpoints))
+	for _, s := range m.endpoints {
+		c := *s
+		c.Statuses = make(map[int]int64, len(s.Statuses))
+		for k, v := range s.Statuses {
+			c.Statuses[k] = v
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Tenant != b.Tenant {
+			return a.Tenant < b.Tenant
+		}
+		return a.Endpoint < b.Endpoint
+	})
+	return out
+}
@@ -0,0 +1,537 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/resilience"
+)
+
+// Observer receives per-operation telemetry from a Client. Set
+// Config.Observer and NewClient wraps every Provider call — including the
+// optional PrepaymentProvider methods, and the *Pages iterations behind
+// the services' All methods, each reported once — so latency, error rates
+// and quota usage can be broken down by provider, tenant and operation.
+//
+// StartOperation has the same shape as an OpenTelemetry tracer's Start: the
+// returned context is handed to the provider, so a span stored in it
+// becomes the parent of anything the call does, and end is called exactly
+// once when the call returns. An adapter is a few lines:
+//
+//	func (o otelObserver) StartOperation(ctx context.Context, op accounting.Operation) (context.Context, func(accounting.OperationResult)) {
+//	    ctx, span := o.tracer.Start(ctx, "accounting."+op.Name, trace.WithAttributes(
+//	        attribute.String("accounting.provider", op.Provider),
+//	        attribute.String("accounting.tenant", op.Tenant)))
+//	    return ctx, func(r accounting.OperationResult) {
+//	        if r.Err != nil {
+//	            span.SetStatus(codes.Error, string(r.ErrorClass))
+//	        }
+//	        span.End()
+//	    }
+//	}
+//
+// Metrics is a dependency-free implementation that aggregates counts and
+// durations in memory.
+type Observer interface {
+	StartOperation(ctx context.Context, op Operation) (context.Context, func(OperationResult))
+}
+
+// HTTPObserver is an optional Observer extension. When Config.Observer
+// implements it, every HTTP exchange the provider client makes — retries
+// included — is reported with its endpoint, status and timing.
+type HTTPObserver interface {
+	ObserveHTTP(ctx context.Context, a HTTPAttempt)
+}
+
+// Operation identifies a single Provider call.
+type Operation struct {
+	// Name is the Provider method, e.g. "CreateInvoice"; it is also the
+	// method's Op.
+	Name     string
+	Provider string // Config.Provider
+	Tenant   string // Config.Tenant
+}
+
+// OperationResult is passed to the end function returned by
+// Observer.StartOperation.
+type OperationResult struct {
+	Operation
+	Duration   time.Duration
+	Outcome    Outcome
+	ErrorClass ErrorClass // empty on success
+	Err        error
+}
+
+// HTTPAttempt is one HTTP exchange reported to an HTTPObserver.
+type HTTPAttempt struct {
+	Tenant string
+	resilience.Attempt
+}
+
+// Outcome is the coarse result of an operation.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeError   Outcome = "error"
+)
+
+// ErrorClass is a low-cardinality label for an error, suitable as a metric
+// dimension.
+type ErrorClass string
+
+const (
+	ErrorClassNotFound     ErrorClass = "not_found"
+	ErrorClassAuth         ErrorClass = "auth"
+	ErrorClassRateLimit    ErrorClass = "rate_limit"
+	ErrorClassInvalidInput ErrorClass = "invalid_input"
+	ErrorClassUnsupported  ErrorClass = "unsupported"
+	ErrorClassDuplicate    ErrorClass = "duplicate"
+	ErrorClassConflict     ErrorClass = "conflict"
+	ErrorClassPeriodClosed ErrorClass = "period_closed"
+	ErrorClassTransient    ErrorClass = "transient"
+	ErrorClassCircuitOpen  ErrorClass = "circuit_open"
+	ErrorClassCanceled     ErrorClass = "canceled"
+	ErrorClassTimeout      ErrorClass = "timeout"
+	ErrorClassProvider     ErrorClass = "provider" // anything else the provider returned
+)
+
+// ClassifyError returns the ErrorClass of err, or "" for nil.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, resilience.ErrCircuitOpen):
+		return ErrorClassCircuitOpen
+	case errors.Is(err, ErrNotFound):
+		return ErrorClassNotFound
+	case errors.Is(err, ErrAuthFailed):
+		return ErrorClassAuth
+	case errors.Is(err, ErrRateLimit):
+		return ErrorClassRateLimit
+	case errors.Is(err, ErrInvalidInput), errors.Is(err, ErrValidation):
+		return ErrorClassInvalidInput
+	case errors.Is(err, ErrNotSupported), errors.Is(err, ErrUnsupportedProvider):
+		return ErrorClassUnsupported
+	case errors.Is(err, ErrDuplicate):
+		return ErrorClassDuplicate
+	case errors.Is(err, ErrConflict):
+		return ErrorClassConflict
+	case errors.Is(err, ErrPeriodClosed):
+		return ErrorClassPeriodClosed
+	case errors.Is(err, ErrTransient):
+		return ErrorClassTransient
+	default:
+		return ErrorClassProvider
+	}
+}
+
+// MultiObserver fans each event out to all of obs, e.g. to feed a tracer
+// and Metrics at once. HTTP attempts reach those that implement
+// HTTPObserver.
+func MultiObserver(obs ...Observer) Observer {
+	return multiObserver(obs)
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) StartOperation(ctx context.Context, op Operation) (context.Context, func(OperationResult)) {
+	ends := make([]func(OperationResult), len(m))
+	for i, o := range m {
+		ctx, ends[i] = o.StartOperation(ctx, op)
+	}
+	return ctx, func(r OperationResult) {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](r)
+		}
+	}
+}
+
+func (m multiObserver) ObserveHTTP(ctx context.Context, a HTTPAttempt) {
+	for _, o := range m {
+		if h, ok := o.(HTTPObserver); ok {
+			h.ObserveHTTP(ctx, a)
+		}
+	}
+}
+
+// withHTTPObserver returns a copy of p that also reports each attempt to h,
+// keeping any OnAttempt hook the caller already set.
+func withHTTPObserver(p *resilience.Policy, tenant string, h HTTPObserver) *resilience.Policy {
+	var out resilience.Policy
+	if p != nil {
+		out = *p
+	}
+	prev := out.OnAttempt
+	out.OnAttempt = func(ctx context.Context, a resilience.Attempt) {
+		if prev != nil {
+			prev(ctx, a)
+		}
+		h.ObserveHTTP(ctx, HTTPAttempt{Tenant: tenant, Attempt: a})
+	}
+	return &out
+}
+
+// observeProvider wraps p so every call is reported to obs, an All
+// iteration as one List operation. The result implements
+// PrepaymentProvider exactly when p does, so capability checks keep
+// working.
+func observeProvider(p Provider, obs Observer, providerName, tenant string) Provider {
+	op := &observedProvider{inner: p, obs: obs, provider: providerName, tenant: tenant}
+	if pp, ok := p.(PrepaymentProvider); ok {
+		return &observedPrepaymentProvider{observedProvider: op, pp: pp}
+	}
+	return op
+}
+
+// unwrapProvider returns the provider beneath any decorators NewClient
+// added, for optional-interface checks such as CircuitState.
+func unwrapProvider(p Provider) Provider {
+	for {
+		u, ok := p.(interface{ Unwrap() Provider })
+		if !ok {
+			return p
+		}
+		p = u.Unwrap()
+	}
+}
+
+var (
+	_ Provider           = (*observedProvider)(nil)
+	_ PrepaymentProvider = (*observedPrepaymentProvider)(nil)
+)
+
+type observedProvider struct {
+	inner    Provider
+	obs      Observer
+	provider string
+	tenant   string
+}
+
+func (p *observedProvider) Unwrap() Provider { return p.inner }
+
+// start begins an operation; the returned function must be called with
+// the call's error.
+func (p *observedProvider) start(ctx context.Context, name string) (context.Context, func(error)) {
+	op := Operation{Name: name, Provider: p.provider, Tenant: p.tenant}
+	begin := time.Now()
+	ctx, end := p.obs.StartOperation(ctx, op)
+	return ctx, func(err error) {
+		r := OperationResult{Operation: op, Duration: time.Since(begin), Outcome: OutcomeSuccess, Err: err}
+		if err != nil {
+			r.Outcome = OutcomeError
+			r.ErrorClass = ClassifyError(err)
+		}
+		end(r)
+	}
+}
+
+func (p *observedProvider) TestConnection(ctx context.Context) (err error) {
+	ctx, end := p.start(ctx, "TestConnection")
+	defer func() { end(err) }()
+	return p.inner.TestConnection(ctx)
+}
+
+func (p *observedProvider) CreateInvoice(ctx context.Context, input CreateInvoiceInput) (_ *Invoice, err error) {
+	ctx, end := p.start(ctx, "CreateInvoice")
+	defer func() { end(err) }()
+	return p.inner.CreateInvoice(ctx, input)
+}
+
+func (p *observedProvider) GetInvoice(ctx context.Context, id string) (_ *Invoice, err error) {
+	ctx, end := p.start(ctx, "GetInvoice")
+	defer func() { end(err) }()
+	return p.inner.GetInvoice(ctx, id)
+}
+
+func (p *observedProvider) GetInvoicePDF(ctx context.Context, id string, deliveryNote bool) (_ *InvoicePDF, err error) {
+	ctx, end := p.start(ctx, "GetInvoicePDF")
+	defer func() { end(err) }()
+	return p.inner.GetInvoicePDF(ctx, id, deliveryNote)
+}
+
+func (p *observedProvider) ListInvoices(ctx context.Context, input ListInvoicesInput) (_ []Invoice, err error) {
+	ctx, end := p.start(ctx, "ListInvoices")
+	defer func() { end(err) }()
+	return p.inner.ListInvoices(ctx, input)
+}
+
+func (p *observedProvider) FindInvoiceByRef(ctx context.Context, refStr string) (_ *Invoice, err error) {
+	ctx, end := p.start(ctx, "FindInvoiceByRef")
+	defer func() { end(err) }()
+	return p.inner.FindInvoiceByRef(ctx, refStr)
+}
+
+func (p *observedProvider) DeleteInvoice(ctx context.Context, id string) (err error) {
+	ctx, end := p.start(ctx, "DeleteInvoice")
+	defer func() { end(err) }()
+	return p.inner.DeleteInvoice(ctx, id)
+}
+
+func (p *observedProvider) CreateCustomer(ctx context.Context, input CreateCustomerInput) (_ *Customer, err error) {
+	ctx, end := p.start(ctx, "CreateCustomer")
+	defer func() { end(err) }()
+	return p.inner.CreateCustomer(ctx, input)
+}
+
+func (p *observedProvider) UpdateCustomer(ctx context.Context, input UpdateCustomerInput) (err error) {
+	ctx, end := p.start(ctx, "UpdateCustomer")
+	defer func() { end(err) }()
+	return p.inner.UpdateCustomer(ctx, input)
+}
+
+func (p *observedProvider) ListCustomers(ctx context.Context, input ListCustomersInput) (_ []Customer, err error) {
+	ctx, end := p.start(ctx, "ListCustomers")
+	defer func() { end(err) }()
+	return p.inner.ListCustomers(ctx, input)
+}
+
+func (p *observedProvider) FindCustomerByEmail(ctx context.Context, email string) (_ *Customer, err error) {
+	ctx, end := p.start(ctx, "FindCustomerByEmail")
+	defer func() { end(err) }()
+	return p.inner.FindCustomerByEmail(ctx, email)
+}
+
+func (p *observedProvider) GetCustomer(ctx context.Context, id string) (_ *Customer, err error) {
+	ctx, end := p.start(ctx, "GetCustomer")
+	defer func() { end(err) }()
+	return p.inner.GetCustomer(ctx, id)
+}
+
+func (p *observedProvider) CreatePayment(ctx context.Context, input CreatePaymentInput) (err error) {
+	ctx, end := p.start(ctx, "CreatePayment")
+	defer func() { end(err) }()
+	return p.inner.CreatePayment(ctx, input)
+}
+
+func (p *observedProvider) ListPayments(ctx context.Context, input ListPaymentsInput) (_ []Payment, err error) {
+	ctx, end := p.start(ctx, "ListPayments")
+	defer func() { end(err) }()
+	return p.inner.ListPayments(ctx, input)
+}
+
+func (p *observedProvider) DeletePayment(ctx context.Context, id string) (err error) {
+	ctx, end := p.start(ctx, "DeletePayment")
+	defer func() { end(err) }()
+	return p.inner.DeletePayment(ctx, id)
+}
+
+func (p *observedProvider) CreateItem(ctx context.Context, input CreateItemInput) (_ *Item, err error) {
+	ctx, end := p.start(ctx, "CreateItem")
+	defer func() { end(err) }()
+	return p.inner.CreateItem(ctx, input)
+}
+
+func (p *observedProvider) ListItems(ctx context.Context, input ListItemsInput) (_ []Item, err error) {
+	ctx, end := p.start(ctx, "ListItems")
+	defer func() { end(err) }()
+	return p.inner.ListItems(ctx, input)
+}
+
+func (p *observedProvider) UpdateItem(ctx context.Context, input UpdateItemInput) (err error) {
+	ctx, end := p.start(ctx, "UpdateItem")
+	defer func() { end(err) }()
+	return p.inner.UpdateItem(ctx, input)
+}
+
+func (p *observedProvider) CreateCreditNote(ctx context.Context, input CreateCreditNoteInput) (_ *Invoice, err error) {
+	ctx, end := p.start(ctx, "CreateCreditNote")
+	defer func() { end(err) }()
+	return p.inner.CreateCreditNote(ctx, input)
+}
+
+func (p *observedProvider) CreatePurchase(ctx context.Context, input CreatePurchaseInput) (_ *PurchaseInvoice, err error) {
+	ctx, end := p.start(ctx, "CreatePurchase")
+	defer func() { end(err) }()
+	return p.inner.CreatePurchase(ctx, input)
+}
+
+func (p *observedProvider) GetPurchase(ctx context.Context, id string) (_ *PurchaseInvoice, err error) {
+	ctx, end := p.start(ctx, "GetPurchase")
+	defer func() { end(err) }()
+	return p.inner.GetPurchase(ctx, id)
+}
+
+func (p *observedProvider) ListPurchases(ctx context.Context, input ListPurchasesInput) (_ []PurchaseInvoice, err error) {
+	ctx, end := p.start(ctx, "ListPurchases")
+	defer func() { end(err) }()
+	return p.inner.ListPurchases(ctx, input)
+}
+
+func (p *observedProvider) DeletePurchase(ctx context.Context, id string) (err error) {
+	ctx, end := p.start(ctx, "DeletePurchase")
+	defer func() { end(err) }()
+	return p.inner.DeletePurchase(ctx, id)
+}
+
+func (p *observedProvider) ListTaxes(ctx context.Context) (_ []Tax, err error) {
+	ctx, end := p.start(ctx, "ListTaxes")
+	defer func() { end(err) }()
+	return p.inner.ListTaxes(ctx)
+}
+
+func (p *observedProvider) ListAccounts(ctx context.Context) (_ []Account, err error) {
+	ctx, end := p.start(ctx, "ListAccounts")
+	defer func() { end(err) }()
+	return p.inner.ListAccounts(ctx)
+}
+
+func (p *observedProvider) ListDimensions(ctx context.Context) (_ *DimensionList, err error) {
+	ctx, end := p.start(ctx, "ListDimensions")
+	defer func() { end(err) }()
+	return p.inner.ListDimensions(ctx)
+}
+
+func (p *observedProvider) ListBanks(ctx context.Context) (_ []Bank, err error) {
+	ctx, end := p.start(ctx, "ListBanks")
+	defer func() { end(err) }()
+	return p.inner.ListBanks(ctx)
+}
+
+func (p *observedProvider) ListPaymentTerms(ctx context.Context) (_ []PaymentTerm, err error) {
+	ctx, end := p.start(ctx, "ListPaymentTerms")
+	defer func() { end(err) }()
+	return p.inner.ListPaymentTerms(ctx)
+}
+
+func (p *observedProvider) CustomerDebts(ctx context.Context, customerName string, overdueDays *int) (_ []CustomerDebt, err error) {
+	ctx, end := p.start(ctx, "CustomerDebts")
+	defer func() { end(err) }()
+	return p.inner.CustomerDebts(ctx, customerName, overdueDays)
+}
+
+func (p *observedProvider) ListInvoicesSince(ctx context.Context, since time.Time, until time.Time) (_ []Invoice, err error) {
+	ctx, end := p.start(ctx, "ListInvoicesSince")
+	defer func() { end(err) }()
+	return p.inner.ListInvoicesSince(ctx, since, until)
+}
+
+func (p *observedProvider) ListPaymentsSince(ctx context.Context, since time.Time, until time.Time) (_ []Payment, err error) {
+	ctx, end := p.start(ctx, "ListPaymentsSince")
+	defer func() { end(err) }()
+	return p.inner.ListPaymentsSince(ctx, since, until)
+}
+
+// observePages reports an iteration over pages as one operation, from
+// the first fetch until the caller stops or the pages run out; pages is
+// given the operation's context.
+func observePages[T any](ctx context.Context, p *observedProvider, name string, pages func(context.Context) iter.Seq2[[]T, error]) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		ctx, end := p.start(ctx, name)
+		var err error
+		defer func() { end(err) }()
+		for page, perr := range pages(ctx) {
+			err = perr
+			if !yield(page, perr) {
+				return
+			}
+		}
+	}
+}
+
+func (p *observedProvider) InvoicePages(ctx context.Context, input ListInvoicesInput) iter.Seq2[[]Invoice, error] {
+	return observePages(ctx, p, "InvoicePages", func(ctx context.Context) iter.Seq2[[]Invoice, error] {
+		return p.inner.InvoicePages(ctx, input)
+	})
+}
+
+func (p *observedProvider) CustomerPages(ctx context.Context, input ListCustomersInput) iter.Seq2[[]Customer, error] {
+	return observePages(ctx, p, "CustomerPages", func(ctx context.Context) iter.Seq2[[]Customer, error] {
+		return p.inner.CustomerPages(ctx, input)
+	})
+}
+
+func (p *observedProvider) PaymentPages(ctx context.Context, input ListPaymentsInput) iter.Seq2[[]Payment, error] {
+	return observePages(ctx, p, "PaymentPages", func(ctx context.Context) iter.Seq2[[]Payment, error] {
+		return p.inner.PaymentPages(ctx, input)
+	})
+}
+
+func (p *observedProvider) ItemPages(ctx context.Context, input ListItemsInput) iter.Seq2[[]Item, error] {
+	return observePages(ctx, p, "ItemPages", func(ctx context.Context) iter.Seq2[[]Item, error] {
+		return p.inner.ItemPages(ctx, input)
+	})
+}
+
+func (p *observedProvider) LocateInvoice(ctx context.Context, input CreateInvoiceInput) (_ *Invoice, err error) {
+	ctx, end := p.start(ctx, "LocateInvoice")
+	defer func() { end(err) }()
+	return p.inner.LocateInvoice(ctx, input)
+}
+
+func (p *observedProvider) LocatePayment(ctx context.Context, input CreatePaymentInput) (_ *Payment, err error) {
+	ctx, end := p.start(ctx, "LocatePayment")
+	defer func() { end(err) }()
+	return p.inner.LocatePayment(ctx, input)
+}
+
+func (p *observedProvider) ListInvoiceChanges(ctx context.Context, since time.Time, until time.Time) (_ InvoiceChanges, err error) {
+	ctx, end := p.start(ctx, "ListInvoiceChanges")
+	defer func() { end(err) }()
+	return p.inner.ListInvoiceChanges(ctx, since, until)
+}
+
+func (p *observedProvider) ListPaymentChanges(ctx context.Context, since time.Time, until time.Time) (_ PaymentChanges, err error) {
+	ctx, end := p.start(ctx, "ListPaymentChanges")
+	defer func() { end(err) }()
+	return p.inner.ListPaymentChanges(ctx, since, until)
+}
+
+func (p *observedProvider) InvoiceChangesAfter(ctx context.Context, cursor string, since time.Time) (_ InvoiceChanges, next string, err error) {
+	ctx, end := p.start(ctx, "InvoiceChangesAfter")
+	defer func() { end(err) }()
+	return p.inner.InvoiceChangesAfter(ctx, cursor, since)
+}
+
+func (p *observedProvider) PaymentChangesAfter(ctx context.Context, cursor string, since time.Time) (_ PaymentChanges, next string, err error) {
+	ctx, end := p.start(ctx, "PaymentChangesAfter")
+	defer func() { end(err) }()
+	return p.inner.PaymentChangesAfter(ctx, cursor, since)
+}
+
+func (p *observedProvider) CustomerChangesAfter(ctx context.Context, cursor string, since time.Time) (_ CustomerChanges, next string, err error) {
+	ctx, end := p.start(ctx, "CustomerChangesAfter")
+	defer func() { end(err) }()
+	return p.inner.CustomerChangesAfter(ctx, cursor, since)
+}
+
+func (p *observedProvider) ItemChangesAfter(ctx context.Context, cursor string, since time.Time) (_ ItemChanges, next string, err error) {
+	ctx, end := p.start(ctx, "ItemChangesAfter")
+	defer func() { end(err) }()
+	return p.inner.ItemChangesAfter(ctx, cursor, since)
+}
+
+type observedPrepaymentProvider struct {
+	*observedProvider
+	pp PrepaymentProvider
+}
+
+func (p *observedPrepaymentProvider) CreatePrepayment(ctx context.Context, input CreatePrepaymentInput) (_ *Prepayment, err error) {
+	ctx, end := p.start(ctx, "CreatePrepayment")
+	defer func() { end(err) }()
+	return p.pp.CreatePrepayment(ctx, input)
+}
+
+func (p *observedPrepaymentProvider) ApplyPrepayment(ctx context.Context, input ApplyPrepaymentInput) (err error) {
+	ctx, end := p.start(ctx, "ApplyPrepayment")
+	defer func() { end(err) }()
+	return p.pp.ApplyPrepayment(ctx, input)
+}
+
+func (p *observedPrepaymentProvider) UnallocateToPrepayment(ctx context.Context, input UnallocateToPrepaymentInput) (_ *Prepayment, err error) {
+	ctx, end := p.start(ctx, "UnallocateToPrepayment")
+	defer func() { end(err) }()
+	return p.pp.UnallocateToPrepayment(ctx, input)
+}
+
+func (p *observedPrepaymentProvider) ListPrepayments(ctx context.Context, input ListPrepaymentsInput) (_ []Prepayment, err error) {
+	ctx, end := p.start(ctx, "ListPrepayments")
+	defer func() { end(err) }()
+	return p.pp.ListPrepayments(ctx, input)
+}
@@ -0,0 +1,109 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+)
+
+type recordingObserver struct {
+	started []Operation
+	ended   []OperationResult
+}
+
+type ctxKey struct{}
+
+func (r *recordingObserver) StartOperation(ctx context.Context, op Operation) (context.Context, func(OperationResult)) {
+	r.started = append(r.started, op)
+	return context.WithValue(ctx, ctxKey{}, op.Name), func(res OperationResult) { r.ended = append(r.ended, res) }
+}
+
+func TestObserver(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+
+	rec := &recordingObserver{}
+	metrics := NewMetrics()
+	var spanCtx []any
+	client := newEmulatorClient(t, Config{
+		Provider: "merit",
+		APIID:    mc.APIID,
+		APIKey:   mc.APIKey,
+		Extra:    map[string]string{"api_url": mc.APIURL},
+		Tenant:   "club-42",
+		Observer: MultiObserver(rec, metrics),
+		Resilience: &resilience.Policy{
+			MaxRetries: -1,
+			// The attempt hook sees the context returned by StartOperation.
+			OnAttempt: func(ctx context.Context, _ resilience.Attempt) { spanCtx = append(spanCtx, ctx.Value(ctxKey{})) },
+		},
+	})
+
+	if _, err := client.Taxes.List(ctx); err != nil {
+		t.Fatalf("Taxes.List: %v", err)
+	}
+	srv.InjectFault("v2/getinvoice", merittest.Fault{Status: http.StatusTooManyRequests, Body: "Too many requests"})
+	if _, err := client.Invoices.Get(ctx, "missing"); !errors.Is(err, ErrRateLimit) {
+		t.Fatalf("Invoices.Get: err = %v, want ErrRateLimit", err)
+	}
+
+	if len(rec.ended) != 2 || rec.ended[0].Name != "ListTaxes" || rec.ended[1].Name != "GetInvoice" {
+		t.Fatalf("ended = %+v, want ListTaxes then GetInvoice", rec.ended)
+	}
+	ok, failed := rec.ended[0], rec.ended[1]
+	if ok.Provider != "merit" || ok.Tenant != "club-42" || ok.Outcome != OutcomeSuccess || ok.ErrorClass != "" || ok.Duration <= 0 {
+		t.Errorf("ListTaxes result = %+v", ok)
+	}
+	if failed.Outcome != OutcomeError || failed.ErrorClass != ErrorClassRateLimit {
+		t.Errorf("GetInvoice result = %+v, want rate_limit error", failed)
+	}
+	if fmt.Sprint(spanCtx) != "[ListTaxes GetInvoice]" {
+		t.Errorf("attempt contexts = %v, want the operation contexts", spanCtx)
+	}
+
+	ops := metrics.Operations()
+	if len(ops) != 2 || ops[0].Operation != "GetInvoice" || ops[0].Errors[ErrorClassRateLimit] != 1 || ops[1].Count != 1 {
+		t.Errorf("Operations() = %+v", ops)
+	}
+	eps := metrics.Endpoints()
+	if len(eps) != 2 || eps[0].Endpoint != "v1/gettaxes" || eps[0].Tenant != "club-42" || eps[1].Statuses[429] != 1 {
+		t.Errorf("Endpoints() = %+v", eps)
+	}
+
+	// Decorating must not hide optional capabilities.
+	if !client.Prepayments.Supported() {
+		t.Error("Prepayments.Supported() = false behind the observer, want true")
+	}
+	if got := client.CircuitState(); got != resilience.StateClosed {
+		t.Errorf("CircuitState() = %s, want closed", got)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ""},
+		{&ProviderError{Provider: "merit", Op: "GetInvoice", Err: ErrNotFound}, ErrorClassNotFound},
+		{&ProviderError{Provider: "merit", Op: "GetInvoice", Err: ErrAuthFailed}, ErrorClassAuth},
+		{fmt.Errorf("merit: send request: %w", context.DeadlineExceeded), ErrorClassTimeout},
+		{fmt.Errorf("directo: %w", resilience.ErrCircuitOpen), ErrorClassCircuitOpen},
+		{errors.New("Korduv arve number"), ErrorClassProvider},
+		{&ProviderError{Provider: "merit", Op: "CreateInvoice", Err: withKind(ErrDuplicate, errors.New("Korduv arve number"))}, ErrorClassDuplicate},
+		{&ProviderError{Provider: "smartaccounts", Op: "CreateInvoice", Err: &ValidationError{Field: "clientName"}}, ErrorClassInvalidInput},
+		{&ProviderError{Provider: "directo", Op: "GetInvoicePDF", Err: ErrNotSupported}, ErrorClassUnsupported},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
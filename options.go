@@ -0,0 +1,245 @@
+package accounting
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MeritOptions are the Merit Aktiva settings of a Config.
+type MeritOptions struct {
+	APIURL string // API base URL, overriding Config.Region (Extra "api_url")
+}
+
+// DirectoOptions are the Directo settings of a Config.
+type DirectoOptions struct {
+	RESTAPIKey  string // X-Directo-Key for the REST API, which serves all reads (Extra "rest_api_key"); required
+	RESTBaseURL string // REST API base URL; empty uses the production host (Extra "rest_base_url")
+	XMLBaseURL  string // XML Direct base URL; empty uses the production host (Extra "xml_base_url")
+}
+
+// ExcellentBooksOptions are the Excellent Books settings of a Config.
+type ExcellentBooksOptions struct {
+	BaseURL     string // Server URL, e.g. "https://test.excellent.ee:3490"; the scheme defaults to https (Extra "base_url"); required
+	CompanyCode string // Company segment of the API path; empty uses "1" (Extra "company_code")
+}
+
+// SmartAccountsOptions are the SmartAccounts settings of a Config.
+type SmartAccountsOptions struct {
+	Language    string // "en" (default) or "et": the language of validation messages (Extra "language")
+	NettingBank string // Bank account name that settles credit notes against their invoice; empty disables (Extra "netting_bank")
+
+	// RatePerSecond and Burst throttle requests to stay under the
+	// per-company limits; zero uses the smartaccounts defaults and a negative
+	// RatePerSecond disables throttling. A non-zero Config.Resilience
+	// RatePerSecond takes precedence.
+	RatePerSecond int
+	Burst         int
+}
+
+// extraKeys are the Config.Extra keys each built-in provider reads, in the
+// typed-option field order.
+var extraKeys = map[string][]string{
+	"merit":          {"api_url"},
+	"directo":        {"rest_api_key", "rest_base_url", "xml_base_url"},
+	"excellentbooks": {"base_url", "company_code"},
+	"smartaccounts":  {"language", "netting_bank"},
+}
+
+// The option accessors below merge a provider's typed options with the
+// older Config.Extra keys; a typed field that is set wins.
+
+func (c Config) meritOptions() MeritOptions {
+	var o MeritOptions
+	if c.Merit != nil {
+		o = *c.Merit
+	}
+	o.APIURL = orExtra(o.APIURL, c.Extra["api_url"])
+	return o
+}
+
+func (c Config) directoOptions() DirectoOptions {
+	var o DirectoOptions
+	if c.Directo != nil {
+		o = *c.Directo
+	}
+	o.RESTAPIKey = orExtra(o.RESTAPIKey, c.Extra["rest_api_key"])
+	o.RESTBaseURL = orExtra(o.RESTBaseURL, c.Extra["rest_base_url"])
+	o.XMLBaseURL = orExtra(o.XMLBaseURL, c.Extra["xml_base_url"])
+	return o
+}
+
+func (c Config) excellentBooksOptions() ExcellentBooksOptions {
+	var o ExcellentBooksOptions
+	if c.ExcellentBooks != nil {
+		o = *c.ExcellentBooks
+	}
+	o.BaseURL = orExtra(o.BaseURL, c.Extra["base_url"])
+	o.CompanyCode = orExtra(o.CompanyCode, c.Extra["company_code"])
+	return o
+}
+
+func (c Config) smartAccountsOptions() SmartAccountsOptions {
+	var o SmartAccountsOptions
+	if c.SmartAccounts != nil {
+		o = *c.SmartAccounts
+	}
+	o.Language = orExtra(o.Language, c.Extra["language"])
+	o.NettingBank = orExtra(o.NettingBank, c.Extra["netting_bank"])
+	return o
+}
+
+// orExtra returns typed unless it is empty.
+func orExtra(typed, extra string) string {
+	if typed != "" {
+		return typed
+	}
+	return extra
+}
+
+// ConfigError reports a missing or malformed Config field. It matches
+// ErrInvalidInput.
+type ConfigError struct {
+	Field   string // e.g. "APIKey", "ExcellentBooks.BaseURL" or `Extra["compnay_code"]`
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("accounting: config %s: %s", e.Field, e.Message)
+}
+
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// Validate checks cfg for the provider it names without contacting the
+// provider: required credentials and options, malformed URLs and enums,
+// options set for another provider, and Extra keys the provider does not
+// read (usually typos). It returns every problem found, joined, each a
+// *ConfigError; nil means NewClient will build a usable client.
+//
+// Providers registered outside this package are checked for a name only.
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, &ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Provider == "" {
+		fail("Provider", "required")
+		return errors.Join(errs...)
+	}
+	if _, ok := lookupProvider(c.Provider); !ok {
+		fail("Provider", "%q is not registered (have %s)", c.Provider, strings.Join(Providers(), ", "))
+		return errors.Join(errs...)
+	}
+
+	if c.Replica != nil && c.Tenant == "" {
+		fail("Tenant", "required with Replica")
+	}
+
+	for _, o := range []struct {
+		provider, field string
+		set             bool
+	}{
+		{"merit", "Merit", c.Merit != nil},
+		{"directo", "Directo", c.Directo != nil},
+		{"excellentbooks", "ExcellentBooks", c.ExcellentBooks != nil},
+		{"smartaccounts", "SmartAccounts", c.SmartAccounts != nil},
+	} {
+		if o.set && o.provider != c.Provider {
+			fail(o.field, "set, but Provider is %q", c.Provider)
+		}
+	}
+
+	known, builtin := extraKeys[c.Provider]
+	if !builtin {
+		return errors.Join(errs...)
+	}
+	var unknown []string
+	for k := range c.Extra {
+		if !slices.Contains(known, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		fail(fmt.Sprintf("Extra[%q]", k), "not a %s setting (known: %s)", c.Provider, strings.Join(known, ", "))
+	}
+
+	switch c.Provider {
+	case "merit":
+		requireCredentials(fail, "API ID", "API key", c)
+		switch strings.ToLower(c.Region) {
+		case "", "ee", "estonia", "pl", "poland":
+		default:
+			fail("Region", "%q is not a Merit region (ee or pl)", c.Region)
+		}
+		if o := c.meritOptions(); o.APIURL != "" {
+			checkURL(fail, "Merit.APIURL", o.APIURL, true)
+		}
+	case "directo":
+		requireCredentials(fail, "company code", "XML Direct token", c)
+		o := c.directoOptions()
+		if o.RESTAPIKey == "" {
+			fail("Directo.RESTAPIKey", "required: the REST API serves all reads")
+		}
+		if o.RESTBaseURL != "" {
+			checkURL(fail, "Directo.RESTBaseURL", o.RESTBaseURL, true)
+		}
+		if o.XMLBaseURL != "" {
+			checkURL(fail, "Directo.XMLBaseURL", o.XMLBaseURL, true)
+		}
+	case "excellentbooks":
+		requireCredentials(fail, "username", "password", c)
+		if o := c.excellentBooksOptions(); o.BaseURL == "" {
+			fail("ExcellentBooks.BaseURL", "required")
+		} else {
+			checkURL(fail, "ExcellentBooks.BaseURL", o.BaseURL, false)
+		}
+	case "smartaccounts":
+		requireCredentials(fail, "public API key", "secret key", c)
+		o := c.smartAccountsOptions()
+		switch o.Language {
+		case "", "en", "et":
+		default:
+			fail("SmartAccounts.Language", "%q is not supported (en or et)", o.Language)
+		}
+		if o.Burst < 0 {
+			fail("SmartAccounts.Burst", "must not be negative")
+		}
+		if strings.Contains(c.Region, "/") {
+			fail("Region", "%q must be a host name, not a URL", c.Region)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// requireCredentials reports an empty APIID or APIKey under the names the
+// provider uses for them, unless a CredentialSource supplies them.
+func requireCredentials(fail func(field, format string, args ...any), id, key string, c Config) {
+	if c.Credentials != nil {
+		return // resolved when the client is built
+	}
+	if c.APIID == "" {
+		fail("APIID", "required (%s)", id)
+	}
+	if c.APIKey == "" {
+		fail("APIKey", "required (%s)", key)
+	}
+}
+
+// checkURL reports a value that is not an http(s) URL. Without
+// needScheme a bare host ("test.excellent.ee:3490") is accepted.
+func checkURL(fail func(field, format string, args ...any), field, raw string, needScheme bool) {
+	if !needScheme && !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail(field, "%q is not an http(s) URL", raw)
+	}
+}
@@ -0,0 +1,100 @@
+package accounting
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		fields []string // ConfigError fields, in order; none means valid
+	}{
+		{
+			name: "merit",
+			cfg:  Config{Provider: "merit", APIID: "id", APIKey: "key", Region: "pl"},
+		},
+		{
+			name:   "no provider",
+			cfg:    Config{},
+			fields: []string{"Provider"},
+		},
+		{
+			name:   "unknown provider",
+			cfg:    Config{Provider: "simplbooks", APIID: "id", APIKey: "key"},
+			fields: []string{"Provider"},
+		},
+		{
+			name:   "merit bad region and URL",
+			cfg:    Config{Provider: "merit", APIID: "id", APIKey: "key", Region: "lv", Merit: &MeritOptions{APIURL: "aktiva.merit.ee/api"}},
+			fields: []string{"Region", "Merit.APIURL"},
+		},
+		{
+			name: "directo via Extra",
+			cfg: Config{Provider: "directo", APIID: "club", APIKey: "token",
+				Extra: map[string]string{"rest_api_key": "rest"}},
+		},
+		{
+			name:   "directo missing REST key",
+			cfg:    Config{Provider: "directo", APIID: "club", APIKey: "token"},
+			fields: []string{"Directo.RESTAPIKey"},
+		},
+		{
+			name: "excellentbooks typo in Extra",
+			cfg: Config{Provider: "excellentbooks", APIID: "user", APIKey: "pass",
+				Extra: map[string]string{"base_url": "test.excellent.ee:3490", "compnay_code": "2"}},
+			fields: []string{`Extra["compnay_code"]`},
+		},
+		{
+			name:   "excellentbooks missing everything",
+			cfg:    Config{Provider: "excellentbooks"},
+			fields: []string{"APIID", "APIKey", "ExcellentBooks.BaseURL"},
+		},
+		{
+			name: "smartaccounts options",
+			cfg: Config{Provider: "smartaccounts", APIID: "pub", APIKey: "secret",
+				SmartAccounts: &SmartAccountsOptions{Language: "et", NettingBank: "Tasaarveldus", RatePerSecond: 2, Burst: 5}},
+		},
+		{
+			name: "smartaccounts wrong options",
+			cfg: Config{Provider: "smartaccounts", APIID: "pub", APIKey: "secret",
+				Directo: &DirectoOptions{}, SmartAccounts: &SmartAccountsOptions{Language: "ru"}},
+			fields: []string{"Directo", "SmartAccounts.Language"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			var got []string
+			if err != nil {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("err = %v, want ErrInvalidInput", err)
+				}
+				for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+					var ce *ConfigError
+					if !errors.As(e, &ce) {
+						t.Fatalf("%v is not a *ConfigError", e)
+					}
+					got = append(got, ce.Field)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("Validate() fields = %v, want %v (err: %v)", got, tt.fields, err)
+			}
+		})
+	}
+}
+
+func TestConfigOptionsOverrideExtra(t *testing.T) {
+	cfg := Config{
+		Provider:       "excellentbooks",
+		Extra:          map[string]string{"base_url": "https://old.example", "company_code": "2"},
+		ExcellentBooks: &ExcellentBooksOptions{BaseURL: "https://new.example"},
+	}
+	o := cfg.excellentBooksOptions()
+	if o.BaseURL != "https://new.example" || o.CompanyCode != "2" {
+		t.Errorf("options = %+v, want the typed BaseURL and Extra company code", o)
+	}
+}
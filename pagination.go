@@ -0,0 +1,126 @@
+package accounting
+
+import (
+	"iter"
+	"time"
+)
+
+// flatten yields the records of pages one by one.
+func flatten[T any](pages iter.Seq2[[]T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page, err := range pages {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, v := range page {
+				if !yield(v, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// streamPageSize is the number of records a page of streamPages holds.
+const streamPageSize = 200
+
+// streamPages groups the records of a provider client's stream, converted
+// with conv, into pages of streamPageSize, passing errors through wrap.
+// Pages are yielded while the response is still arriving, so a large
+// register is never held in memory as a whole. Time the caller spends on
+// a page does not count against the response's attempt timeout, which
+// only runs while the stream is read.
+func streamPages[S, T any](records iter.Seq2[S, error], conv func(*S) T, wrap func(error) error) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		page := make([]T, 0, streamPageSize)
+		for rec, err := range records {
+			if err != nil {
+				yield(nil, wrap(err))
+				return
+			}
+			page = append(page, conv(&rec))
+			if len(page) == streamPageSize {
+				if !yield(page, nil) {
+					return
+				}
+				page = make([]T, 0, streamPageSize)
+			}
+		}
+		if len(page) > 0 {
+			yield(page, nil)
+		}
+	}
+}
+
+// onePage presents a List call as a single page.
+func onePage[T any](list func() ([]T, error)) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		page, err := list()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if len(page) > 0 {
+			yield(page, nil)
+		}
+	}
+}
+
+// mapPages converts each page of a provider client's records with conv,
+// passing errors through wrap.
+func mapPages[S, T any](pages iter.Seq2[[]S, error], conv func(*S) T, wrap func(error) error) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		for page, err := range pages {
+			if err != nil {
+				yield(nil, wrap(err))
+				return
+			}
+			out := make([]T, len(page))
+			for i := range page {
+				out[i] = conv(&page[i])
+			}
+			if !yield(out, nil) {
+				return
+			}
+		}
+	}
+}
+
+// collectPages gathers every page into one slice, for List methods built
+// on their *Pages method.
+func collectPages[T any](pages iter.Seq2[[]T, error]) ([]T, error) {
+	var all []T
+	for page, err := range pages {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+	}
+	if all == nil {
+		all = []T{}
+	}
+	return all, nil
+}
+
+// dateWindows splits the days from start to end, both inclusive, into
+// consecutive windows of months months each, the last one possibly
+// shorter. A zero end means today.
+func dateWindows(start, end time.Time, months int) iter.Seq2[time.Time, time.Time] {
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return func(yield func(time.Time, time.Time) bool) {
+		for from := start; !from.After(end); {
+			to := from.AddDate(0, months, -1)
+			if to.After(end) {
+				to = end
+			}
+			if !yield(from, to) {
+				return
+			}
+			from = to.AddDate(0, 0, 1)
+		}
+	}
+}
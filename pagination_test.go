@@ -0,0 +1,235 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/excellentbooks/ebtest"
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/qbitsoftware/accounting-service/smartaccounts/satest"
+)
+
+func TestAll_ExcellentBooks(t *testing.T) {
+	ctx := context.Background()
+	srv := ebtest.NewServer()
+	defer srv.Close()
+	ec := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "excellentbooks",
+		APIID:    ec.Username,
+		APIKey:   ec.Password,
+		ExcellentBooks: &ExcellentBooksOptions{
+			BaseURL:     ec.BaseURL,
+			CompanyCode: ec.CompanyCode,
+		},
+	})
+
+	var want []string
+	for i := range 5 {
+		code := fmt.Sprintf("C%d", i+1)
+		if _, err := client.Customers.Create(ctx, CreateCustomerInput{Code: code, Name: "Customer " + code}); err != nil {
+			t.Fatalf("Customers.Create: %v", err)
+		}
+		want = append(want, code)
+	}
+
+	var got []string
+	for c, err := range client.Customers.All(ctx, ListCustomersInput{}) {
+		if err != nil {
+			t.Fatalf("Customers.All: %v", err)
+		}
+		got = append(got, c.ID)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Customers.All = %v, want %v", got, want)
+	}
+
+	srv.InjectFault("GET CUVc", ebtest.ErrorPayload("5", "", "Server busy"))
+	for _, err := range client.Customers.All(ctx, ListCustomersInput{}) {
+		var pe *ProviderError
+		if !errors.As(err, &pe) || pe.Op != "ListCustomers" {
+			t.Errorf("failed page: err = %v, want a ListCustomers ProviderError", err)
+		}
+	}
+}
+
+func TestAll_SmartAccountsPages(t *testing.T) {
+	ctx := context.Background()
+	srv := satest.NewServer()
+	defer srv.Close()
+	srv.PageSize = 2
+	sc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider:   "smartaccounts",
+		APIID:      sc.APIKey,
+		APIKey:     sc.SecretKey,
+		Region:     sc.Host,
+		HTTPClient: sc.HTTPClient,
+		Resilience: &resilience.Policy{RatePerSecond: 1000},
+	})
+
+	for i := range 5 {
+		if _, err := client.Customers.Create(ctx, CreateCustomerInput{Name: fmt.Sprintf("Customer %d", i+1)}); err != nil {
+			t.Fatalf("Customers.Create: %v", err)
+		}
+	}
+
+	n := 0
+	for _, err := range client.Customers.All(ctx, ListCustomersInput{}) {
+		if err != nil {
+			t.Fatalf("Customers.All: %v", err)
+		}
+		n++
+	}
+	if n != 5 {
+		t.Errorf("Customers.All yielded %d customers, want 5", n)
+	}
+
+	before := countRequests(srv.Requests(), "purchasesales/clients:get")
+	for range client.Customers.All(ctx, ListCustomersInput{}) {
+		break
+	}
+	if n := countRequests(srv.Requests(), "purchasesales/clients:get") - before; n != 1 {
+		t.Errorf("stopping after the first customer fetched %d pages, want 1", n)
+	}
+}
+
+func TestAll_MeritPeriods(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "merit",
+		APIID:    mc.APIID,
+		APIKey:   mc.APIKey,
+		Merit:    &MeritOptions{APIURL: mc.APIURL},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	start := time.Date(e2eDocDate.Year(), e2eDocDate.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -5, 0)
+	var want []string
+	for i := range 6 {
+		in := e2eInvoice(cust.ID, cust.Name, fmt.Sprintf("M-%d", i+1), srv.TaxID(22))
+		in.DocDate = start.AddDate(0, i, 14)
+		in.DueDate = in.DocDate.AddDate(0, 0, 14)
+		if _, err := client.Invoices.Create(ctx, in); err != nil {
+			t.Fatalf("Invoices.Create: %v", err)
+		}
+		want = append(want, in.InvoiceNo)
+	}
+
+	before := countRequests(srv.Requests(), "v2/getinvoices")
+	var got []string
+	for inv, err := range client.Invoices.All(ctx, ListInvoicesInput{PeriodStart: start}) {
+		if err != nil {
+			t.Fatalf("Invoices.All: %v", err)
+		}
+		got = append(got, inv.Number)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("Invoices.All = %v, want %v", got, want)
+	}
+	if n := countRequests(srv.Requests(), "v2/getinvoices") - before; n != 6 {
+		t.Errorf("Invoices.All made %d requests, want one per month (6)", n)
+	}
+}
+
+func TestDateWindows(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2025, m, d, 0, 0, 0, 0, time.UTC) }
+	var got [][2]time.Time
+	for from, to := range dateWindows(day(1, 15), day(3, 20), 1) {
+		got = append(got, [2]time.Time{from, to})
+	}
+	want := [][2]time.Time{
+		{day(1, 15), day(2, 14)},
+		{day(2, 15), day(3, 14)},
+		{day(3, 15), day(3, 20)},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("dateWindows = %v, want %v", got, want)
+	}
+}
+
+// TestAll_Decorated checks All passes through the observer, the replica
+// and a middleware while still paging natively.
+func TestAll_Decorated(t *testing.T) {
+	ctx := context.Background()
+	srv := merittest.NewServer()
+	defer srv.Close()
+	mc := srv.Config()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	rec := &recordingObserver{}
+	client := newEmulatorClient(t, Config{
+		Provider:   "merit",
+		APIID:      mc.APIID,
+		APIKey:     mc.APIKey,
+		Merit:      &MeritOptions{APIURL: mc.APIURL},
+		Tenant:     "club-1",
+		Observer:   rec,
+		Replica:    store,
+		Resilience: &resilience.Policy{MaxRetries: -1},
+		Middleware: func(next Provider) Provider { return Decorator{Next: next} },
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	start := time.Date(e2eDocDate.Year(), e2eDocDate.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -2, 0)
+	for i := range 3 {
+		in := e2eInvoice(cust.ID, cust.Name, fmt.Sprintf("M-%d", i+1), srv.TaxID(22))
+		in.DocDate = start.AddDate(0, i, 14)
+		in.DueDate = in.DocDate.AddDate(0, 0, 14)
+		if _, err := client.Invoices.Create(ctx, in); err != nil {
+			t.Fatalf("Invoices.Create: %v", err)
+		}
+	}
+
+	all := func() []string {
+		t.Helper()
+		var got []string
+		for inv, err := range client.Invoices.All(ctx, ListInvoicesInput{PeriodStart: start}) {
+			if err != nil {
+				t.Fatalf("Invoices.All: %v", err)
+			}
+			got = append(got, inv.Number)
+		}
+		slices.Sort(got)
+		return got
+	}
+	want := []string{"M-1", "M-2", "M-3"}
+	rec.ended = nil
+	before := countRequests(srv.Requests(), "v2/getinvoices")
+	if got := all(); !slices.Equal(got, want) {
+		t.Errorf("Invoices.All = %v, want %v", got, want)
+	}
+	if n := countRequests(srv.Requests(), "v2/getinvoices") - before; n != 3 {
+		t.Errorf("Invoices.All made %d requests, want one per month (3)", n)
+	}
+	if len(rec.ended) != 1 || rec.ended[0].Name != "InvoicePages" || rec.ended[0].Outcome != OutcomeSuccess {
+		t.Errorf("observed %+v, want one successful InvoicePages", rec.ended)
+	}
+	kept, err := store.QueryInvoices(ctx, "club-1", InvoiceQuery{})
+	if err != nil || len(kept) != 3 {
+		t.Errorf("replica holds %d invoices (%v), want 3", len(kept), err)
+	}
+
+	// A rate-limited first page is answered from the replica.
+	srv.InjectFault("v2/getinvoices", merittest.Fault{Status: 429, Body: "Too many requests"})
+	if got := all(); !slices.Equal(got, want) {
+		t.Errorf("Invoices.All while rate limited = %v, want %v from the replica", got, want)
+	}
+}
@@ -1,17 +1,44 @@
 package accounting
 
-import "context"
+import (
+	"context"
+	"iter"
+)
 
 type PaymentService struct {
-	provider Provider
+	provider   Provider
+	idempotent bool
 }
 
+// Create records a payment. When the call is idempotent — an
+// IdempotencyKey is set or the client was built with
+// Config.IdempotentCreates — it succeeds without posting again if an
+// earlier attempt already recorded the payment.
 func (s *PaymentService) Create(ctx context.Context, input CreatePaymentInput) error {
-	return s.provider.CreatePayment(ctx, input)
+	if !s.idempotent && input.IdempotencyKey == "" {
+		return s.provider.CreatePayment(ctx, input)
+	}
+	_, err := createOnce(ctx, input.IdempotencyKey != "",
+		func(ctx context.Context) (*Payment, error) { return s.provider.LocatePayment(ctx, input) },
+		func(ctx context.Context) (*Payment, error) { return nil, s.provider.CreatePayment(ctx, input) },
+		func(p *Payment) bool { return paymentMatches(p, input) },
+	)
+	return err
 }
 
 func (s *PaymentService) List(ctx context.Context, input ListPaymentsInput) ([]Payment, error) {
-	return s.provider.ListPayments(ctx, input)
+	list, err := s.provider.ListPayments(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return filterList(list, input.matches), nil
+}
+
+// All iterates over the payments matching input, fetching them a page at a
+// time where the provider supports it. Breaking out of the loop stops
+// fetching.
+func (s *PaymentService) All(ctx context.Context, input ListPaymentsInput) iter.Seq2[Payment, error] {
+	return filterSeq(flatten(s.provider.PaymentPages(ctx, input)), input.matches)
 }
 
 func (s *PaymentService) Delete(ctx context.Context, id string) error {
@@ -0,0 +1,268 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/qbitsoftware/accounting-service/smartaccounts"
+)
+
+// DefaultPoolIdleTimeout is how long a tenant's Client stays pooled without
+// use when PoolConfig.IdleTimeout is zero.
+const DefaultPoolIdleTimeout = 30 * time.Minute
+
+// PoolConfig configures a ClientPool.
+type PoolConfig struct {
+	// Load returns the Config for a tenant, e.g. from the tenant's stored
+	// credentials. It is called when the tenant is first used, and again
+	// after Invalidate or eviction. Required.
+	Load func(ctx context.Context, tenant string) (Config, error)
+
+	// HTTPClient is shared by the Clients of tenants whose Config has no
+	// HTTPClient of its own. Nil uses a client over a pooled transport
+	// sized for many tenants calling the same provider hosts.
+	HTTPClient *http.Client
+
+	// IdleTimeout is how long a tenant may go unused before its Client is
+	// dropped. Zero uses DefaultPoolIdleTimeout; negative keeps Clients
+	// until Evict.
+	IdleTimeout time.Duration
+}
+
+// ClientPool hands out one Client per tenant, building it on first use and
+// reusing it afterwards. It is safe for concurrent use.
+//
+// A tenant keeps a single request budget for as long as it is pooled: the
+// rate limiter is created with the tenant's first Client and shared by every
+// Client built after a rotation, so SmartAccounts' per-company limits (60
+// requests a minute, 1000 a day) hold across credential changes.
+//
+//	pool, err := accounting.NewClientPool(accounting.PoolConfig{
+//	    Load: func(ctx context.Context, club string) (accounting.Config, error) {
+//	        return store.AccountingConfig(ctx, club)
+//	    },
+//	})
+//	client, err := pool.Get(ctx, "club-42")
+type ClientPool struct {
+	load        func(ctx context.Context, tenant string) (Config, error)
+	httpClient  *http.Client
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	mu        sync.Mutex
+	tenants   map[string]*poolEntry
+	lastSweep time.Time
+}
+
+// poolEntry is one tenant's slot. mu serialises building its Client so
+// concurrent first calls load the Config once.
+type poolEntry struct {
+	mu       sync.Mutex
+	client   *Client
+	limiter  resilience.Limiter
+	limited  bool // limiter has been derived (it may be nil: unthrottled)
+	lastUsed time.Time
+}
+
+// NewClientPool returns an empty pool.
+func NewClientPool(cfg PoolConfig) (*ClientPool, error) {
+	if cfg.Load == nil {
+		return nil, errors.New("accounting: PoolConfig.Load is required")
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		// The default of 2 idle connections per host makes tenants of the
+		// same provider queue for connections.
+		transport.MaxIdleConnsPerHost = 32
+		httpClient = &http.Client{Transport: transport}
+	}
+	idle := cfg.IdleTimeout
+	if idle == 0 {
+		idle = DefaultPoolIdleTimeout
+	}
+	return &ClientPool{
+		load:        cfg.Load,
+		httpClient:  httpClient,
+		idleTimeout: idle,
+		now:         time.Now,
+		tenants:     make(map[string]*poolEntry),
+	}, nil
+}
+
+// Get returns the tenant's Client, building it from PoolConfig.Load on
+// first use. Tenants idle for longer than the idle timeout are evicted as
+// a side effect.
+func (p *ClientPool) Get(ctx context.Context, tenant string) (*Client, error) {
+	e := p.entry(tenant)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client != nil {
+		return e.client, nil
+	}
+	cfg, err := p.load(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("accounting: load config for tenant %s: %w", tenant, err)
+	}
+	client, err := p.build(e, tenant, cfg)
+	if err != nil {
+		return nil, err
+	}
+	e.client = client
+	return client, nil
+}
+
+// Rotate replaces the tenant's Client with one built from cfg, e.g. after
+// the tenant changed its API key. Calls already running on the previous
+// Client finish on it; later Gets return the new one. The tenant's rate
+// limiter carries over.
+func (p *ClientPool) Rotate(tenant string, cfg Config) error {
+	e := p.entry(tenant)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	client, err := p.build(e, tenant, cfg)
+	if err != nil {
+		return err
+	}
+	e.client = client
+	return nil
+}
+
+// Invalidate drops the tenant's Client so that the next Get reloads its
+// Config. Unlike Evict it keeps the tenant's rate limiter.
+func (p *ClientPool) Invalidate(tenant string) {
+	p.mu.Lock()
+	e, ok := p.tenants[tenant]
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	e.client = nil
+	e.mu.Unlock()
+}
+
+// Evict removes the tenant from the pool, rate limiter included.
+func (p *ClientPool) Evict(tenant string) {
+	p.mu.Lock()
+	delete(p.tenants, tenant)
+	p.mu.Unlock()
+}
+
+// Len returns the number of pooled tenants.
+func (p *ClientPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tenants)
+}
+
+// entry returns the tenant's slot, creating it if needed, and marks it
+// used.
+func (p *ClientPool) entry(tenant string) *poolEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	p.sweep(now)
+	e, ok := p.tenants[tenant]
+	if !ok {
+		e = &poolEntry{}
+		p.tenants[tenant] = e
+	}
+	e.lastUsed = now
+	return e
+}
+
+// sweep evicts idle tenants, at most twice per idle timeout. p.mu is held.
+func (p *ClientPool) sweep(now time.Time) {
+	if p.idleTimeout < 0 || now.Sub(p.lastSweep) < p.idleTimeout/2 {
+		return
+	}
+	p.lastSweep = now
+	for tenant, e := range p.tenants {
+		if now.Sub(e.lastUsed) > p.idleTimeout {
+			delete(p.tenants, tenant)
+		}
+	}
+}
+
+// build creates a Client for tenant from cfg, wiring in the pool's shared
+// HTTP client and the tenant's rate limiter. e.mu is held.
+func (p *ClientPool) build(e *poolEntry, tenant string, cfg Config) (*Client, error) {
+	if cfg.Tenant == "" {
+		cfg.Tenant = tenant
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = p.httpClient
+	}
+	if !e.limited {
+		e.limiter = tenantLimiter(cfg)
+		e.limited = true
+	}
+	if e.limiter != nil {
+		var policy resilience.Policy
+		if cfg.Resilience != nil {
+			policy = *cfg.Resilience
+		}
+		policy.Limiter = e.limiter
+		cfg.Resilience = &policy
+	}
+	return NewClient(cfg)
+}
+
+// tenantLimiter returns the request budget of one tenant: the configured
+// Resilience rate, or for SmartAccounts the SmartAccounts options' rate
+// and its per-company limits. Nil leaves the tenant unthrottled.
+func tenantLimiter(cfg Config) resilience.Limiter {
+	var rps float64
+	var burst int
+	if cfg.Resilience != nil {
+		if cfg.Resilience.Limiter != nil {
+			return cfg.Resilience.Limiter
+		}
+		rps, burst = cfg.Resilience.RatePerSecond, cfg.Resilience.Burst
+	}
+	if rps < 0 {
+		return nil
+	}
+	if cfg.Provider != "smartaccounts" {
+		if rps == 0 {
+			return nil
+		}
+		return rate.NewLimiter(rate.Limit(rps), max(burst, 1))
+	}
+	if rps == 0 {
+		opts := cfg.smartAccountsOptions()
+		if opts.RatePerSecond < 0 {
+			return nil
+		}
+		rps, burst = float64(opts.RatePerSecond), opts.Burst
+	}
+	if rps == 0 {
+		rps, burst = smartaccounts.DefaultRatePerSecond, smartaccounts.DefaultBurst
+	}
+	return limiters{
+		rate.NewLimiter(rate.Limit(rps), max(burst, 1)),
+		// A bucket refilling DailyRequestLimit tokens a day keeps any
+		// 24-hour window near the daily cap.
+		rate.NewLimiter(rate.Every(24*time.Hour/smartaccounts.DailyRequestLimit), smartaccounts.DailyRequestLimit),
+	}
+}
+
+// limiters waits on each of its limiters in turn.
+type limiters []resilience.Limiter
+
+func (l limiters) Wait(ctx context.Context) error {
+	for _, lim := range l {
+		if err := lim.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/merit/merittest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+)
+
+func meritPool(t *testing.T, loads *atomic.Int32) (*ClientPool, *merittest.Server) {
+	t.Helper()
+	srv := merittest.NewServer()
+	t.Cleanup(srv.Close)
+	mc := srv.Config()
+	pool, err := NewClientPool(PoolConfig{
+		Load: func(ctx context.Context, tenant string) (Config, error) {
+			loads.Add(1)
+			return Config{
+				Provider:   "merit",
+				APIID:      mc.APIID,
+				APIKey:     mc.APIKey,
+				Extra:      map[string]string{"api_url": mc.APIURL},
+				Resilience: &resilience.Policy{RatePerSecond: 1000},
+			}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewClientPool: %v", err)
+	}
+	return pool, srv
+}
+
+func TestClientPool_Reuse(t *testing.T) {
+	ctx := context.Background()
+	var loads atomic.Int32
+	pool, _ := meritPool(t, &loads)
+
+	var wg sync.WaitGroup
+	clients := make([]*Client, 8)
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := pool.Get(ctx, "club-1")
+			if err != nil {
+				t.Errorf("Get: %v", err)
+			}
+			clients[i] = c
+		}()
+	}
+	wg.Wait()
+	for _, c := range clients[1:] {
+		if c != clients[0] {
+			t.Fatal("Get returned different clients for one tenant")
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Load called %d times, want 1", n)
+	}
+
+	other, err := pool.Get(ctx, "club-2")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if other == clients[0] {
+		t.Error("two tenants share a client")
+	}
+	if err := other.TestConnection(ctx); err != nil {
+		t.Errorf("TestConnection: %v", err)
+	}
+	if pool.Len() != 2 {
+		t.Errorf("Len = %d, want 2", pool.Len())
+	}
+}
+
+func TestClientPool_Rotate(t *testing.T) {
+	ctx := context.Background()
+	var loads atomic.Int32
+	pool, srv := meritPool(t, &loads)
+	mc := srv.Config()
+
+	old, err := pool.Get(ctx, "club-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	limiter := pool.tenants["club-1"].limiter
+	if limiter == nil {
+		t.Fatal("tenant has no limiter")
+	}
+
+	err = pool.Rotate("club-1", Config{
+		Provider:   "merit",
+		APIID:      mc.APIID,
+		APIKey:     "revoked",
+		Extra:      map[string]string{"api_url": mc.APIURL},
+		Resilience: &resilience.Policy{RatePerSecond: 50},
+	})
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	rotated, err := pool.Get(ctx, "club-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if rotated == old {
+		t.Fatal("Get returned the pre-rotation client")
+	}
+	if err := rotated.TestConnection(ctx); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("rotated TestConnection: err = %v, want ErrAuthFailed", err)
+	}
+	if err := old.TestConnection(ctx); err != nil {
+		t.Errorf("pre-rotation client: %v", err)
+	}
+	if pool.tenants["club-1"].limiter != limiter {
+		t.Error("Rotate replaced the tenant's limiter")
+	}
+
+	pool.Invalidate("club-1")
+	reloaded, err := pool.Get(ctx, "club-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := reloaded.TestConnection(ctx); err != nil {
+		t.Errorf("reloaded TestConnection: %v", err)
+	}
+	if n := loads.Load(); n != 2 {
+		t.Errorf("Load called %d times, want 2", n)
+	}
+}
+
+func TestClientPool_IdleEviction(t *testing.T) {
+	ctx := context.Background()
+	var loads atomic.Int32
+	pool, _ := meritPool(t, &loads)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+
+	if _, err := pool.Get(ctx, "club-1"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	now = now.Add(DefaultPoolIdleTimeout / 2)
+	if _, err := pool.Get(ctx, "club-2"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	now = now.Add(DefaultPoolIdleTimeout/2 + time.Minute)
+	if _, err := pool.Get(ctx, "club-2"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if _, ok := pool.tenants["club-1"]; ok {
+		t.Error("idle tenant club-1 still pooled")
+	}
+	if pool.Len() != 1 {
+		t.Errorf("Len = %d, want 1", pool.Len())
+	}
+
+	pool.Evict("club-2")
+	if pool.Len() != 0 {
+		t.Errorf("Len after Evict = %d, want 0", pool.Len())
+	}
+}
+
+func TestTenantLimiter(t *testing.T) {
+	if l := tenantLimiter(Config{Provider: "merit"}); l != nil {
+		t.Errorf("merit without a rate: limiter = %v, want none", l)
+	}
+	if l, ok := tenantLimiter(Config{Provider: "smartaccounts"}).(limiters); !ok || len(l) != 2 {
+		t.Errorf("smartaccounts: limiter = %v, want per-second and daily limits", l)
+	}
+	if l := tenantLimiter(Config{Provider: "smartaccounts", Resilience: &resilience.Policy{RatePerSecond: -1}}); l != nil {
+		t.Errorf("throttling disabled: limiter = %v, want none", l)
+	}
+}
@@ -1,37 +1,62 @@
 package accounting
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 // PrepaymentService exposes customer-prepayment (ettemaks) operations. The
 // underlying provider must implement the optional PrepaymentProvider capability;
-// if it does not, every method returns ErrUnsupportedProvider wrapped with the
-// provider name. Callers can probe support with Supported().
+// if it does not, every method returns ErrNotSupported (and, for older callers,
+// ErrUnsupportedProvider) wrapped with the provider name. Callers can probe support with Supported().
 type PrepaymentService struct {
 	provider     Provider
 	providerName string
+	idempotent   bool
 }
 
 // Supported reports whether the configured provider implements prepayments.
 func (s *PrepaymentService) Supported() bool {
-	_, ok := s.provider.(PrepaymentProvider)
+	_, ok := s.prepayments()
 	return ok
 }
 
-func (s *PrepaymentService) capable(op string) (PrepaymentProvider, error) {
+// prepayments returns the provider as a PrepaymentProvider when both it
+// and the adapter beneath its decorators implement one: a Decorator
+// always has the methods, whether or not what it wraps does.
+func (s *PrepaymentService) prepayments() (PrepaymentProvider, bool) {
 	pp, ok := s.provider.(PrepaymentProvider)
+	if _, base := unwrapProvider(s.provider).(PrepaymentProvider); !base {
+		return nil, false
+	}
+	return pp, ok
+}
+
+func (s *PrepaymentService) capable(op string) (PrepaymentProvider, error) {
+	pp, ok := s.prepayments()
 	if !ok {
-		return nil, &ProviderError{Provider: s.providerName, Op: op, Err: ErrUnsupportedProvider}
+		return nil, &ProviderError{Provider: s.providerName, Op: op, Err: fmt.Errorf("%w: %w", ErrNotSupported, ErrUnsupportedProvider)}
 	}
 	return pp, nil
 }
 
-// Create records an unallocated customer advance.
+// Create records an unallocated customer advance. When the call is
+// idempotent — an IdempotencyKey is set or the client was built with
+// Config.IdempotentCreates — a prepayment with the same number is returned
+// instead of posting a second one.
 func (s *PrepaymentService) Create(ctx context.Context, input CreatePrepaymentInput) (*Prepayment, error) {
 	pp, err := s.capable("CreatePrepayment")
 	if err != nil {
 		return nil, err
 	}
-	return pp.CreatePrepayment(ctx, input)
+	if !s.idempotent && input.IdempotencyKey == "" {
+		return pp.CreatePrepayment(ctx, input)
+	}
+	return createOnce(ctx, input.IdempotencyKey != "",
+		func(ctx context.Context) (*Prepayment, error) { return locatePrepayment(ctx, pp, input) },
+		func(ctx context.Context) (*Prepayment, error) { return pp.CreatePrepayment(ctx, input) },
+		func(p *Prepayment) bool { return prepaymentMatches(p, input) },
+	)
 }
 
 // Apply settles (part of) an invoice from an existing prepayment.
@@ -71,6 +71,11 @@ type CreatePrepaymentInput struct {
 	// BankID is the provider payment-method code (EB PayMode); required by EB.
 	BankID  string
 	Comment string
+	// IdempotencyKey is the prepayments counterpart of
+	// CreateInvoiceInput.IdempotencyKey. No provider has a field to keep it
+	// in, so Prepayments.Create refuses it with ErrNotSupported;
+	// Config.IdempotentCreates finds an earlier prepayment by PrepaymentNo.
+	IdempotencyKey string
 }
 
 type ApplyPrepaymentInput struct {
@@ -2,18 +2,33 @@ package accounting
 
 import (
 	"context"
+	"iter"
 	"time"
 )
 
 // Provider defines the interface that accounting backends must implement.
+//
+// The *Pages methods list a page at a time for the services' All methods.
+// Each yields the records matching input in the order the List method
+// returns them, stops at the first error (wrapped as List wraps it) and
+// stops fetching when the caller stops iterating. Providers without
+// native paging yield their List result as a single page.
 type Provider interface {
 	TestConnection(ctx context.Context) error
 
 	// Invoices
 	CreateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error)
+	// LocateInvoice returns the invoice an earlier CreateInvoice of input
+	// produced, or nil when there is none; InvoiceService.Create calls it
+	// for idempotent creates. With an IdempotencyKey it looks the key up
+	// in the field CreateInvoice keeps it in, and otherwise the
+	// caller-assigned InvoiceNo. Providers with no way to tell return an
+	// error wrapping ErrNotSupported.
+	LocateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error)
 	GetInvoice(ctx context.Context, id string) (*Invoice, error)
 	GetInvoicePDF(ctx context.Context, id string, deliveryNote bool) (*InvoicePDF, error)
 	ListInvoices(ctx context.Context, input ListInvoicesInput) ([]Invoice, error)
+	InvoicePages(ctx context.Context, input ListInvoicesInput) iter.Seq2[[]Invoice, error]
 	FindInvoiceByRef(ctx context.Context, refStr string) (*Invoice, error)
 	DeleteInvoice(ctx context.Context, id string) error
 
@@ -21,6 +36,7 @@ type Provider interface {
 	CreateCustomer(ctx context.Context, input CreateCustomerInput) (*Customer, error)
 	UpdateCustomer(ctx context.Context, input UpdateCustomerInput) error
 	ListCustomers(ctx context.Context, input ListCustomersInput) ([]Customer, error)
+	CustomerPages(ctx context.Context, input ListCustomersInput) iter.Seq2[[]Customer, error]
 	FindCustomerByEmail(ctx context.Context, email string) (*Customer, error)
 	// GetCustomer fetches a single customer card by its provider-side ID/code.
 	// Supported by Excellent Books (code-keyed register); Merit/Directo return
@@ -29,12 +45,16 @@ type Provider interface {
 
 	// Payments
 	CreatePayment(ctx context.Context, input CreatePaymentInput) error
+	// LocatePayment is the payments counterpart of LocateInvoice.
+	LocatePayment(ctx context.Context, input CreatePaymentInput) (*Payment, error)
 	ListPayments(ctx context.Context, input ListPaymentsInput) ([]Payment, error)
+	PaymentPages(ctx context.Context, input ListPaymentsInput) iter.Seq2[[]Payment, error]
 	DeletePayment(ctx context.Context, id string) error
 
 	// Items
 	CreateItem(ctx context.Context, input CreateItemInput) (*Item, error)
 	ListItems(ctx context.Context, input ListItemsInput) ([]Item, error)
+	ItemPages(ctx context.Context, input ListItemsInput) iter.Seq2[[]Item, error]
 	UpdateItem(ctx context.Context, input UpdateItemInput) error
 
 	// Credit Notes
@@ -67,4 +87,16 @@ type Provider interface {
 	// Sync
 	ListInvoicesSince(ctx context.Context, since time.Time, until time.Time) ([]Invoice, error)
 	ListPaymentsSince(ctx context.Context, since time.Time, until time.Time) ([]Payment, error)
+	// ListInvoiceChanges is ListInvoicesSince with the tombstones of the
+	// invoices deleted in the window, for providers whose Capabilities
+	// report SupportsDeletionSync; for the others Deleted is empty.
+	ListInvoiceChanges(ctx context.Context, since time.Time, until time.Time) (InvoiceChanges, error)
+	ListPaymentChanges(ctx context.Context, since time.Time, until time.Time) (PaymentChanges, error)
+	// InvoiceChangesAfter and its siblings read the provider's change
+	// feed; see SyncService.InvoiceChangesAfter. Providers without one
+	// return an error wrapping ErrNotSupported.
+	InvoiceChangesAfter(ctx context.Context, cursor string, since time.Time) (InvoiceChanges, string, error)
+	PaymentChangesAfter(ctx context.Context, cursor string, since time.Time) (PaymentChanges, string, error)
+	CustomerChangesAfter(ctx context.Context, cursor string, since time.Time) (CustomerChanges, string, error)
+	ItemChangesAfter(ctx context.Context, cursor string, since time.Time) (ItemChanges, string, error)
 }
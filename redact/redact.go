@@ -0,0 +1,282 @@
+// Package redact masks personal data and credentials in request and
+// response bodies before the provider clients log them.
+//
+// A Policy names the fields to mask. Field names are compared after
+// lower-casing and dropping everything but letters and digits, and a field
+// matches when its normalised name contains one of the policy's patterns —
+// so "email" covers Merit's "Email", EB's "set_field.eMail" and Directo's
+// email="…" attribute alike.
+//
+// Usage:
+//
+//	p := redact.Default().With("comment")
+//	logger.Debug("merit api request", "body", p.Body(body))
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Mask replaces redacted values.
+const Mask = "***"
+
+// Secret returns Mask for a non-empty credential and "" for an empty one,
+// for String and Format methods of types that hold credentials.
+func Secret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return Mask
+}
+
+// DefaultFields are the field-name patterns masked by Default: contact
+// details, identifiers of people and companies, bank details, and API
+// credentials.
+var DefaultFields = []string{
+	// Personal and company data.
+	"name", "email", "phone", "mobile", "fax", "address", "addr", "street",
+	"zip", "postal", "regno", "regnr", "regcode", "vatno", "vatnr",
+	"vatnumber", "vatregno", "personalcode", "idcode", "contact", "iban",
+	"bankaccount", "swift",
+	// Credentials.
+	"apiid", "apikey", "secret", "signature", "token", "password", "key",
+	"authorization",
+}
+
+// Policy decides which fields are masked. A nil *Policy behaves like
+// Default(); a Policy with no Fields masks nothing.
+type Policy struct {
+	// Fields are normalised name patterns (lower-case letters and digits);
+	// a field is masked when its normalised name contains any of them.
+	Fields []string
+}
+
+// Default returns a Policy masking DefaultFields.
+func Default() *Policy {
+	return &Policy{Fields: slices.Clone(DefaultFields)}
+}
+
+// With returns a copy of p that also masks the given patterns.
+func (p *Policy) With(fields ...string) *Policy {
+	out := &Policy{Fields: slices.Clone(p.fields())}
+	for _, f := range fields {
+		out.Fields = append(out.Fields, normalize(f))
+	}
+	return out
+}
+
+func (p *Policy) fields() []string {
+	if p == nil {
+		return DefaultFields
+	}
+	return p.Fields
+}
+
+// Match reports whether values of the named field are masked.
+func (p *Policy) Match(field string) bool {
+	n := normalize(field)
+	if n == "" {
+		return false
+	}
+	for _, f := range p.fields() {
+		if f != "" && strings.Contains(n, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Body masks a request or response body, detecting JSON, XML and
+// form-encoded content from its first bytes. Other content — e.g. a plain
+// text error message — is returned unchanged.
+func (p *Policy) Body(b []byte) string {
+	t := bytes.TrimSpace(b)
+	if len(t) == 0 {
+		return string(b)
+	}
+	switch t[0] {
+	case '{', '[':
+		return p.JSON(t)
+	case '<':
+		return p.XML(string(t))
+	}
+	if looksLikeForm(t) {
+		return p.Form(string(t))
+	}
+	return string(b)
+}
+
+// JSON masks matching object members at any depth. Bodies that are not
+// valid JSON are replaced by a length placeholder, since a truncated body
+// cannot be masked reliably.
+func (p *Policy) JSON(b []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return unparseable("JSON", len(b))
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(p.maskJSON(v)); err != nil {
+		return unparseable("JSON", len(b))
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+func (p *Policy) maskJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if p.Match(k) {
+				v[k] = Mask
+			} else {
+				v[k] = p.maskJSON(child)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = p.maskJSON(child)
+		}
+	}
+	return v
+}
+
+// XML masks matching attributes and the text content of matching elements.
+// Bodies that fail to tokenize are replaced by a length placeholder.
+func (p *Policy) XML(s string) string {
+	dec := xml.NewDecoder(strings.NewReader(s))
+	dec.Strict = false
+	var out strings.Builder
+	var stack []bool // whether each open element's text is masked
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			return out.String()
+		}
+		if err != nil {
+			return unparseable("XML", len(s))
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			masked := p.Match(t.Name.Local) || (len(stack) > 0 && stack[len(stack)-1])
+			stack = append(stack, masked)
+			out.WriteString("<" + qname(t.Name))
+			for _, a := range t.Attr {
+				val := a.Value
+				if p.Match(a.Name.Local) {
+					val = Mask
+				}
+				out.WriteString(" " + qname(a.Name) + `="`)
+				xml.EscapeText(&out, []byte(val))
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			out.WriteString("</" + qname(t.Name) + ">")
+		case xml.CharData:
+			if len(stack) > 0 && stack[len(stack)-1] && len(bytes.TrimSpace(t)) > 0 {
+				out.WriteString(Mask)
+			} else {
+				xml.EscapeText(&out, t)
+			}
+		case xml.ProcInst:
+			out.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+		case xml.Directive:
+			out.WriteString("<!" + string(t) + ">")
+		case xml.Comment:
+			// Comments are dropped; they carry nothing worth logging.
+		}
+	}
+}
+
+func qname(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// Form masks matching keys of a URL-encoded form or query string.
+func (p *Policy) Form(s string) string {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return unparseable("form", len(s))
+	}
+	return p.Values(values).Encode()
+}
+
+// Values returns a copy of v with matching keys masked.
+func (p *Policy) Values(v url.Values) url.Values {
+	out := make(url.Values, len(v))
+	for k, vals := range v {
+		if p.Match(k) {
+			out[k] = []string{Mask}
+			continue
+		}
+		out[k] = slices.Clone(vals)
+	}
+	return out
+}
+
+// Map returns a copy of a flat field map with matching keys masked.
+func (p *Policy) Map(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if p.Match(k) {
+			v = Mask
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// URL masks matching query parameters and any userinfo password.
+func (p *Policy) URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return unparseable("URL", len(raw))
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = p.Form(u.RawQuery)
+	}
+	return u.Redacted()
+}
+
+func looksLikeForm(b []byte) bool {
+	if !bytes.Contains(b, []byte("=")) {
+		return false
+	}
+	for _, c := range b {
+		if c == ' ' || c == '\n' || c == '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func unparseable(kind string, n int) string {
+	return fmt.Sprintf("[unparseable %s, %d bytes]", kind, n)
+}
@@ -0,0 +1,108 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	p := Default()
+	for _, field := range []string{"Name", "CustomerName", "set_field.eMail", "RegNo", "vatNumber", "regCode", "ApiId", "signature", "token", "X-Directo-Key", "BankAccount"} {
+		if !p.Match(field) {
+			t.Errorf("Match(%q) = false, want true", field)
+		}
+	}
+	for _, field := range []string{"InvoiceNo", "TotalAmount", "what", "timestamp", "Currency", ""} {
+		if p.Match(field) {
+			t.Errorf("Match(%q) = true, want false", field)
+		}
+	}
+	if (&Policy{}).Match("Email") {
+		t.Error("empty Policy masks Email, want nothing masked")
+	}
+	var nilPolicy *Policy
+	if !nilPolicy.Match("Email") {
+		t.Error("nil Policy does not mask Email, want Default behaviour")
+	}
+	if !Default().With("Comment").Match("HComment") {
+		t.Error("With(Comment) does not mask HComment")
+	}
+}
+
+func TestBody(t *testing.T) {
+	p := Default()
+	tests := []struct {
+		name     string
+		in       string
+		want     []string
+		withheld []string
+	}{
+		{
+			name:     "json",
+			in:       `{"Customer":{"Name":"Acme OÜ","Email":"a@acme.ee","RegNo":"123"},"Rows":[{"Amount":12.50}],"InvoiceNo":"A-1"}`,
+			want:     []string{`"InvoiceNo":"A-1"`, `"Amount":12.50`, `"Name":"***"`},
+			withheld: []string{"Acme", "a@acme.ee", "123"},
+		},
+		{
+			name:     "xml",
+			in:       `<?xml version="1.0"?><customers><customer code="C1" name="Acme &amp; Co" email="a@acme.ee"><address>Tartu mnt 1</address></customer></customers>`,
+			want:     []string{`code="C1"`, `name="***"`, `<address>***</address>`},
+			withheld: []string{"Acme", "a@acme.ee", "Tartu"},
+		},
+		{
+			name:     "form",
+			in:       "set_field.Code=C1&set_field.Name=Acme%20O%C3%9C&set_field.eMail=a%40acme.ee",
+			want:     []string{"set_field.Code=C1"},
+			withheld: []string{"Acme", "acme.ee"},
+		},
+		{
+			name: "plain text",
+			in:   "Korduv arve number A-1",
+			want: []string{"Korduv arve number A-1"},
+		},
+		{
+			name:     "truncated json",
+			in:       `{"Name":"Acme OÜ"`,
+			want:     []string{"unparseable JSON"},
+			withheld: []string{"Acme"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Body([]byte(tt.in))
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("Body() = %s, want it to contain %s", got, w)
+				}
+			}
+			for _, w := range tt.withheld {
+				if strings.Contains(got, w) {
+					t.Errorf("Body() = %s, leaks %s", got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	got := Default().URL("https://user:pw@example.com/xml?get=1&what=customer&token=s3cret")
+	for _, leak := range []string{"s3cret", "pw@"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("URL() = %s, leaks %s", got, leak)
+		}
+	}
+	if !strings.Contains(got, "what=customer") {
+		t.Errorf("URL() = %s, want routing params kept", got)
+	}
+}
+
+func TestMap(t *testing.T) {
+	in := map[string]string{"set_field.Name": "Acme", "set_field.Code": "C1"}
+	got := Default().Map(in)
+	if got["set_field.Name"] != Mask || got["set_field.Code"] != "C1" {
+		t.Errorf("Map() = %v", got)
+	}
+	if in["set_field.Name"] != "Acme" {
+		t.Error("Map() modified its input")
+	}
+}
@@ -0,0 +1,84 @@
+package accounting
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ProviderFactory builds a Provider from the caller's Config. It is invoked
+// by NewClient once per Client; returning an error aborts client creation.
+type ProviderFactory func(cfg Config) (Provider, error)
+
+type registration struct {
+	factory      ProviderFactory
+	capabilities Capabilities
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]registration{}
+)
+
+// RegisterProvider makes a provider available to NewClient and
+// ProviderCapabilities under the given name. The four built-in adapters
+// register themselves from init; in-house or test backends can do the same
+// without forking the package.
+//
+// Capabilities registered with the Supports* flags only get their
+// Operations filled in from those flags, without the idempotent-create
+// lookups and change feeds, which have none; set Operations to advertise
+// them.
+//
+// Like database/sql.Register, it panics if name is empty, factory is nil, or
+// the name is already registered — these are programming errors that should
+// surface at start-up rather than as a misrouted Client later.
+func RegisterProvider(name string, factory ProviderFactory, capabilities Capabilities) {
+	if name == "" {
+		panic("accounting: RegisterProvider with empty name")
+	}
+	if factory == nil {
+		panic("accounting: RegisterProvider factory is nil for " + name)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("accounting: RegisterProvider called twice for " + name)
+	}
+	registry[name] = registration{factory: factory, capabilities: capabilities.withOperations()}
+}
+
+// Providers returns the names of all registered providers, sorted.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupProvider(name string) (registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	reg, ok := registry[name]
+	return reg, ok
+}
+
+// newProvider builds the Provider registered under cfg.Provider.
+func newProvider(cfg Config) (Provider, error) {
+	reg, ok := lookupProvider(cfg.Provider)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedProvider, cfg.Provider)
+	}
+	p, err := reg.factory(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("accounting: provider %s factory returned nil", cfg.Provider)
+	}
+	return p, nil
+}
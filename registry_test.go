@@ -0,0 +1,76 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubProvider satisfies Provider by embedding the interface; only the
+// methods a test exercises need real bodies.
+type stubProvider struct {
+	Provider
+	connErr error
+}
+
+func (s *stubProvider) TestConnection(context.Context) error { return s.connErr }
+
+func TestRegisterProvider_NewClientUsesFactory(t *testing.T) {
+	wantErr := errors.New("stub connection")
+	var gotCfg Config
+	RegisterProvider("registry-test-stub", func(cfg Config) (Provider, error) {
+		gotCfg = cfg
+		return &stubProvider{connErr: wantErr}, nil
+	}, Capabilities{SupportsInvoicePDF: true})
+
+	client, err := NewClient(Config{Provider: "registry-test-stub", APIID: "id"})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if gotCfg.APIID != "id" {
+		t.Errorf("factory got APIID %q, want %q", gotCfg.APIID, "id")
+	}
+	if err := client.TestConnection(context.Background()); !errors.Is(err, wantErr) {
+		t.Errorf("TestConnection = %v, want %v", err, wantErr)
+	}
+	if !client.Capabilities().SupportsInvoicePDF {
+		t.Error("Capabilities() did not return the registered capability set")
+	}
+	if !ProviderCapabilities("registry-test-stub").SupportsInvoicePDF {
+		t.Error("ProviderCapabilities did not return the registered capability set")
+	}
+}
+
+func TestRegisterProvider_BuiltinsRegistered(t *testing.T) {
+	registered := map[string]bool{}
+	for _, name := range Providers() {
+		registered[name] = true
+	}
+	for _, name := range []string{"merit", "directo", "excellentbooks", "smartaccounts"} {
+		if !registered[name] {
+			t.Errorf("built-in provider %q not registered", name)
+		}
+	}
+	if !ProviderCapabilities("excellentbooks").SupportsFindInvoiceByRef {
+		t.Error("excellentbooks capabilities lost in registration")
+	}
+}
+
+func TestRegisterProvider_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	RegisterProvider("merit", func(Config) (Provider, error) { return nil, nil }, Capabilities{})
+}
+
+func TestNewClient_UnknownProvider(t *testing.T) {
+	_, err := NewClient(Config{Provider: "no-such-provider"})
+	if !errors.Is(err, ErrUnsupportedProvider) {
+		t.Errorf("err = %v, want ErrUnsupportedProvider", err)
+	}
+	if caps := ProviderCapabilities("no-such-provider"); caps != (Capabilities{}) {
+		t.Errorf("unknown provider caps = %+v, want zero value", caps)
+	}
+}
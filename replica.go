@@ -0,0 +1,175 @@
+package accounting
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Store is a local replica of the provider data of many tenants: what
+// dashboards and search pages read instead of calling a provider with a
+// tight quota (SmartAccounts allows 1000 requests a day). A SyncEngine with
+// SyncEngineConfig.Replica keeps it current, and a Client with
+// Config.Replica writes what it reads into it and reads from it when the
+// provider cannot answer.
+//
+// Records are keyed by their provider ID; prepayments by DocID, or Number
+// when there is none. Putting a record replaces the stored version,
+// except that an invoice put without Lines or Payments, as list calls
+// return them, keeps the ones stored before. Implementations must be safe
+// for concurrent use; FileStore is the embedded default.
+type Store interface {
+	PutInvoices(ctx context.Context, tenant string, invoices []Invoice) error
+	PutPayments(ctx context.Context, tenant string, payments []Payment) error
+	PutCustomers(ctx context.Context, tenant string, customers []Customer) error
+	PutItems(ctx context.Context, tenant string, items []Item) error
+	PutPrepayments(ctx context.Context, tenant string, prepayments []Prepayment) error
+	// Delete removes the records the tombstones name.
+	Delete(ctx context.Context, tenant string, deleted []Tombstone) error
+
+	// GetInvoice and GetCustomer return ErrNotFound for a record the
+	// replica does not hold.
+	GetInvoice(ctx context.Context, tenant, id string) (*Invoice, error)
+	GetCustomer(ctx context.Context, tenant, id string) (*Customer, error)
+	QueryInvoices(ctx context.Context, tenant string, q InvoiceQuery) ([]Invoice, error)
+	QueryPayments(ctx context.Context, tenant string, q PaymentQuery) ([]Payment, error)
+	QueryCustomers(ctx context.Context, tenant string, q CustomerQuery) ([]Customer, error)
+	QueryItems(ctx context.Context, tenant string, q ItemQuery) ([]Item, error)
+	QueryPrepayments(ctx context.Context, tenant string, q PrepaymentQuery) ([]Prepayment, error)
+}
+
+// Queries keep the records that meet every condition set; zero fields
+// are ignored. Text keeps the records in which every word of Text begins
+// a word of the searched fields, ignoring case: "acm inv-1" finds Acme
+// OÜ's INV-10. Time ranges are inclusive. A positive Limit returns at most
+// that many records.
+
+// InvoiceQuery selects invoices, ordered by document date and number.
+// Text searches the number, customer name, reference and line
+// descriptions.
+type InvoiceQuery struct {
+	CustomerID  string
+	Status      InvoiceStatus
+	UnpaidOnly  bool
+	ReferenceNo string
+	DocFrom     time.Time
+	DocTo       time.Time
+	DueFrom     time.Time
+	DueTo       time.Time
+	Text        string
+	Limit       int
+}
+
+// PaymentQuery selects payments, ordered by date. Text searches the
+// document number, counterpart name and the numbers of the invoices paid.
+type PaymentQuery struct {
+	CounterPartID string
+	// InvoiceID keeps the payments linked to the invoice with that ID.
+	InvoiceID string
+	Direction PaymentDirection
+	From      time.Time
+	To        time.Time
+	Text      string
+	Limit     int
+}
+
+// CustomerQuery selects customers, ordered by name. Text searches the
+// name, registry and VAT numbers and e-mail.
+type CustomerQuery struct {
+	RegNo string
+	Email string // case-insensitive
+	Text  string
+	Limit int
+}
+
+// ItemQuery selects items, ordered by code. Text searches the code, name
+// and description.
+type ItemQuery struct {
+	Code  string
+	Type  ItemType
+	Text  string
+	Limit int
+}
+
+// PrepaymentQuery selects prepayments, ordered by date. Text searches the
+// number and comment.
+type PrepaymentQuery struct {
+	CustomerCode string
+	// OpenOnly keeps the prepayments with something left to apply.
+	OpenOnly bool
+	From     time.Time
+	To       time.Time
+	Text     string
+	Limit    int
+}
+
+// inRange reports whether t is within [from, to], either end open when
+// zero.
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+}
+
+func (q InvoiceQuery) matches(inv *Invoice) bool {
+	return (q.CustomerID == "" || inv.CustomerID == q.CustomerID) &&
+		(q.Status == "" || inv.Status == q.Status) &&
+		(!q.UnpaidOnly || inv.Status != InvoiceStatusPaid) &&
+		(q.ReferenceNo == "" || inv.ReferenceNo == q.ReferenceNo) &&
+		inRange(inv.DocDate, q.DocFrom, q.DocTo) &&
+		inRange(inv.DueDate, q.DueFrom, q.DueTo)
+}
+
+func (q PaymentQuery) matches(p *Payment) bool {
+	return (q.CounterPartID == "" || p.CounterPartID == q.CounterPartID) &&
+		(q.InvoiceID == "" || paysInvoice(p, q.InvoiceID)) &&
+		(q.Direction == "" || p.Direction == q.Direction) &&
+		inRange(p.DocumentDate, q.From, q.To)
+}
+
+func paysInvoice(p *Payment, invoiceID string) bool {
+	for _, l := range p.InvoiceLinks {
+		if l.InvoiceID == invoiceID {
+			return true
+		}
+	}
+	return false
+}
+
+func (q CustomerQuery) matches(c *Customer) bool {
+	return (q.RegNo == "" || strings.TrimSpace(c.RegNo) == strings.TrimSpace(q.RegNo)) &&
+		(q.Email == "" || strings.EqualFold(strings.TrimSpace(c.Email), strings.TrimSpace(q.Email)))
+}
+
+func (q ItemQuery) matches(it *Item) bool {
+	return (q.Code == "" || it.Code == q.Code) &&
+		(q.Type == "" || it.Type == q.Type)
+}
+
+func (q PrepaymentQuery) matches(p *Prepayment) bool {
+	return (q.CustomerCode == "" || p.CustomerCode == q.CustomerCode) &&
+		(!q.OpenOnly || p.Remaining.IsPositive()) &&
+		inRange(p.Date, q.From, q.To)
+}
+
+// The sync results write themselves to a replica.
+
+func (c InvoiceChanges) replicate(ctx context.Context, s Store, tenant string) error {
+	if err := s.PutInvoices(ctx, tenant, c.Invoices); err != nil {
+		return err
+	}
+	return s.Delete(ctx, tenant, c.Deleted)
+}
+
+func (c PaymentChanges) replicate(ctx context.Context, s Store, tenant string) error {
+	if err := s.PutPayments(ctx, tenant, c.Payments); err != nil {
+		return err
+	}
+	return s.Delete(ctx, tenant, c.Deleted)
+}
+
+func (c CustomerChanges) replicate(ctx context.Context, s Store, tenant string) error {
+	return s.PutCustomers(ctx, tenant, c.Customers)
+}
+
+func (c ItemChanges) replicate(ctx context.Context, s Store, tenant string) error {
+	return s.PutItems(ctx, tenant, c.Items)
+}
@@ -0,0 +1,631 @@
+package accounting
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// FileStore is a Store that keeps each tenant in memory, indexed by
+// customer, status, reference, date and the words of its text fields, and
+// on disk in its directory: a JSON file of the tenant's records and a
+// journal of the writes made since. Every write appends one line to the
+// journal and syncs it, so a write costs the size of what it changes,
+// not of the tenant. Once the journal outgrows the file, the file is
+// rewritten through a temporary file and a rename, as FileCheckpointStore
+// does, and the journal emptied. It suits the few thousand records a
+// tenant has, not a ledger's worth. One process should own the directory.
+type FileStore struct {
+	dir string
+
+	mu      sync.Mutex
+	tenants map[string]*replicaTenant
+}
+
+// NewFileStore opens the replica kept in dir, creating the directory if
+// needed. Tenants are read from disk when first used.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("accounting: replica: %w", err)
+	}
+	return &FileStore{dir: dir, tenants: make(map[string]*replicaTenant)}, nil
+}
+
+// journalMinSize is the size up to which a journal is left to grow
+// before it is folded into the tenant's file, however small that is.
+const journalMinSize = 1 << 20
+
+// replicaTenant is the replica of one tenant.
+type replicaTenant struct {
+	path     string // the tenant's file
+	journal  string // the writes made since path was written
+	fileSize int64
+	logSize  int64
+
+	mu          sync.RWMutex
+	invoices    *table[Invoice]
+	payments    *table[Payment]
+	customers   *table[Customer]
+	items       *table[Item]
+	prepayments *table[Prepayment]
+}
+
+// replicaFile is the on-disk form of a tenant.
+type replicaFile struct {
+	Invoices    []Invoice    `json:"invoices,omitempty"`
+	Payments    []Payment    `json:"payments,omitempty"`
+	Customers   []Customer   `json:"customers,omitempty"`
+	Items       []Item       `json:"items,omitempty"`
+	Prepayments []Prepayment `json:"prepayments,omitempty"`
+}
+
+// replicaEntry is one write in a tenant's journal: the records it put, as
+// stored, and the ones it deleted. Replaying an entry twice is harmless.
+type replicaEntry struct {
+	replicaFile
+	Deleted []Tombstone `json:"deleted,omitempty"`
+}
+
+func newReplicaTenant(path string) *replicaTenant {
+	return &replicaTenant{
+		path:    path,
+		journal: strings.TrimSuffix(path, ".json") + ".log",
+		invoices: &table[Invoice]{
+			key: func(inv *Invoice) string { return inv.ID },
+			fields: func(inv *Invoice) []string {
+				return []string{"customer\x00" + inv.CustomerID, "status\x00" + string(inv.Status), "ref\x00" + inv.ReferenceNo}
+			},
+			text: func(inv *Invoice) []string {
+				text := []string{inv.Number, inv.CustomerName, inv.ReferenceNo}
+				for _, l := range inv.Lines {
+					text = append(text, l.Description)
+				}
+				return text
+			},
+			date: func(inv *Invoice) time.Time { return inv.DueDate },
+			order: func(a, b *Invoice) int {
+				return cmp.Or(a.DocDate.Compare(b.DocDate), compareNumbers(a.Number, b.Number), strings.Compare(a.ID, b.ID))
+			},
+		},
+		payments: &table[Payment]{
+			key: func(p *Payment) string { return p.ID },
+			fields: func(p *Payment) []string {
+				keys := []string{"counterpart\x00" + p.CounterPartID}
+				for _, l := range p.InvoiceLinks {
+					keys = append(keys, "invoice\x00"+l.InvoiceID)
+				}
+				return keys
+			},
+			text: func(p *Payment) []string {
+				text := []string{p.DocumentNo, p.CounterPartName}
+				for _, l := range p.InvoiceLinks {
+					text = append(text, l.InvoiceNo)
+				}
+				return text
+			},
+			date: func(p *Payment) time.Time { return p.DocumentDate },
+			order: func(a, b *Payment) int {
+				return cmp.Or(a.DocumentDate.Compare(b.DocumentDate), strings.Compare(a.ID, b.ID))
+			},
+		},
+		customers: &table[Customer]{
+			key: func(c *Customer) string { return c.ID },
+			fields: func(c *Customer) []string {
+				return []string{"regno\x00" + strings.TrimSpace(c.RegNo), "email\x00" + strings.ToLower(strings.TrimSpace(c.Email))}
+			},
+			text: func(c *Customer) []string { return []string{c.Name, c.RegNo, c.VATRegNo, c.Email} },
+			order: func(a, b *Customer) int {
+				return cmp.Or(strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), strings.Compare(a.ID, b.ID))
+			},
+		},
+		items: &table[Item]{
+			key:    func(it *Item) string { return it.ID },
+			fields: func(it *Item) []string { return []string{"code\x00" + it.Code, "type\x00" + string(it.Type)} },
+			text:   func(it *Item) []string { return []string{it.Code, it.Name, it.Description} },
+			order: func(a, b *Item) int {
+				return cmp.Or(strings.Compare(a.Code, b.Code), strings.Compare(a.ID, b.ID))
+			},
+		},
+		prepayments: &table[Prepayment]{
+			key:    func(p *Prepayment) string { return prepaymentKey(*p) },
+			fields: func(p *Prepayment) []string { return []string{"customer\x00" + p.CustomerCode} },
+			text:   func(p *Prepayment) []string { return []string{p.Number, p.Comment} },
+			date:   func(p *Prepayment) time.Time { return p.Date },
+			order: func(a, b *Prepayment) int {
+				return cmp.Or(a.Date.Compare(b.Date), strings.Compare(a.Number, b.Number))
+			},
+		},
+	}
+}
+
+// snapshot returns the records of the replica in their on-disk form.
+func (t *replicaTenant) snapshot() replicaFile {
+	return replicaFile{
+		Invoices:    t.invoices.all(),
+		Payments:    t.payments.all(),
+		Customers:   t.customers.all(),
+		Items:       t.items.all(),
+		Prepayments: t.prepayments.all(),
+	}
+}
+
+// apply makes the write e to the records of the replica.
+func (t *replicaTenant) apply(e replicaEntry) {
+	t.invoices.put(e.Invoices...)
+	t.payments.put(e.Payments...)
+	t.customers.put(e.Customers...)
+	t.items.put(e.Items...)
+	t.prepayments.put(e.Prepayments...)
+	for _, d := range e.Deleted {
+		switch d.Entity {
+		case SyncInvoices:
+			t.invoices.remove(d.ID)
+		case SyncPayments:
+			t.payments.remove(d.ID)
+		case SyncCustomers:
+			t.customers.remove(d.ID)
+		case SyncItems:
+			t.items.remove(d.ID)
+		}
+	}
+	t.sortDates()
+}
+
+func (t *replicaTenant) sortDates() {
+	t.invoices.sortDates()
+	t.payments.sortDates()
+	t.prepayments.sortDates()
+}
+
+// tenant returns the replica of tenant, reading it from disk on first use.
+func (s *FileStore) tenant(tenant string) (*replicaTenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tenants[tenant]; ok {
+		return t, nil
+	}
+	t := newReplicaTenant(filepath.Join(s.dir, url.PathEscape(tenant)+".json"))
+	if err := t.load(); err != nil {
+		return nil, fmt.Errorf("accounting: replica: %w", err)
+	}
+	s.tenants[tenant] = t
+	return t, nil
+}
+
+// load reads the tenant's file and replays its journal. A last line cut
+// short by a crash is dropped from the journal.
+func (t *replicaTenant) load() error {
+	b, err := os.ReadFile(t.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		var f replicaFile
+		if err := json.Unmarshal(b, &f); err != nil {
+			return fmt.Errorf("%s: %w", t.path, err)
+		}
+		t.apply(replicaEntry{replicaFile: f})
+		t.fileSize = int64(len(b))
+	}
+
+	b, err = os.ReadFile(t.journal)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	for len(b[t.logSize:]) > 0 {
+		line, _, complete := bytes.Cut(b[t.logSize:], []byte("\n"))
+		if !complete {
+			return os.Truncate(t.journal, t.logSize)
+		}
+		var e replicaEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("%s: offset %d: %w", t.journal, t.logSize, err)
+		}
+		t.apply(e)
+		t.logSize += int64(len(line)) + 1
+	}
+	return nil
+}
+
+// append adds e to the journal and syncs it. On failure the journal is
+// cut back to what it held, so a torn line is not followed by others.
+func (t *replicaTenant) append(e replicaEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(t.journal, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Truncate(t.logSize)
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	t.logSize += int64(len(b)) + 1
+	return nil
+}
+
+// compact writes the replica to the tenant's file and empties the
+// journal. A crash in between leaves a journal whose entries the file
+// already holds, which replays to the same records.
+func (t *replicaTenant) compact() error {
+	b, err := json.Marshal(t.snapshot())
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(t.path, b); err != nil {
+		return err
+	}
+	t.fileSize = int64(len(b))
+	if err := os.Truncate(t.journal, 0); err != nil {
+		return err
+	}
+	t.logSize = 0
+	return nil
+}
+
+// update journals the write that entry returns for the tenant's replica,
+// then makes it, so reads never serve a write that a restart would lose.
+// The journal is folded into the file once it outgrows it.
+func (s *FileStore) update(tenant string, entry func(t *replicaTenant) replicaEntry) error {
+	t, err := s.tenant(tenant)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := entry(t)
+	if err := t.append(e); err != nil {
+		return fmt.Errorf("accounting: replica: %w", err)
+	}
+	t.apply(e)
+	if t.logSize > max(t.fileSize, journalMinSize) {
+		if err := t.compact(); err != nil {
+			return fmt.Errorf("accounting: replica: %w", err)
+		}
+	}
+	return nil
+}
+
+// read runs f on the tenant's replica.
+func (s *FileStore) read(tenant string, f func(t *replicaTenant)) error {
+	t, err := s.tenant(tenant)
+	if err != nil {
+		return err
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	f(t)
+	return nil
+}
+
+func (s *FileStore) PutInvoices(_ context.Context, tenant string, invoices []Invoice) error {
+	if len(invoices) == 0 {
+		return nil
+	}
+	return s.update(tenant, func(t *replicaTenant) (e replicaEntry) {
+		for _, inv := range invoices {
+			if old, ok := t.invoices.rows[inv.ID]; ok {
+				if inv.Lines == nil {
+					inv.Lines = old.Lines
+				}
+				if inv.Payments == nil {
+					inv.Payments = old.Payments
+				}
+			}
+			e.Invoices = append(e.Invoices, inv)
+		}
+		return e
+	})
+}
+
+func (s *FileStore) PutPayments(_ context.Context, tenant string, payments []Payment) error {
+	if len(payments) == 0 {
+		return nil
+	}
+	return s.update(tenant, func(*replicaTenant) (e replicaEntry) { e.Payments = payments; return e })
+}
+
+func (s *FileStore) PutCustomers(_ context.Context, tenant string, customers []Customer) error {
+	if len(customers) == 0 {
+		return nil
+	}
+	return s.update(tenant, func(*replicaTenant) (e replicaEntry) { e.Customers = customers; return e })
+}
+
+func (s *FileStore) PutItems(_ context.Context, tenant string, items []Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return s.update(tenant, func(*replicaTenant) (e replicaEntry) { e.Items = items; return e })
+}
+
+func (s *FileStore) PutPrepayments(_ context.Context, tenant string, prepayments []Prepayment) error {
+	if len(prepayments) == 0 {
+		return nil
+	}
+	return s.update(tenant, func(*replicaTenant) (e replicaEntry) { e.Prepayments = prepayments; return e })
+}
+
+func (s *FileStore) Delete(_ context.Context, tenant string, deleted []Tombstone) error {
+	if len(deleted) == 0 {
+		return nil
+	}
+	return s.update(tenant, func(*replicaTenant) replicaEntry { return replicaEntry{Deleted: deleted} })
+}
+
+func (s *FileStore) GetInvoice(_ context.Context, tenant, id string) (*Invoice, error) {
+	var inv Invoice
+	var ok bool
+	if err := s.read(tenant, func(t *replicaTenant) { inv, ok = t.invoices.rows[id] }); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("accounting: replica: invoice %s: %w", id, ErrNotFound)
+	}
+	return &inv, nil
+}
+
+func (s *FileStore) GetCustomer(_ context.Context, tenant, id string) (*Customer, error) {
+	var c Customer
+	var ok bool
+	if err := s.read(tenant, func(t *replicaTenant) { c, ok = t.customers.rows[id] }); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("accounting: replica: customer %s: %w", id, ErrNotFound)
+	}
+	return &c, nil
+}
+
+func (s *FileStore) QueryInvoices(_ context.Context, tenant string, q InvoiceQuery) (out []Invoice, err error) {
+	conds := conditions("customer", q.CustomerID, "status", string(q.Status), "ref", q.ReferenceNo)
+	err = s.read(tenant, func(t *replicaTenant) {
+		out = t.invoices.query(conds, q.DueFrom, q.DueTo, q.Text, q.matches, q.Limit)
+	})
+	return out, err
+}
+
+func (s *FileStore) QueryPayments(_ context.Context, tenant string, q PaymentQuery) (out []Payment, err error) {
+	conds := conditions("counterpart", q.CounterPartID, "invoice", q.InvoiceID)
+	err = s.read(tenant, func(t *replicaTenant) {
+		out = t.payments.query(conds, q.From, q.To, q.Text, q.matches, q.Limit)
+	})
+	return out, err
+}
+
+func (s *FileStore) QueryCustomers(_ context.Context, tenant string, q CustomerQuery) (out []Customer, err error) {
+	conds := conditions("regno", strings.TrimSpace(q.RegNo), "email", strings.ToLower(strings.TrimSpace(q.Email)))
+	err = s.read(tenant, func(t *replicaTenant) {
+		out = t.customers.query(conds, time.Time{}, time.Time{}, q.Text, q.matches, q.Limit)
+	})
+	return out, err
+}
+
+func (s *FileStore) QueryItems(_ context.Context, tenant string, q ItemQuery) (out []Item, err error) {
+	conds := conditions("code", q.Code, "type", string(q.Type))
+	err = s.read(tenant, func(t *replicaTenant) {
+		out = t.items.query(conds, time.Time{}, time.Time{}, q.Text, q.matches, q.Limit)
+	})
+	return out, err
+}
+
+func (s *FileStore) QueryPrepayments(_ context.Context, tenant string, q PrepaymentQuery) (out []Prepayment, err error) {
+	conds := conditions("customer", q.CustomerCode)
+	err = s.read(tenant, func(t *replicaTenant) {
+		out = t.prepayments.query(conds, q.From, q.To, q.Text, q.matches, q.Limit)
+	})
+	return out, err
+}
+
+// conditions turns field, value pairs into index keys, skipping empty
+// values.
+func conditions(pairs ...string) []string {
+	var keys []string
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			keys = append(keys, pairs[i]+"\x00"+pairs[i+1])
+		}
+	}
+	return keys
+}
+
+// table holds one kind of record of a tenant with its indexes: an exact
+// index of the keys fields returns, a word index of text and, when date
+// is set, the records in date order. The caller locks.
+type table[T any] struct {
+	key    func(*T) string
+	fields func(*T) []string
+	text   func(*T) []string
+	date   func(*T) time.Time
+	order  func(a, b *T) int
+
+	rows   map[string]T
+	exact  map[string]set
+	words  map[string]set
+	byDate []string // record keys in date order; nil until sortDates
+}
+
+type set = map[string]struct{}
+
+func (t *table[T]) put(rows ...T) {
+	if t.rows == nil {
+		t.rows, t.exact, t.words = make(map[string]T), make(map[string]set), make(map[string]set)
+	}
+	for _, r := range rows {
+		k := t.key(&r)
+		t.remove(k)
+		t.rows[k] = r
+		for _, f := range t.fields(&r) {
+			add(t.exact, f, k)
+		}
+		for _, w := range tokenize(t.text(&r)...) {
+			add(t.words, w, k)
+		}
+	}
+	t.byDate = nil
+}
+
+func (t *table[T]) remove(k string) {
+	r, ok := t.rows[k]
+	if !ok {
+		return
+	}
+	delete(t.rows, k)
+	for _, f := range t.fields(&r) {
+		drop(t.exact, f, k)
+	}
+	for _, w := range tokenize(t.text(&r)...) {
+		drop(t.words, w, k)
+	}
+	t.byDate = nil
+}
+
+func add(index map[string]set, value, k string) {
+	if index[value] == nil {
+		index[value] = make(set)
+	}
+	index[value][k] = struct{}{}
+}
+
+func drop(index map[string]set, value, k string) {
+	delete(index[value], k)
+	if len(index[value]) == 0 {
+		delete(index, value)
+	}
+}
+
+// all returns every record, in key order so the file is stable.
+func (t *table[T]) all() []T {
+	out := make([]T, 0, len(t.rows))
+	for _, k := range slices.Sorted(maps.Keys(t.rows)) {
+		out = append(out, t.rows[k])
+	}
+	return out
+}
+
+// query returns the records that have every one of conds, fall within
+// [from, to] by date, contain the words of text and match, in order.
+func (t *table[T]) query(conds []string, from, to time.Time, text string, match func(*T) bool, limit int) []T {
+	var keys set
+	restricted := false // keys holds the candidates; otherwise every record is one
+	narrow := func(s set) {
+		if !restricted {
+			keys, restricted = s, true
+			return
+		}
+		next := make(set)
+		for k := range keys {
+			if _, ok := s[k]; ok {
+				next[k] = struct{}{}
+			}
+		}
+		keys = next
+	}
+	for _, c := range conds {
+		narrow(t.exact[c])
+	}
+	for _, w := range tokenize(text) {
+		narrow(t.prefixed(w))
+	}
+	if t.date != nil && (!from.IsZero() || !to.IsZero()) {
+		narrow(t.between(from, to))
+	}
+	if !restricted {
+		keys = make(set, len(t.rows))
+		for k := range t.rows {
+			keys[k] = struct{}{}
+		}
+	}
+
+	out := []T{}
+	for k := range keys {
+		if r := t.rows[k]; match(&r) {
+			out = append(out, r)
+		}
+	}
+	slices.SortFunc(out, func(a, b T) int { return t.order(&a, &b) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// prefixed returns the records with a word beginning with w.
+func (t *table[T]) prefixed(w string) set {
+	out := make(set)
+	for word, keys := range t.words {
+		if strings.HasPrefix(word, w) {
+			maps.Copy(out, keys)
+		}
+	}
+	return out
+}
+
+// sortDates rebuilds the date order after writes.
+func (t *table[T]) sortDates() {
+	if t.date == nil || t.byDate != nil {
+		return
+	}
+	t.byDate = slices.SortedFunc(maps.Keys(t.rows), func(a, b string) int {
+		ra, rb := t.rows[a], t.rows[b]
+		return t.date(&ra).Compare(t.date(&rb))
+	})
+}
+
+// between returns the records dated within [from, to], either end open
+// when zero.
+func (t *table[T]) between(from, to time.Time) set {
+	at := func(i int) time.Time { r := t.rows[t.byDate[i]]; return t.date(&r) }
+	lo := 0
+	if !from.IsZero() {
+		lo = sort.Search(len(t.byDate), func(i int) bool { return !at(i).Before(from) })
+	}
+	hi := len(t.byDate)
+	if !to.IsZero() {
+		hi = sort.Search(len(t.byDate), func(i int) bool { return at(i).After(to) })
+	}
+	out := make(set)
+	for _, k := range t.byDate[lo:max(lo, hi)] {
+		out[k] = struct{}{}
+	}
+	return out
+}
+
+// tokenize splits text into lower-case words of letters and digits.
+func tokenize(text ...string) []string {
+	var words []string
+	for _, s := range text {
+		words = append(words, strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+	return words
+}
@@ -0,0 +1,326 @@
+package accounting
+
+import (
+	"context"
+	"iter"
+	"log/slog"
+)
+
+// replicateProvider wraps p so that reads go through to the replica s:
+// what the provider returns is written to it, and when the provider fails
+// with a retryable error (IsRetryable: rate limit, transient failure, open
+// circuit) the replica answers instead; the services' All iterations are
+// kept page by page. Writes keep it current too: created documents are
+// put and deleted ones removed. A failed replica write is logged, never
+// returned; the call it follows succeeded.
+func replicateProvider(p Provider, s Store, tenant string, logger *slog.Logger) Provider {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	rp := &replicaProvider{Provider: p, store: s, tenant: tenant, logger: logger}
+	if pp, ok := p.(PrepaymentProvider); ok {
+		return &replicaPrepaymentProvider{replicaProvider: rp, pp: pp}
+	}
+	return rp
+}
+
+var (
+	_ Provider           = (*replicaProvider)(nil)
+	_ PrepaymentProvider = (*replicaPrepaymentProvider)(nil)
+)
+
+type replicaProvider struct {
+	Provider
+	store  Store
+	tenant string
+	logger *slog.Logger
+}
+
+type replicaPrepaymentProvider struct {
+	*replicaProvider
+	pp PrepaymentProvider
+}
+
+func (p *replicaProvider) Unwrap() Provider { return p.Provider }
+
+// keep writes what a call returned to the replica.
+func (p *replicaProvider) keep(op string, err error) {
+	if err != nil {
+		p.logger.Warn("accounting: replica write failed", "tenant", p.tenant, "op", op, "error", err)
+	}
+}
+
+// fallback answers a failed call from the replica when the failure is
+// retryable and the replica can; otherwise it returns the call's error.
+func fallback[T any](p *replicaProvider, op string, err error, read func() (T, error)) (T, error) {
+	var zero T
+	if !IsRetryable(err) {
+		return zero, err
+	}
+	out, rerr := read()
+	if rerr != nil {
+		return zero, err
+	}
+	p.logger.Warn("accounting: provider unavailable, answered from replica", "tenant", p.tenant, "op", op, "error", err)
+	return out, nil
+}
+
+// replicaPages writes each page of pages to the replica as it passes.
+// When the provider fails before the first page, the replica answers
+// instead, in one page, as fallback allows; a failure after that is
+// passed on, since the earlier pages were already yielded.
+func replicaPages[T any](p *replicaProvider, op string, pages iter.Seq2[[]T, error], put func([]T) error, read func() ([]T, error)) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		started := false
+		for page, err := range pages {
+			if err != nil && !started {
+				yield(fallback(p, op, err, read))
+				return
+			}
+			if err == nil {
+				p.keep(op, put(page))
+			}
+			started = true
+			if !yield(page, err) {
+				return
+			}
+		}
+	}
+}
+
+func (p *replicaProvider) CreateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error) {
+	inv, err := p.Provider.CreateInvoice(ctx, input)
+	if err == nil && inv != nil {
+		p.keep("CreateInvoice", p.store.PutInvoices(ctx, p.tenant, []Invoice{*inv}))
+	}
+	return inv, err
+}
+
+func (p *replicaProvider) GetInvoice(ctx context.Context, id string) (*Invoice, error) {
+	inv, err := p.Provider.GetInvoice(ctx, id)
+	if err != nil {
+		return fallback(p, "GetInvoice", err, func() (*Invoice, error) { return p.store.GetInvoice(ctx, p.tenant, id) })
+	}
+	if inv != nil {
+		p.keep("GetInvoice", p.store.PutInvoices(ctx, p.tenant, []Invoice{*inv}))
+	}
+	return inv, nil
+}
+
+func (p *replicaProvider) ListInvoices(ctx context.Context, input ListInvoicesInput) ([]Invoice, error) {
+	invoices, err := p.Provider.ListInvoices(ctx, input)
+	if err != nil {
+		return fallback(p, "ListInvoices", err, func() ([]Invoice, error) { return p.readInvoices(ctx, input) })
+	}
+	p.keep("ListInvoices", p.store.PutInvoices(ctx, p.tenant, invoices))
+	return invoices, nil
+}
+
+func (p *replicaProvider) InvoicePages(ctx context.Context, input ListInvoicesInput) iter.Seq2[[]Invoice, error] {
+	return replicaPages(p, "InvoicePages", p.Provider.InvoicePages(ctx, input),
+		func(page []Invoice) error { return p.store.PutInvoices(ctx, p.tenant, page) },
+		func() ([]Invoice, error) { return p.readInvoices(ctx, input) })
+}
+
+// readInvoices answers ListInvoices from the replica.
+func (p *replicaProvider) readInvoices(ctx context.Context, input ListInvoicesInput) ([]Invoice, error) {
+	return p.store.QueryInvoices(ctx, p.tenant, InvoiceQuery{
+		CustomerID:  input.CustomerCode,
+		Status:      input.Status,
+		UnpaidOnly:  input.UnpaidOnly,
+		ReferenceNo: input.ReferenceNo,
+		DocFrom:     input.PeriodStart,
+		DocTo:       input.PeriodEnd,
+	})
+}
+
+// LocateInvoice keeps the invoice an earlier create produced, like
+// GetInvoice.
+func (p *replicaProvider) LocateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error) {
+	inv, err := p.Provider.LocateInvoice(ctx, input)
+	if err == nil && inv != nil {
+		p.keep("LocateInvoice", p.store.PutInvoices(ctx, p.tenant, []Invoice{*inv}))
+	}
+	return inv, err
+}
+
+func (p *replicaProvider) FindInvoiceByRef(ctx context.Context, refStr string) (*Invoice, error) {
+	inv, err := p.Provider.FindInvoiceByRef(ctx, refStr)
+	if err != nil {
+		return fallback(p, "FindInvoiceByRef", err, func() (*Invoice, error) {
+			found, err := p.store.QueryInvoices(ctx, p.tenant, InvoiceQuery{ReferenceNo: refStr, Limit: 1})
+			if err != nil || len(found) == 0 {
+				return nil, ErrNotFound
+			}
+			return &found[0], nil
+		})
+	}
+	if inv != nil {
+		p.keep("FindInvoiceByRef", p.store.PutInvoices(ctx, p.tenant, []Invoice{*inv}))
+	}
+	return inv, nil
+}
+
+func (p *replicaProvider) DeleteInvoice(ctx context.Context, id string) error {
+	if err := p.Provider.DeleteInvoice(ctx, id); err != nil {
+		return err
+	}
+	p.keep("DeleteInvoice", p.store.Delete(ctx, p.tenant, []Tombstone{{Entity: SyncInvoices, ID: id}}))
+	return nil
+}
+
+func (p *replicaProvider) CreateCreditNote(ctx context.Context, input CreateCreditNoteInput) (*Invoice, error) {
+	inv, err := p.Provider.CreateCreditNote(ctx, input)
+	if err == nil && inv != nil {
+		p.keep("CreateCreditNote", p.store.PutInvoices(ctx, p.tenant, []Invoice{*inv}))
+	}
+	return inv, err
+}
+
+func (p *replicaProvider) CreateCustomer(ctx context.Context, input CreateCustomerInput) (*Customer, error) {
+	c, err := p.Provider.CreateCustomer(ctx, input)
+	if err == nil && c != nil {
+		p.keep("CreateCustomer", p.store.PutCustomers(ctx, p.tenant, []Customer{*c}))
+	}
+	return c, err
+}
+
+func (p *replicaProvider) ListCustomers(ctx context.Context, input ListCustomersInput) ([]Customer, error) {
+	customers, err := p.Provider.ListCustomers(ctx, input)
+	if err != nil {
+		return fallback(p, "ListCustomers", err, func() ([]Customer, error) { return p.readCustomers(ctx, input) })
+	}
+	p.keep("ListCustomers", p.store.PutCustomers(ctx, p.tenant, customers))
+	return customers, nil
+}
+
+func (p *replicaProvider) CustomerPages(ctx context.Context, input ListCustomersInput) iter.Seq2[[]Customer, error] {
+	return replicaPages(p, "CustomerPages", p.Provider.CustomerPages(ctx, input),
+		func(page []Customer) error { return p.store.PutCustomers(ctx, p.tenant, page) },
+		func() ([]Customer, error) { return p.readCustomers(ctx, input) })
+}
+
+func (p *replicaProvider) readCustomers(ctx context.Context, input ListCustomersInput) ([]Customer, error) {
+	return p.store.QueryCustomers(ctx, p.tenant, CustomerQuery{RegNo: input.RegNo, Email: input.Email})
+}
+
+func (p *replicaProvider) FindCustomerByEmail(ctx context.Context, email string) (*Customer, error) {
+	c, err := p.Provider.FindCustomerByEmail(ctx, email)
+	if err != nil {
+		return fallback(p, "FindCustomerByEmail", err, func() (*Customer, error) {
+			found, err := p.store.QueryCustomers(ctx, p.tenant, CustomerQuery{Email: email, Limit: 1})
+			if err != nil || len(found) == 0 {
+				return nil, ErrNotFound
+			}
+			return &found[0], nil
+		})
+	}
+	if c != nil {
+		p.keep("FindCustomerByEmail", p.store.PutCustomers(ctx, p.tenant, []Customer{*c}))
+	}
+	return c, nil
+}
+
+func (p *replicaProvider) GetCustomer(ctx context.Context, id string) (*Customer, error) {
+	c, err := p.Provider.GetCustomer(ctx, id)
+	if err != nil {
+		return fallback(p, "GetCustomer", err, func() (*Customer, error) { return p.store.GetCustomer(ctx, p.tenant, id) })
+	}
+	if c != nil {
+		p.keep("GetCustomer", p.store.PutCustomers(ctx, p.tenant, []Customer{*c}))
+	}
+	return c, nil
+}
+
+func (p *replicaProvider) ListPayments(ctx context.Context, input ListPaymentsInput) ([]Payment, error) {
+	payments, err := p.Provider.ListPayments(ctx, input)
+	if err != nil {
+		return fallback(p, "ListPayments", err, func() ([]Payment, error) { return p.readPayments(ctx, input) })
+	}
+	p.keep("ListPayments", p.store.PutPayments(ctx, p.tenant, payments))
+	return payments, nil
+}
+
+func (p *replicaProvider) PaymentPages(ctx context.Context, input ListPaymentsInput) iter.Seq2[[]Payment, error] {
+	return replicaPages(p, "PaymentPages", p.Provider.PaymentPages(ctx, input),
+		func(page []Payment) error { return p.store.PutPayments(ctx, p.tenant, page) },
+		func() ([]Payment, error) { return p.readPayments(ctx, input) })
+}
+
+func (p *replicaProvider) readPayments(ctx context.Context, input ListPaymentsInput) ([]Payment, error) {
+	return p.store.QueryPayments(ctx, p.tenant, PaymentQuery{Direction: input.Direction, From: input.PeriodStart, To: input.PeriodEnd})
+}
+
+func (p *replicaProvider) LocatePayment(ctx context.Context, input CreatePaymentInput) (*Payment, error) {
+	pay, err := p.Provider.LocatePayment(ctx, input)
+	if err == nil && pay != nil {
+		p.keep("LocatePayment", p.store.PutPayments(ctx, p.tenant, []Payment{*pay}))
+	}
+	return pay, err
+}
+
+func (p *replicaProvider) DeletePayment(ctx context.Context, id string) error {
+	if err := p.Provider.DeletePayment(ctx, id); err != nil {
+		return err
+	}
+	p.keep("DeletePayment", p.store.Delete(ctx, p.tenant, []Tombstone{{Entity: SyncPayments, ID: id}}))
+	return nil
+}
+
+func (p *replicaProvider) CreateItem(ctx context.Context, input CreateItemInput) (*Item, error) {
+	it, err := p.Provider.CreateItem(ctx, input)
+	if err == nil && it != nil {
+		p.keep("CreateItem", p.store.PutItems(ctx, p.tenant, []Item{*it}))
+	}
+	return it, err
+}
+
+func (p *replicaProvider) ListItems(ctx context.Context, input ListItemsInput) ([]Item, error) {
+	items, err := p.Provider.ListItems(ctx, input)
+	if err != nil {
+		return fallback(p, "ListItems", err, func() ([]Item, error) { return p.readItems(ctx, input) })
+	}
+	p.keep("ListItems", p.store.PutItems(ctx, p.tenant, items))
+	return items, nil
+}
+
+func (p *replicaProvider) ItemPages(ctx context.Context, input ListItemsInput) iter.Seq2[[]Item, error] {
+	return replicaPages(p, "ItemPages", p.Provider.ItemPages(ctx, input),
+		func(page []Item) error { return p.store.PutItems(ctx, p.tenant, page) },
+		func() ([]Item, error) { return p.readItems(ctx, input) })
+}
+
+func (p *replicaProvider) readItems(ctx context.Context, input ListItemsInput) ([]Item, error) {
+	return p.store.QueryItems(ctx, p.tenant, ItemQuery{Code: input.Code, Type: input.Type})
+}
+
+func (p *replicaPrepaymentProvider) CreatePrepayment(ctx context.Context, input CreatePrepaymentInput) (*Prepayment, error) {
+	pre, err := p.pp.CreatePrepayment(ctx, input)
+	if err == nil && pre != nil {
+		p.keep("CreatePrepayment", p.store.PutPrepayments(ctx, p.tenant, []Prepayment{*pre}))
+	}
+	return pre, err
+}
+
+func (p *replicaPrepaymentProvider) ApplyPrepayment(ctx context.Context, input ApplyPrepaymentInput) error {
+	return p.pp.ApplyPrepayment(ctx, input)
+}
+
+func (p *replicaPrepaymentProvider) UnallocateToPrepayment(ctx context.Context, input UnallocateToPrepaymentInput) (*Prepayment, error) {
+	pre, err := p.pp.UnallocateToPrepayment(ctx, input)
+	if err == nil && pre != nil {
+		p.keep("UnallocateToPrepayment", p.store.PutPrepayments(ctx, p.tenant, []Prepayment{*pre}))
+	}
+	return pre, err
+}
+
+func (p *replicaPrepaymentProvider) ListPrepayments(ctx context.Context, input ListPrepaymentsInput) ([]Prepayment, error) {
+	prepayments, err := p.pp.ListPrepayments(ctx, input)
+	if err != nil {
+		return fallback(p.replicaProvider, "ListPrepayments", err, func() ([]Prepayment, error) {
+			return p.store.QueryPrepayments(ctx, p.tenant, PrepaymentQuery{CustomerCode: input.CustomerCode, From: input.Since, To: input.Until})
+		})
+	}
+	p.keep("ListPrepayments", p.store.PutPrepayments(ctx, p.tenant, prepayments))
+	return prepayments, nil
+}
@@ -0,0 +1,325 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func invoiceIDs(invoices []Invoice) []string {
+	ids := make([]string, len(invoices))
+	for i, inv := range invoices {
+		ids[i] = inv.ID
+	}
+	return ids
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+	err = store.PutInvoices(ctx, "club-1", []Invoice{
+		{ID: "1", Number: "INV-10", CustomerID: "C1", CustomerName: "Acme OÜ", DocDate: day(1), DueDate: day(15), Status: InvoiceStatusUnpaid, ReferenceNo: "1012",
+			Lines: []InvoiceLine{{Description: "Membership fee"}}},
+		{ID: "2", Number: "INV-11", CustomerID: "C1", CustomerName: "Acme OÜ", DocDate: day(2), DueDate: day(20), Status: InvoiceStatusPaid},
+		{ID: "3", Number: "INV-12", CustomerID: "C2", CustomerName: "Beta AS", DocDate: day(3), DueDate: day(10), Status: InvoiceStatusPartial},
+	})
+	if err != nil {
+		t.Fatalf("PutInvoices: %v", err)
+	}
+	if err := store.PutInvoices(ctx, "club-2", []Invoice{{ID: "9", CustomerID: "C1"}}); err != nil {
+		t.Fatalf("PutInvoices: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		q    InvoiceQuery
+		want []string
+	}{
+		{"all", InvoiceQuery{}, []string{"1", "2", "3"}},
+		{"customer", InvoiceQuery{CustomerID: "C1"}, []string{"1", "2"}},
+		{"status", InvoiceQuery{Status: InvoiceStatusPartial}, []string{"3"}},
+		{"unpaid", InvoiceQuery{UnpaidOnly: true}, []string{"1", "3"}},
+		{"reference", InvoiceQuery{ReferenceNo: "1012"}, []string{"1"}},
+		{"due date", InvoiceQuery{DueFrom: day(10), DueTo: day(15)}, []string{"1", "3"}},
+		{"due after", InvoiceQuery{DueFrom: day(16)}, []string{"2"}},
+		{"text prefixes", InvoiceQuery{Text: "acm inv-1"}, []string{"1", "2"}},
+		{"line text", InvoiceQuery{Text: "MEMBER"}, []string{"1"}},
+		{"text and customer", InvoiceQuery{Text: "beta", CustomerID: "C1"}, []string{}},
+		{"unknown customer", InvoiceQuery{CustomerID: "C9"}, []string{}},
+		{"limit", InvoiceQuery{Limit: 2}, []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		got, err := store.QueryInvoices(ctx, "club-1", tt.q)
+		if err != nil {
+			t.Fatalf("%s: QueryInvoices: %v", tt.name, err)
+		}
+		if ids := invoiceIDs(got); !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+
+	// A listed version without lines keeps the stored ones; a changed
+	// status moves the invoice between index entries.
+	if err := store.PutInvoices(ctx, "club-1", []Invoice{{ID: "1", Number: "INV-10", CustomerID: "C1", DueDate: day(15), Status: InvoiceStatusPaid}}); err != nil {
+		t.Fatalf("PutInvoices: %v", err)
+	}
+	if got, _ := store.QueryInvoices(ctx, "club-1", InvoiceQuery{Status: InvoiceStatusUnpaid}); len(got) != 0 {
+		t.Errorf("unpaid after payment = %v, want none", invoiceIDs(got))
+	}
+	if err := store.Delete(ctx, "club-1", []Tombstone{{Entity: SyncInvoices, ID: "3"}}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	inv, err := reopened.GetInvoice(ctx, "club-1", "1")
+	if err != nil {
+		t.Fatalf("GetInvoice after reopening: %v", err)
+	}
+	if inv.Status != InvoiceStatusPaid || len(inv.Lines) != 1 {
+		t.Errorf("reopened invoice = %s with %d lines, want paid with 1", inv.Status, len(inv.Lines))
+	}
+	if _, err := reopened.GetInvoice(ctx, "club-1", "3"); !IsNotFound(err) {
+		t.Errorf("GetInvoice of a deleted invoice: err = %v, want ErrNotFound", err)
+	}
+	if got, _ := reopened.QueryInvoices(ctx, "club-1", InvoiceQuery{Text: "membership"}); !slices.Equal(invoiceIDs(got), []string{"1"}) {
+		t.Errorf("text search after reopening = %v, want [1]", invoiceIDs(got))
+	}
+	if got, _ := reopened.QueryInvoices(ctx, "club-2", InvoiceQuery{}); !slices.Equal(invoiceIDs(got), []string{"9"}) {
+		t.Errorf("other tenant = %v, want [9]", invoiceIDs(got))
+	}
+	// A write that cannot be saved is not served from memory either: a
+	// directory in place of the tenant's journal makes the append fail.
+	path := filepath.Join(dir, "club-2.log")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.PutInvoices(ctx, "club-2", []Invoice{{ID: "10", CustomerID: "C1"}}); err == nil {
+		t.Fatal("PutInvoices over a directory: no error")
+	}
+	if got, _ := reopened.QueryInvoices(ctx, "club-2", InvoiceQuery{}); !slices.Equal(invoiceIDs(got), []string{"9"}) {
+		t.Errorf("after a failed write = %v, want [9]", invoiceIDs(got))
+	}
+}
+
+func TestFileStore_Journal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	size := func(name string) int64 {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return 0
+		}
+		return fi.Size()
+	}
+	// One invoice at a time, as GetInvoice read-throughs put them: each
+	// write appends to the journal and leaves the file alone until the
+	// journal outgrows it.
+	lines := make([]InvoiceLine, 50)
+	for i := range lines {
+		lines[i] = InvoiceLine{Description: fmt.Sprintf("Line %d of a long enough invoice", i)}
+	}
+	var files int
+	var last int64
+	for i := range 500 {
+		if err := store.PutInvoices(ctx, "club-1", []Invoice{{ID: fmt.Sprint(i), CustomerID: "C1", Lines: lines}}); err != nil {
+			t.Fatalf("PutInvoices: %v", err)
+		}
+		if s := size("club-1.json"); s != last {
+			files, last = files+1, s
+		}
+	}
+	if files == 0 || files > 5 {
+		t.Errorf("tenant file written %d times for 500 puts, want a few", files)
+	}
+	if size("club-1.log") > max(size("club-1.json"), journalMinSize) {
+		t.Errorf("journal of %d bytes outgrew a file of %d", size("club-1.log"), size("club-1.json"))
+	}
+
+	// A crash in the middle of an append leaves a torn last line, which
+	// reopening drops.
+	if err := store.Delete(ctx, "club-1", []Tombstone{{Entity: SyncInvoices, ID: "0"}}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "club-1.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"invoices":[{"id":"torn"`)
+	f.Close()
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	got, err := reopened.QueryInvoices(ctx, "club-1", InvoiceQuery{})
+	if err != nil {
+		t.Fatalf("QueryInvoices after reopening: %v", err)
+	}
+	if len(got) != 499 {
+		t.Errorf("reopened with %d invoices, want 499", len(got))
+	}
+	if err := reopened.PutInvoices(ctx, "club-1", []Invoice{{ID: "new"}}); err != nil {
+		t.Fatalf("PutInvoices after a torn line: %v", err)
+	}
+	again, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if _, err := again.GetInvoice(ctx, "club-1", "new"); err != nil {
+		t.Errorf("GetInvoice of a write after a torn line: %v", err)
+	}
+}
+
+func TestFileStore_OtherRecords(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	dec := decimal.RequireFromString
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(store.PutPayments(ctx, "club-1", []Payment{
+		{ID: "p1", DocumentNo: "R-1", CounterPartID: "C1", CounterPartName: "Acme OÜ", InvoiceLinks: []PaymentInvoiceLink{{InvoiceID: "1", InvoiceNo: "INV-10"}}},
+		{ID: "p2", DocumentNo: "R-2", CounterPartID: "C2"},
+	}))
+	must(store.PutCustomers(ctx, "club-1", []Customer{
+		{ID: "C1", Name: "Acme OÜ", RegNo: "12345678", Email: "Info@Acme.ee"},
+		{ID: "C2", Name: "Beta AS"},
+	}))
+	must(store.PutItems(ctx, "club-1", []Item{{ID: "i1", Code: "FEE", Name: "Membership fee", Type: ItemTypeService}}))
+	must(store.PutPrepayments(ctx, "club-1", []Prepayment{
+		{Number: "PP-1", CustomerCode: "C1", Amount: dec("50"), Remaining: dec("20")},
+		{Number: "PP-2", CustomerCode: "C1", Amount: dec("50"), Remaining: dec("0")},
+	}))
+
+	if got, _ := store.QueryPayments(ctx, "club-1", PaymentQuery{InvoiceID: "1"}); len(got) != 1 || got[0].ID != "p1" {
+		t.Errorf("payments of invoice 1 = %+v, want p1", got)
+	}
+	if got, _ := store.QueryPayments(ctx, "club-1", PaymentQuery{Text: "inv-10"}); len(got) != 1 || got[0].ID != "p1" {
+		t.Errorf("payments matching inv-10 = %+v, want p1", got)
+	}
+	if got, _ := store.QueryCustomers(ctx, "club-1", CustomerQuery{Email: "info@acme.ee"}); len(got) != 1 || got[0].ID != "C1" {
+		t.Errorf("customers by e-mail = %+v, want C1", got)
+	}
+	if c, err := store.GetCustomer(ctx, "club-1", "C2"); err != nil || c.Name != "Beta AS" {
+		t.Errorf("GetCustomer = %+v, %v", c, err)
+	}
+	if got, _ := store.QueryItems(ctx, "club-1", ItemQuery{Text: "fee", Type: ItemTypeService}); len(got) != 1 {
+		t.Errorf("items = %+v, want FEE", got)
+	}
+	if got, _ := store.QueryPrepayments(ctx, "club-1", PrepaymentQuery{CustomerCode: "C1", OpenOnly: true}); len(got) != 1 || got[0].Number != "PP-1" {
+		t.Errorf("open prepayments = %+v, want PP-1", got)
+	}
+}
+
+// flakyProvider answers invoice reads with invoices, or with err when set.
+type flakyProvider struct {
+	Provider
+	invoices []Invoice
+	err      error
+}
+
+func (p *flakyProvider) ListInvoices(context.Context, ListInvoicesInput) ([]Invoice, error) {
+	return p.invoices, p.err
+}
+
+func (p *flakyProvider) GetInvoice(_ context.Context, id string) (*Invoice, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	for _, inv := range p.invoices {
+		if inv.ID == id {
+			return &inv, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func TestReplicaFallback(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	flaky := &flakyProvider{invoices: []Invoice{{ID: "1", Number: "1", CustomerID: "C1"}, {ID: "2", Number: "2", CustomerID: "C2"}}}
+	invoices := &InvoiceService{provider: replicateProvider(flaky, store, "club-1", nil)}
+
+	if _, err := invoices.List(ctx, ListInvoicesInput{}); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	flaky.err = &ProviderError{Provider: "smartaccounts", Op: "ListInvoices", Err: ErrRateLimit}
+	got, err := invoices.List(ctx, ListInvoicesInput{CustomerCode: "C2"})
+	if err != nil {
+		t.Fatalf("List while rate limited: %v", err)
+	}
+	if ids := invoiceIDs(got); !slices.Equal(ids, []string{"2"}) {
+		t.Errorf("List from the replica = %v, want [2]", ids)
+	}
+	if inv, err := invoices.Get(ctx, "1"); err != nil || inv.ID != "1" {
+		t.Errorf("Get from the replica = %+v, %v", inv, err)
+	}
+	if _, err := invoices.Get(ctx, "3"); !IsRateLimit(err) {
+		t.Errorf("Get of an invoice the replica lacks: err = %v, want the provider's", err)
+	}
+
+	// Definitive failures are not masked.
+	flaky.err = fmt.Errorf("%w: bad filter", ErrInvalidInput)
+	if _, err := invoices.List(ctx, ListInvoicesInput{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("List with a definitive failure: err = %v, want ErrInvalidInput", err)
+	}
+
+	if _, err := NewClient(Config{Provider: "merit", Replica: store}); err == nil {
+		t.Error("NewClient with a Replica but no Tenant: want error")
+	}
+}
+
+func TestSyncEngine_Replica(t *testing.T) {
+	ctx := context.Background()
+	checkpoints, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore: %v", err)
+	}
+	replica, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	engine, err := NewSyncEngine(SyncEngineConfig{Store: checkpoints, Replica: replica})
+	if err != nil {
+		t.Fatalf("NewSyncEngine: %v", err)
+	}
+	p := &feedProvider{}
+	client := feedClient(p)
+	if err := engine.SyncInvoices(ctx, "club-1", client, nil); err != nil {
+		t.Fatalf("SyncInvoices: %v", err)
+	}
+	if _, err := replica.GetInvoice(ctx, "club-1", "1"); err != nil {
+		t.Errorf("replica after sync: %v", err)
+	}
+}
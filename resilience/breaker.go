@@ -0,0 +1,130 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+// State is a circuit breaker state.
+type State int
+
+const (
+	// StateClosed lets every request through.
+	StateClosed State = iota
+	// StateOpen rejects requests with ErrCircuitOpen until the cooldown
+	// elapses.
+	StateOpen
+	// StateHalfOpen lets a single probe through; its outcome closes or
+	// re-opens the circuit.
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// breaker is a consecutive-failure circuit breaker.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	onChange  func(from, to State)
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func (b *breaker) current() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a request may proceed. After the cooldown it
+// admits exactly one probe.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	var from, to State
+	changed := false
+	defer func() {
+		b.mu.Unlock()
+		if changed {
+			b.onChange(from, to)
+		}
+	}()
+
+	switch b.state {
+	case StateClosed:
+		return true
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		from, to, changed = StateOpen, StateHalfOpen, true
+		b.state = StateHalfOpen
+		b.probing = true
+		return true
+	default: // half-open
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+}
+
+func (b *breaker) success() {
+	b.transition(func() State {
+		b.failures = 0
+		b.probing = false
+		return StateClosed
+	})
+}
+
+func (b *breaker) failure() {
+	b.transition(func() State {
+		b.failures++
+		b.probing = false
+		if b.state == StateHalfOpen || b.failures >= b.threshold {
+			b.openedAt = b.now()
+			return StateOpen
+		}
+		return b.state
+	})
+}
+
+// cancelProbe frees the half-open slot when the probe was abandoned
+// before reaching the provider.
+func (b *breaker) cancelProbe() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// transition applies f under the lock and reports any state change after
+// unlocking.
+func (b *breaker) transition(f func() State) {
+	b.mu.Lock()
+	from := b.state
+	b.state = f()
+	to := b.state
+	b.mu.Unlock()
+	if from != to {
+		b.onChange(from, to)
+	}
+}
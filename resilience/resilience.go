@@ -0,0 +1,527 @@
+// Package resilience is the transport layer shared by the provider clients
+// (merit, directo, excellentbooks, smartaccounts). It wraps each HTTP
+// exchange with client-side throttling, per-attempt and per-operation
+// timeouts, retries with jittered exponential backoff and a circuit
+// breaker.
+//
+// Retries are split by what the server is known to have done:
+//
+//   - Rate-limit rejections (429, or 503 with Retry-After) mean the request
+//     was not processed, so they are retried for every call, honouring
+//     Retry-After.
+//   - Network errors, attempt timeouts and other 5xx responses are
+//     ambiguous — the write may have landed — so they are retried only for
+//     calls the client marks idempotent. Non-idempotent writes surface the
+//     failure to the caller instead of risking a duplicate document.
+//
+// Usage:
+//
+//	exec := resilience.New("merit", resilience.Policy{MaxRetries: 3}, httpClient)
+//	resp, err := exec.Do(ctx, "v2/getinvoice", true, func(ctx context.Context) (*http.Request, error) {
+//	    return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+//	})
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Defaults applied to zero-valued Policy fields.
+const (
+	DefaultMaxRetries       = 2
+	DefaultBaseDelay        = 250 * time.Millisecond
+	DefaultMaxDelay         = 10 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// maxRetryAfter caps how long a server-requested Retry-After is honoured.
+const maxRetryAfter = 60 * time.Second
+
+// ErrCircuitOpen is returned without contacting the provider while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("resilience: circuit open, provider unhealthy")
+
+// Policy configures an Executor. The zero value gives sensible defaults:
+// two retries, 250ms–10s backoff, no throttling, no timeouts, and a
+// breaker that opens after five consecutive failures for 30s.
+type Policy struct {
+	// MaxRetries is the number of retries after the first attempt. Zero
+	// uses DefaultMaxRetries; negative disables retries.
+	MaxRetries int
+
+	// BaseDelay and MaxDelay bound the exponential backoff between
+	// retries. Each delay is jittered to between half and all of
+	// min(MaxDelay, BaseDelay*2^n).
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// RatePerSecond and Burst configure a token bucket shared by all calls
+	// through the Executor. Zero or negative RatePerSecond disables
+	// throttling; Burst defaults to 1.
+	RatePerSecond float64
+	Burst         int
+
+	// Limiter, if set, replaces the token bucket built from RatePerSecond
+	// and Burst. Executors sharing a Limiter draw on one budget, e.g. all
+	// the clients built over time for one SmartAccounts company, whose
+	// limits are per API key.
+	Limiter Limiter
+
+	// Timeout bounds each attempt, including reading the response body.
+	// When Stream leaves the body to the caller, only the time spent
+	// waiting in its reads counts, so a caller working through records
+	// between reads does not run the attempt out. Zero means attempts are
+	// bounded only by the caller's context.
+	Timeout time.Duration
+
+	// OperationTimeout bounds a whole call: its attempts, the throttling
+	// before them and the backoff between them. No retry starts that
+	// could not begin before it runs out; the last outcome is returned
+	// instead. Time between the reads of a stream does not count, as for
+	// Timeout. Zero means calls are bounded only by the caller's context.
+	OperationTimeout time.Duration
+
+	// BreakerThreshold is the number of consecutive failed attempts
+	// (network errors, timeouts, 5xx) that opens the circuit. Zero uses
+	// DefaultBreakerThreshold; negative disables the breaker.
+	BreakerThreshold int
+
+	// BreakerCooldown is how long the circuit stays open before a single
+	// probe request is let through. Zero uses DefaultBreakerCooldown.
+	BreakerCooldown time.Duration
+
+	// OnStateChange, if set, is called whenever the breaker changes state.
+	// It runs synchronously and must not call back into the Executor.
+	OnStateChange func(provider string, from, to State)
+
+	// Logger receives retry and breaker warnings. Nil uses slog.Default().
+	Logger *slog.Logger
+
+	// OnAttempt, if set, is called after every HTTP exchange — including
+	// ones that are retried — with its endpoint, status and timing. It runs
+	// synchronously on the calling goroutine.
+	OnAttempt func(ctx context.Context, a Attempt)
+}
+
+// Limiter throttles attempts; Wait blocks until one may proceed or ctx is
+// done. *rate.Limiter implements it.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Attempt describes a single HTTP exchange made by an Executor.
+type Attempt struct {
+	Provider   string
+	Endpoint   string        // client-chosen label, e.g. "v2/getinvoice" or "GET IVVc"
+	Retry      int           // 0 for the first attempt
+	StatusCode int           // 0 when the exchange itself failed
+	Duration   time.Duration // request through reading the response body; through its headers for a stream
+	Err        error
+}
+
+// Response is a fully-read HTTP response, except for the 2xx responses of
+// Stream.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Stream is the unread body of a 2xx response returned by Stream, in
+	// place of Body. The caller must Close it.
+	Stream io.ReadCloser
+}
+
+// Executor runs HTTP exchanges under a Policy. It is safe for concurrent
+// use; one Executor is shared by all calls of a provider client so that
+// throttling and breaker state are per provider.
+type Executor struct {
+	name       string
+	policy     Policy
+	httpClient *http.Client
+	limiter    Limiter
+	breaker    *breaker
+}
+
+// New returns an Executor for the named provider. A nil httpClient uses
+// http.DefaultClient.
+func New(name string, p Policy, httpClient *http.Client) *Executor {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if p.MaxRetries == 0 {
+		p.MaxRetries = DefaultMaxRetries
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultMaxDelay
+	}
+	if p.BreakerThreshold == 0 {
+		p.BreakerThreshold = DefaultBreakerThreshold
+	}
+	if p.BreakerCooldown <= 0 {
+		p.BreakerCooldown = DefaultBreakerCooldown
+	}
+	if p.Logger == nil {
+		p.Logger = slog.Default()
+	}
+
+	e := &Executor{name: name, policy: p, httpClient: httpClient}
+	if p.Limiter != nil {
+		e.limiter = p.Limiter
+	} else if p.RatePerSecond > 0 {
+		burst := p.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		e.limiter = rate.NewLimiter(rate.Limit(p.RatePerSecond), burst)
+	}
+	if p.BreakerThreshold > 0 {
+		e.breaker = &breaker{
+			threshold: p.BreakerThreshold,
+			cooldown:  p.BreakerCooldown,
+			onChange: func(from, to State) {
+				p.Logger.Warn("resilience: circuit state changed", "provider", name, "from", from, "to", to)
+				if p.OnStateChange != nil {
+					p.OnStateChange(name, from, to)
+				}
+			},
+			now: time.Now,
+		}
+	}
+	return e
+}
+
+// State reports the circuit breaker state. It is always StateClosed when
+// the breaker is disabled.
+func (e *Executor) State() State {
+	if e.breaker == nil {
+		return StateClosed
+	}
+	return e.breaker.current()
+}
+
+// Do performs the exchange built by build, retrying per the Policy. build
+// is called once per attempt with the attempt's context, so signed
+// requests can be re-signed with a fresh timestamp. endpoint labels the
+// call in logs and in Policy.OnAttempt.
+//
+// idempotent marks calls that are safe to repeat after an ambiguous
+// failure. Non-2xx responses are returned, not turned into errors — the
+// caller owns status handling — so after retries are exhausted the last
+// response is returned as-is. The error is non-nil only for transport
+// failures, context cancellation and ErrCircuitOpen.
+func (e *Executor) Do(ctx context.Context, endpoint string, idempotent bool, build func(ctx context.Context) (*http.Request, error)) (*Response, error) {
+	return e.do(ctx, endpoint, idempotent, build, false)
+}
+
+// Stream is Do for large responses: a 2xx response is returned unread, in
+// Response.Stream, for the caller to decode as it arrives. Other responses
+// are read in full, so retries and status handling are those of Do. A
+// failure while reading the stream is the caller's, and is not retried:
+// records decoded before it may already have been used.
+func (e *Executor) Stream(ctx context.Context, endpoint string, idempotent bool, build func(ctx context.Context) (*http.Request, error)) (*Response, error) {
+	return e.do(ctx, endpoint, idempotent, build, true)
+}
+
+func (e *Executor) do(ctx context.Context, endpoint string, idempotent bool, build func(ctx context.Context) (*http.Request, error), stream bool) (*Response, error) {
+	var deadline time.Time
+	if e.policy.OperationTimeout > 0 {
+		deadline = time.Now().Add(e.policy.OperationTimeout)
+	}
+	for attempt := 0; ; attempt++ {
+		if e.breaker != nil && !e.breaker.allow() {
+			return nil, fmt.Errorf("%s: %w", e.name, ErrCircuitOpen)
+		}
+		if e.limiter != nil {
+			if err := e.wait(ctx, deadline); err != nil {
+				e.release()
+				return nil, err
+			}
+		}
+		timeout := e.policy.Timeout
+		if !deadline.IsZero() {
+			left := time.Until(deadline)
+			if left <= 0 {
+				e.release()
+				return nil, fmt.Errorf("%s: operation timeout: %w", e.name, context.DeadlineExceeded)
+			}
+			if timeout <= 0 || left < timeout {
+				timeout = left
+			}
+		}
+
+		start := time.Now()
+		resp, err := e.attempt(ctx, build, stream, timeout)
+		if e.policy.OnAttempt != nil {
+			e.policy.OnAttempt(ctx, Attempt{
+				Provider:   e.name,
+				Endpoint:   endpoint,
+				Retry:      attempt,
+				StatusCode: statusOf(resp),
+				Duration:   time.Since(start),
+				Err:        err,
+			})
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about provider health.
+			e.release()
+			if resp != nil && resp.Stream != nil {
+				resp.Stream.Close()
+			}
+			return nil, ctx.Err()
+		}
+		if err != nil && !deadline.IsZero() && !time.Now().Before(deadline) {
+			// The operation ran out, which may have cut the attempt short.
+			e.release()
+			return nil, err
+		}
+
+		retryable, rateLimited, wait := e.classify(resp, err, idempotent)
+		if e.breaker != nil {
+			switch {
+			case failed(resp, err):
+				e.breaker.failure()
+			case err != nil:
+				// The request could not be built; the provider was not
+				// contacted.
+				e.release()
+			default:
+				e.breaker.success()
+			}
+		}
+		if !retryable || attempt >= e.policy.MaxRetries {
+			return resp, err
+		}
+
+		if wait <= 0 {
+			wait = e.backoff(attempt)
+		}
+		if !deadline.IsZero() && time.Until(deadline) <= wait {
+			return resp, err
+		}
+		e.policy.Logger.Warn("resilience: retrying", "provider", e.name, "endpoint", endpoint, "attempt", attempt+1,
+			"status", statusOf(resp), "error", err, "rate_limited", rateLimited, "delay", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// wait takes a token from the limiter, waiting no later than deadline
+// when it is set.
+func (e *Executor) wait(ctx context.Context, deadline time.Time) error {
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	return e.limiter.Wait(ctx)
+}
+
+// attempt sends one request and reads its body within timeout, if
+// positive. With stream, the body of a 2xx response is left unread; what
+// is left of the timeout is then spent only while the caller waits in its
+// reads.
+func (e *Executor) attempt(ctx context.Context, build func(ctx context.Context) (*http.Request, error), stream bool, timeout time.Duration) (*Response, error) {
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	cancel := func() { cancelCause(context.Canceled) }
+	clock := newAttemptClock(timeout, cancelCause)
+	req, err := build(ctx)
+	if err != nil {
+		clock.stop()
+		cancel()
+		return nil, err
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		clock.stop()
+		cancel()
+		return nil, &transportError{err: err}
+	}
+	if stream && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		clock.pause()
+		return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Stream: &streamBody{ReadCloser: resp.Body, clock: clock, cancel: cancel}}, nil
+	}
+	defer cancel()
+	defer clock.stop()
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &transportError{err: err}
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// streamBody runs the attempt's clock only during reads, and ends the
+// attempt when the stream is closed.
+type streamBody struct {
+	io.ReadCloser
+	clock  *attemptClock
+	cancel context.CancelFunc
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	b.clock.resume()
+	n, err := b.ReadCloser.Read(p)
+	b.clock.pause()
+	return n, err
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.clock.stop()
+	b.cancel()
+	return err
+}
+
+// attemptClock spends an attempt's Timeout only while it runs, cancelling
+// the attempt with context.DeadlineExceeded once the budget is used up.
+// A zero Timeout gives a clock that never expires.
+type attemptClock struct {
+	left    time.Duration
+	started time.Time
+	timer   *time.Timer
+}
+
+func newAttemptClock(timeout time.Duration, cancel context.CancelCauseFunc) *attemptClock {
+	if timeout <= 0 {
+		return &attemptClock{}
+	}
+	return &attemptClock{
+		left:    timeout,
+		started: time.Now(),
+		timer:   time.AfterFunc(timeout, func() { cancel(context.DeadlineExceeded) }),
+	}
+}
+
+// pause stops the clock, keeping what is left of the budget.
+func (c *attemptClock) pause() {
+	if c.timer == nil || c.started.IsZero() {
+		return
+	}
+	c.timer.Stop()
+	c.left -= time.Since(c.started)
+	c.started = time.Time{}
+}
+
+// resume restarts a paused clock with what is left of the budget.
+func (c *attemptClock) resume() {
+	if c.timer == nil || !c.started.IsZero() {
+		return
+	}
+	c.started = time.Now()
+	c.timer.Reset(max(c.left, 0))
+}
+
+func (c *attemptClock) stop() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// classify decides whether an attempt's outcome should be retried, and
+// whether the server asked for a specific delay.
+func (e *Executor) classify(resp *Response, err error, idempotent bool) (retryable, rateLimited bool, wait time.Duration) {
+	if err != nil {
+		var te *transportError
+		return errors.As(err, &te) && idempotent, false, 0
+	}
+	delay, hasRetryAfter := retryAfter(resp.Header)
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusServiceUnavailable && hasRetryAfter:
+		return true, true, delay
+	case resp.StatusCode >= 500:
+		return idempotent, false, 0
+	}
+	return false, false, 0
+}
+
+// backoff returns the jittered delay before retry n (0-based).
+func (e *Executor) backoff(n int) time.Duration {
+	d := e.policy.MaxDelay
+	if n < 30 {
+		if exp := e.policy.BaseDelay << n; exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// release hands back a half-open probe slot that was never used.
+func (e *Executor) release() {
+	if e.breaker != nil {
+		e.breaker.cancelProbe()
+	}
+}
+
+// failed reports whether an attempt counts against provider health.
+// Rate-limit rejections do not: the provider is up, just busy. Nor do
+// errors building the request, which never reached it.
+func failed(resp *Response, err error) bool {
+	if err != nil {
+		var te *transportError
+		return errors.As(err, &te)
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		_, limited := retryAfter(resp.Header)
+		return !limited
+	}
+	return resp.StatusCode >= 500
+}
+
+func statusOf(resp *Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// transportError marks failures of the exchange itself (dial, TLS, reset,
+// attempt timeout), as opposed to errors building the request.
+type transportError struct{ err error }
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+// retryAfter parses a Retry-After header in either form — delta-seconds
+// ("120") or an HTTP date — and returns the delay, capped at 60s. It
+// returns false when the header is absent, in the past, or unparseable.
+func retryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs <= 0 {
+			return 0, false
+		}
+		d = time.Duration(secs) * time.Second
+	} else if when, perr := http.ParseTime(v); perr == nil {
+		d = time.Until(when)
+		if d <= 0 {
+			return 0, false
+		}
+	} else {
+		return 0, false
+	}
+	return min(d, maxRetryAfter), true
+}
@@ -0,0 +1,464 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// fastPolicy keeps backoff short so retry tests run quickly.
+func fastPolicy() Policy {
+	return Policy{BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+}
+
+// statusServer answers with statuses in order, then 200 for every later call.
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1)) - 1
+		if n < len(statuses) {
+			if statuses[n] == http.StatusTooManyRequests || statuses[n] == http.StatusServiceUnavailable {
+				w.Header().Set("Retry-After", "1")
+			}
+			w.WriteHeader(statuses[n])
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func get(url string) func(ctx context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	}
+}
+
+func TestDo_RetriesServerErrorsOnlyWhenIdempotent(t *testing.T) {
+	srv, calls := statusServer(t, 500, 502)
+	e := New("test", fastPolicy(), srv.Client())
+
+	resp, err := e.Do(context.Background(), "test", true, get(srv.URL))
+	if err != nil || resp.StatusCode != 200 || string(resp.Body) != "ok" {
+		t.Fatalf("idempotent: resp = %+v, err = %v; want 200 ok", resp, err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("idempotent: %d calls, want 3", got)
+	}
+
+	srv, calls = statusServer(t, 500)
+	e = New("test", fastPolicy(), srv.Client())
+	resp, err = e.Do(context.Background(), "test", false, get(srv.URL))
+	if err != nil || resp.StatusCode != 500 {
+		t.Fatalf("non-idempotent: resp = %+v, err = %v; want the 500 response", resp, err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("non-idempotent: %d calls, want 1", got)
+	}
+}
+
+func TestDo_RetriesRateLimitForWrites(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	e := New("test", fastPolicy(), srv.Client())
+
+	resp, err := e.Do(context.Background(), "test", false, get(srv.URL))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("resp = %+v, err = %v; want 200 after a 429", resp, err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d calls, want 2", got)
+	}
+}
+
+func TestDo_ReturnsLastResponseWhenRetriesExhausted(t *testing.T) {
+	srv, calls := statusServer(t, 500, 500, 500, 500)
+	p := fastPolicy()
+	p.MaxRetries = 1
+	e := New("test", p, srv.Client())
+
+	resp, err := e.Do(context.Background(), "test", true, get(srv.URL))
+	if err != nil || resp.StatusCode != 500 {
+		t.Fatalf("resp = %+v, err = %v; want the final 500", resp, err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d calls, want 2", got)
+	}
+}
+
+func TestDo_AttemptTimeout(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	p := fastPolicy()
+	p.Timeout = 50 * time.Millisecond
+	e := New("test", p, srv.Client())
+
+	if _, err := e.Do(context.Background(), "test", false, get(srv.URL)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("non-idempotent: err = %v, want DeadlineExceeded", err)
+	}
+	resp, err := e.Do(context.Background(), "test", true, get(srv.URL))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("idempotent: resp = %+v, err = %v; want 200", resp, err)
+	}
+}
+
+func TestDo_OperationTimeout(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+	p := fastPolicy()
+	p.MaxRetries = 10
+	p.Timeout = 40 * time.Millisecond
+	p.OperationTimeout = 100 * time.Millisecond
+	e := New("test", p, srv.Client())
+
+	start := time.Now()
+	if _, err := e.Do(context.Background(), "test", true, get(srv.URL)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 300*time.Millisecond {
+		t.Errorf("Do took %s, want about the operation timeout", d)
+	}
+	if got := calls.Load(); got < 2 || got > 3 {
+		t.Errorf("%d calls, want the 2 or 3 attempts that fit", got)
+	}
+}
+
+func TestDo_OperationTimeoutSkipsLateRetry(t *testing.T) {
+	srv, calls := statusServer(t, 500, 500)
+	p := fastPolicy()
+	p.BaseDelay, p.MaxDelay = time.Second, time.Second
+	p.OperationTimeout = 100 * time.Millisecond
+	e := New("test", p, srv.Client())
+
+	resp, err := e.Do(context.Background(), "test", true, get(srv.URL))
+	if err != nil || resp.StatusCode != 500 {
+		t.Fatalf("resp = %+v, err = %v; want the 500, not a retry past the deadline", resp, err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("%d calls, want 1", got)
+	}
+}
+
+func TestStream(t *testing.T) {
+	srv, calls := statusServer(t, 502, 400)
+	e := New("test", fastPolicy(), srv.Client())
+
+	// Failed responses are retried and read as by Do; a 2xx one is left
+	// for the caller to read.
+	resp, err := e.Stream(context.Background(), "test", true, get(srv.URL))
+	if err != nil || resp.StatusCode != 400 || resp.Stream != nil {
+		t.Fatalf("resp = %+v, err = %v; want the 400, read", resp, err)
+	}
+	resp, err = e.Stream(context.Background(), "test", true, get(srv.URL))
+	if err != nil || resp.StatusCode != 200 || resp.Body != nil || resp.Stream == nil {
+		t.Fatalf("resp = %+v, err = %v; want a 200 stream", resp, err)
+	}
+	body, err := io.ReadAll(resp.Stream)
+	resp.Stream.Close()
+	if err != nil || string(body) != "ok" {
+		t.Errorf("stream = %q, err = %v; want ok", body, err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("%d calls, want 3", got)
+	}
+}
+
+func TestStream_AttemptTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("["))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+	p := fastPolicy()
+	p.Timeout = 50 * time.Millisecond
+	e := New("test", p, srv.Client())
+
+	resp, err := e.Stream(context.Background(), "test", true, get(srv.URL))
+	if err != nil || resp.Stream == nil {
+		t.Fatalf("resp = %+v, err = %v; want a stream", resp, err)
+	}
+	defer resp.Stream.Close()
+	if _, err := io.ReadAll(resp.Stream); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("reading a stalled stream: err = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestStream_CallerTimeNotCounted(t *testing.T) {
+	resume := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("["))
+		w.(http.Flusher).Flush()
+		<-resume
+		w.Write([]byte("]"))
+	}))
+	defer srv.Close()
+	p := fastPolicy()
+	p.Timeout = 50 * time.Millisecond
+	e := New("test", p, srv.Client())
+
+	resp, err := e.Stream(context.Background(), "test", true, get(srv.URL))
+	if err != nil || resp.Stream == nil {
+		t.Fatalf("resp = %+v, err = %v; want a stream", resp, err)
+	}
+	defer resp.Stream.Close()
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(resp.Stream, first); err != nil {
+		t.Fatalf("reading the first byte: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond) // the caller working past Timeout
+	close(resume)
+	rest, err := io.ReadAll(resp.Stream)
+	if err != nil || string(first)+string(rest) != "[]" {
+		t.Errorf("reading on = %q, %v; want the rest of the body", rest, err)
+	}
+}
+
+func TestDo_OnAttempt(t *testing.T) {
+	srv, _ := statusServer(t, 502)
+	var attempts []Attempt
+	p := fastPolicy()
+	p.OnAttempt = func(_ context.Context, a Attempt) { attempts = append(attempts, a) }
+	e := New("test", p, srv.Client())
+
+	if _, err := e.Do(context.Background(), "v2/gettaxes", true, get(srv.URL)); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("OnAttempt called %d times, want 2", len(attempts))
+	}
+	for i, want := range []int{502, 200} {
+		a := attempts[i]
+		if a.Provider != "test" || a.Endpoint != "v2/gettaxes" || a.Retry != i || a.StatusCode != want || a.Duration <= 0 {
+			t.Errorf("attempts[%d] = %+v, want status %d", i, a, want)
+		}
+	}
+}
+
+func TestDo_CallerCancellation(t *testing.T) {
+	srv, _ := statusServer(t, 500, 500, 500)
+	p := fastPolicy()
+	p.BaseDelay, p.MaxDelay = time.Second, time.Second
+	e := New("test", p, srv.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := e.Do(ctx, "test", true, get(srv.URL)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the caller's deadline", err)
+	}
+}
+
+func TestDo_CircuitBreaker(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var transitions []string
+	p := fastPolicy()
+	p.MaxRetries = -1
+	p.BreakerThreshold = 2
+	p.BreakerCooldown = time.Minute
+	p.OnStateChange = func(provider string, from, to State) {
+		mu.Lock()
+		defer mu.Unlock()
+		transitions = append(transitions, provider+":"+from.String()+"->"+to.String())
+	}
+	e := New("test", p, srv.Client())
+	now := time.Now()
+	e.breaker.now = func() time.Time { return now }
+
+	for range 2 {
+		if _, err := e.Do(context.Background(), "test", true, get(srv.URL)); err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+	}
+	if e.State() != StateOpen {
+		t.Fatalf("State = %s after 2 failures, want open", e.State())
+	}
+	if _, err := e.Do(context.Background(), "test", true, get(srv.URL)); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("open circuit: err = %v, want ErrCircuitOpen", err)
+	}
+
+	// After the cooldown a failed probe re-opens the circuit.
+	now = now.Add(time.Minute)
+	if e.State() != StateHalfOpen {
+		t.Fatalf("State = %s after cooldown, want half-open", e.State())
+	}
+	if resp, err := e.Do(context.Background(), "test", true, get(srv.URL)); err != nil || resp.StatusCode != 500 {
+		t.Fatalf("probe: resp = %+v, err = %v", resp, err)
+	}
+	if e.State() != StateOpen {
+		t.Fatalf("State = %s after failed probe, want open", e.State())
+	}
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	failing.Store(false)
+	if resp, err := e.Do(context.Background(), "test", true, get(srv.URL)); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("probe: resp = %+v, err = %v", resp, err)
+	}
+	if e.State() != StateClosed {
+		t.Fatalf("State = %s after successful probe, want closed", e.State())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"test:closed->open",
+		"test:open->half-open", "test:half-open->open",
+		"test:open->half-open", "test:half-open->closed",
+	}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transitions[%d] = %s, want %s", i, transitions[i], want[i])
+		}
+	}
+}
+
+func TestDo_RateLimitDoesNotTripBreaker(t *testing.T) {
+	srv, _ := statusServer(t, 503, 503, 503)
+	p := fastPolicy()
+	p.MaxRetries = -1
+	p.BreakerThreshold = 1
+	e := New("test", p, srv.Client())
+
+	for range 3 {
+		if _, err := e.Do(context.Background(), "test", true, get(srv.URL)); err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+	}
+	if e.State() != StateClosed {
+		t.Errorf("State = %s after 503+Retry-After, want closed", e.State())
+	}
+}
+
+func TestDo_BuildErrorDoesNotTripBreaker(t *testing.T) {
+	srv, calls := statusServer(t)
+	p := fastPolicy()
+	p.BreakerThreshold = 1
+	e := New("test", p, srv.Client())
+
+	bad := func(context.Context) (*http.Request, error) { return nil, errors.New("bad request body") }
+	for range 3 {
+		if _, err := e.Do(context.Background(), "test", true, bad); err == nil {
+			t.Fatal("Do with a failing build: no error")
+		}
+	}
+	if e.State() != StateClosed {
+		t.Errorf("State = %s after build errors, want closed", e.State())
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("%d calls, want 0", got)
+	}
+}
+
+func TestDo_Throttles(t *testing.T) {
+	srv, _ := statusServer(t)
+	p := fastPolicy()
+	p.RatePerSecond = 20
+	p.Burst = 1
+	e := New("test", p, srv.Client())
+
+	start := time.Now()
+	for range 3 {
+		if _, err := e.Do(context.Background(), "test", true, get(srv.URL)); err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 calls at 20/s with burst 1 took %s, want >= 100ms", elapsed)
+	}
+}
+
+func TestDo_SharedLimiter(t *testing.T) {
+	srv, _ := statusServer(t)
+	shared := rate.NewLimiter(20, 1)
+	p := fastPolicy()
+	p.RatePerSecond = 1000 // ignored: Limiter takes precedence
+	p.Limiter = shared
+	a := New("test", p, srv.Client())
+	b := New("test", p, srv.Client())
+
+	start := time.Now()
+	for _, e := range []*Executor{a, b, a} {
+		if _, err := e.Do(context.Background(), "test", true, get(srv.URL)); err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 calls through two executors sharing a 20/s limiter took %s, want >= 100ms", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"2", 2 * time.Second, true},
+		{"0", 0, false},
+		{"600", maxRetryAfter, true},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, false},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.value != "" {
+			h.Set("Retry-After", tt.value)
+		}
+		got, ok := retryAfter(h)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v; want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -18,10 +18,12 @@
 package smartaccounts
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
-	"time"
 
-	"golang.org/x/time/rate"
+	"github.com/qbitsoftware/accounting-service/redact"
+	"github.com/qbitsoftware/accounting-service/resilience"
 )
 
 // DefaultHost is the SmartAccounts API host.
@@ -34,8 +36,13 @@ const DefaultHost = "sa.smartaccounts.eu"
 const (
 	DefaultRatePerSecond = 1
 	DefaultBurst         = 5
+	DailyRequestLimit    = 1000
 )
 
+// DefaultMaxRetries bounds how many times a single request is retried, e.g.
+// after a rate-limit response, when Config.Resilience leaves it unset.
+const DefaultMaxRetries = 3
+
 // Config holds the configuration for a SmartAccounts API client.
 type Config struct {
 	// Host is the API host. Defaults to DefaultHost if empty.
@@ -62,9 +69,24 @@ type Config struct {
 	// rather than relying solely on reactive 503/Retry-After backoff. Both
 	// zero (the default) → DefaultRatePerSecond / DefaultBurst. Set
 	// RatePerSecond to a negative value to disable throttling entirely (tests).
+	// A non-zero Resilience.RatePerSecond takes precedence.
 	RatePerSecond int
 	Burst         int
 
+	// Resilience configures retries, attempt timeouts and the circuit
+	// breaker. Nil uses the resilience package defaults, except that up to
+	// DefaultMaxRetries rate-limited attempts are retried. Only GETs are
+	// retried after ambiguous failures.
+	Resilience *resilience.Policy
+
+	// Logger receives request and response logs. Nil uses slog.Default().
+	// Request and response bodies are logged at Debug only.
+	Logger *slog.Logger
+
+	// Redaction selects the fields masked in logged bodies. Nil uses
+	// redact.Default().
+	Redaction *redact.Policy
+
 	// NettingBank, when set, is the bank account NAME used to post a netting
 	// payment that closes a credit invoice against its original immediately on
 	// CreateCreditNote. Typically a dedicated offset account (e.g.
@@ -79,15 +101,20 @@ type Client struct {
 	baseURL     string // e.g. "https://sa.smartaccounts.eu/en/api/"
 	apiKey      string
 	secretKey   string
-	httpClient  *http.Client
-	limiter     *rate.Limiter // nil = no throttling
-	nettingBank string        // "" = auto-settle disabled
+	exec        *resilience.Executor
+	logger      *slog.Logger
+	redact      *redact.Policy
+	nettingBank string // "" = auto-settle disabled
 }
 
 // NettingBank returns the configured netting bank account name, or "" if
 // auto-settling of credit notes is disabled.
 func (c *Client) NettingBank() string { return c.nettingBank }
 
+// Logger returns the logger the client was configured with, so callers
+// layering on the client can log to the same destination.
+func (c *Client) Logger() *slog.Logger { return c.logger }
+
 // New creates a new SmartAccounts API client with the given configuration.
 func New(cfg Config) *Client {
 	host := cfg.Host
@@ -104,28 +131,61 @@ func New(cfg Config) *Client {
 		httpClient = http.DefaultClient
 	}
 
-	var limiter *rate.Limiter
-	switch {
-	case cfg.RatePerSecond < 0:
-		limiter = nil // explicitly disabled
-	default:
-		rps := cfg.RatePerSecond
-		if rps == 0 {
-			rps = DefaultRatePerSecond
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var policy resilience.Policy
+	if cfg.Resilience != nil {
+		policy = *cfg.Resilience
+	}
+	if policy.Logger == nil {
+		policy.Logger = logger
+	}
+	if policy.MaxRetries == 0 {
+		policy.MaxRetries = DefaultMaxRetries
+	}
+	if policy.RatePerSecond == 0 && cfg.RatePerSecond >= 0 {
+		policy.RatePerSecond = float64(cfg.RatePerSecond)
+		if policy.RatePerSecond == 0 {
+			policy.RatePerSecond = DefaultRatePerSecond
 		}
-		burst := cfg.Burst
-		if burst <= 0 {
-			burst = DefaultBurst
+		policy.Burst = cfg.Burst
+		if policy.Burst <= 0 {
+			policy.Burst = DefaultBurst
 		}
-		limiter = rate.NewLimiter(rate.Every(time.Second/time.Duration(rps)), burst)
 	}
 
 	return &Client{
 		baseURL:     "https://" + host + "/" + lang + "/api/",
 		apiKey:      cfg.APIKey,
 		secretKey:   cfg.SecretKey,
-		httpClient:  httpClient,
-		limiter:     limiter,
+		exec:        resilience.New("smartaccounts", policy, httpClient),
+		logger:      logger,
+		redact:      cfg.Redaction,
 		nettingBank: cfg.NettingBank,
 	}
 }
+
+// CircuitState reports the circuit breaker state for this client's
+// provider; StateOpen means SmartAccounts is currently considered
+// unhealthy.
+func (c *Client) CircuitState() resilience.State {
+	return c.exec.State()
+}
+
+// Format prints c with SecretKey masked.
+func (c Config) Format(f fmt.State, verb rune) {
+	type config Config // drops the methods, so formatting does not recurse
+	out := config(c)
+	out.SecretKey = redact.Secret(c.SecretKey)
+	fmt.Fprintf(f, fmt.FormatString(f, verb), out)
+}
+
+// String identifies the client without its secrets.
+func (c *Client) String() string {
+	return fmt.Sprintf("smartaccounts.Client{BaseURL: %s, APIKey: %s}", c.baseURL, c.apiKey)
+}
+
+func (c *Client) GoString() string { return c.String() }
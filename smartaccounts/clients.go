@@ -2,6 +2,7 @@ package smartaccounts
 
 import (
 	"context"
+	"iter"
 	"net/url"
 )
 
@@ -30,6 +31,14 @@ func (p ListClientsParams) values() url.Values {
 	return v
 }
 
+// ClientPages yields the clients matching params a page at a time, with
+// contacts and addresses, fetching pages as the caller iterates.
+func (c *Client) ClientPages(ctx context.Context, params ListClientsParams) iter.Seq2[[]ClientItem, error] {
+	params.FetchContacts = true
+	params.FetchAddress = true
+	return listPages[ClientItem](ctx, c, "purchasesales/clients:get", params.values())
+}
+
 // ListClients retrieves clients matching params, following pagination.
 func (c *Client) ListClients(ctx context.Context, params ListClientsParams) ([]ClientItem, error) {
 	// Always fetch contacts/addresses so email/phone/address are populated.
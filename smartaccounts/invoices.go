@@ -3,6 +3,7 @@ package smartaccounts
 import (
 	"context"
 	"fmt"
+	"iter"
 	"net/url"
 )
 
@@ -42,6 +43,13 @@ func (c *Client) ListInvoices(ctx context.Context, params ListInvoicesParams) ([
 	return items, deleted, nil
 }
 
+// InvoicePages yields the client invoices matching params a page at a time.
+// Pages are fetched as the caller iterates; breaking out of the loop stops
+// fetching.
+func (c *Client) InvoicePages(ctx context.Context, params ListInvoicesParams) iter.Seq2[[]InvoiceItem, error] {
+	return listPages[InvoiceItem](ctx, c, "purchasesales/clientinvoices:get", params.values())
+}
+
 // GetInvoice fetches a single client invoice by ID (rows included).
 func (c *Client) GetInvoice(ctx context.Context, id string) (*InvoiceItem, error) {
 	v := url.Values{}
@@ -3,6 +3,7 @@ package smartaccounts
 import (
 	"context"
 	"fmt"
+	"iter"
 	"net/url"
 
 	"github.com/shopspring/decimal"
@@ -44,6 +45,12 @@ func (c *Client) ListPayments(ctx context.Context, params ListPaymentsParams) ([
 	return items, deleted, nil
 }
 
+// PaymentPages yields the payments matching params a page at a time,
+// fetching pages as the caller iterates.
+func (c *Client) PaymentPages(ctx context.Context, params ListPaymentsParams) iter.Seq2[[]PaymentItem, error] {
+	return listPages[PaymentItem](ctx, c, "purchasesales/payments:get", params.values())
+}
+
 // CreatePayment adds a payment.
 func (c *Client) CreatePayment(ctx context.Context, req CreatePaymentRequest) (*PaymentResponse, error) {
 	var resp PaymentResponse
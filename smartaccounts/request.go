@@ -6,18 +6,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log/slog"
+	"iter"
+	"maps"
 	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
-	"time"
 )
 
-// APIError represents a non-2xx response from the SmartAccounts API.
+// APIError represents a non-2xx response from the SmartAccounts API. Code
+// and Message come from SmartAccounts' JSON error body (Code e.g.
+// "INVOICE-NUMBER-EXISTS"); a body of another shape is kept whole in
+// Message.
 type APIError struct {
 	StatusCode int
 	Message    string
+	Code       string
 }
 
 func (e *APIError) Error() string {
@@ -40,10 +44,12 @@ type page struct {
 // "purchasesales/clientinvoices:get". params holds caller-supplied query
 // parameters; timestamp, apikey and signature are added automatically, with
 // signature placed last as required by the signing scheme.
-// maxRateLimitRetries bounds how many times a single request is retried after a
-// rate-limit response before giving up.
-const maxRateLimitRetries = 3
-
+//
+// Throttling and retries go through the client's resilience.Executor.
+// SmartAccounts answers its per-company rate limit with 503 + Retry-After
+// (429 from some proxies); those are retried for every method since the
+// request was not processed. GETs are also retried on network errors and
+// other 5xx; POST writes are not, so a lost response can't double-book.
 func (c *Client) do(ctx context.Context, method, endpoint string, params url.Values, payload, result any) error {
 	var body []byte
 	if payload != nil {
@@ -55,21 +61,10 @@ func (c *Client) do(ctx context.Context, method, endpoint string, params url.Val
 	}
 
 	// Request bodies and responses can carry PII (customer/invoice data), so log
-	// them at Debug only; endpoint/status stay at Info for operational tracing.
-	slog.Debug("smartaccounts api request", "method", method, "endpoint", endpoint, "body", string(body))
+	// them at Debug only and with PII fields masked by the redaction policy.
+	c.logger.Debug("smartaccounts api request", "method", method, "endpoint", endpoint, "body", c.redact.Body(body))
 
-	var respBody []byte
-	var statusCode int
-	for attempt := 0; ; attempt++ {
-		// Proactive throttle so we stay under SmartAccounts' 60/min, 1000/day
-		// per-company caps. Wait blocks until a token is available or ctx fires.
-		// Retries on rate-limit responses go through here too, so backoff and
-		// throttle compose naturally.
-		if c.limiter != nil {
-			if err := c.limiter.Wait(ctx); err != nil {
-				return err
-			}
-		}
+	resp, err := c.exec.Do(ctx, endpoint, method == http.MethodGet, func(ctx context.Context) (*http.Request, error) {
 		// Re-sign on every attempt: the signature embeds a timestamp and stale
 		// (>15 min) requests are rejected, so a retried request needs a fresh one.
 		query := encodeQuery(c.apiKey, params)
@@ -82,44 +77,33 @@ func (c *Client) do(ctx context.Context, method, endpoint string, params url.Val
 		}
 		req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
 		if err != nil {
-			return fmt.Errorf("smartaccounts: create request: %w", err)
+			return nil, fmt.Errorf("smartaccounts: create request: %w", err)
 		}
 		if body != nil {
 			req.Header.Set("Content-Type", "application/json; charset=utf-8")
 		}
-
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("smartaccounts: send request: %w", err)
-		}
-		respBody, err = io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return fmt.Errorf("smartaccounts: read response: %w", err)
-		}
-		statusCode = resp.StatusCode
-
-		// Retry on rate limiting (SA returns 503 for rate limits and 429 for some
-		// proxies). Only retry when a Retry-After is given so we don't hammer a
-		// genuine 503 billing/outage, and honour the delay it asks for.
-		if (statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable) && attempt < maxRateLimitRetries {
-			if delay, ok := retryAfter(resp.Header); ok {
-				slog.Warn("smartaccounts api rate-limited; backing off", "endpoint", endpoint, "status", statusCode, "delay", delay, "attempt", attempt+1)
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case <-time.After(delay):
-				}
-				continue
-			}
-		}
-		break
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("smartaccounts: send request: %w", err)
 	}
+	respBody, statusCode := resp.Body, resp.StatusCode
 
-	slog.Debug("smartaccounts api response", "endpoint", endpoint, "status", statusCode, "body", string(respBody))
+	c.logger.Debug("smartaccounts api response", "endpoint", endpoint, "status", statusCode, "body", c.redact.Body(respBody))
 
 	if statusCode < 200 || statusCode >= 300 {
-		return &APIError{StatusCode: statusCode, Message: string(respBody)}
+		apiErr := &APIError{StatusCode: statusCode, Message: string(respBody)}
+		var body struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &body) == nil && body.Code != "" {
+			apiErr.Code = body.Code
+			if body.Message != "" {
+				apiErr.Message = body.Message
+			}
+		}
+		return apiErr
 	}
 
 	if result != nil && len(respBody) > 0 {
@@ -130,35 +114,6 @@ func (c *Client) do(ctx context.Context, method, endpoint string, params url.Val
 	return nil
 }
 
-// retryAfter parses a Retry-After header in either form — delta-seconds
-// ("120") or an HTTP date ("Wed, 21 Oct 2015 07:28:00 GMT") — and returns the
-// delay to wait, capped at 60s. Returns false when the header is absent, in the
-// past, or unparseable.
-func retryAfter(h http.Header) (time.Duration, bool) {
-	v := h.Get("Retry-After")
-	if v == "" {
-		return 0, false
-	}
-	var d time.Duration
-	if secs, err := strconv.Atoi(v); err == nil {
-		if secs <= 0 {
-			return 0, false
-		}
-		d = time.Duration(secs) * time.Second
-	} else if when, perr := http.ParseTime(v); perr == nil {
-		d = time.Until(when)
-		if d <= 0 {
-			return 0, false
-		}
-	} else {
-		return 0, false
-	}
-	if d > 60*time.Second {
-		d = 60 * time.Second
-	}
-	return d, true
-}
-
 // get is a convenience wrapper for a signed GET request with no body.
 func (c *Client) get(ctx context.Context, endpoint string, params url.Values, result any) error {
 	return c.do(ctx, http.MethodGet, endpoint, params, nil, result)
@@ -169,45 +124,91 @@ func (c *Client) post(ctx context.Context, endpoint string, params url.Values, p
 	return c.do(ctx, http.MethodPost, endpoint, params, payload, result)
 }
 
+// rawPages yields the raw JSON of each page of a change-tracked GET service,
+// incrementing pageNumber until the response reports hasMoreEntries=false or
+// the caller stops iterating. Only one page is held at a time.
+func (c *Client) rawPages(ctx context.Context, endpoint string, params url.Values) iter.Seq2[json.RawMessage, error] {
+	return func(yield func(json.RawMessage, error) bool) {
+		params := maps.Clone(params)
+		if params == nil {
+			params = url.Values{}
+		}
+		// Hard cap so a misbehaving API (one that never sets hasMoreEntries=false or
+		// keeps returning the same page) can't spin forever. 1000 pages × 100 rows
+		// is far beyond any realistic club's invoice/payment volume per sync window.
+		const maxPages = 1000
+		for pageNum := 1; pageNum <= maxPages; pageNum++ {
+			params.Set("pageNumber", strconv.Itoa(pageNum))
+
+			var raw json.RawMessage
+			if err := c.get(ctx, endpoint, params, &raw); err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(raw) == 0 {
+				return
+			}
+
+			var meta page
+			if err := json.Unmarshal(raw, &meta); err != nil {
+				yield(nil, fmt.Errorf("smartaccounts: unmarshal page meta: %w", err))
+				return
+			}
+			if !yield(raw, nil) || !meta.HasMoreEntries {
+				return
+			}
+		}
+		yield(nil, fmt.Errorf("smartaccounts: pagination exceeded %d pages for %s — aborting (API may not be advancing hasMoreEntries)", maxPages, endpoint))
+	}
+}
+
 // getPaginated drives a change-tracked GET service across all its pages,
-// invoking collect with each page's raw JSON. It increments pageNumber until
-// the response reports hasMoreEntries=false. The deleted IDs reported on the
+// invoking collect with each page's raw JSON. The deleted IDs reported on the
 // first page are returned so callers that care about removals can act on them.
 func (c *Client) getPaginated(ctx context.Context, endpoint string, params url.Values, collect func(raw json.RawMessage) error) (deleted []string, err error) {
-	if params == nil {
-		params = url.Values{}
-	}
-	// Hard cap so a misbehaving API (one that never sets hasMoreEntries=false or
-	// keeps returning the same page) can't spin forever. 1000 pages × 100 rows
-	// is far beyond any realistic club's invoice/payment volume per sync window.
-	const maxPages = 1000
-	for pageNum := 1; pageNum <= maxPages; pageNum++ {
-		params.Set("pageNumber", strconv.Itoa(pageNum))
-
-		var raw json.RawMessage
-		if err := c.get(ctx, endpoint, params, &raw); err != nil {
+	first := true
+	for raw, err := range c.rawPages(ctx, endpoint, params) {
+		if err != nil {
 			return deleted, err
 		}
-		if len(raw) == 0 {
-			return deleted, nil
-		}
-
-		var meta page
-		if err := json.Unmarshal(raw, &meta); err != nil {
-			return deleted, fmt.Errorf("smartaccounts: unmarshal page meta: %w", err)
-		}
-		if pageNum == 1 {
-			deleted = meta.Deleted
+		if first {
+			var meta page
+			if err := json.Unmarshal(raw, &meta); err != nil {
+				return deleted, fmt.Errorf("smartaccounts: unmarshal page meta: %w", err)
+			}
+			deleted, first = meta.Deleted, false
 		}
-
 		if err := collect(raw); err != nil {
 			return deleted, err
 		}
-		if !meta.HasMoreEntries {
-			return deleted, nil
+	}
+	return deleted, nil
+}
+
+// listPages yields the items of each page of a change-tracked GET service,
+// decoded into T, for the exported *Pages iterators.
+func listPages[T any](ctx context.Context, c *Client, endpoint string, params url.Values) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		for raw, err := range c.rawPages(ctx, endpoint, params) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			arr, err := extractList(raw)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			var items []T
+			if err := json.Unmarshal(arr, &items); err != nil {
+				yield(nil, fmt.Errorf("smartaccounts: unmarshal list items: %w", err))
+				return
+			}
+			if !yield(items, nil) {
+				return
+			}
 		}
 	}
-	return deleted, fmt.Errorf("smartaccounts: pagination exceeded %d pages for %s — aborting (API may not be advancing hasMoreEntries)", maxPages, endpoint)
 }
 
 // getList drives a change-tracked GET service across all pages and decodes the
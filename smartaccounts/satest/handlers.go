@@ -0,0 +1,659 @@
+package satest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/smartaccounts"
+	"github.com/shopspring/decimal"
+)
+
+// handler serves one service method. It is called with s.mu held and
+// returns the value to encode as the JSON response.
+type handler func(s *Server, q url.Values, body []byte) (any, *apiError)
+
+var handlers = map[string]handler{
+	"settings/vatpcs:get":                 (*Server).getVatPcs,
+	"settings/accounts:get":               (*Server).getAccounts,
+	"settings/bankaccounts:get":           (*Server).getBankAccounts,
+	"settings/objects:get":                (*Server).getObjects,
+	"purchasesales/clients:get":           (*Server).getClients,
+	"purchasesales/clients:add":           (*Server).addClient,
+	"purchasesales/clients:edit":          (*Server).editClient,
+	"purchasesales/clientinvoices:get":    (*Server).getInvoices,
+	"purchasesales/clientinvoices:add":    (*Server).addInvoice,
+	"purchasesales/clientinvoices:delete": (*Server).deleteInvoice,
+	"purchasesales/clientinvoices:getpdf": (*Server).getInvoicePDF,
+	"purchasesales/payments:get":          (*Server).getPayments,
+	"purchasesales/payments:add":          (*Server).addPayment,
+	"purchasesales/payments:delete":       (*Server).deletePayment,
+}
+
+// --- Settings ---
+
+func (s *Server) getVatPcs(url.Values, []byte) (any, *apiError) {
+	return map[string]any{"vatPcs": s.vatPcs}, nil
+}
+
+func (s *Server) getAccounts(url.Values, []byte) (any, *apiError) {
+	return map[string]any{"accounts": s.accounts}, nil
+}
+
+func (s *Server) getBankAccounts(url.Values, []byte) (any, *apiError) {
+	return map[string]any{"bankAccounts": s.banks}, nil
+}
+
+func (s *Server) getObjects(url.Values, []byte) (any, *apiError) {
+	return map[string]any{"objects": []smartaccounts.ObjectItem{}}, nil
+}
+
+func (s *Server) vatPc(code string) (decimal.Decimal, bool) {
+	for _, v := range s.vatPcs {
+		if v.VatPc == code {
+			return v.Pc, true
+		}
+	}
+	return decimal.Zero, false
+}
+
+// --- Clients ---
+
+func (s *Server) getClients(q url.Values, _ []byte) (any, *apiError) {
+	from, to, aerr := dateRange(q.Get("modifiedFrom"), q.Get("modifiedTo"))
+	if aerr != nil {
+		return nil, aerr
+	}
+	needle := strings.ToLower(q.Get("nameOrRegCode"))
+	var out []smartaccounts.ClientItem
+	for _, c := range s.clients {
+		if id := q.Get("id"); id != "" && c.item.ID != id {
+			continue
+		}
+		if needle != "" && !strings.Contains(strings.ToLower(c.item.Name), needle) && !strings.HasPrefix(c.item.RegCode, needle) {
+			continue
+		}
+		if !inRange(c.modified, from, to) {
+			continue
+		}
+		item := c.item
+		if q.Get("fetchContacts") != "true" {
+			item.Contacts = nil
+		}
+		if q.Get("fetchAddresses") != "true" {
+			item.Address = nil
+		}
+		out = append(out, item)
+	}
+	return s.paginate(q, "clients", out, nil)
+}
+
+func (s *Server) addClient(_ url.Values, body []byte) (any, *apiError) {
+	var req smartaccounts.CreateClientRequest
+	if aerr := decode(body, &req); aerr != nil {
+		return nil, aerr
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, badRequest("CLIENT-NAME-MISSING", "Client name is required")
+	}
+	c := &client{modified: s.Now()}
+	c.item.ID = s.newID()
+	c.item.ReferenceNumber = referenceNumber(len(s.clients) + 1)
+	setClient(&c.item, req)
+	s.clients = append(s.clients, c)
+	return smartaccounts.ClientResponse{ClientID: c.item.ID, ReferenceNumber: c.item.ReferenceNumber}, nil
+}
+
+func (s *Server) editClient(_ url.Values, body []byte) (any, *apiError) {
+	var req smartaccounts.CreateClientRequest
+	if aerr := decode(body, &req); aerr != nil {
+		return nil, aerr
+	}
+	c := s.client(req.ID)
+	if c == nil {
+		return nil, &apiError{status: http.StatusNotFound, code: "CLIENT-NOT-FOUND", msg: "Client by id not found"}
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, badRequest("CLIENT-NAME-MISSING", "Client name is required")
+	}
+	setClient(&c.item, req)
+	c.modified = s.Now()
+	return smartaccounts.ClientResponse{ClientID: c.item.ID, ReferenceNumber: c.item.ReferenceNumber}, nil
+}
+
+// setClient copies the writable fields of req onto item. Like
+// SmartAccounts, :edit replaces the whole object rather than merging.
+func setClient(item *smartaccounts.ClientItem, req smartaccounts.CreateClientRequest) {
+	item.Name = req.Name
+	item.RegCode = req.RegCode
+	item.VatNumber = req.VatNumber
+	item.InvoiceDueDate = 0
+	if req.InvoiceDueDate != nil {
+		item.InvoiceDueDate = *req.InvoiceDueDate
+	}
+	item.Address = req.Address
+	item.Contacts = req.Contacts
+}
+
+func (s *Server) client(id string) *client {
+	for _, c := range s.clients {
+		if c.item.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (c *client) ref() *smartaccounts.PartnerRef {
+	return &smartaccounts.PartnerRef{ID: c.item.ID, Name: c.item.Name, RegCode: c.item.RegCode, VatNumber: c.item.VatNumber}
+}
+
+// --- Client invoices ---
+
+func (s *Server) getInvoices(q url.Values, _ []byte) (any, *apiError) {
+	if q.Get("dateFrom") == "" && q.Get("paymentStatus") == "" && q.Get("id") == "" {
+		return nil, badRequest("DATE-FROM-MISSING", "dateFrom only allowed to be null when paymentStatus or id is provided")
+	}
+	from, to, aerr := dateRange(q.Get("dateFrom"), q.Get("dateTo"))
+	if aerr != nil {
+		return nil, aerr
+	}
+	dateType := q.Get("dateType")
+	today := truncateDay(s.Now())
+	var out []invoice
+	for _, inv := range s.invoices {
+		if id := q.Get("id"); id != "" && inv.ID != id {
+			continue
+		}
+		if c := q.Get("clientId"); c != "" && inv.ClientID != c {
+			continue
+		}
+		if n := q.Get("invoiceNumber"); n != "" && inv.InvoiceNumber != n {
+			continue
+		}
+		switch q.Get("paymentStatus") {
+		case "unpaid":
+			if inv.OutstandingAmount.IsZero() {
+				continue
+			}
+		case "overdue":
+			due, _ := time.Parse(dateFormat, inv.DueDate)
+			if inv.OutstandingAmount.IsZero() || !due.Before(today) {
+				continue
+			}
+		}
+		var at time.Time
+		switch dateType {
+		case "", "date":
+			at, _ = time.Parse(dateFormat, inv.Date)
+		case "entrydate":
+			at, _ = time.Parse(dateFormat, inv.EntryDate)
+		case "duedate":
+			at, _ = time.Parse(dateFormat, inv.DueDate)
+		case "modifydate":
+			at = inv.modified
+		default:
+			return nil, badRequest("DATE-TYPE-INVALID", "Unknown dateType %q", dateType)
+		}
+		if !inRange(at, from, to) {
+			continue
+		}
+		item := *inv
+		if q.Get("fetchRows") != "true" {
+			item.Rows = nil
+		}
+		out = append(out, item)
+	}
+	var deleted []string
+	if dateType == "modifydate" {
+		deleted = s.deletedSince("clientinvoices", from, to)
+	}
+	return s.paginate(q, "clientInvoices", out, deleted)
+}
+
+func (s *Server) addInvoice(_ url.Values, body []byte) (any, *apiError) {
+	var req smartaccounts.CreateInvoiceRequest
+	if aerr := decode(body, &req); aerr != nil {
+		return nil, aerr
+	}
+	c := s.client(req.ClientID)
+	if c == nil {
+		return nil, badRequest("CLIENT-NOT-FOUND", "Client by id not found")
+	}
+	date, err := time.Parse(dateFormat, req.Date)
+	if err != nil {
+		return nil, badRequest("DATE-INVALID", "Invalid date %q", req.Date)
+	}
+	if len(req.Rows) == 0 {
+		return nil, badRequest("ROWS-MISSING", "Invoice must have at least one row")
+	}
+	if req.InvoiceNumber != "" {
+		for _, inv := range s.invoices {
+			if inv.InvoiceNumber == req.InvoiceNumber {
+				return nil, badRequest("INVOICE-NUMBER-EXISTS", "Invoice with number %s already exists", req.InvoiceNumber)
+			}
+		}
+	}
+	entryDate := truncateDay(s.Now())
+	if req.EntryDate != "" {
+		if entryDate, err = time.Parse(dateFormat, req.EntryDate); err != nil {
+			return nil, badRequest("DATE-INVALID", "Invalid entryDate %q", req.EntryDate)
+		}
+	}
+
+	sign := decimal.NewFromInt(1)
+	var base *invoice
+	if req.Type == smartaccounts.InvoiceTypeCredit {
+		sign = sign.Neg()
+		if req.BaseForCreditInvoiceID != "" {
+			if base = s.invoice(req.BaseForCreditInvoiceID); base == nil {
+				return nil, badRequest("CREDIT-INVOICE-BASE-NOT-FOUND", "Base invoice by id not found")
+			}
+			baseDate, _ := time.Parse(dateFormat, base.Date)
+			baseEntry, _ := time.Parse(dateFormat, base.EntryDate)
+			if date.Before(baseDate) {
+				return nil, badRequest("CREDIT-INVOICE-EARLIER-THAN-BASE", "Credit invoice date must not be before initial invoice date")
+			}
+			if entryDate.Before(baseEntry) {
+				return nil, badRequest("CREDIT-INVOICE-ENTRY-EARLIER-THAN-BASE", "Credit invoice entry date must not be before initial invoice entry date")
+			}
+		}
+	} else if req.Type != "" {
+		return nil, badRequest("INVOICE-TYPE-INVALID", "Unknown invoice type %q", req.Type)
+	}
+
+	inv := &invoice{
+		ID:                     s.newID(),
+		ClientID:               c.item.ID,
+		Client:                 c.ref(),
+		Type:                   req.Type,
+		BaseForCreditInvoiceID: req.BaseForCreditInvoiceID,
+		Date:                   req.Date,
+		DueDate:                req.DueDate,
+		EntryDate:              entryDate.Format(dateFormat),
+		InvoiceNumber:          req.InvoiceNumber,
+		ReferenceNumber:        req.ReferenceNumber,
+		Currency:               req.Currency,
+		modified:               s.Now(),
+	}
+	if inv.DueDate == "" {
+		inv.DueDate = date.AddDate(0, 0, c.item.InvoiceDueDate).Format(dateFormat)
+	}
+	if inv.Currency == "" {
+		inv.Currency = "EUR"
+	}
+	if inv.ReferenceNumber == "" {
+		inv.ReferenceNumber = c.item.ReferenceNumber
+	}
+	inv.Number = strconv.Itoa(s.nextNo)
+	s.nextNo++
+	if inv.InvoiceNumber == "" {
+		inv.InvoiceNumber = inv.Number
+	}
+
+	for i, r := range req.Rows {
+		pc := decimal.Zero
+		if r.VatPc != "" {
+			var ok bool
+			if pc, ok = s.vatPc(r.VatPc); !ok {
+				return nil, badRequest("VATPC-NOT-FOUND", "VAT percentage %q not found", r.VatPc)
+			}
+		}
+		sum := r.Price.Mul(r.Quantity)
+		discount := decimal.Zero
+		if r.Discount != nil {
+			discount = *r.Discount
+			sum = sum.Mul(decimal.NewFromInt(100).Sub(discount)).Div(decimal.NewFromInt(100))
+		}
+		sum = sum.Round(2).Mul(sign)
+		vat := sum.Mul(pc).Div(decimal.NewFromInt(100)).Round(2)
+		inv.Rows = append(inv.Rows, smartaccounts.InvoiceRow{
+			Code:         r.Code,
+			Description:  r.Description,
+			Price:        r.Price,
+			Quantity:     r.Quantity,
+			Unit:         r.Unit,
+			Discount:     discount,
+			Vat:          vat,
+			VatPc:        r.VatPc,
+			Sum:          sum,
+			Order:        i + 1,
+			ObjectID:     r.ObjectID,
+			AccountSales: r.AccountSales,
+		})
+		inv.Amount = inv.Amount.Add(sum)
+		inv.VatAmount = inv.VatAmount.Add(vat)
+	}
+	inv.TotalAmount = inv.Amount.Add(inv.VatAmount)
+	if req.TotalAmount != nil {
+		// totalAmount is gross; the difference to the computed total is
+		// booked as rounding.
+		given := req.TotalAmount.Abs().Mul(sign)
+		inv.RoundAmount = given.Sub(inv.TotalAmount)
+		inv.TotalAmount = given
+	}
+	inv.OutstandingAmount = inv.TotalAmount
+	s.invoices = append(s.invoices, inv)
+
+	return map[string]any{
+		"invoiceId":       inv.ID,
+		"clientId":        inv.ClientID,
+		"number":          inv.Number,
+		"invoiceNumber":   inv.InvoiceNumber,
+		"referenceNumber": inv.ReferenceNumber,
+		"amount":          inv.Amount,
+		"vatAmount":       inv.VatAmount,
+		"totalAmount":     inv.TotalAmount,
+		"roundAmount":     inv.RoundAmount,
+		"dueDate":         inv.DueDate,
+	}, nil
+}
+
+func (s *Server) deleteInvoice(q url.Values, body []byte) (any, *apiError) {
+	if len(body) == 0 {
+		return nil, badRequest("BODY-READ", "POST body read error")
+	}
+	id := q.Get("id")
+	inv := s.invoice(id)
+	if inv == nil {
+		return nil, badRequest("INVOICE-NOT-FOUND", "Invoice by id not found")
+	}
+	for _, p := range s.payments {
+		for _, r := range p.Rows {
+			if r.ID == id {
+				return nil, badRequest("INVOICE-HAS-PAYMENTS", "Invoice has payments and can not be deleted")
+			}
+		}
+	}
+	for i, x := range s.invoices {
+		if x == inv {
+			s.invoices = append(s.invoices[:i], s.invoices[i+1:]...)
+			break
+		}
+	}
+	s.deleted["clientinvoices"] = append(s.deleted["clientinvoices"], tombstone{id: id, at: s.Now()})
+	return nil, nil
+}
+
+func (s *Server) getInvoicePDF(q url.Values, _ []byte) (any, *apiError) {
+	inv := s.invoice(q.Get("id"))
+	if inv == nil {
+		return nil, &apiError{status: http.StatusNotFound, code: "INVOICE-NOT-FOUND", msg: "Invoice by id not found"}
+	}
+	pdf := "%PDF-1.4\n% satest invoice " + inv.InvoiceNumber + "\n%%EOF\n"
+	return smartaccounts.PDFResponse{
+		FileName:    "invoice_" + inv.InvoiceNumber + ".pdf",
+		FileContent: base64.StdEncoding.EncodeToString([]byte(pdf)),
+	}, nil
+}
+
+func (s *Server) invoice(id string) *invoice {
+	for _, inv := range s.invoices {
+		if inv.ID == id {
+			return inv
+		}
+	}
+	return nil
+}
+
+// --- Payments ---
+
+func (s *Server) getPayments(q url.Values, _ []byte) (any, *apiError) {
+	from, to, aerr := dateRange(q.Get("dateFrom"), q.Get("dateTo"))
+	if aerr != nil {
+		return nil, aerr
+	}
+	dateType := q.Get("dateType")
+	var out []payment
+	for _, p := range s.payments {
+		if t := q.Get("partnerType"); t != "" && p.PartnerType != t {
+			continue
+		}
+		if t := q.Get("accountType"); t != "" && p.AccountType != t {
+			continue
+		}
+		if id := q.Get("partnerId"); id != "" && p.ClientID != id {
+			continue
+		}
+		var at time.Time
+		switch dateType {
+		case "", "date":
+			at, _ = time.Parse(dateFormat, p.Date)
+		case "modifydate":
+			at = p.modified
+		default:
+			return nil, badRequest("DATE-TYPE-INVALID", "Unknown dateType %q", dateType)
+		}
+		if !inRange(at, from, to) {
+			continue
+		}
+		item := *p
+		if q.Get("fetchRows") != "true" {
+			item.Rows = nil
+		}
+		out = append(out, item)
+	}
+	var deleted []string
+	if dateType == "modifydate" {
+		deleted = s.deletedSince("payments", from, to)
+	}
+	return s.paginate(q, "payments", out, deleted)
+}
+
+func (s *Server) addPayment(_ url.Values, body []byte) (any, *apiError) {
+	var req smartaccounts.CreatePaymentRequest
+	if aerr := decode(body, &req); aerr != nil {
+		return nil, aerr
+	}
+	if req.PartnerType != smartaccounts.PartnerClient {
+		return nil, badRequest("PARTNER-TYPE-INVALID", "Unsupported partnerType %q", req.PartnerType)
+	}
+	c := s.client(req.ClientID)
+	if c == nil {
+		return nil, badRequest("CLIENT-NOT-FOUND", "Client by id not found")
+	}
+	if _, err := time.Parse(dateFormat, req.Date); err != nil {
+		return nil, badRequest("DATE-INVALID", "Invalid date %q", req.Date)
+	}
+	accountType := req.AccountType
+	if accountType == "" {
+		accountType = smartaccounts.AccountBank
+	}
+	if accountType == smartaccounts.AccountBank && !s.hasBank(req.AccountName) {
+		return nil, badRequest("BANK-ACCOUNT-NOT-FOUND", "Bank account %q not found", req.AccountName)
+	}
+
+	// Validate every row before settling any, so a rejected payment leaves
+	// no partial effect.
+	targets := make([]*invoice, len(req.Rows))
+	for i, r := range req.Rows {
+		if r.Type != smartaccounts.RowClientInvoice {
+			return nil, badRequest("ROW-TYPE-INVALID", "Unsupported row type %q", r.Type)
+		}
+		inv := s.invoice(r.ID)
+		if inv == nil || inv.ClientID != c.item.ID {
+			return nil, badRequest("INVOICE-NOT-FOUND", "Invoice by id not found on row %d", i+1)
+		}
+		if r.Amount.Abs().GreaterThan(inv.OutstandingAmount.Abs()) {
+			return nil, badRequest("PAYMENT-AMOUNT-TOO-LARGE", "payment amount larger than outstanding amount on row")
+		}
+		targets[i] = inv
+	}
+	now := s.Now()
+	for i, r := range req.Rows {
+		settle(targets[i], r.Amount.Abs())
+		targets[i].modified = now
+	}
+
+	p := &payment{
+		ID:          s.newID(),
+		Date:        req.Date,
+		Number:      strconv.Itoa(len(s.payments) + 1),
+		Document:    req.Document,
+		PartnerType: req.PartnerType,
+		ClientID:    c.item.ID,
+		Client:      c.ref(),
+		AccountType: accountType,
+		AccountName: req.AccountName,
+		Currency:    req.Currency,
+		Amount:      req.Amount,
+		Rows:        req.Rows,
+		modified:    now,
+	}
+	if p.Currency == "" {
+		p.Currency = "EUR"
+	}
+	s.payments = append(s.payments, p)
+	return map[string]any{"paymentId": p.ID, "number": p.Number, "amount": p.Amount}, nil
+}
+
+func (s *Server) deletePayment(q url.Values, body []byte) (any, *apiError) {
+	if len(body) == 0 {
+		return nil, badRequest("BODY-READ", "POST body read error")
+	}
+	id := q.Get("id")
+	for i, p := range s.payments {
+		if p.ID != id {
+			continue
+		}
+		now := s.Now()
+		for _, r := range p.Rows {
+			if inv := s.invoice(r.ID); inv != nil {
+				settle(inv, r.Amount.Abs().Neg())
+				inv.modified = now
+			}
+		}
+		s.payments = append(s.payments[:i], s.payments[i+1:]...)
+		s.deleted["payments"] = append(s.deleted["payments"], tombstone{id: id, at: now})
+		return nil, nil
+	}
+	return nil, badRequest("PAYMENT-NOT-FOUND", "Payment by id not found")
+}
+
+// settle moves inv's outstanding amount towards zero by amount (or away
+// from it, for a negative amount). Credit invoices carry negative totals,
+// so the direction depends on the sign of the total.
+func settle(inv *invoice, amount decimal.Decimal) {
+	if inv.TotalAmount.IsNegative() {
+		inv.OutstandingAmount = inv.OutstandingAmount.Add(amount)
+	} else {
+		inv.OutstandingAmount = inv.OutstandingAmount.Sub(amount)
+	}
+}
+
+func (s *Server) hasBank(name string) bool {
+	for _, b := range s.banks {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Shared ---
+
+// paginate slices items by the pageNumber query parameter and wraps the
+// page in the service's envelope. Deleted IDs are reported on page 1 only.
+func (s *Server) paginate(q url.Values, key string, items any, deleted []string) (any, *apiError) {
+	page := 1
+	if v := q.Get("pageNumber"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, badRequest("PAGE-NUMBER-INVALID", "Invalid pageNumber %q", v)
+		}
+		page = n
+	}
+	raw, _ := json.Marshal(items)
+	var all []json.RawMessage
+	json.Unmarshal(raw, &all)
+
+	size := s.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	start := min((page-1)*size, len(all))
+	end := min(start+size, len(all))
+	out := map[string]any{
+		key:              append([]json.RawMessage{}, all[start:end]...),
+		"hasMoreEntries": end < len(all),
+	}
+	if page == 1 && deleted != nil {
+		out["deleted"] = deleted
+	}
+	return out, nil
+}
+
+// deletedSince returns the IDs of objects of kind deleted within [from, to].
+func (s *Server) deletedSince(kind string, from, to time.Time) []string {
+	ids := []string{}
+	for _, t := range s.deleted[kind] {
+		if inRange(t.at, from, to) {
+			ids = append(ids, t.id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// dateRange parses dd.MM.yyyy bounds; to is inclusive of the whole day.
+// Empty bounds are open.
+func dateRange(fromStr, toStr string) (from, to time.Time, aerr *apiError) {
+	if fromStr != "" {
+		var err error
+		if from, err = time.Parse(dateFormat, fromStr); err != nil {
+			return from, to, badRequest("DATE-INVALID", "Invalid date %q", fromStr)
+		}
+	}
+	if toStr != "" {
+		t, err := time.Parse(dateFormat, toStr)
+		if err != nil {
+			return from, to, badRequest("DATE-INVALID", "Invalid date %q", toStr)
+		}
+		to = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return from, to, nil
+}
+
+// inRange compares on the wall clock so that dates (parsed as UTC) and
+// modification times (server-local) line up by calendar day.
+func inRange(t, from, to time.Time) bool {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && t.After(to) {
+		return false
+	}
+	return true
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// referenceNumber builds an Estonian 7-3-1 check-digit reference number.
+func referenceNumber(n int) string {
+	base := strconv.Itoa(1000 + n)
+	weights := []int{7, 3, 1}
+	sum := 0
+	for i := range base {
+		d := int(base[len(base)-1-i] - '0')
+		sum += d * weights[i%3]
+	}
+	return base + strconv.Itoa((10-sum%10)%10)
+}
+
+func decode(body []byte, v any) *apiError {
+	if len(body) == 0 {
+		return badRequest("BODY-READ", "POST body read error")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return badRequest("BODY-INVALID", "Invalid JSON: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+// Package satest provides a local emulator of the SmartAccounts API for
+// end-to-end tests of the smartaccounts client and the accounting
+// adapter.
+//
+// The emulator serves https://{host}/{lang}/api/{service}:{method} over
+// TLS, since the client always dials https. Every request must carry
+// timestamp, apikey and a trailing hex HMAC-SHA256 signature over the
+// query string plus body; stale timestamps and bad signatures are
+// rejected. Change-tracked services paginate with pageNumber and
+// hasMoreEntries and report deleted IDs on modifydate queries. Rate
+// limiting is modelled by Throttle, which answers 503 with Retry-After.
+//
+// Usage:
+//
+//	srv := satest.NewServer()
+//	defer srv.Close()
+//	client := smartaccounts.New(srv.Config())
+package satest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/smartaccounts"
+	"github.com/shopspring/decimal"
+)
+
+// Default credentials accepted by a Server.
+const (
+	DefaultAPIKey    = "satest-api-key"
+	DefaultSecretKey = "satest-secret-key"
+)
+
+// DefaultPageSize is the number of objects per page on change-tracked
+// services.
+const DefaultPageSize = 100
+
+const (
+	dateFormat      = "02.01.2006"
+	timestampFormat = "02012006150405"
+	// maxSkew is how far a request timestamp may drift from server time.
+	maxSkew = 15 * time.Minute
+)
+
+// Fault is a canned response returned instead of the normal one.
+type Fault struct {
+	Status int
+	Body   string
+	Header http.Header
+	// Delay is slept before replying, to exercise client timeouts.
+	Delay time.Duration
+}
+
+// Server is a running SmartAccounts emulator. Its state is safe for
+// concurrent use.
+type Server struct {
+	*httptest.Server
+
+	APIKey    string
+	SecretKey string
+	// PageSize bounds list pages. Defaults to DefaultPageSize.
+	PageSize int
+	// Now is the server clock, used for timestamp checks, entry dates
+	// and modification times. Defaults to time.Now.
+	Now func() time.Time
+
+	mu         sync.Mutex
+	nextID     int
+	nextNo     int
+	faults     map[string][]Fault
+	throttle   int
+	retryAfter time.Duration
+	clients    []*client
+	invoices   []*invoice
+	payments   []*payment
+	deleted    map[string][]tombstone
+	vatPcs     []smartaccounts.VatPc
+	accounts   []smartaccounts.AccountItem
+	banks      []smartaccounts.BankAccountItem
+	requests   []string
+}
+
+type client struct {
+	item     smartaccounts.ClientItem
+	modified time.Time
+}
+
+// invoice and payment are the emulator's own wire shapes: the client's
+// read-side types use an unexported string type for document numbers.
+type invoice struct {
+	ID                     string                     `json:"id"`
+	ClientID               string                     `json:"clientId"`
+	Client                 *smartaccounts.PartnerRef  `json:"client,omitempty"`
+	Type                   string                     `json:"type,omitempty"`
+	BaseForCreditInvoiceID string                     `json:"baseForCreditInvoiceId,omitempty"`
+	Date                   string                     `json:"date"`
+	DueDate                string                     `json:"dueDate"`
+	EntryDate              string                     `json:"entryDate"`
+	Number                 string                     `json:"number"`
+	InvoiceNumber          string                     `json:"invoiceNumber"`
+	ReferenceNumber        string                     `json:"referenceNumber"`
+	Currency               string                     `json:"currency"`
+	Amount                 decimal.Decimal            `json:"amount"`
+	RoundAmount            decimal.Decimal            `json:"roundAmount"`
+	VatAmount              decimal.Decimal            `json:"vatAmount"`
+	TotalAmount            decimal.Decimal            `json:"totalAmount"`
+	OutstandingAmount      decimal.Decimal            `json:"outstandingAmount"`
+	Rows                   []smartaccounts.InvoiceRow `json:"rows,omitempty"`
+
+	modified time.Time
+}
+
+type payment struct {
+	ID          string                     `json:"id"`
+	Date        string                     `json:"date"`
+	Number      string                     `json:"number"`
+	Document    string                     `json:"document,omitempty"`
+	PartnerType string                     `json:"partnerType"`
+	ClientID    string                     `json:"clientId,omitempty"`
+	Client      *smartaccounts.PartnerRef  `json:"client,omitempty"`
+	AccountType string                     `json:"accountType"`
+	AccountName string                     `json:"accountName"`
+	Currency    string                     `json:"currency"`
+	Amount      decimal.Decimal            `json:"amount"`
+	Rows        []smartaccounts.PaymentRow `json:"rows,omitempty"`
+
+	modified time.Time
+}
+
+type tombstone struct {
+	id string
+	at time.Time
+}
+
+// NewServer starts an emulator seeded with the Estonian VAT rates, a
+// small chart of accounts and one EUR bank account named "LHV".
+func NewServer() *Server {
+	s := &Server{
+		APIKey:    DefaultAPIKey,
+		SecretKey: DefaultSecretKey,
+		PageSize:  DefaultPageSize,
+		Now:       time.Now,
+		nextNo:    1,
+		faults:    map[string][]Fault{},
+		deleted:   map[string][]tombstone{},
+		vatPcs: []smartaccounts.VatPc{
+			{VatPc: "KM22", Pc: decimal.NewFromInt(22), DescriptionEt: "Käibemaks 22%", DescriptionEn: "VAT 22%", ActiveSales: true},
+			{VatPc: "KM9", Pc: decimal.NewFromInt(9), DescriptionEt: "Käibemaks 9%", DescriptionEn: "VAT 9%", ActiveSales: true},
+			{VatPc: "KM0", Pc: decimal.Zero, DescriptionEt: "Käibemaks 0%", DescriptionEn: "VAT 0%", ActiveSales: true},
+		},
+		accounts: []smartaccounts.AccountItem{
+			{Code: "1020", ID: "acc-1020", Type: "ASSET", DescriptionEt: "Pank", DescriptionEn: "Bank"},
+			{Code: "1200", ID: "acc-1200", Type: "ASSET", DescriptionEt: "Ostjate tasumata arved", DescriptionEn: "Accounts receivable"},
+			{Code: "3000", ID: "acc-3000", Type: "INCOME", DescriptionEt: "Müügitulu", DescriptionEn: "Sales revenue"},
+		},
+		banks: []smartaccounts.BankAccountItem{
+			{Name: "LHV", Account: "1020", Currency: "EUR", IBAN: "EE717700771001735865", Swift: "LHVBEE22"},
+		},
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Config returns a smartaccounts.Config pointed at the emulator, with an
+// HTTP client that trusts its certificate and client-side throttling
+// disabled.
+func (s *Server) Config() smartaccounts.Config {
+	return smartaccounts.Config{
+		Host:          strings.TrimPrefix(s.URL, "https://"),
+		APIKey:        s.APIKey,
+		SecretKey:     s.SecretKey,
+		HTTPClient:    s.Client(),
+		RatePerSecond: -1,
+	}
+}
+
+// InjectFault queues f for the next call to endpoint (e.g.
+// "purchasesales/clientinvoices:add"). Queued faults are consumed one per
+// call, in order.
+func (s *Server) InjectFault(endpoint string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[endpoint] = append(s.faults[endpoint], f)
+}
+
+// Throttle makes the next n requests fail with 503 Service Unavailable
+// and a Retry-After of the given delay, rounded up to whole seconds, the
+// way SmartAccounts signals its per-company rate limit.
+func (s *Server) Throttle(n int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttle, s.retryAfter = n, retryAfter
+}
+
+// Requests returns the endpoints called so far, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("00000000-5a00-4000-8000-%012d", s.nextID)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	// /{lang}/api/{endpoint}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) != 3 || parts[1] != "api" || (parts[0] != "en" && parts[0] != "et") {
+		writeError(w, http.StatusNotFound, "NOT-FOUND", "Unknown path "+r.URL.Path)
+		return
+	}
+	endpoint := parts[2]
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BODY-READ", "POST body read error")
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, endpoint)
+	if s.throttle > 0 {
+		s.throttle--
+		delay := int((s.retryAfter + time.Second - 1) / time.Second)
+		s.mu.Unlock()
+		w.Header().Set("Retry-After", strconv.Itoa(delay))
+		writeError(w, http.StatusServiceUnavailable, "RATE-LIMIT", "Too many requests")
+		return
+	}
+	var fault *Fault
+	if q := s.faults[endpoint]; len(q) > 0 {
+		fault = &q[0]
+		s.faults[endpoint] = q[1:]
+	}
+	s.mu.Unlock()
+	if fault != nil {
+		time.Sleep(fault.Delay)
+		for k, vs := range fault.Header {
+			w.Header()[k] = vs
+		}
+		w.WriteHeader(fault.Status)
+		io.WriteString(w, fault.Body)
+		return
+	}
+
+	if status, code, msg := s.authenticate(r.URL.RawQuery, body); status != 0 {
+		writeError(w, status, code, msg)
+		return
+	}
+
+	h, ok := handlers[endpoint]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT-FOUND", "Unknown method "+endpoint)
+		return
+	}
+	if want := methodFor(endpoint); r.Method != want {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD", endpoint+" requires "+want)
+		return
+	}
+
+	s.mu.Lock()
+	result, aerr := h(s, r.URL.Query(), body)
+	s.mu.Unlock()
+	if aerr != nil {
+		writeError(w, aerr.status, aerr.code, aerr.msg)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if result != nil {
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// methodFor returns the HTTP method SmartAccounts expects for a service
+// method: reads are GET, everything else is POST.
+func methodFor(endpoint string) string {
+	if strings.HasSuffix(endpoint, ":get") || strings.HasSuffix(endpoint, ":getpdf") {
+		return http.MethodGet
+	}
+	return http.MethodPost
+}
+
+// authenticate verifies apikey, timestamp freshness and the signature,
+// which covers the raw query string up to "&signature=" followed by the
+// body.
+func (s *Server) authenticate(rawQuery string, body []byte) (int, string, string) {
+	signed, sig, ok := strings.Cut(rawQuery, "&signature=")
+	if !ok || sig == "" || strings.Contains(sig, "&") {
+		return http.StatusUnauthorized, "SIGNATURE-MISSING", "signature must be the last query parameter"
+	}
+	q, err := url.ParseQuery(signed)
+	if err != nil {
+		return http.StatusBadRequest, "QUERY", err.Error()
+	}
+	if q.Get("apikey") != s.APIKey {
+		return http.StatusUnauthorized, "APIKEY-INVALID", "Invalid apikey"
+	}
+	if !s.fresh(q.Get("timestamp")) {
+		return http.StatusUnauthorized, "TIMESTAMP-INVALID", "Request timestamp is missing or differs from server time by more than 15 minutes"
+	}
+	mac := hmac.New(sha256.New, []byte(s.SecretKey))
+	mac.Write([]byte(signed))
+	mac.Write(body)
+	if !hmac.Equal([]byte(sig), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return http.StatusUnauthorized, "SIGNATURE-INVALID", "Invalid signature"
+	}
+	return 0, "", ""
+}
+
+// fresh reports whether ts, Estonian local time, is within maxSkew of the
+// server clock. Both EET and EEST offsets are tried so the check does not
+// depend on the host's tzdata.
+func (s *Server) fresh(ts string) bool {
+	now := s.Now()
+	for _, offset := range []int{2, 3} {
+		t, err := time.ParseInLocation(timestampFormat, ts, time.FixedZone("EE", offset*3600))
+		if err != nil {
+			return false
+		}
+		if d := now.Sub(t); d < maxSkew && d > -maxSkew {
+			return true
+		}
+	}
+	return false
+}
+
+type apiError struct {
+	status int
+	code   string
+	msg    string
+}
+
+func badRequest(code, format string, args ...any) *apiError {
+	return &apiError{status: http.StatusBadRequest, code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"code": code, "message": msg})
+}
@@ -0,0 +1,137 @@
+package satest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/smartaccounts"
+	"github.com/qbitsoftware/accounting-service/smartaccounts/satest"
+	"github.com/shopspring/decimal"
+)
+
+func createInvoice(t *testing.T, client *smartaccounts.Client, clientID, number string) *smartaccounts.InvoiceResponse {
+	t.Helper()
+	resp, err := client.CreateInvoice(context.Background(), smartaccounts.CreateInvoiceRequest{
+		ClientID:      clientID,
+		Date:          time.Now().Format("02.01.2006"),
+		InvoiceNumber: number,
+		Rows: []smartaccounts.InvoiceRowInput{
+			{Code: "FEE", Price: decimal.NewFromInt(100), Quantity: decimal.NewFromInt(1), VatPc: "KM22"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateInvoice %s: %v", number, err)
+	}
+	return resp
+}
+
+func TestServer_RejectsBadSignature(t *testing.T) {
+	srv := satest.NewServer()
+	defer srv.Close()
+	cfg := srv.Config()
+	cfg.SecretKey = "wrong"
+
+	_, err := smartaccounts.New(cfg).ListVatPcs(context.Background())
+	var apiErr *smartaccounts.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+		t.Fatalf("err = %v, want 401 APIError", err)
+	}
+}
+
+func TestServer_PaginationAndDeleted(t *testing.T) {
+	ctx := context.Background()
+	srv := satest.NewServer()
+	srv.PageSize = 2
+	defer srv.Close()
+	client := smartaccounts.New(srv.Config())
+
+	c, err := client.CreateClient(ctx, smartaccounts.CreateClientRequest{Name: "Acme OÜ"})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	var last *smartaccounts.InvoiceResponse
+	for i := 1; i <= 5; i++ {
+		last = createInvoice(t, client, c.ClientID, fmt.Sprintf("S-%d", i))
+	}
+	if err := client.DeleteInvoice(ctx, last.InvoiceID); err != nil {
+		t.Fatalf("DeleteInvoice: %v", err)
+	}
+
+	today := time.Now().Format("02.01.2006")
+	items, deleted, err := client.ListInvoices(ctx, smartaccounts.ListInvoicesParams{DateFrom: today, DateType: "modifydate"})
+	if err != nil {
+		t.Fatalf("ListInvoices: %v", err)
+	}
+	if len(items) != 4 {
+		t.Errorf("got %d invoices across pages, want 4", len(items))
+	}
+	if len(deleted) != 1 || deleted[0] != last.InvoiceID {
+		t.Errorf("deleted = %v, want [%s]", deleted, last.InvoiceID)
+	}
+}
+
+func TestServer_DateFromRequired(t *testing.T) {
+	srv := satest.NewServer()
+	defer srv.Close()
+	client := smartaccounts.New(srv.Config())
+
+	_, _, err := client.ListInvoices(context.Background(), smartaccounts.ListInvoicesParams{InvoiceNumber: "S-1"})
+	if err == nil || !strings.Contains(err.Error(), "dateFrom only allowed to be null") {
+		t.Errorf("err = %v, want the dateFrom rule", err)
+	}
+}
+
+func TestServer_PaymentSettlesInvoice(t *testing.T) {
+	ctx := context.Background()
+	srv := satest.NewServer()
+	defer srv.Close()
+	client := smartaccounts.New(srv.Config())
+
+	c, err := client.CreateClient(ctx, smartaccounts.CreateClientRequest{Name: "Acme OÜ"})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	inv := createInvoice(t, client, c.ClientID, "S-1")
+	pay := func(amount int64) error {
+		_, err := client.CreatePayment(ctx, smartaccounts.CreatePaymentRequest{
+			Date:        time.Now().Format("02.01.2006"),
+			PartnerType: smartaccounts.PartnerClient,
+			ClientID:    c.ClientID,
+			AccountName: "LHV",
+			Amount:      decimal.NewFromInt(amount),
+			Rows:        []smartaccounts.PaymentRow{{Type: smartaccounts.RowClientInvoice, ID: inv.InvoiceID, Amount: decimal.NewFromInt(amount)}},
+		})
+		return err
+	}
+	if err := pay(200); err == nil {
+		t.Error("overpayment: want error")
+	}
+	if err := pay(122); err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	got, err := client.GetInvoice(ctx, inv.InvoiceID)
+	if err != nil {
+		t.Fatalf("GetInvoice: %v", err)
+	}
+	if !got.OutstandingAmount.IsZero() {
+		t.Errorf("outstanding = %s, want 0", got.OutstandingAmount)
+	}
+}
+
+func TestServer_ThrottleIsRetried(t *testing.T) {
+	srv := satest.NewServer()
+	defer srv.Close()
+	srv.Throttle(1, time.Second)
+	client := smartaccounts.New(srv.Config())
+
+	if _, err := client.ListVatPcs(context.Background()); err != nil {
+		t.Fatalf("ListVatPcs: %v", err)
+	}
+	if got := srv.Requests(); len(got) != 2 {
+		t.Errorf("Requests() = %v, want the throttled call plus one retry", got)
+	}
+}
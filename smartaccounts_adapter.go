@@ -5,7 +5,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"log/slog"
+	"iter"
 	"strings"
 	"time"
 
@@ -18,15 +18,39 @@ type smartProvider struct {
 	client *smartaccounts.Client
 }
 
+func init() {
+	RegisterProvider("smartaccounts", func(cfg Config) (Provider, error) {
+		return newSmartAccountsProvider(cfg), nil
+	}, smartCapabilities)
+}
+
+// smartCapabilities is the feature set registered for the "smartaccounts" provider.
+// Operations are derived from the adapter; the ones it does not implement
+// are listed here.
+var smartCapabilities = adapterCapabilities((*smartProvider)(nil),
+	Capabilities{SupportsVendorPayments: true, SupportsIncrementalSync: true, SupportsDeletionSync: true},
+	OpListPaymentTerms,
+	OpInvoiceChangesAfter,
+	OpPaymentChangesAfter,
+	OpCustomerChangesAfter,
+	OpItemChangesAfter,
+)
+
 func newSmartAccountsProvider(cfg Config) *smartProvider {
+	opts := cfg.smartAccountsOptions()
 	return &smartProvider{
 		client: smartaccounts.New(smartaccounts.Config{
-			Host:        cfg.Region, // optional host override; empty → default host
-			APIKey:      cfg.APIID,  // public API key
-			SecretKey:   cfg.APIKey, // private/secret key (HMAC key)
-			Language:    cfg.Extra["language"],
-			NettingBank: cfg.Extra["netting_bank"], // empty disables auto-settle on credit notes
-			HTTPClient:  cfg.HTTPClient,
+			Host:          cfg.Region, // optional host override; empty → default host
+			APIKey:        cfg.APIID,  // public API key
+			SecretKey:     cfg.APIKey, // private/secret key (HMAC key)
+			Language:      opts.Language,
+			NettingBank:   opts.NettingBank, // empty disables auto-settle on credit notes
+			RatePerSecond: opts.RatePerSecond,
+			Burst:         opts.Burst,
+			HTTPClient:    cfg.HTTPClient,
+			Resilience:    cfg.Resilience,
+			Logger:        cfg.Logger,
+			Redaction:     cfg.Redaction,
 		}),
 	}
 }
@@ -141,15 +165,53 @@ func (p *smartProvider) GetInvoicePDF(ctx context.Context, id string, _ bool) (*
 }
 
 func (p *smartProvider) ListInvoices(ctx context.Context, input ListInvoicesInput) ([]Invoice, error) {
-	items, _, err := p.client.ListInvoices(ctx, smartaccounts.ListInvoicesParams{
+	items, _, err := p.client.ListInvoices(ctx, saInvoiceParams(input))
+	if err != nil {
+		return nil, p.wrapError("ListInvoices", err)
+	}
+	invoices := mapSAInvoices(items)
+	if !input.ChangedSince.IsZero() {
+		invoices = filterList(invoices, input.inPeriod)
+	}
+	return invoices, nil
+}
+
+// listsInvoiceDetails: invoices are listed with their rows (FetchRows).
+func (p *smartProvider) listsInvoiceDetails() {}
+
+// InvoicePages follows SmartAccounts' page numbers, one request per page.
+func (p *smartProvider) InvoicePages(ctx context.Context, input ListInvoicesInput) iter.Seq2[[]Invoice, error] {
+	pages := mapPages(p.client.InvoicePages(ctx, saInvoiceParams(input)),
+		func(item *smartaccounts.InvoiceItem) Invoice { return mapSAInvoice(*item) },
+		func(err error) error { return p.wrapError("ListInvoices", err) })
+	if !input.ChangedSince.IsZero() {
+		pages = filterPages(pages, input.inPeriod)
+	}
+	return pages
+}
+
+// saInvoiceParams pushes down the filters clientinvoices:get supports: the
+// client, an exact number and unpaid status (which includes partly paid).
+// The query ranges on one date, so with ChangedSince it lists by modify
+// date from that day and the caller applies the document period.
+func saInvoiceParams(input ListInvoicesInput) smartaccounts.ListInvoicesParams {
+	params := smartaccounts.ListInvoicesParams{
 		DateFrom:  saFormatDate(input.PeriodStart),
 		DateTo:    saFormatDate(input.PeriodEnd),
+		ClientID:  input.CustomerCode,
 		FetchRows: true,
-	})
-	if err != nil {
-		return nil, p.wrapError("ListInvoices", err)
 	}
-	return mapSAInvoices(items), nil
+	if !input.ChangedSince.IsZero() {
+		params.DateFrom, params.DateTo = saFormatDate(input.ChangedSince), ""
+		params.DateType = "modifydate"
+	}
+	if input.NumberFrom != "" && input.NumberFrom == input.NumberTo {
+		params.InvoiceNumber = input.NumberFrom
+	}
+	if input.UnpaidOnly || input.Status == InvoiceStatusUnpaid || input.Status == InvoiceStatusPartial {
+		params.PaymentStatus = "unpaid"
+	}
+	return params
 }
 
 func (p *smartProvider) FindInvoiceByRef(ctx context.Context, refStr string) (*Invoice, error) {
@@ -165,6 +227,17 @@ func (p *smartProvider) DeleteInvoice(ctx context.Context, id string) error {
 	return p.wrapError("DeleteInvoice", p.client.DeleteInvoice(ctx, id))
 }
 
+// LocateInvoice looks the invoice number up.
+func (p *smartProvider) LocateInvoice(ctx context.Context, input CreateInvoiceInput) (*Invoice, error) {
+	if input.IdempotencyKey != "" {
+		return nil, p.wrapError("LocateInvoice", errKeyNotStored)
+	}
+	if input.InvoiceNo == "" {
+		return nil, nil
+	}
+	return absent(p.FindInvoiceByRef(ctx, input.InvoiceNo))
+}
+
 // --- Credit Notes ---
 
 func (p *smartProvider) CreateCreditNote(ctx context.Context, input CreateCreditNoteInput) (*Invoice, error) {
@@ -269,12 +342,12 @@ func (p *smartProvider) CreateCreditNote(ctx context.Context, input CreateCredit
 func (p *smartProvider) autoSettleCreditNote(ctx context.Context, resp *smartaccounts.InvoiceResponse, originalID string, input CreateCreditNoteInput) {
 	orig, err := p.client.GetInvoice(ctx, originalID)
 	if err != nil {
-		slog.Warn("smartaccounts: credit note created but auto-settle skipped — could not refetch original",
+		p.client.Logger().Warn("smartaccounts: credit note created but auto-settle skipped — could not refetch original",
 			"credit_id", resp.InvoiceID, "original_id", originalID, "error", err)
 		return
 	}
 	if !orig.OutstandingAmount.IsPositive() {
-		slog.Warn("smartaccounts: credit note created but auto-settle skipped — original is already fully paid; credit is a refund liability",
+		p.client.Logger().Warn("smartaccounts: credit note created but auto-settle skipped — original is already fully paid; credit is a refund liability",
 			"credit_id", resp.InvoiceID, "original_id", originalID, "original_outstanding", orig.OutstandingAmount.String())
 		return
 	}
@@ -288,11 +361,11 @@ func (p *smartProvider) autoSettleCreditNote(ctx context.Context, resp *smartacc
 	}
 	pay, err := p.client.SettleInvoiceAgainstCredit(ctx, resp.ClientID, originalID, resp.InvoiceID, settle, input.Currency, saFormatDate(input.DocDate))
 	if err != nil {
-		slog.Error("smartaccounts: credit note created but auto-settle FAILED — please settle manually in SA UI",
+		p.client.Logger().Error("smartaccounts: credit note created but auto-settle FAILED — please settle manually in SA UI",
 			"credit_id", resp.InvoiceID, "original_id", originalID, "settle_amount", settle.String(), "error", err)
 		return
 	}
-	slog.Info("smartaccounts: credit note auto-settled",
+	p.client.Logger().Info("smartaccounts: credit note auto-settled",
 		"credit_id", resp.InvoiceID, "original_id", originalID, "settle_amount", settle.String(), "payment_id", pay.PaymentID)
 }
 
@@ -385,8 +458,8 @@ func (p *smartProvider) UpdateCustomer(ctx context.Context, input UpdateCustomer
 	return p.wrapError("UpdateCustomer", p.client.EditClient(ctx, req))
 }
 
-func (p *smartProvider) ListCustomers(ctx context.Context, _ ListCustomersInput) ([]Customer, error) {
-	items, err := p.client.ListClients(ctx, smartaccounts.ListClientsParams{})
+func (p *smartProvider) ListCustomers(ctx context.Context, input ListCustomersInput) ([]Customer, error) {
+	items, err := p.client.ListClients(ctx, saClientParams(input))
 	if err != nil {
 		return nil, p.wrapError("ListCustomers", err)
 	}
@@ -397,6 +470,25 @@ func (p *smartProvider) ListCustomers(ctx context.Context, _ ListCustomersInput)
 	return customers, nil
 }
 
+func (p *smartProvider) CustomerPages(ctx context.Context, input ListCustomersInput) iter.Seq2[[]Customer, error] {
+	return mapPages(p.client.ClientPages(ctx, saClientParams(input)),
+		func(item *smartaccounts.ClientItem) Customer { return mapSAClient(*item) },
+		func(err error) error { return p.wrapError("ListCustomers", err) })
+}
+
+// saClientParams pushes down the change date and one of registry code and
+// name, which clients:get searches through a single parameter.
+func saClientParams(input ListCustomersInput) smartaccounts.ListClientsParams {
+	params := smartaccounts.ListClientsParams{
+		NameOrRegCode: strings.TrimSpace(input.RegNo),
+		ModifiedFrom:  saFormatDate(input.ChangedSince),
+	}
+	if params.NameOrRegCode == "" {
+		params.NameOrRegCode = input.Name
+	}
+	return params
+}
+
 func (p *smartProvider) GetCustomer(ctx context.Context, id string) (*Customer, error) {
 	item, err := p.client.GetClient(ctx, id)
 	if err != nil {
@@ -407,15 +499,16 @@ func (p *smartProvider) GetCustomer(ctx context.Context, id string) (*Customer,
 }
 
 func (p *smartProvider) FindCustomerByEmail(ctx context.Context, email string) (*Customer, error) {
-	items, err := p.client.ListClients(ctx, smartaccounts.ListClientsParams{})
-	if err != nil {
-		return nil, p.wrapError("FindCustomerByEmail", err)
-	}
 	email = strings.ToLower(strings.TrimSpace(email))
-	for _, item := range items {
-		if strings.ToLower(strings.TrimSpace(contactValue(item.Contacts, smartaccounts.ContactEmail))) == email {
-			c := mapSAClient(item)
-			return &c, nil
+	for items, err := range p.client.ClientPages(ctx, smartaccounts.ListClientsParams{}) {
+		if err != nil {
+			return nil, p.wrapError("FindCustomerByEmail", err)
+		}
+		for _, item := range items {
+			if strings.ToLower(strings.TrimSpace(contactValue(item.Contacts, smartaccounts.ContactEmail))) == email {
+				c := mapSAClient(item)
+				return &c, nil
+			}
 		}
 	}
 	return nil, &ProviderError{Provider: "smartaccounts", Op: "FindCustomerByEmail", Err: ErrNotFound}
@@ -445,6 +538,7 @@ func (p *smartProvider) CreatePayment(ctx context.Context, input CreatePaymentIn
 
 	req := smartaccounts.CreatePaymentRequest{
 		Date:        saFormatDate(input.PaymentDate),
+		Document:    input.PaymentNo,
 		PartnerType: smartaccounts.PartnerClient,
 		ClientID:    invoice.ClientID,
 		AccountType: smartaccounts.AccountBank,
@@ -463,11 +557,7 @@ func (p *smartProvider) CreatePayment(ctx context.Context, input CreatePaymentIn
 }
 
 func (p *smartProvider) ListPayments(ctx context.Context, input ListPaymentsInput) ([]Payment, error) {
-	items, _, err := p.client.ListPayments(ctx, smartaccounts.ListPaymentsParams{
-		DateFrom:  saFormatDate(input.PeriodStart),
-		DateTo:    saFormatDate(input.PeriodEnd),
-		FetchRows: true,
-	})
+	items, _, err := p.client.ListPayments(ctx, saPaymentParams(input))
 	if err != nil {
 		return nil, p.wrapError("ListPayments", err)
 	}
@@ -478,10 +568,49 @@ func (p *smartProvider) ListPayments(ctx context.Context, input ListPaymentsInpu
 	return payments, nil
 }
 
+func (p *smartProvider) PaymentPages(ctx context.Context, input ListPaymentsInput) iter.Seq2[[]Payment, error] {
+	return mapPages(p.client.PaymentPages(ctx, saPaymentParams(input)),
+		func(item *smartaccounts.PaymentItem) Payment { return mapSAPayment(*item) },
+		func(err error) error { return p.wrapError("ListPayments", err) })
+}
+
+func saPaymentParams(input ListPaymentsInput) smartaccounts.ListPaymentsParams {
+	params := smartaccounts.ListPaymentsParams{
+		DateFrom:  saFormatDate(input.PeriodStart),
+		DateTo:    saFormatDate(input.PeriodEnd),
+		FetchRows: true,
+	}
+	switch input.Direction {
+	case PaymentDirectionCustomer:
+		params.PartnerType = smartaccounts.PartnerClient
+	case PaymentDirectionVendor:
+		params.PartnerType = smartaccounts.PartnerVendor
+	}
+	return params
+}
+
 func (p *smartProvider) DeletePayment(ctx context.Context, id string) error {
 	return p.wrapError("DeletePayment", p.client.DeletePayment(ctx, id))
 }
 
+// LocatePayment finds the payment by PaymentNo. SmartAccounts numbers
+// payments itself; CreatePayment keeps PaymentNo in the document field,
+// which is searched among the payments of the payment date.
+func (p *smartProvider) LocatePayment(ctx context.Context, input CreatePaymentInput) (*Payment, error) {
+	if input.IdempotencyKey != "" {
+		return nil, p.wrapError("LocatePayment", errKeyNotStored)
+	}
+	if input.PaymentNo == "" {
+		return nil, nil
+	}
+	start, end := documentDay(input.PaymentDate)
+	payments, err := p.ListPayments(ctx, ListPaymentsInput{PeriodStart: start, PeriodEnd: end})
+	if err != nil {
+		return nil, err
+	}
+	return findPaymentNo(payments, input.PaymentNo, func(p *Payment) string { return p.Reference }), nil
+}
+
 // --- Items ---
 
 func (p *smartProvider) CreateItem(ctx context.Context, input CreateItemInput) (*Item, error) {
@@ -528,6 +657,11 @@ func (p *smartProvider) ListItems(ctx context.Context, input ListItemsInput) ([]
 	return result, nil
 }
 
+// ItemPages yields the article list as one page.
+func (p *smartProvider) ItemPages(ctx context.Context, input ListItemsInput) iter.Seq2[[]Item, error] {
+	return onePage(func() ([]Item, error) { return p.ListItems(ctx, input) })
+}
+
 // UpdateItem fetches the existing article, applies changes, and sends the full
 // object (SmartAccounts edit requires all fields).
 func (p *smartProvider) UpdateItem(ctx context.Context, input UpdateItemInput) error {
@@ -741,37 +875,88 @@ func (p *smartProvider) CustomerDebts(ctx context.Context, customerName string,
 // --- Sync (incremental, by modify date) ---
 
 func (p *smartProvider) ListInvoicesSince(ctx context.Context, since, until time.Time) ([]Invoice, error) {
-	items, _, err := p.client.ListInvoices(ctx, smartaccounts.ListInvoicesParams{
+	changes, err := p.listInvoicesSince(ctx, since, until)
+	if err != nil {
+		return nil, p.wrapError("ListInvoicesSince", err)
+	}
+	return changes.Invoices, nil
+}
+
+// ListInvoiceChanges reports deletions too: the modifydate query also
+// returns the IDs deleted in the window, though not when.
+func (p *smartProvider) ListInvoiceChanges(ctx context.Context, since, until time.Time) (InvoiceChanges, error) {
+	changes, err := p.listInvoicesSince(ctx, since, until)
+	return changes, p.wrapError("ListInvoiceChanges", err)
+}
+
+func (p *smartProvider) listInvoicesSince(ctx context.Context, since, until time.Time) (InvoiceChanges, error) {
+	items, deleted, err := p.client.ListInvoices(ctx, smartaccounts.ListInvoicesParams{
 		DateFrom:  saFormatDate(since),
 		DateTo:    saFormatDate(until),
 		DateType:  "modifydate",
 		FetchRows: true,
 	})
 	if err != nil {
-		return nil, p.wrapError("ListInvoicesSince", err)
+		return InvoiceChanges{}, err
 	}
-	// NOTE: the modifydate query also returns IDs deleted since `since` (the
-	// `deleted` array). The Provider interface returns only []Invoice, so
-	// deletions are not propagated yet — surfacing them needs an interface
-	// change (see plan, follow-up).
-	return mapSAInvoices(items), nil
+	return InvoiceChanges{Invoices: mapSAInvoices(items), Deleted: saTombstones(SyncInvoices, deleted)}, nil
 }
 
 func (p *smartProvider) ListPaymentsSince(ctx context.Context, since, until time.Time) ([]Payment, error) {
-	items, _, err := p.client.ListPayments(ctx, smartaccounts.ListPaymentsParams{
+	changes, err := p.listPaymentsSince(ctx, since, until)
+	if err != nil {
+		return nil, p.wrapError("ListPaymentsSince", err)
+	}
+	return changes.Payments, nil
+}
+
+// ListPaymentChanges is the payments counterpart of ListInvoiceChanges.
+func (p *smartProvider) ListPaymentChanges(ctx context.Context, since, until time.Time) (PaymentChanges, error) {
+	changes, err := p.listPaymentsSince(ctx, since, until)
+	return changes, p.wrapError("ListPaymentChanges", err)
+}
+
+func (p *smartProvider) listPaymentsSince(ctx context.Context, since, until time.Time) (PaymentChanges, error) {
+	items, deleted, err := p.client.ListPayments(ctx, smartaccounts.ListPaymentsParams{
 		DateFrom:  saFormatDate(since),
 		DateTo:    saFormatDate(until),
 		DateType:  "modifydate",
 		FetchRows: true,
 	})
 	if err != nil {
-		return nil, p.wrapError("ListPaymentsSince", err)
+		return PaymentChanges{}, err
 	}
 	payments := make([]Payment, len(items))
 	for i, item := range items {
 		payments[i] = mapSAPayment(item)
 	}
-	return payments, nil
+	return PaymentChanges{Payments: payments, Deleted: saTombstones(SyncPayments, deleted)}, nil
+}
+
+func saTombstones(entity SyncEntity, ids []string) []Tombstone {
+	var out []Tombstone
+	for _, id := range ids {
+		out = append(out, Tombstone{Entity: entity, ID: id})
+	}
+	return out
+}
+
+// SmartAccounts has no change feed; the SyncEngine syncs it by modify date.
+
+func (p *smartProvider) InvoiceChangesAfter(_ context.Context, _ string, _ time.Time) (InvoiceChanges, string, error) {
+	return InvoiceChanges{}, "", p.wrapError("InvoiceChangesAfter", fmt.Errorf("%w by SmartAccounts API", ErrNotSupported))
+}
+
+func (p *smartProvider) PaymentChangesAfter(_ context.Context, _ string, _ time.Time) (PaymentChanges, string, error) {
+	return PaymentChanges{}, "", p.wrapError("PaymentChangesAfter", fmt.Errorf("%w by SmartAccounts API", ErrNotSupported))
+}
+
+func (p *smartProvider) CustomerChangesAfter(_ context.Context, _ string, _ time.Time) (CustomerChanges, string, error) {
+	return CustomerChanges{}, "", p.wrapError("CustomerChangesAfter", fmt.Errorf("%w by SmartAccounts API", ErrNotSupported))
+}
+
+func (p *smartProvider) ItemChangesAfter(_ context.Context, _ string, _ time.Time) (ItemChanges, string, error) {
+	return ItemChanges{}, "", p.wrapError("ItemChangesAfter", fmt.Errorf("%w by SmartAccounts API", ErrNotSupported))
 }
 
 // --- Resolution helpers ---
@@ -941,6 +1126,7 @@ func mapSAPayment(item smartaccounts.PaymentItem) Payment {
 		Currency:         item.Currency,
 		Direction:        saPaymentDirection(item.PartnerType),
 		InvoiceLinks:     links,
+		Reference:        item.Document,
 		ExternalBankName: item.AccountName,
 		ExternalPayMode:  item.AccountName,
 	}
@@ -1067,18 +1253,51 @@ func (p *smartProvider) wrapError(op string, err error) error {
 	}
 	var apiErr *smartaccounts.APIError
 	if errors.As(err, &apiErr) {
-		var sentinel error
-		switch apiErr.StatusCode {
-		case 401, 403:
-			sentinel = ErrAuthFailed
-		case 404:
-			sentinel = ErrNotFound
-		case 429, 503:
-			sentinel = ErrRateLimit
-		default:
-			sentinel = err
-		}
-		return &ProviderError{Provider: "smartaccounts", Op: op, Err: sentinel}
+		return &ProviderError{Provider: "smartaccounts", Op: op, Err: smartErrorKind(apiErr, err)}
 	}
 	return &ProviderError{Provider: "smartaccounts", Op: op, Err: err}
 }
+
+// smartErrorKind maps a SmartAccounts error onto the error taxonomy using
+// the code of its JSON error body: INVOICE-NUMBER-EXISTS is a duplicate,
+// INVOICE-HAS-PAYMENTS a conflict, and the other 400 codes (CLIENT-NAME-
+// MISSING, DATE-INVALID, VATPC-NOT-FOUND, ...) name the offending field.
+func smartErrorKind(apiErr *smartaccounts.APIError, err error) error {
+	code := apiErr.Code
+	switch {
+	case apiErr.StatusCode == 401, apiErr.StatusCode == 403:
+		return ErrAuthFailed
+	case apiErr.StatusCode == 404:
+		return ErrNotFound
+	case apiErr.StatusCode == 429, apiErr.StatusCode == 503:
+		return ErrRateLimit
+	case strings.HasSuffix(code, "-EXISTS"):
+		return withKind(ErrDuplicate, err)
+	case periodClosedMessage(apiErr.Message):
+		return withKind(ErrPeriodClosed, err)
+	case apiErr.StatusCode == 409, strings.Contains(code, "-HAS-"):
+		return withKind(ErrConflict, err)
+	case apiErr.StatusCode >= 500:
+		return withKind(ErrTransient, err)
+	case apiErr.StatusCode == 400:
+		return &ValidationError{Field: saErrorField(code), Code: code, Message: apiErr.Message, Err: err}
+	}
+	return err
+}
+
+// saErrorField derives the request field from a SmartAccounts error code,
+// e.g. "DATE-FROM-MISSING" → "dateFrom".
+func saErrorField(code string) string {
+	for _, suffix := range []string{"-MISSING", "-INVALID", "-NOT-FOUND"} {
+		if field, ok := strings.CutSuffix(code, suffix); ok {
+			parts := strings.Split(strings.ToLower(field), "-")
+			for i := 1; i < len(parts); i++ {
+				if parts[i] != "" {
+					parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+				}
+			}
+			return strings.Join(parts, "")
+		}
+	}
+	return ""
+}
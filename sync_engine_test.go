@@ -0,0 +1,187 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/directo/directotest"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	store, err := NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore on a missing file: %v", err)
+	}
+	if cp, err := store.Load(ctx, "club-1", SyncInvoices); err != nil || !cp.IsZero() {
+		t.Fatalf("Load before Save = %+v, %v, want a zero checkpoint", cp, err)
+	}
+
+	want := Checkpoint{Since: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), Saved: time.Date(2025, 3, 1, 12, 0, 1, 0, time.UTC)}
+	if err := store.Save(ctx, "club-1", SyncInvoices, want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Save(ctx, "club-1", SyncPayments, Checkpoint{Cursor: "42"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reopened, err := NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore: %v", err)
+	}
+	if got, _ := reopened.Load(ctx, "club-1", SyncInvoices); !got.Since.Equal(want.Since) || !got.Saved.Equal(want.Saved) {
+		t.Errorf("reopened invoices checkpoint = %+v, want %+v", got, want)
+	}
+	if got, _ := reopened.Load(ctx, "club-1", SyncPayments); got.Cursor != "42" {
+		t.Errorf("reopened payments cursor = %q, want 42", got.Cursor)
+	}
+	if got, _ := reopened.Load(ctx, "club-2", SyncInvoices); !got.IsZero() {
+		t.Errorf("other tenant = %+v, want a zero checkpoint", got)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileCheckpointStore(path); err == nil {
+		t.Error("NewFileCheckpointStore on a corrupt file: want error")
+	}
+}
+
+func TestSyncEngine_Directo(t *testing.T) {
+	ctx := context.Background()
+	srv := directotest.NewServer()
+	defer srv.Close()
+	t0 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := t0
+	srv.Now = func() time.Time { return clock }
+	dc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "directo",
+		APIID:    dc.Company,
+		APIKey:   dc.Token,
+		Directo: &DirectoOptions{
+			RESTAPIKey:  dc.RestAPIKey,
+			RESTBaseURL: dc.RESTBaseURL,
+			XMLBaseURL:  dc.XMLBaseURL,
+		},
+	})
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Code: "C1", Name: "Acme OÜ", Email: "acme@example.com"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	if _, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "D-1", "1")); err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	store, err := NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore: %v", err)
+	}
+	engine, err := NewSyncEngine(SyncEngineConfig{Store: store})
+	if err != nil {
+		t.Fatalf("NewSyncEngine: %v", err)
+	}
+	engine.now = func() time.Time { return clock }
+
+	var got []string
+	collect := func(_ context.Context, c InvoiceChanges) error {
+		for _, inv := range c.Invoices {
+			got = append(got, inv.Number)
+		}
+		return nil
+	}
+
+	// A failing handler leaves the checkpoint where it was.
+	clock = t0.Add(time.Hour)
+	boom := errors.New("boom")
+	err = engine.SyncInvoices(ctx, "club-1", client, func(context.Context, InvoiceChanges) error { return boom })
+	if !errors.Is(err, boom) {
+		t.Fatalf("SyncInvoices with a failing handler: err = %v, want %v", err, boom)
+	}
+	if cp, _ := store.Load(ctx, "club-1", SyncInvoices); !cp.IsZero() {
+		t.Fatalf("checkpoint after a failed sync = %+v, want none", cp)
+	}
+
+	if err := engine.SyncInvoices(ctx, "club-1", client, collect); err != nil {
+		t.Fatalf("SyncInvoices: %v", err)
+	}
+	if want := []string{"D-1"}; !slices.Equal(got, want) {
+		t.Errorf("first sync = %v, want %v", got, want)
+	}
+
+	// D-2 is new; D-1 changed before the checkpoint less its overlap.
+	clock = t0.Add(2 * time.Hour)
+	if _, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "D-2", "1")); err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+	clock = t0.Add(3 * time.Hour)
+	got = nil
+	// A restarted process resumes from the file.
+	store, err = NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore: %v", err)
+	}
+	engine.store = store
+	if err := engine.SyncInvoices(ctx, "club-1", client, collect); err != nil {
+		t.Fatalf("SyncInvoices: %v", err)
+	}
+	if want := []string{"D-2"}; !slices.Equal(got, want) {
+		t.Errorf("second sync = %v, want %v", got, want)
+	}
+	if cp, _ := store.Load(ctx, "club-1", SyncInvoices); !cp.Since.Equal(clock) {
+		t.Errorf("checkpoint = %v, want %v", cp.Since, clock)
+	}
+}
+
+var registerFeedStub sync.Once
+
+// feedClient returns a client on p that advertises its invoice feed.
+func feedClient(p *feedProvider) *Client {
+	registerFeedStub.Do(func() {
+		RegisterProvider("feed-stub", func(Config) (Provider, error) {
+			return nil, errors.New("feed-stub is not for NewClient")
+		}, Capabilities{Operations: NewOpSet(OpInvoiceChangesAfter)})
+	})
+	return &Client{provider: p, providerName: "feed-stub", Sync: &SyncService{provider: p}}
+}
+
+// feedProvider is a provider that numbers its invoice changes.
+type feedProvider struct {
+	Provider
+	cursors []string
+}
+
+func (p *feedProvider) InvoiceChangesAfter(_ context.Context, cursor string, _ time.Time) (InvoiceChanges, string, error) {
+	p.cursors = append(p.cursors, cursor)
+	return InvoiceChanges{Invoices: []Invoice{{ID: "1"}}}, cursor + "+", nil
+}
+
+func TestSyncEngine_Feed(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore: %v", err)
+	}
+	engine, err := NewSyncEngine(SyncEngineConfig{Store: store})
+	if err != nil {
+		t.Fatalf("NewSyncEngine: %v", err)
+	}
+	p := &feedProvider{}
+	client := feedClient(p)
+	for range 2 {
+		if err := engine.SyncInvoices(ctx, "club-1", client, func(context.Context, InvoiceChanges) error { return nil }); err != nil {
+			t.Fatalf("SyncInvoices: %v", err)
+		}
+	}
+	if want := []string{"", "+"}; !slices.Equal(p.cursors, want) {
+		t.Errorf("cursors = %q, want %q", p.cursors, want)
+	}
+}
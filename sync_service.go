@@ -11,6 +11,11 @@ type SyncService struct {
 
 // PullInvoiceStatuses returns invoices changed since the given time, using
 // Merit's DateType=1 (changed-date) filter for incremental sync.
+//
+// Excellent Books has no change date to filter on, so there the window
+// selects by document date and misses back-dated edits. Its changes are
+// numbered instead, by the register's @sequence, which a time window
+// cannot express: follow them with InvoiceChangesAfter.
 func (s *SyncService) PullInvoiceStatuses(ctx context.Context, since time.Time, until time.Time) ([]Invoice, error) {
 	return s.provider.ListInvoicesSince(ctx, since, until)
 }
@@ -20,3 +25,86 @@ func (s *SyncService) PullInvoiceStatuses(ctx context.Context, since time.Time,
 func (s *SyncService) PullPayments(ctx context.Context, since time.Time, until time.Time) ([]Payment, error) {
 	return s.provider.ListPaymentsSince(ctx, since, until)
 }
+
+// Tombstone records that the provider hard-deleted a record.
+type Tombstone struct {
+	Entity    SyncEntity
+	ID        string    // the deleted record's Invoice.ID or Payment.ID
+	DeletedAt time.Time // zero when the provider does not report it
+}
+
+// InvoiceChanges are the invoices changed within a sync window and the
+// tombstones of those deleted within it.
+type InvoiceChanges struct {
+	Invoices []Invoice
+	Deleted  []Tombstone
+}
+
+func (c InvoiceChanges) empty() bool { return len(c.Invoices) == 0 && len(c.Deleted) == 0 }
+
+// PaymentChanges are the payments counterpart of InvoiceChanges.
+type PaymentChanges struct {
+	Payments []Payment
+	Deleted  []Tombstone
+}
+
+func (c PaymentChanges) empty() bool { return len(c.Payments) == 0 && len(c.Deleted) == 0 }
+
+// CustomerChanges are the customers changed within a sync window.
+type CustomerChanges struct {
+	Customers []Customer
+}
+
+func (c CustomerChanges) empty() bool { return len(c.Customers) == 0 }
+
+// ItemChanges are the items changed within a sync window.
+type ItemChanges struct {
+	Items []Item
+}
+
+func (c ItemChanges) empty() bool { return len(c.Items) == 0 }
+
+// PullInvoiceChanges is PullInvoiceStatuses with the tombstones of the
+// invoices deleted in the window, for providers whose Capabilities report
+// SupportsDeletionSync. For the others Deleted is always empty.
+func (s *SyncService) PullInvoiceChanges(ctx context.Context, since time.Time, until time.Time) (InvoiceChanges, error) {
+	return s.provider.ListInvoiceChanges(ctx, since, until)
+}
+
+// PullPaymentChanges is PullPayments with the tombstones of the payments
+// deleted in the window; see PullInvoiceChanges.
+func (s *SyncService) PullPaymentChanges(ctx context.Context, since time.Time, until time.Time) (PaymentChanges, error) {
+	return s.provider.ListPaymentChanges(ctx, since, until)
+}
+
+// InvoiceChangesAfter lists the invoices changed after cursor, a position
+// in the provider's change feed, and returns the cursor to pass next
+// time. An empty cursor starts the feed: it lists the invoices dated on
+// or after since and returns the feed's current position, so nothing
+// changed meanwhile is missed. Unlike a time window, the feed catches
+// back-dated edits and confirmations.
+//
+// Excellent Books has a feed, numbered by each register's @sequence; for
+// the other providers the methods return an error wrapping
+// ErrNotSupported, and PullInvoiceChanges is the way to sync them. The
+// SyncEngine picks whichever applies and keeps the cursor for you.
+func (s *SyncService) InvoiceChangesAfter(ctx context.Context, cursor string, since time.Time) (InvoiceChanges, string, error) {
+	return s.provider.InvoiceChangesAfter(ctx, cursor, since)
+}
+
+// PaymentChangesAfter is the payments counterpart of InvoiceChangesAfter.
+func (s *SyncService) PaymentChangesAfter(ctx context.Context, cursor string, since time.Time) (PaymentChanges, string, error) {
+	return s.provider.PaymentChangesAfter(ctx, cursor, since)
+}
+
+// CustomerChangesAfter is the customers counterpart of
+// InvoiceChangesAfter; since applies to the customers' changed date.
+func (s *SyncService) CustomerChangesAfter(ctx context.Context, cursor string, since time.Time) (CustomerChanges, string, error) {
+	return s.provider.CustomerChangesAfter(ctx, cursor, since)
+}
+
+// ItemChangesAfter is the items counterpart of InvoiceChangesAfter.
+// Items carry no date, so an empty cursor lists them all.
+func (s *SyncService) ItemChangesAfter(ctx context.Context, cursor string, since time.Time) (ItemChanges, string, error) {
+	return s.provider.ItemChangesAfter(ctx, cursor, since)
+}
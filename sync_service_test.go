@@ -0,0 +1,124 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/directo/directotest"
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/qbitsoftware/accounting-service/smartaccounts/satest"
+)
+
+func TestPullInvoiceChanges_SmartAccounts(t *testing.T) {
+	ctx := context.Background()
+	srv := satest.NewServer()
+	defer srv.Close()
+	sc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider:   "smartaccounts",
+		APIID:      sc.APIKey,
+		APIKey:     sc.SecretKey,
+		Region:     sc.Host,
+		HTTPClient: sc.HTTPClient,
+		Resilience: &resilience.Policy{RatePerSecond: 1000},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Name: "Acme OÜ", RegNo: "12345678"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	kept, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "S-1", "KM22"))
+	if err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+	gone, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, "S-2", "KM22"))
+	if err != nil {
+		t.Fatalf("Invoices.Create: %v", err)
+	}
+	if err := client.Invoices.Delete(ctx, gone.ID); err != nil {
+		t.Fatalf("Invoices.Delete: %v", err)
+	}
+
+	now := time.Now()
+	changes, err := client.Sync.PullInvoiceChanges(ctx, now.AddDate(0, 0, -1), now)
+	if err != nil {
+		t.Fatalf("PullInvoiceChanges: %v", err)
+	}
+	if len(changes.Invoices) != 1 || changes.Invoices[0].ID != kept.ID {
+		t.Errorf("changed invoices = %+v, want only %s", changes.Invoices, kept.ID)
+	}
+	if len(changes.Deleted) != 1 || changes.Deleted[0] != (Tombstone{Entity: SyncInvoices, ID: gone.ID}) {
+		t.Errorf("tombstones = %+v, want %s", changes.Deleted, gone.ID)
+	}
+	if !client.Capabilities().SupportsDeletionSync {
+		t.Error("SmartAccounts capabilities: want SupportsDeletionSync")
+	}
+	if _, _, err := client.Sync.InvoiceChangesAfter(ctx, "", now); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("InvoiceChangesAfter: err = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestPullInvoiceChanges_Directo(t *testing.T) {
+	ctx := context.Background()
+	srv := directotest.NewServer()
+	defer srv.Close()
+	clock := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv.Now = func() time.Time { return clock }
+	dc := srv.Config()
+	client := newEmulatorClient(t, Config{
+		Provider: "directo",
+		APIID:    dc.Company,
+		APIKey:   dc.Token,
+		Directo: &DirectoOptions{
+			RESTAPIKey:  dc.RestAPIKey,
+			RESTBaseURL: dc.RESTBaseURL,
+			XMLBaseURL:  dc.XMLBaseURL,
+		},
+	})
+
+	cust, err := client.Customers.Create(ctx, CreateCustomerInput{Code: "C1", Name: "Acme OÜ", Email: "acme@example.com"})
+	if err != nil {
+		t.Fatalf("Customers.Create: %v", err)
+	}
+	for _, no := range []string{"D-1", "D-2"} {
+		if _, err := client.Invoices.Create(ctx, e2eInvoice(cust.ID, cust.Name, no, "1")); err != nil {
+			t.Fatalf("Invoices.Create(%s): %v", no, err)
+		}
+	}
+	clock = clock.Add(time.Hour)
+	if err := client.Invoices.Delete(ctx, "D-1"); err != nil {
+		t.Fatalf("Invoices.Delete: %v", err)
+	}
+	clock = clock.Add(time.Hour)
+	if err := client.Invoices.Delete(ctx, "D-2"); err != nil {
+		t.Fatalf("Invoices.Delete: %v", err)
+	}
+
+	// The window ends before D-2 was deleted.
+	since, until := clock.Add(-3*time.Hour), clock.Add(-30*time.Minute)
+	changes, err := client.Sync.PullInvoiceChanges(ctx, since, until)
+	if err != nil {
+		t.Fatalf("PullInvoiceChanges: %v", err)
+	}
+	want := Tombstone{Entity: SyncInvoices, ID: "D-1", DeletedAt: clock.Add(-time.Hour)}
+	if len(changes.Deleted) != 1 || changes.Deleted[0].ID != want.ID || !changes.Deleted[0].DeletedAt.Equal(want.DeletedAt) {
+		t.Errorf("tombstones = %+v, want %+v", changes.Deleted, want)
+	}
+	payments, err := client.Sync.PullPaymentChanges(ctx, since, clock)
+	if err != nil {
+		t.Fatalf("PullPaymentChanges: %v", err)
+	}
+	if len(payments.Deleted) != 0 {
+		t.Errorf("payment tombstones = %+v, want none", payments.Deleted)
+	}
+}
+
+func TestCapabilities_DeletionSync(t *testing.T) {
+	for name, client := range conformanceClients(t) {
+		if got, want := client.Capabilities().SupportsDeletionSync, name == "smartaccounts" || name == "directo"; got != want {
+			t.Errorf("%s: SupportsDeletionSync = %v, want %v", name, got, want)
+		}
+	}
+}
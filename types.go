@@ -37,8 +37,11 @@ type Invoice struct {
 	Paid         bool
 	Status       InvoiceStatus
 	ReferenceNo  string
-	Lines        []InvoiceLine
-	Payments     []InvoicePayment
+	// ChangedAt is when the invoice was last created or changed, where
+	// the provider reports it (Merit, Directo); zero otherwise.
+	ChangedAt time.Time
+	Lines     []InvoiceLine
+	Payments  []InvoicePayment
 }
 
 type InvoiceLine struct {
@@ -97,6 +100,10 @@ type Payment struct {
 	CounterPartID   string
 	CounterPartName string
 	InvoiceLinks    []PaymentInvoiceLink
+	// Reference is the caller's PaymentNo where the provider keeps it in a
+	// text field: the receipt comment for Excellent Books, the document
+	// field for SmartAccounts.
+	Reference string
 
 	// ExternalPayMode is the provider-specific payment-method identifier:
 	// for Excellent Books this is the PayMode code (e.g. "P1", "K"); for
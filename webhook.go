@@ -0,0 +1,142 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/resilience"
+)
+
+// Headers set on every webhook request.
+const (
+	WebhookSignatureHeader = "X-Accounting-Signature"
+	WebhookEventHeader     = "X-Accounting-Event"
+	WebhookEventIDHeader   = "X-Accounting-Event-Id"
+)
+
+// WebhookConfig configures a WebhookNotifier.
+type WebhookConfig struct {
+	// URL receives each event as a JSON POST. Required.
+	URL string
+	// Secret signs the requests. Required.
+	Secret string
+	// HTTPClient sends the requests; nil uses http.DefaultClient.
+	HTTPClient *http.Client
+	// Retry is the retry, timeout and circuit-breaker policy; nil uses the
+	// resilience defaults. Events carry a stable ID, so a POST is retried
+	// after 5xx responses and network errors as well as 429s.
+	Retry *resilience.Policy
+}
+
+// WebhookNotifier is a Notifier that POSTs each event as JSON. The
+// request is signed in the WebhookSignatureHeader as
+//
+//	t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed by the secret>
+//
+// which receivers check with VerifyWebhookSignature. Any 2xx response
+// acknowledges the event.
+type WebhookNotifier struct {
+	url    string
+	secret []byte
+	exec   *resilience.Executor
+	now    func() time.Time
+}
+
+// NewWebhookNotifier returns a WebhookNotifier for cfg.
+func NewWebhookNotifier(cfg WebhookConfig) (*WebhookNotifier, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("accounting: WebhookConfig.URL is required")
+	}
+	if cfg.Secret == "" {
+		return nil, errors.New("accounting: WebhookConfig.Secret is required")
+	}
+	var policy resilience.Policy
+	if cfg.Retry != nil {
+		policy = *cfg.Retry
+	}
+	return &WebhookNotifier{
+		url:    cfg.URL,
+		secret: []byte(cfg.Secret),
+		exec:   resilience.New("webhook", policy, cfg.HTTPClient),
+		now:    time.Now,
+	}, nil
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("accounting: webhook: %w", err)
+	}
+	resp, err := w.exec.Do(ctx, string(e.Type), true, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WebhookEventHeader, string(e.Type))
+		req.Header.Set(WebhookEventIDHeader, e.ID)
+		req.Header.Set(WebhookSignatureHeader, signWebhook(w.secret, w.now(), body))
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("accounting: webhook: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("accounting: webhook: %s answered %d", w.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func signWebhook(secret []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + webhookMAC(secret, ts, body)
+}
+
+func webhookMAC(secret []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrWebhookSignature is returned by VerifyWebhookSignature for a request
+// that was not signed with the secret, or was signed too long ago.
+var ErrWebhookSignature = errors.New("accounting: invalid webhook signature")
+
+// VerifyWebhookSignature checks the WebhookSignatureHeader value of a
+// request against its body. A signature older than maxAge is rejected, so
+// a captured request cannot be replayed later; zero maxAge skips the
+// check.
+func VerifyWebhookSignature(secret, header string, body []byte, maxAge time.Duration) error {
+	var ts, sig string
+	for part := range strings.SplitSeq(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrWebhookSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(webhookMAC([]byte(secret), ts, body))) {
+		return ErrWebhookSignature
+	}
+	if maxAge > 0 && time.Since(time.Unix(sec, 0)) > maxAge {
+		return ErrWebhookSignature
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qbitsoftware/accounting-service/resilience"
+	"github.com/shopspring/decimal"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	const secret = "s3cret"
+	var calls atomic.Int32
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifyWebhookSignature(secret, r.Header.Get(WebhookSignatureHeader), body, time.Minute); err != nil {
+			t.Errorf("signature: %v", err)
+		}
+		if err := VerifyWebhookSignature("other", r.Header.Get(WebhookSignatureHeader), body, 0); !errors.Is(err, ErrWebhookSignature) {
+			t.Errorf("signature with the wrong secret: err = %v, want ErrWebhookSignature", err)
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := NewWebhookNotifier(WebhookConfig{URL: srv.URL, Secret: secret, Retry: &resilience.Policy{BaseDelay: time.Millisecond}})
+	if err != nil {
+		t.Fatalf("NewWebhookNotifier: %v", err)
+	}
+	e := Event{ID: "club-1/payment.added/pay-1", Type: EventPaymentAdded, Tenant: "club-1", RecordID: "pay-1", Amount: decimal.NewFromInt(40)}
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("requests = %d, want a retry after the 502", calls.Load())
+	}
+	if got.ID != e.ID || got.Type != e.Type || !got.Amount.Equal(e.Amount) {
+		t.Errorf("delivered %+v, want %+v", got, e)
+	}
+}
+
+func TestWebhookNotifier_Rejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	n, err := NewWebhookNotifier(WebhookConfig{URL: srv.URL, Secret: "s"})
+	if err != nil {
+		t.Fatalf("NewWebhookNotifier: %v", err)
+	}
+	if err := n.Notify(context.Background(), Event{Type: EventInvoicePaid}); err == nil {
+		t.Error("Notify answered 400: want error")
+	}
+	if _, err := NewWebhookNotifier(WebhookConfig{URL: srv.URL}); err == nil {
+		t.Error("NewWebhookNotifier without a secret: want error")
+	}
+}
+
+func TestVerifyWebhookSignature_Expired(t *testing.T) {
+	body := []byte(`{}`)
+	header := signWebhook([]byte("s"), time.Now().Add(-time.Hour), body)
+	if err := VerifyWebhookSignature("s", header, body, time.Minute); !errors.Is(err, ErrWebhookSignature) {
+		t.Errorf("hour-old signature: err = %v, want ErrWebhookSignature", err)
+	}
+	if err := VerifyWebhookSignature("s", header, body, 0); err != nil {
+		t.Errorf("hour-old signature without maxAge: %v", err)
+	}
+}